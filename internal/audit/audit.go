@@ -20,11 +20,16 @@ const (
 	EventHookFailed    auditkit.EventType = "hook_failed"
 	EventHookTimeout   auditkit.EventType = "hook_timeout"
 	EventHookCancelled auditkit.EventType = "hook_cancelled"
+	EventHookAttempt   auditkit.EventType = "hook_attempt"
+	EventHookKilled    auditkit.EventType = "hook_killed"
 
 	// Signature verification events
 	EventSignatureValid   auditkit.EventType = "signature_valid"
 	EventSignatureInvalid auditkit.EventType = "signature_invalid"
 
+	// Replay protection events
+	EventReplayRejected auditkit.EventType = "replay_rejected"
+
 	// Access events
 	EventHookNotFound      auditkit.EventType = "hook_not_found"
 	EventMethodNotAllowed  auditkit.EventType = "method_not_allowed"
@@ -190,6 +195,41 @@ func LogHookCancelled(requestID, hookID, ip, userAgent string, durationMS int64)
 	Log(record)
 }
 
+// LogHookAttempt logs a single attempt of a queued asynchronous hook execution
+func LogHookAttempt(requestID, hookID, jobID, ip, userAgent string, attempt, maxAttempts int, reason string, durationMS int64) {
+	result := auditkit.ResultSuccess
+	if reason != "" {
+		result = auditkit.ResultFailure
+	}
+	record := auditkit.NewRecord(EventHookAttempt, result).
+		WithRequestID(requestID).
+		WithResource(hookID).
+		WithIP(ip).
+		WithUserAgent(userAgent).
+		WithDuration(durationMS).
+		WithMetadata("job_id", jobID).
+		WithMetadata("attempt", attempt).
+		WithMetadata("max_attempts", maxAttempts)
+	if reason != "" {
+		record = record.WithReason(reason)
+	}
+	Log(record)
+}
+
+// LogHookKilled logs the termination of a hook command, recording why it was
+// terminated (timeout, cancelled, superseded or shutdown) and the last signal sent.
+func LogHookKilled(requestID, hookID, ip, userAgent, reason, signal string, durationMS int64) {
+	record := auditkit.NewRecord(EventHookKilled, auditkit.ResultFailure).
+		WithRequestID(requestID).
+		WithResource(hookID).
+		WithIP(ip).
+		WithUserAgent(userAgent).
+		WithDuration(durationMS).
+		WithReason(reason).
+		WithMetadata("signal", signal)
+	Log(record)
+}
+
 // LogHookTriggered logs when a hook is triggered (before execution)
 func LogHookTriggered(requestID, hookID, ip, userAgent, method string) {
 	record := auditkit.NewRecord(EventHookTriggered, auditkit.ResultSuccess).
@@ -224,14 +264,18 @@ func LogMethodNotAllowed(requestID, hookID, ip, userAgent, method string) {
 	Log(record)
 }
 
-// LogRulesNotSatisfied logs when trigger rules are not satisfied
-func LogRulesNotSatisfied(requestID, hookID, ip, userAgent string) {
+// LogRulesNotSatisfied logs when trigger rules are not satisfied. An empty
+// reason is recorded as rules_not_satisfied.
+func LogRulesNotSatisfied(requestID, hookID, ip, userAgent, reason string) {
+	if reason == "" {
+		reason = "rules_not_satisfied"
+	}
 	record := auditkit.NewRecord(EventRulesNotSatisfied, auditkit.ResultFailure).
 		WithRequestID(requestID).
 		WithResource(hookID).
 		WithIP(ip).
 		WithUserAgent(userAgent).
-		WithReason("rules_not_satisfied")
+		WithReason(reason)
 	Log(record)
 }
 
@@ -256,6 +300,19 @@ func LogSignatureInvalid(requestID, hookID, ip, algorithm, reason string) {
 	Log(record)
 }
 
+// LogReplayRejected logs a request rejected by a replay-guard rule
+func LogReplayRejected(requestID, hookID, ip, reason, nonce string) {
+	record := auditkit.NewRecord(EventReplayRejected, auditkit.ResultFailure).
+		WithRequestID(requestID).
+		WithResource(hookID).
+		WithIP(ip).
+		WithReason(reason)
+	if nonce != "" {
+		record = record.WithMetadata("nonce", nonce)
+	}
+	Log(record)
+}
+
 // LogRateLimited logs when a request is rate limited
 func LogRateLimited(requestID, hookID, ip, userAgent string) {
 	record := auditkit.NewRecord(auditkit.EventRateLimited, auditkit.ResultFailure).
@@ -313,6 +313,7 @@ func TestLogSignatureEvents(t *testing.T) {
 
 	LogSignatureValid("req-sig-1", "test-hook", "192.168.1.1", "sha256")
 	LogSignatureInvalid("req-sig-2", "test-hook", "192.168.1.1", "sha256", "invalid_signature")
+	LogReplayRejected("req-sig-3", "test-hook", "192.168.1.1", "duplicate_nonce", "delivery-1")
 
 	time.Sleep(100 * time.Millisecond)
 }
@@ -397,7 +398,8 @@ func TestLogRulesNotSatisfied(t *testing.T) {
 		}
 	}()
 
-	LogRulesNotSatisfied("req-rules", "test-hook", "192.168.1.1", "test-agent")
+	LogRulesNotSatisfied("req-rules", "test-hook", "192.168.1.1", "test-agent", "")
+	LogRulesNotSatisfied("req-rules-2", "test-hook", "192.168.1.1", "test-agent", "outside_time_window")
 
 	time.Sleep(100 * time.Millisecond)
 }
@@ -457,3 +459,61 @@ func TestLogHookCancelled(t *testing.T) {
 
 	time.Sleep(100 * time.Millisecond)
 }
+
+func TestLogHookAttempt(t *testing.T) {
+	tmpFile := t.TempDir() + "/test_audit.log"
+
+	appFlags := flags.AppFlags{
+		AuditEnabled:     true,
+		AuditStorageType: "file",
+		AuditFilePath:    tmpFile,
+		AuditQueueSize:   100,
+		AuditWorkers:     1,
+	}
+
+	manager, err := NewManager(appFlags)
+	assert.NoError(t, err)
+
+	oldManager := globalManager
+	globalManager = manager
+	defer func() {
+		globalManager = oldManager
+		if manager.writer != nil {
+			_ = manager.writer.Stop()
+		}
+	}()
+
+	LogHookAttempt("req-attempt", "test-hook", "job-1", "192.168.1.1", "test-agent", 1, 3, "exit status 1", 150)
+	LogHookAttempt("req-attempt", "test-hook", "job-1", "192.168.1.1", "test-agent", 2, 3, "", 120)
+
+	time.Sleep(100 * time.Millisecond)
+}
+
+func TestLogHookKilled(t *testing.T) {
+	tmpFile := t.TempDir() + "/test_audit.log"
+
+	appFlags := flags.AppFlags{
+		AuditEnabled:     true,
+		AuditStorageType: "file",
+		AuditFilePath:    tmpFile,
+		AuditQueueSize:   100,
+		AuditWorkers:     1,
+	}
+
+	manager, err := NewManager(appFlags)
+	assert.NoError(t, err)
+
+	oldManager := globalManager
+	globalManager = manager
+	defer func() {
+		globalManager = oldManager
+		if manager.writer != nil {
+			_ = manager.writer.Stop()
+		}
+	}()
+
+	LogHookKilled("req-killed", "test-hook", "192.168.1.1", "test-agent", "timeout", "SIGTERM", 30000)
+	LogHookKilled("req-killed", "test-hook", "192.168.1.1", "test-agent", "shutdown", "SIGKILL", 35000)
+
+	time.Sleep(100 * time.Millisecond)
+}
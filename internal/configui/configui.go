@@ -208,7 +208,7 @@ func requestToHook(req *generateRequest) *hook.Hook {
 	}
 	if req.TriggerRuleJSON != "" {
 		var r hook.Rules
-		if err := json.Unmarshal([]byte(req.TriggerRuleJSON), &r); err == nil && (r.And != nil || r.Or != nil || r.Not != nil || r.Match != nil) {
+		if err := json.Unmarshal([]byte(req.TriggerRuleJSON), &r); err == nil && (r.And != nil || r.Or != nil || r.Not != nil || r.Match != nil || r.ReplayGuard != nil || r.Expr != nil) {
 			h.TriggerRule = &r
 		}
 	}
@@ -104,6 +104,12 @@ func TestRequestToHook(t *testing.T) {
 	if len(h.HTTPMethods) != 1 || h.HTTPMethods[0] != "POST" {
 		t.Errorf("HTTPMethods = %v", h.HTTPMethods)
 	}
+
+	req.TriggerRuleJSON = `{"expr":"payload.ref == \"refs/heads/main\""}`
+	h = requestToHook(req)
+	if h.TriggerRule == nil || h.TriggerRule.Expr == nil {
+		t.Errorf("TriggerRule = %+v", h.TriggerRule)
+	}
 }
 
 func TestWriteJSONError(t *testing.T) {
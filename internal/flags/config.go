@@ -44,6 +44,8 @@ func ParseConfig() AppFlags {
 	fs.Int("hook-timeout-seconds", DEFAULT_HOOK_TIMEOUT_SECONDS, "default timeout in seconds for hook execution (default 30)")
 	fs.Int("max-concurrent-hooks", DEFAULT_MAX_CONCURRENT_HOOKS, "maximum number of concurrent hook executions (default 10)")
 	fs.Int("hook-execution-timeout", DEFAULT_HOOK_EXECUTION_TIMEOUT, "timeout in seconds for acquiring execution slot when max concurrent hooks reached (default 5)")
+	fs.String("job-queue-path", DEFAULT_JOB_QUEUE_PATH, "file used to persist queued asynchronous hook executions so they survive a restart; empty keeps the queue in memory")
+	fs.Int("job-retention-seconds", DEFAULT_JOB_RETENTION_SECONDS, "how long in seconds finished asynchronous jobs stay available from the /jobs endpoint (default 3600)")
 	fs.Bool("allow-auto-chmod", DEFAULT_ALLOW_AUTO_CHMOD, "allow automatically modifying file permissions when permission denied (SECURITY RISK: default false)")
 
 	// Security flags
@@ -52,6 +54,7 @@ func ParseConfig() AppFlags {
 	fs.Int("max-total-args-length", DEFAULT_MAX_TOTAL_ARGS_LENGTH, "maximum total length for all command arguments in bytes (default 10MB)")
 	fs.Int("max-args-count", DEFAULT_MAX_ARGS_COUNT, "maximum number of command arguments (default 1000)")
 	fs.Bool("strict-mode", DEFAULT_STRICT_MODE, "strict mode: reject arguments containing potentially dangerous characters (default false)")
+	fs.Int("nonce-store-size", DEFAULT_NONCE_STORE_SIZE, "maximum number of unexpired replay-guard nonces kept in memory when Redis is not enabled (default 100000)")
 
 	// Rate limiting flags
 	fs.Bool("rate-limit-enabled", DEFAULT_RATE_LIMIT_ENABLED, "enable rate limiting (default false)")
@@ -101,6 +104,15 @@ func ParseConfig() AppFlags {
 	// Hooks directory: scan for *.json, *.yaml; when empty, watch for new files (use with or without -hotreload)
 	fs.String("hooks-dir", DEFAULT_HOOKS_DIR, "directory to scan for hook config files (*.json, *.yaml); if empty, watch for new files")
 
+	// TLS flags
+	fs.String("cert", DEFAULT_TLS_CERT, "PEM certificate file to serve hooks over HTTPS; reloaded when the file changes")
+	fs.String("key", DEFAULT_TLS_KEY, "PEM private key file of the certificate set with -cert")
+	fs.String("client-ca", DEFAULT_TLS_CLIENT_CA, "PEM CA bundle used to verify client certificates (mutual TLS); reloaded when the file changes")
+	fs.String("client-auth", DEFAULT_TLS_CLIENT_AUTH, "client certificate policy when client-ca is set: require or verify-if-given (default require)")
+
+	// Rule explain flags
+	fs.String("explain-token", DEFAULT_EXPLAIN_TOKEN, "token authorizing trigger rule traces via the /explain dry-run endpoint and the X-Webhook-Explain request header; disabled when empty")
+
 	showVersion := fs.Bool("version", false, "display webhook version and quit")
 	validateConfig := fs.Bool("validate-config", false, "validate configuration and exit")
 
@@ -157,6 +169,8 @@ func ParseConfig() AppFlags {
 	flags.MaxConcurrentHooks = configutil.ResolveInt(fs, "max-concurrent-hooks", ENV_KEY_MAX_CONCURRENT_HOOKS, DEFAULT_MAX_CONCURRENT_HOOKS, false)
 	flags.HookExecutionTimeout = configutil.ResolveInt(fs, "hook-execution-timeout", ENV_KEY_HOOK_EXECUTION_TIMEOUT, DEFAULT_HOOK_EXECUTION_TIMEOUT, true)
 	flags.AllowAutoChmod = configutil.ResolveBool(fs, "allow-auto-chmod", ENV_KEY_ALLOW_AUTO_CHMOD, DEFAULT_ALLOW_AUTO_CHMOD)
+	flags.JobQueuePath = configutil.ResolveString(fs, "job-queue-path", ENV_KEY_JOB_QUEUE_PATH, DEFAULT_JOB_QUEUE_PATH, true)
+	flags.JobRetentionSeconds = configutil.ResolveInt(fs, "job-retention-seconds", ENV_KEY_JOB_RETENTION_SECONDS, DEFAULT_JOB_RETENTION_SECONDS, true)
 
 	// Security settings
 	flags.AllowedCommandPaths = configutil.ResolveString(fs, "allowed-command-paths", ENV_KEY_ALLOWED_COMMAND_PATHS, DEFAULT_ALLOWED_COMMAND_PATHS, true)
@@ -164,6 +178,7 @@ func ParseConfig() AppFlags {
 	flags.MaxTotalArgsLength = configutil.ResolveInt(fs, "max-total-args-length", ENV_KEY_MAX_TOTAL_ARGS_LENGTH, DEFAULT_MAX_TOTAL_ARGS_LENGTH, false)
 	flags.MaxArgsCount = configutil.ResolveInt(fs, "max-args-count", ENV_KEY_MAX_ARGS_COUNT, DEFAULT_MAX_ARGS_COUNT, false)
 	flags.StrictMode = configutil.ResolveBool(fs, "strict-mode", ENV_KEY_STRICT_MODE, DEFAULT_STRICT_MODE)
+	flags.NonceStoreSize = configutil.ResolveInt(fs, "nonce-store-size", ENV_KEY_NONCE_STORE_SIZE, DEFAULT_NONCE_STORE_SIZE, false)
 
 	// Rate limiting settings
 	flags.RateLimitEnabled = configutil.ResolveBool(fs, "rate-limit-enabled", ENV_KEY_RATE_LIMIT_ENABLED, DEFAULT_RATE_LIMIT_ENABLED)
@@ -217,6 +232,15 @@ func ParseConfig() AppFlags {
 		flags.HooksDir = filepath.Clean(flags.HooksDir)
 	}
 
+	// TLS settings
+	flags.TLSCertFile = configutil.ResolveString(fs, "cert", ENV_KEY_TLS_CERT, DEFAULT_TLS_CERT, true)
+	flags.TLSKeyFile = configutil.ResolveString(fs, "key", ENV_KEY_TLS_KEY, DEFAULT_TLS_KEY, true)
+	flags.TLSClientCAFile = configutil.ResolveString(fs, "client-ca", ENV_KEY_TLS_CLIENT_CA, DEFAULT_TLS_CLIENT_CA, true)
+	flags.TLSClientAuth = configutil.ResolveString(fs, "client-auth", ENV_KEY_TLS_CLIENT_AUTH, DEFAULT_TLS_CLIENT_AUTH, true)
+
+	// Rule explain settings
+	flags.ExplainToken = configutil.ResolveString(fs, "explain-token", ENV_KEY_EXPLAIN_TOKEN, DEFAULT_EXPLAIN_TOKEN, true)
+
 	// Special flags
 	flags.ShowVersion = *showVersion
 	flags.ValidateConfig = *validateConfig
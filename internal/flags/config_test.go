@@ -136,12 +136,15 @@ func TestParseConfig_AllFlagsComprehensive(t *testing.T) {
 		"-hook-timeout-seconds", "60",
 		"-max-concurrent-hooks", "20",
 		"-hook-execution-timeout", "10",
+		"-job-queue-path", "/tmp/jobs.log",
+		"-job-retention-seconds", "600",
 		"-allow-auto-chmod",
 		"-allowed-command-paths", "/usr/bin,/bin",
 		"-max-arg-length", "2048",
 		"-max-total-args-length", "5242880",
 		"-max-args-count", "2000",
 		"-strict-mode",
+		"-nonce-store-size", "5000",
 		"-rate-limit-enabled",
 		"-rate-limit-rps", "200",
 		"-rate-limit-burst", "20",
@@ -151,6 +154,11 @@ func TestParseConfig_AllFlagsComprehensive(t *testing.T) {
 		"-write-timeout-seconds", "60",
 		"-idle-timeout-seconds", "180",
 		"-max-header-bytes", "2097152",
+		"-cert", "/tmp/server.pem",
+		"-key", "/tmp/server-key.pem",
+		"-client-ca", "/tmp/ca.pem",
+		"-client-auth", "verify-if-given",
+		"-explain-token", "explain-secret",
 	}
 	result := ParseConfig()
 
@@ -176,12 +184,15 @@ func TestParseConfig_AllFlagsComprehensive(t *testing.T) {
 	assert.Equal(t, 60, result.HookTimeoutSeconds)
 	assert.Equal(t, 20, result.MaxConcurrentHooks)
 	assert.Equal(t, 10, result.HookExecutionTimeout)
+	assert.Equal(t, "/tmp/jobs.log", result.JobQueuePath)
+	assert.Equal(t, 600, result.JobRetentionSeconds)
 	assert.True(t, result.AllowAutoChmod)
 	assert.Equal(t, "/usr/bin,/bin", result.AllowedCommandPaths)
 	assert.Equal(t, 2048, result.MaxArgLength)
 	assert.Equal(t, 5242880, result.MaxTotalArgsLength)
 	assert.Equal(t, 2000, result.MaxArgsCount)
 	assert.True(t, result.StrictMode)
+	assert.Equal(t, 5000, result.NonceStoreSize)
 	assert.True(t, result.RateLimitEnabled)
 	assert.Equal(t, 200, result.RateLimitRPS)
 	assert.Equal(t, 20, result.RateLimitBurst)
@@ -191,6 +202,11 @@ func TestParseConfig_AllFlagsComprehensive(t *testing.T) {
 	assert.Equal(t, 60, result.WriteTimeoutSeconds)
 	assert.Equal(t, 180, result.IdleTimeoutSeconds)
 	assert.Equal(t, 2097152, result.MaxHeaderBytes)
+	assert.Equal(t, "/tmp/server.pem", result.TLSCertFile)
+	assert.Equal(t, "/tmp/server-key.pem", result.TLSKeyFile)
+	assert.Equal(t, "/tmp/ca.pem", result.TLSClientCAFile)
+	assert.Equal(t, "verify-if-given", result.TLSClientAuth)
+	assert.Equal(t, "explain-secret", result.ExplainToken)
 }
 
 func TestParseConfig_HooksFilesLocking(t *testing.T) {
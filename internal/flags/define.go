@@ -30,6 +30,8 @@ const (
 	DEFAULT_HOOK_TIMEOUT_SECONDS   = 30
 	DEFAULT_MAX_CONCURRENT_HOOKS   = 10
 	DEFAULT_HOOK_EXECUTION_TIMEOUT = 5
+	DEFAULT_JOB_QUEUE_PATH         = ""
+	DEFAULT_JOB_RETENTION_SECONDS  = 3600
 
 	DEFAULT_ALLOW_AUTO_CHMOD = false
 
@@ -39,6 +41,7 @@ const (
 	DEFAULT_MAX_TOTAL_ARGS_LENGTH = 10 * 1024 * 1024 // 10MB
 	DEFAULT_MAX_ARGS_COUNT        = 1000
 	DEFAULT_STRICT_MODE           = false
+	DEFAULT_NONCE_STORE_SIZE      = hook.DefaultNonceStoreSize
 
 	// Rate limiting defaults
 	DEFAULT_RATE_LIMIT_ENABLED = false
@@ -87,6 +90,21 @@ const (
 
 	// Hooks directory: default scan dir for hook configs
 	DEFAULT_HOOKS_DIR = "./hooks"
+
+	// TLS defaults
+	DEFAULT_TLS_CERT        = ""
+	DEFAULT_TLS_KEY         = ""
+	DEFAULT_TLS_CLIENT_CA   = ""
+	DEFAULT_TLS_CLIENT_AUTH = TLS_CLIENT_AUTH_REQUIRE
+
+	// Rule explain defaults: empty token disables the explain endpoint and header
+	DEFAULT_EXPLAIN_TOKEN = ""
+)
+
+// Client certificate policies of the TLS listener when a client CA is set
+const (
+	TLS_CLIENT_AUTH_REQUIRE         = "require"
+	TLS_CLIENT_AUTH_VERIFY_IF_GIVEN = "verify-if-given"
 )
 
 const (
@@ -117,6 +135,8 @@ const (
 	ENV_KEY_HOOK_TIMEOUT_SECONDS   = "HOOK_TIMEOUT_SECONDS"
 	ENV_KEY_MAX_CONCURRENT_HOOKS   = "MAX_CONCURRENT_HOOKS"
 	ENV_KEY_HOOK_EXECUTION_TIMEOUT = "HOOK_EXECUTION_TIMEOUT"
+	ENV_KEY_JOB_QUEUE_PATH         = "JOB_QUEUE_PATH"
+	ENV_KEY_JOB_RETENTION_SECONDS  = "JOB_RETENTION_SECONDS"
 	ENV_KEY_ALLOW_AUTO_CHMOD       = "ALLOW_AUTO_CHMOD"
 
 	// Security environment keys
@@ -125,6 +145,7 @@ const (
 	ENV_KEY_MAX_TOTAL_ARGS_LENGTH = "MAX_TOTAL_ARGS_LENGTH"
 	ENV_KEY_MAX_ARGS_COUNT        = "MAX_ARGS_COUNT"
 	ENV_KEY_STRICT_MODE           = "STRICT_MODE"
+	ENV_KEY_NONCE_STORE_SIZE      = "NONCE_STORE_SIZE"
 
 	// Rate limiting environment keys
 	ENV_KEY_RATE_LIMIT_ENABLED = "RATE_LIMIT_ENABLED"
@@ -173,6 +194,15 @@ const (
 
 	// Hooks directory
 	ENV_KEY_HOOKS_DIR = "HOOKS_DIR"
+
+	// TLS environment keys
+	ENV_KEY_TLS_CERT        = "TLS_CERT"
+	ENV_KEY_TLS_KEY         = "TLS_KEY"
+	ENV_KEY_TLS_CLIENT_CA   = "TLS_CLIENT_CA"
+	ENV_KEY_TLS_CLIENT_AUTH = "TLS_CLIENT_AUTH"
+
+	// Rule explain environment keys
+	ENV_KEY_EXPLAIN_TOKEN = "EXPLAIN_TOKEN"
 )
 
 type AppFlags struct {
@@ -206,6 +236,8 @@ type AppFlags struct {
 	MaxConcurrentHooks   int
 	HookExecutionTimeout int
 	AllowAutoChmod       bool
+	JobQueuePath         string // 异步任务队列日志文件路径，为空时仅保存在内存中
+	JobRetentionSeconds  int    // 已结束任务在 /jobs 接口中的保留时间（秒）
 
 	// Security settings
 	AllowedCommandPaths string // 逗号分隔的允许的命令路径列表
@@ -213,6 +245,7 @@ type AppFlags struct {
 	MaxTotalArgsLength  int    // 所有参数总长度限制
 	MaxArgsCount        int    // 最大参数数量
 	StrictMode          bool   // 严格模式：禁止危险字符
+	NonceStoreSize      int    // 内存中最多保存的 replay-guard nonce 数量
 
 	// Rate limiting settings
 	RateLimitEnabled bool // 是否启用限流
@@ -261,4 +294,13 @@ type AppFlags struct {
 
 	// Hooks directory: when set, scan for hook config files (*.json, *.yaml); if empty, watch for new files
 	HooksDir string
+
+	// TLS settings
+	TLSCertFile     string // 服务端证书文件（PEM），设置后以 HTTPS 提供服务
+	TLSKeyFile      string // 服务端私钥文件（PEM）
+	TLSClientCAFile string // 用于校验客户端证书的 CA 文件（PEM），设置后启用 mTLS
+	TLSClientAuth   string // 客户端证书策略：require 或 verify-if-given
+
+	// Rule explain settings
+	ExplainToken string // 访问触发规则评估轨迹（explain 接口与请求头）所需的令牌，为空时禁用
 }
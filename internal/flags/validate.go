@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"github.com/soulteary/cli-kit/validator"
 	"github.com/soulteary/webhook/internal/hook"
@@ -55,11 +56,19 @@ func Validate(flags AppFlags) *ValidationResult {
 		validateFilePath(result, "pid-path", flags.PidPath, true, false)
 	}
 
+	// 验证异步任务队列文件路径
+	if flags.JobQueuePath != "" {
+		validateFilePath(result, "job-queue-path", flags.JobQueuePath, true, false)
+	}
+
 	// 验证 I18n 目录
 	if flags.I18nDir != "" {
 		validateDirectory(result, "i18n-dir", flags.I18nDir, false)
 	}
 
+	// 验证 TLS 证书配置
+	validateTLS(result, flags)
+
 	// 验证 Hook 文件
 	validateHookFiles(result, flags)
 
@@ -104,6 +113,9 @@ func Validate(flags AppFlags) *ValidationResult {
 	if err := validator.ValidateNonNegative(flags.HookExecutionTimeout); err != nil {
 		result.AddError("hook-execution-timeout", i18n.Sprintf(i18n.ERR_VALIDATE_INVALID_TIMEOUT, "hook-execution-timeout"))
 	}
+	if err := validator.ValidateNonNegative(flags.JobRetentionSeconds); err != nil {
+		result.AddError("job-retention-seconds", i18n.Sprintf(i18n.ERR_VALIDATE_INVALID_TIMEOUT, "job-retention-seconds"))
+	}
 
 	// 验证安全配置 - 使用 cli-kit/validator
 	if err := validator.ValidatePositive(flags.MaxArgLength); err != nil {
@@ -115,6 +127,9 @@ func Validate(flags AppFlags) *ValidationResult {
 	if err := validator.ValidatePositive(flags.MaxArgsCount); err != nil {
 		result.AddError("max-args-count", i18n.Sprintf(i18n.ERR_VALIDATE_INVALID_POSITIVE_INT, "max-args-count"))
 	}
+	if err := validator.ValidatePositive(flags.NonceStoreSize); err != nil {
+		result.AddError("nonce-store-size", i18n.Sprintf(i18n.ERR_VALIDATE_INVALID_POSITIVE_INT, "nonce-store-size"))
+	}
 
 	// 验证大小限制 - 使用 cli-kit/validator
 	if err := validator.ValidatePositiveInt64(flags.MaxMultipartMem); err != nil {
@@ -156,6 +171,30 @@ func validateFilePath(result *ValidationResult, field, path string, checkWritabl
 	}
 }
 
+// validateTLS 验证 TLS 证书、私钥与客户端 CA 配置
+func validateTLS(result *ValidationResult, flags AppFlags) {
+	if (flags.TLSCertFile == "") != (flags.TLSKeyFile == "") {
+		result.AddError("tls", i18n.Sprintf(i18n.ERR_VALIDATE_TLS_CERT_KEY_PAIR))
+		return
+	}
+	if flags.TLSCertFile != "" {
+		validateFilePath(result, "cert", flags.TLSCertFile, false, true)
+		validateFilePath(result, "key", flags.TLSKeyFile, false, true)
+	}
+	if flags.TLSClientCAFile != "" {
+		if flags.TLSCertFile == "" {
+			result.AddError("client-ca", i18n.Sprintf(i18n.ERR_VALIDATE_TLS_CLIENT_CA_WITHOUT_CERT))
+		} else {
+			validateFilePath(result, "client-ca", flags.TLSClientCAFile, false, true)
+		}
+	}
+	switch flags.TLSClientAuth {
+	case "", TLS_CLIENT_AUTH_REQUIRE, TLS_CLIENT_AUTH_VERIFY_IF_GIVEN:
+	default:
+		result.AddError("client-auth", i18n.Sprintf(i18n.ERR_VALIDATE_INVALID_TLS_CLIENT_AUTH, flags.TLSClientAuth))
+	}
+}
+
 // validateDirectory 验证目录路径
 func validateDirectory(result *ValidationResult, field, path string, mustExist bool) {
 	cleanPath := filepath.Clean(path)
@@ -208,6 +247,9 @@ func validateHookFiles(result *ValidationResult, flags AppFlags) {
 		}
 	}
 
+	// 收集所有文件中的 Hook，用于跨文件校验 Hook 之间的引用
+	var allHooks hook.Hooks
+
 	// 验证每个 Hook 文件
 	for _, hookFile := range uniqueFiles {
 		if hookFile == "" {
@@ -228,6 +270,30 @@ func validateHookFiles(result *ValidationResult, flags AppFlags) {
 
 		// 验证 Hook 内容
 		validateHookContent(result, hookFile, hooks)
+		allHooks = append(allHooks, hooks...)
+	}
+
+	validateHookReferences(result, allHooks)
+}
+
+// validateHookReferences 验证 on-success / on-failure / on-timeout 与 actions 引用的 Hook 存在且不构成循环
+func validateHookReferences(result *ValidationResult, hooks hook.Hooks) {
+	hookIDs := make(map[string]bool, len(hooks))
+	for _, h := range hooks {
+		hookIDs[h.ID] = true
+	}
+
+	for _, h := range hooks {
+		for _, ref := range h.References() {
+			if !hookIDs[ref] {
+				result.AddError(fmt.Sprintf("hooks[%s]", h.ID),
+					i18n.Sprintf(i18n.ERR_VALIDATE_HOOK_UNKNOWN_REF, h.ID, ref))
+			}
+		}
+	}
+
+	if cycle := hook.FindReferenceCycle(hooks); cycle != nil {
+		result.AddError("hooks", i18n.Sprintf(i18n.ERR_VALIDATE_HOOK_REF_CYCLE, strings.Join(cycle, " -> ")))
 	}
 }
 
@@ -250,6 +316,12 @@ func validateHookContent(result *ValidationResult, hookFile string, hooks hook.H
 		}
 		hookIDs[h.ID] = true
 
+		// 验证超时、并发与终止配置
+		if err := h.Validate(); err != nil {
+			result.AddError(fmt.Sprintf("hook-file[%s].hooks[%d]", hookFile, i),
+				i18n.Sprintf(i18n.ERR_VALIDATE_HOOK_INVALID_CONFIG, h.ID, err))
+		}
+
 		// 验证命令路径（如果指定了允许的命令路径）
 		// 注意：这里只做基本验证，实际执行时的安全检查在 security 模块中
 	}
@@ -1,6 +1,7 @@
 package flags
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -40,6 +41,7 @@ func createValidFlags() AppFlags {
 		MaxArgLength:       DEFAULT_MAX_ARG_LENGTH,
 		MaxTotalArgsLength: DEFAULT_MAX_TOTAL_ARGS_LENGTH,
 		MaxArgsCount:       DEFAULT_MAX_ARGS_COUNT,
+		NonceStoreSize:     DEFAULT_NONCE_STORE_SIZE,
 		MaxMultipartMem:    int64(DEFAULT_MAX_MPART_MEM),
 		MaxRequestBodySize: int64(DEFAULT_MAX_REQUEST_BODY_SIZE),
 		MaxHeaderBytes:     DEFAULT_MAX_HEADER_BYTES,
@@ -211,6 +213,90 @@ func TestValidate_PidPath(t *testing.T) {
 	assert.False(t, result.HasErrors())
 }
 
+func TestValidate_JobQueuePath(t *testing.T) {
+	tempDir := t.TempDir()
+
+	tests := []struct {
+		name         string
+		jobQueuePath string
+		hasError     bool
+	}{
+		{"empty job queue path", "", false},
+		{"valid job queue path", filepath.Join(tempDir, "jobs.log"), false},
+		{"non-existent dir", filepath.Join(tempDir, "nonexistent", "jobs.log"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := createValidFlags()
+			flags.JobQueuePath = tt.jobQueuePath
+			result := Validate(flags)
+			hasJobQueueError := false
+			for _, err := range result.Errors {
+				if validationErr, ok := err.(*ValidationError); ok && validationErr.Field == "job-queue-path" {
+					hasJobQueueError = true
+					break
+				}
+			}
+			assert.Equal(t, tt.hasError, hasJobQueueError)
+		})
+	}
+}
+
+func TestValidate_TLS(t *testing.T) {
+	tempDir := t.TempDir()
+	certFile := filepath.Join(tempDir, "server.pem")
+	keyFile := filepath.Join(tempDir, "server-key.pem")
+	caFile := filepath.Join(tempDir, "ca.pem")
+	for _, path := range []string{certFile, keyFile, caFile} {
+		require.NoError(t, os.WriteFile(path, []byte("test"), 0o600))
+	}
+
+	tests := []struct {
+		name       string
+		cert       string
+		key        string
+		clientCA   string
+		clientAuth string
+		errField   string
+	}{
+		{"tls disabled", "", "", "", "", ""},
+		{"cert and key", certFile, keyFile, "", "", ""},
+		{"mutual tls", certFile, keyFile, caFile, TLS_CLIENT_AUTH_REQUIRE, ""},
+		{"verify if given", certFile, keyFile, caFile, TLS_CLIENT_AUTH_VERIFY_IF_GIVEN, ""},
+		{"cert without key", certFile, "", "", "", "tls"},
+		{"key without cert", "", keyFile, "", "", "tls"},
+		{"missing cert file", filepath.Join(tempDir, "missing.pem"), keyFile, "", "", "cert"},
+		{"client ca without cert", "", "", caFile, "", "client-ca"},
+		{"missing client ca file", certFile, keyFile, filepath.Join(tempDir, "missing-ca.pem"), "", "client-ca"},
+		{"invalid client auth", certFile, keyFile, caFile, "optional", "client-auth"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := createValidFlags()
+			flags.TLSCertFile = tt.cert
+			flags.TLSKeyFile = tt.key
+			flags.TLSClientCAFile = tt.clientCA
+			flags.TLSClientAuth = tt.clientAuth
+			result := Validate(flags)
+			fields := []string{}
+			for _, err := range result.Errors {
+				if validationErr, ok := err.(*ValidationError); ok {
+					fields = append(fields, validationErr.Field)
+				}
+			}
+			if tt.errField == "" {
+				for _, field := range []string{"tls", "cert", "key", "client-ca", "client-auth"} {
+					assert.NotContains(t, fields, field)
+				}
+			} else {
+				assert.Contains(t, fields, tt.errField)
+			}
+		})
+	}
+}
+
 func TestValidate_I18nDir(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -476,6 +562,13 @@ func TestValidate_HookExecution(t *testing.T) {
 			},
 			hasError: true,
 		},
+		{
+			name: "negative job retention",
+			flags: AppFlags{
+				JobRetentionSeconds: -1,
+			},
+			hasError: true,
+		},
 	}
 
 	// Create a temporary hooks file to avoid validation errors
@@ -514,6 +607,9 @@ func TestValidate_HookExecution(t *testing.T) {
 			if tt.flags.HookExecutionTimeout != 0 {
 				flags.HookExecutionTimeout = tt.flags.HookExecutionTimeout
 			}
+			if tt.flags.JobRetentionSeconds != 0 {
+				flags.JobRetentionSeconds = tt.flags.JobRetentionSeconds
+			}
 			result := Validate(flags)
 			if tt.hasError {
 				assert.True(t, result.HasErrors())
@@ -578,6 +674,13 @@ func TestValidate_Security(t *testing.T) {
 			},
 			hasError: true,
 		},
+		{
+			name: "invalid nonce store size",
+			flags: AppFlags{
+				NonceStoreSize: -1,
+			},
+			hasError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -595,6 +698,9 @@ func TestValidate_Security(t *testing.T) {
 			if tt.name == "invalid max args count" || tt.flags.MaxArgsCount != 0 {
 				flags.MaxArgsCount = tt.flags.MaxArgsCount
 			}
+			if tt.flags.NonceStoreSize != 0 {
+				flags.NonceStoreSize = tt.flags.NonceStoreSize
+			}
 			result := Validate(flags)
 			if tt.hasError {
 				assert.True(t, result.HasErrors())
@@ -766,6 +872,89 @@ func TestValidate_HookContent(t *testing.T) {
 
 	result = Validate(flags)
 	assert.True(t, result.HasErrors())
+
+	// Test with invalid concurrency settings
+	content3 := `[
+		{
+			"id": "backup",
+			"execute-command": "/bin/echo",
+			"max-concurrent": 1,
+			"concurrency-policy": "drop"
+		}
+	]`
+	err = os.WriteFile(hookFile, []byte(content3), 0644)
+	require.NoError(t, err)
+
+	// 无效的 hook 配置在加载文件时即被拒绝
+	result = Validate(flags)
+	require.True(t, result.HasErrors())
+	assert.Equal(t, fmt.Sprintf("hook-file[%s]", hookFile), result.Errors[0].(*ValidationError).Field)
+	assert.Contains(t, result.Errors[0].Error(), "hook backup")
+
+	// Test with valid concurrency settings
+	content4 := `[
+		{
+			"id": "backup",
+			"execute-command": "/bin/echo",
+			"timeout": "10m",
+			"max-concurrent": 1,
+			"concurrency-policy": "cancel-previous"
+		}
+	]`
+	err = os.WriteFile(hookFile, []byte(content4), 0644)
+	require.NoError(t, err)
+
+	result = Validate(flags)
+	assert.False(t, result.HasErrors())
+
+	// Test with an expression that does not compile
+	content5 := `[
+		{
+			"id": "deploy",
+			"execute-command": "/bin/echo",
+			"trigger-rule": {"expr": "payload.ref == "}
+		}
+	]`
+	err = os.WriteFile(hookFile, []byte(content5), 0644)
+	require.NoError(t, err)
+
+	result = Validate(flags)
+	require.True(t, result.HasErrors())
+	assert.Contains(t, result.Errors[0].Error(), "payload.ref ==")
+}
+
+func TestValidate_HookReferences(t *testing.T) {
+	tempDir := t.TempDir()
+	deployFile := filepath.Join(tempDir, "deploy.json")
+	notifyFile := filepath.Join(tempDir, "notify.json")
+
+	require.NoError(t, os.WriteFile(notifyFile, []byte(`[{"id": "notify", "execute-command": "/bin/echo"}]`), 0644))
+
+	validate := func(content string) *ValidationResult {
+		require.NoError(t, os.WriteFile(deployFile, []byte(content), 0644))
+
+		rules.LockHooksFiles()
+		rules.HooksFiles = []string{deployFile, notifyFile}
+		rules.UnlockHooksFiles()
+
+		flags := createValidFlags()
+		flags.HooksFiles = []string{deployFile, notifyFile}
+		return Validate(flags)
+	}
+
+	// References across hook files
+	result := validate(`[{"id": "deploy", "execute-command": "/bin/echo", "on-success": ["notify"], "on-failure": ["rollback"]}, {"id": "rollback", "execute-command": "/bin/echo"}]`)
+	assert.False(t, result.HasErrors())
+
+	// Unknown reference
+	result = validate(`[{"id": "deploy", "execute-command": "/bin/echo", "on-timeout": ["missing"]}]`)
+	require.True(t, result.HasErrors())
+	assert.Equal(t, "hooks[deploy]", result.Errors[0].(*ValidationError).Field)
+
+	// Cycle through chaining and actions
+	result = validate(`[{"id": "deploy", "execute-command": "/bin/echo", "on-failure": ["rollback"]}, {"id": "rollback", "actions": [{"hook": "deploy"}]}]`)
+	require.True(t, result.HasErrors())
+	assert.Equal(t, "hooks", result.Errors[0].(*ValidationError).Field)
 }
 
 func TestValidateFilePath(t *testing.T) {
@@ -0,0 +1,113 @@
+package hook
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Execution modes of a hook's actions.
+const (
+	ActionsSequential = "sequential"
+	ActionsParallel   = "parallel"
+)
+
+// Action is a single step of a hook with multiple actions. Exactly one of
+// ExecuteCommand, Forward or Hook must be set.
+type Action struct {
+	Name                     string         `json:"name,omitempty"`
+	ExecuteCommand           string         `json:"execute-command,omitempty"`
+	CommandWorkingDirectory  string         `json:"command-working-directory,omitempty"`
+	PassEnvironmentToCommand []Argument     `json:"pass-environment-to-command,omitempty"`
+	PassArgumentsToCommand   []Argument     `json:"pass-arguments-to-command,omitempty"`
+	PassFileToCommand        []Argument     `json:"pass-file-to-command,omitempty"`
+	Forward                  *ForwardConfig `json:"forward,omitempty"`
+	// Hook runs the action of another hook, referenced by ID. Its trigger
+	// rules are not evaluated.
+	Hook string `json:"hook,omitempty"`
+	// ContinueOnError keeps running the remaining actions when this one
+	// fails, and does not fail the hook.
+	ContinueOnError bool `json:"continue-on-error,omitempty"`
+}
+
+// ActionName returns the name of the i-th action, defaulting to its 1-based
+// position.
+func (h *Hook) ActionName(i int) string {
+	if name := h.Actions[i].Name; name != "" {
+		return name
+	}
+	return strconv.Itoa(i + 1)
+}
+
+// Parallel reports whether the actions of the hook run concurrently.
+func (h *Hook) Parallel() bool {
+	return h.ActionsMode == ActionsParallel
+}
+
+// ActionHook returns a copy of h that runs the command or forward of the i-th
+// action. Execution settings such as timeouts, sandbox and kill-signal are
+// inherited from h.
+func (h *Hook) ActionHook(i int) *Hook {
+	a := h.Actions[i]
+
+	ah := *h
+	ah.ID = h.ID + "/" + h.ActionName(i)
+	ah.Actions = nil
+	ah.ExecuteCommand = a.ExecuteCommand
+	ah.Forward = a.Forward
+	ah.PassEnvironmentToCommand = a.PassEnvironmentToCommand
+	ah.PassArgumentsToCommand = a.PassArgumentsToCommand
+	ah.PassFileToCommand = a.PassFileToCommand
+	if a.CommandWorkingDirectory != "" {
+		ah.CommandWorkingDirectory = a.CommandWorkingDirectory
+	}
+	return &ah
+}
+
+// ValidateActions checks the actions and actions-mode settings.
+func (h *Hook) ValidateActions() error {
+	switch h.ActionsMode {
+	case "", ActionsSequential, ActionsParallel:
+	default:
+		return fmt.Errorf("unknown actions-mode %q (must be %s or %s)", h.ActionsMode, ActionsSequential, ActionsParallel)
+	}
+	if len(h.Actions) == 0 {
+		return nil
+	}
+
+	if h.ExecuteCommand != "" || h.Forward != nil {
+		return errors.New("actions cannot be combined with execute-command or forward")
+	}
+	if h.StreamCommandOutput {
+		return errors.New("actions cannot be combined with stream-command-output")
+	}
+	if h.CommandResponse != "" {
+		return errors.New("actions cannot be combined with command-response")
+	}
+
+	names := make(map[string]bool, len(h.Actions))
+	for i, a := range h.Actions {
+		name := h.ActionName(i)
+		if names[name] {
+			return fmt.Errorf("duplicate action name %q", name)
+		}
+		names[name] = true
+
+		set := 0
+		for _, ok := range []bool{a.ExecuteCommand != "", a.Forward != nil, a.Hook != ""} {
+			if ok {
+				set++
+			}
+		}
+		if set != 1 {
+			return fmt.Errorf("action %s must set exactly one of execute-command, forward or hook", name)
+		}
+		if a.Hook == h.ID {
+			return fmt.Errorf("action %s references its own hook", name)
+		}
+		if err := a.Forward.Validate(); err != nil {
+			return fmt.Errorf("action %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package hook
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestActionHook(t *testing.T) {
+	h := &Hook{
+		ID:                      "deploy",
+		CommandWorkingDirectory: "/srv",
+		Timeout:                 Duration(time.Minute),
+		KillSignal:              "SIGINT",
+		Actions: []Action{
+			{Name: "build", ExecuteCommand: "/bin/build", PassArgumentsToCommand: []Argument{{Source: "payload", Name: "ref"}}},
+			{ExecuteCommand: "/bin/notify", CommandWorkingDirectory: "/tmp"},
+		},
+	}
+
+	build := h.ActionHook(0)
+	if build.ID != "deploy/build" || build.ExecuteCommand != "/bin/build" || build.CommandWorkingDirectory != "/srv" || len(build.PassArgumentsToCommand) != 1 {
+		t.Errorf("unexpected action hook: %+v", build)
+	}
+	if build.Timeout != h.Timeout || build.KillSignal != "SIGINT" || build.Actions != nil {
+		t.Errorf("action hook should inherit execution settings: %+v", build)
+	}
+
+	notify := h.ActionHook(1)
+	if notify.ID != "deploy/2" || notify.CommandWorkingDirectory != "/tmp" || len(notify.PassArgumentsToCommand) != 0 {
+		t.Errorf("unexpected action hook: %+v", notify)
+	}
+	if len(h.Actions) != 2 || h.ExecuteCommand != "" {
+		t.Error("ActionHook must not modify the hook")
+	}
+}
+
+func TestValidateActions(t *testing.T) {
+	tests := []struct {
+		name string
+		h    Hook
+		ok   bool
+	}{
+		{"no actions", Hook{ID: "a"}, true},
+		{"valid", Hook{ID: "a", ActionsMode: ActionsParallel, Actions: []Action{{ExecuteCommand: "/bin/true"}, {Hook: "b", ContinueOnError: true}, {Forward: &ForwardConfig{URL: "https://example.com"}}}}, true},
+		{"unknown mode", Hook{ID: "a", ActionsMode: "random"}, false},
+		{"combined with command", Hook{ID: "a", ExecuteCommand: "/bin/true", Actions: []Action{{ExecuteCommand: "/bin/true"}}}, false},
+		{"combined with streaming", Hook{ID: "a", StreamCommandOutput: true, Actions: []Action{{ExecuteCommand: "/bin/true"}}}, false},
+		{"empty action", Hook{ID: "a", Actions: []Action{{Name: "noop"}}}, false},
+		{"several targets", Hook{ID: "a", Actions: []Action{{ExecuteCommand: "/bin/true", Hook: "b"}}}, false},
+		{"duplicate names", Hook{ID: "a", Actions: []Action{{Name: "x", ExecuteCommand: "/bin/true"}, {Name: "x", Hook: "b"}}}, false},
+		{"self reference", Hook{ID: "a", Actions: []Action{{Hook: "a"}}}, false},
+		{"invalid forward", Hook{ID: "a", Actions: []Action{{Forward: &ForwardConfig{}}}}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.h.Validate()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: unexpected error state: %v", tt.name, err)
+		}
+	}
+}
+
+func TestLoadHookWithActions(t *testing.T) {
+	var hooks Hooks
+	data := []byte(`[{"id": "push", "actions-mode": "parallel", "actions": [{"name": "deploy", "execute-command": "/bin/deploy"}, {"hook": "notify", "continue-on-error": true}]}]`)
+	if err := json.Unmarshal(data, &hooks); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	h := hooks[0]
+	if !h.Parallel() || len(h.Actions) != 2 || h.ActionName(0) != "deploy" || h.Actions[1].Hook != "notify" || !h.Actions[1].ContinueOnError {
+		t.Errorf("unexpected actions: %+v", h)
+	}
+}
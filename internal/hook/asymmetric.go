@@ -0,0 +1,317 @@
+package hook
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha256" // register SHA-256 for crypto.Hash
+	_ "crypto/sha512" // register SHA-384 and SHA-512 for crypto.Hash
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Constants for the public key signature MatchRule types
+const (
+	Ed25519Signature string = "ed25519-signature"
+	RSASignature     string = "rsa-signature"
+	RSAPSSSignature  string = "rsa-pss-signature"
+	ECDSASignature   string = "ecdsa-signature"
+	JWSSignature     string = "jws-signature"
+)
+
+// Encodings of detached signatures.
+const (
+	SignatureEncodingBase64 string = "base64"
+	SignatureEncodingHex    string = "hex"
+)
+
+// PublicKeyConfig configures the public key signature MatchRule types. The
+// signature is read from the rule parameter and verified over SignedPayload
+// with the keys of the embedded PublicKeySource.
+type PublicKeyConfig struct {
+	PublicKeySource
+	// KeyID restricts verification to the JWK with this "kid".
+	KeyID string `json:"key-id,omitempty"`
+	// Hash is the digest used by RSA and ECDSA signatures: sha256 (default), sha384 or sha512.
+	Hash string `json:"hash,omitempty"`
+	// Encoding of the signature: base64 (default, standard or URL-safe) or hex.
+	// Ignored by jws-signature.
+	Encoding string `json:"encoding,omitempty"`
+	// SignedPayload is a template of the signed content, rendered with the
+	// same data as forward templates. Defaults to the raw request body.
+	SignedPayload string `json:"signed-payload,omitempty"`
+}
+
+// IsPublicKeySignature returns whether the MatchRule type verifies a public key signature.
+func IsPublicKeySignature(ruleType string) bool {
+	switch ruleType {
+	case Ed25519Signature, RSASignature, RSAPSSSignature, ECDSASignature, JWSSignature:
+		return true
+	}
+	return false
+}
+
+// Validate checks the public key signature configuration.
+func (c *PublicKeyConfig) Validate() error {
+	if c == nil {
+		return errors.New("public-key is required")
+	}
+	if err := c.PublicKeySource.Validate(); err != nil {
+		return err
+	}
+	if _, err := parseSignatureHash(c.Hash); err != nil {
+		return err
+	}
+	switch c.Encoding {
+	case "", SignatureEncodingBase64, SignatureEncodingHex:
+	default:
+		return fmt.Errorf("unsupported signature encoding %q", c.Encoding)
+	}
+	if c.SignedPayload != "" {
+		if _, err := parseForwardTemplate("signed-payload", c.SignedPayload); err != nil {
+			return fmt.Errorf("invalid signed-payload template: %w", err)
+		}
+	}
+	return nil
+}
+
+func parseSignatureHash(name string) (crypto.Hash, error) {
+	switch strings.ToLower(name) {
+	case "", "sha256":
+		return crypto.SHA256, nil
+	case "sha384":
+		return crypto.SHA384, nil
+	case "sha512":
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf("unsupported signature hash %q", name)
+}
+
+// signedPayload renders the content covered by the signature.
+func (c *PublicKeyConfig) signedPayload(r *Request) ([]byte, error) {
+	if c.SignedPayload == "" {
+		return r.SignedBody(), nil
+	}
+	data := &ForwardData{
+		ID:          r.ID,
+		ContentType: r.ContentType,
+		Body:        string(r.SignedBody()),
+		Headers:     r.Headers,
+		Query:       r.Query,
+		Payload:     r.Payload,
+	}
+	s, err := renderForwardTemplate("signed-payload", c.SignedPayload, data)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering signed-payload: %w", err)
+	}
+	return []byte(s), nil
+}
+
+// evaluatePublicKeySignature verifies the signature referenced by the rule
+// parameter and records the outcome on the request.
+func (r MatchRule) evaluatePublicKeySignature(req *Request) (bool, error) {
+	signature, err := r.Parameter.Get(req)
+	if err == nil {
+		_, err = CheckPublicKeySignature(req, r.Type, r.PublicKey, signature)
+	}
+	req.recordSignatureCheck(r.Type, err == nil, err)
+	return err == nil, err
+}
+
+// CheckPublicKeySignature verifies a detached signature of the given MatchRule
+// type. Signatures that do not verify with any of the configured keys result
+// in a SignatureError.
+func CheckPublicKeySignature(r *Request, ruleType string, c *PublicKeyConfig, signature string) (bool, error) {
+	if c == nil {
+		return false, errors.New("signature validation public-key can not be empty")
+	}
+	payload, err := c.signedPayload(r)
+	if err != nil {
+		return false, err
+	}
+	ctx := context.Background()
+	if r.RawRequest != nil {
+		ctx = r.RawRequest.Context()
+	}
+
+	if ruleType == JWSSignature {
+		return verifyDetachedJWS(ctx, c, signature, payload)
+	}
+
+	var sig []byte
+	if c.Encoding == SignatureEncodingHex {
+		sig, err = hex.DecodeString(strings.TrimSpace(signature))
+	} else {
+		sig, err = decodeBase64(signature)
+	}
+	if err != nil || len(sig) == 0 {
+		return false, &SignatureError{Signature: signature}
+	}
+
+	keys, err := c.Keys(ctx, c.KeyID)
+	if err != nil {
+		return false, err
+	}
+	keys = SelectPublicKeys(keys, c.KeyID)
+	if len(keys) == 0 {
+		return false, fmt.Errorf("no public key with key-id %q", c.KeyID)
+	}
+
+	hash, err := parseSignatureHash(c.Hash)
+	if err != nil {
+		return false, err
+	}
+	for _, k := range keys {
+		if verifySignature(ruleType, k.Key, hash, payload, sig) {
+			return true, nil
+		}
+	}
+	return false, &SignatureError{Signature: signature}
+}
+
+// verifySignature verifies sig over msg with key for the given MatchRule type.
+func verifySignature(ruleType string, key crypto.PublicKey, hash crypto.Hash, msg, sig []byte) bool {
+	switch ruleType {
+	case Ed25519Signature:
+		k, ok := key.(ed25519.PublicKey)
+		return ok && ed25519.Verify(k, msg, sig)
+
+	case RSASignature, RSAPSSSignature:
+		k, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return false
+		}
+		h := hash.New()
+		h.Write(msg)
+		if ruleType == RSAPSSSignature {
+			return rsa.VerifyPSS(k, hash, h.Sum(nil), sig, nil) == nil
+		}
+		return rsa.VerifyPKCS1v15(k, hash, h.Sum(nil), sig) == nil
+
+	case ECDSASignature:
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return false
+		}
+		h := hash.New()
+		h.Write(msg)
+		digest := h.Sum(nil)
+		// Raw r||s signatures (as used by JWS) have a fixed size, anything
+		// else is treated as ASN.1 DER.
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) == 2*size {
+			r := new(big.Int).SetBytes(sig[:size])
+			s := new(big.Int).SetBytes(sig[size:])
+			if ecdsa.Verify(k, digest, r, s) {
+				return true
+			}
+		}
+		return ecdsa.VerifyASN1(k, digest, sig)
+	}
+	return false
+}
+
+// jwsAlgorithms maps JWS "alg" values to the MatchRule type and hash used to verify them.
+var jwsAlgorithms = map[string]struct {
+	ruleType string
+	hash     crypto.Hash
+}{
+	"RS256": {RSASignature, crypto.SHA256},
+	"RS384": {RSASignature, crypto.SHA384},
+	"RS512": {RSASignature, crypto.SHA512},
+	"PS256": {RSAPSSSignature, crypto.SHA256},
+	"PS384": {RSAPSSSignature, crypto.SHA384},
+	"PS512": {RSAPSSSignature, crypto.SHA512},
+	"ES256": {ECDSASignature, crypto.SHA256},
+	"ES384": {ECDSASignature, crypto.SHA384},
+	"ES512": {ECDSASignature, crypto.SHA512},
+	"EdDSA": {Ed25519Signature, 0},
+}
+
+// VerifyJWS verifies a JWS signature over signingInput with the given keys.
+// Unsupported algorithms (including "none") never verify.
+func VerifyJWS(alg string, keys []PublicKey, signingInput, sig []byte) bool {
+	a, ok := jwsAlgorithms[alg]
+	if !ok {
+		return false
+	}
+	for _, k := range keys {
+		// JWS uses raw r||s ECDSA signatures only.
+		if a.ruleType == ECDSASignature {
+			if ek, ok := k.Key.(*ecdsa.PublicKey); !ok || len(sig) != 2*((ek.Curve.Params().BitSize+7)/8) {
+				continue
+			}
+		}
+		if verifySignature(a.ruleType, k.Key, a.hash, signingInput, sig) {
+			return true
+		}
+	}
+	return false
+}
+
+type jwsHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	// B64 is false for unencoded payloads (RFC 7797).
+	B64 *bool `json:"b64"`
+}
+
+// verifyDetachedJWS verifies a JWS with detached content (RFC 7515,
+// Appendix F) in compact serialization: "<header>..<signature>".
+func verifyDetachedJWS(ctx context.Context, c *PublicKeyConfig, signature string, payload []byte) (bool, error) {
+	invalid := &SignatureError{Signature: signature}
+
+	parts := strings.Split(strings.TrimSpace(signature), ".")
+	if len(parts) != 3 {
+		return false, invalid
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false, invalid
+	}
+	var header jwsHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return false, invalid
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false, invalid
+	}
+
+	encoded := header.B64 == nil || *header.B64
+	content := string(payload)
+	if encoded {
+		content = base64.RawURLEncoding.EncodeToString(payload)
+	}
+	// An attached payload must be the request content.
+	if parts[1] != "" && parts[1] != content {
+		return false, invalid
+	}
+
+	kid := header.Kid
+	if c.KeyID != "" {
+		if kid != "" && kid != c.KeyID {
+			return false, invalid
+		}
+		kid = c.KeyID
+	}
+	keys, err := c.Keys(ctx, kid)
+	if err != nil {
+		return false, err
+	}
+	if kid != "" {
+		keys = SelectPublicKeys(keys, kid)
+	}
+
+	if !VerifyJWS(header.Alg, keys, []byte(parts[0]+"."+content), sig) {
+		return false, invalid
+	}
+	return true, nil
+}
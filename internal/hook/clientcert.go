@@ -0,0 +1,133 @@
+package hook
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MatchClientCert is the MatchRule type matching the verified TLS client certificate.
+const MatchClientCert string = "client-cert"
+
+// ClientCertRule matches the TLS client certificate of the request. Every
+// configured list must contain a value of the certificate; a rule without
+// lists matches any client certificate.
+type ClientCertRule struct {
+	// CommonName lists accepted subject common names.
+	CommonName []string `json:"common-name,omitempty"`
+	// SAN lists accepted subject alternative names (DNS names, email
+	// addresses, URIs or IP addresses).
+	SAN []string `json:"san,omitempty"`
+	// Fingerprint lists accepted SHA-256 fingerprints of the certificate, in
+	// hex with or without colons.
+	Fingerprint []string `json:"fingerprint,omitempty"`
+}
+
+// Validate checks the client-cert rule configuration.
+func (c *ClientCertRule) Validate() error {
+	if c == nil {
+		return nil
+	}
+	for _, fp := range c.Fingerprint {
+		if b, err := hex.DecodeString(normalizeFingerprint(fp)); err != nil || len(b) != sha256.Size {
+			return errors.New("fingerprint must be a hex encoded SHA-256 digest")
+		}
+	}
+	return nil
+}
+
+// Evaluate ClientCertRule will return true if the request has a client
+// certificate matching the rule.
+func (c *ClientCertRule) Evaluate(req *Request) bool {
+	cert := ClientCertificate(req)
+	if cert == nil {
+		return false
+	}
+	if c == nil {
+		return true
+	}
+
+	if len(c.CommonName) > 0 && !slices.Contains(c.CommonName, cert.Subject.CommonName) {
+		return false
+	}
+	if len(c.SAN) > 0 && !slices.ContainsFunc(subjectAltNames(cert), func(san string) bool {
+		return slices.Contains(c.SAN, san)
+	}) {
+		return false
+	}
+	if len(c.Fingerprint) > 0 {
+		fp := CertificateFingerprint(cert)
+		if !slices.ContainsFunc(c.Fingerprint, func(v string) bool {
+			return compare(normalizeFingerprint(v), fp)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// ClientCertificate returns the TLS client certificate of the request, or nil.
+// The listener only accepts certificates signed by the configured client CA.
+func ClientCertificate(r *Request) *x509.Certificate {
+	if r == nil || r.RawRequest == nil || r.RawRequest.TLS == nil || len(r.RawRequest.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	return r.RawRequest.TLS.PeerCertificates[0]
+}
+
+// CertificateFingerprint returns the lowercase hex SHA-256 fingerprint of cert.
+func CertificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeFingerprint(fp string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(fp), ":", ""))
+}
+
+func subjectAltNames(cert *x509.Certificate) []string {
+	names := append([]string{}, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		names = append(names, u.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	return names
+}
+
+// clientCertValue returns a field of the client certificate for the request
+// argument source.
+func clientCertValue(r *Request, name string) (string, bool, error) {
+	field, ok := strings.CutPrefix(name, "client-cert-")
+	if !ok {
+		return "", false, nil
+	}
+	cert := ClientCertificate(r)
+	if cert == nil {
+		return "", true, &ParameterNodeError{Key: name}
+	}
+
+	switch field {
+	case "cn":
+		return cert.Subject.CommonName, true, nil
+	case "subject":
+		return cert.Subject.String(), true, nil
+	case "issuer":
+		return cert.Issuer.String(), true, nil
+	case "serial":
+		return cert.SerialNumber.String(), true, nil
+	case "fingerprint":
+		return CertificateFingerprint(cert), true, nil
+	case "san":
+		return strings.Join(subjectAltNames(cert), ","), true, nil
+	case "not-after":
+		return cert.NotAfter.UTC().Format(time.RFC3339), true, nil
+	}
+	return "", false, nil
+}
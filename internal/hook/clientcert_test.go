@@ -0,0 +1,142 @@
+package hook
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testClientCertificate(t *testing.T) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spiffe, _ := url.Parse("spiffe://example.org/ci/deployer")
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(42),
+		Subject:        pkix.Name{CommonName: "ci-runner", Organization: []string{"Example"}},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		DNSNames:       []string{"runner.example.org"},
+		EmailAddresses: []string{"ci@example.org"},
+		URIs:           []*url.URL{spiffe},
+		IPAddresses:    []net.IP{net.ParseIP("10.0.0.7")},
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func requestWithClientCert(cert *x509.Certificate) *Request {
+	raw := &http.Request{Method: http.MethodPost}
+	if cert != nil {
+		raw.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	}
+	return &Request{RawRequest: raw}
+}
+
+func TestClientCertRule(t *testing.T) {
+	cert := testClientCertificate(t)
+	fp := CertificateFingerprint(cert)
+	var colonFP []string
+	for i := 0; i < len(fp); i += 2 {
+		colonFP = append(colonFP, strings.ToUpper(fp[i:i+2]))
+	}
+
+	tests := []struct {
+		description string
+		rule        *ClientCertRule
+		cert        *x509.Certificate
+		ok          bool
+	}{
+		{"any certificate", nil, cert, true},
+		{"no certificate", nil, nil, false},
+		{"common name", &ClientCertRule{CommonName: []string{"other", "ci-runner"}}, cert, true},
+		{"wrong common name", &ClientCertRule{CommonName: []string{"other"}}, cert, false},
+		{"dns san", &ClientCertRule{SAN: []string{"runner.example.org"}}, cert, true},
+		{"uri san", &ClientCertRule{SAN: []string{"spiffe://example.org/ci/deployer"}}, cert, true},
+		{"ip san", &ClientCertRule{SAN: []string{"10.0.0.7"}}, cert, true},
+		{"common name is not a san", &ClientCertRule{SAN: []string{"ci-runner"}}, cert, false},
+		{"fingerprint", &ClientCertRule{Fingerprint: []string{fp}}, cert, true},
+		{"fingerprint with colons", &ClientCertRule{Fingerprint: []string{strings.Join(colonFP, ":")}}, cert, true},
+		{"wrong fingerprint", &ClientCertRule{Fingerprint: []string{strings.Repeat("00", 32)}}, cert, false},
+		{"all fields must match", &ClientCertRule{CommonName: []string{"ci-runner"}, SAN: []string{"other.example.org"}}, cert, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			rule := Rules{Match: &MatchRule{Type: MatchClientCert, ClientCert: tt.rule}}
+			if err := rule.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			ok, err := rule.Evaluate(requestWithClientCert(tt.cert))
+			if ok != tt.ok || err != nil {
+				t.Errorf("got ok=%v err=%v, want ok=%v", ok, err, tt.ok)
+			}
+		})
+	}
+
+	invalid := &MatchRule{Type: MatchClientCert, ClientCert: &ClientCertRule{Fingerprint: []string{"abc"}}}
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for invalid fingerprint")
+	}
+}
+
+func TestClientCertRequestSource(t *testing.T) {
+	cert := testClientCertificate(t)
+	r := requestWithClientCert(cert)
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"method", http.MethodPost},
+		{"client-cert-cn", "ci-runner"},
+		{"Client-Cert-CN", "ci-runner"},
+		{"client-cert-subject", "CN=ci-runner,O=Example"},
+		{"client-cert-issuer", "CN=ci-runner,O=Example"},
+		{"client-cert-serial", "42"},
+		{"client-cert-fingerprint", CertificateFingerprint(cert)},
+		{"client-cert-san", "runner.example.org,ci@example.org,spiffe://example.org/ci/deployer,10.0.0.7"},
+		{"client-cert-not-after", cert.NotAfter.UTC().Format(time.RFC3339)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			arg := Argument{Source: SourceRequest, Name: tt.name}
+			value, err := arg.Get(r)
+			if err != nil || value != tt.value {
+				t.Errorf("got %q, %v, want %q", value, err, tt.value)
+			}
+		})
+	}
+
+	arg := Argument{Source: SourceRequest, Name: "client-cert-unknown"}
+	if _, err := arg.Get(r); err == nil || IsParameterNodeError(err) {
+		t.Errorf("expected unsupported key error, got %v", err)
+	}
+
+	// Without a client certificate the values are missing parameters.
+	arg = Argument{Source: SourceRequest, Name: "client-cert-cn"}
+	if _, err := arg.Get(requestWithClientCert(nil)); !IsParameterNodeError(err) {
+		t.Errorf("expected ParameterNodeError, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package hook
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// cloudEventHeaderPrefix is the prefix of the context attribute headers of
+// binary mode CloudEvents.
+const cloudEventHeaderPrefix = "ce-"
+
+// ParseCloudEventHeaders collects the context attributes of a binary mode
+// CloudEvent from the ce-* headers; the Content-Type is the datacontenttype.
+// Requests without a ce-specversion header are left unchanged.
+func (r *Request) ParseCloudEventHeaders() {
+	attributes := make(map[string]interface{})
+
+	for k, v := range r.Headers {
+		name := strings.ToLower(k)
+		if !strings.HasPrefix(name, cloudEventHeaderPrefix) {
+			continue
+		}
+
+		value, _ := v.(string)
+		// attribute values are percent-encoded in headers
+		if decoded, err := url.PathUnescape(value); err == nil {
+			value = decoded
+		}
+		attributes[strings.TrimPrefix(name, cloudEventHeaderPrefix)] = value
+	}
+
+	if _, ok := attributes["specversion"]; !ok {
+		return
+	}
+	if r.ContentType != "" {
+		attributes["datacontenttype"] = r.ContentType
+	}
+
+	r.CloudEvent = attributes
+}
+
+// ParseCloudEventPayload parses a structured mode CloudEvent. The context
+// attributes are stored in CloudEvent and the event data becomes the payload:
+// JSON data is used as is, data_base64 and string data of other content
+// types are parsed according to datacontenttype.
+func (r *Request) ParseCloudEventPayload() error {
+	if strings.Contains(strings.ToLower(r.ContentType), "cloudevents-batch") {
+		return errors.New("error parsing CloudEvent payload: batched mode is not supported")
+	}
+
+	var event map[string]json.RawMessage
+	if err := json.Unmarshal(r.Body, &event); err != nil {
+		return fmt.Errorf("error parsing CloudEvent payload %+v", err)
+	}
+	if _, ok := event["specversion"]; !ok {
+		return errors.New("error parsing CloudEvent payload: missing specversion")
+	}
+
+	attributes := make(map[string]interface{}, len(event))
+	for k, raw := range event {
+		if k == "data" || k == "data_base64" {
+			continue
+		}
+		value, err := decodeJSONValue(raw)
+		if err != nil {
+			return fmt.Errorf("error parsing CloudEvent attribute %q %+v", k, err)
+		}
+		attributes[k] = value
+	}
+	r.CloudEvent = attributes
+
+	contentType, _ := attributes["datacontenttype"].(string)
+
+	if raw, ok := event["data_base64"]; ok {
+		var encoded string
+		if err := json.Unmarshal(raw, &encoded); err != nil {
+			return fmt.Errorf("error parsing CloudEvent data_base64 %+v", err)
+		}
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("error decoding CloudEvent data_base64 %+v", err)
+		}
+		return r.parseCloudEventData(contentType, data)
+	}
+
+	raw, ok := event["data"]
+	if !ok {
+		return nil
+	}
+
+	if contentType != "" && !strings.Contains(contentType, "json") {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return r.parseCloudEventData(contentType, []byte(s))
+		}
+	}
+
+	data, err := decodeJSONValue(raw)
+	if err != nil {
+		return fmt.Errorf("error parsing CloudEvent data %+v", err)
+	}
+	switch data := data.(type) {
+	case nil:
+	case map[string]interface{}:
+		r.Payload = data
+	default:
+		r.Payload = map[string]interface{}{"root": data}
+	}
+
+	return nil
+}
+
+// parseCloudEventData parses event data according to its content type,
+// defaulting to JSON.
+func (r *Request) parseCloudEventData(contentType string, data []byte) error {
+	embedded := Request{Body: data, ContentType: contentType}
+
+	var err error
+	switch {
+	case contentType == "" || strings.Contains(contentType, "json"):
+		err = embedded.ParseJSONPayload()
+	case strings.Contains(contentType, "x-www-form-urlencoded"):
+		err = embedded.ParseFormPayload()
+	case strings.Contains(contentType, "xml"):
+		err = embedded.ParseXMLPayload()
+	case strings.Contains(contentType, "yaml"):
+		err = embedded.ParseYAMLPayload()
+	default:
+		return fmt.Errorf("error parsing CloudEvent data: unsupported datacontenttype %q", contentType)
+	}
+	if err != nil {
+		return err
+	}
+
+	r.Payload = embedded.Payload
+	return nil
+}
+
+// decodeJSONValue decodes raw keeping numbers as json.Number.
+func decodeJSONValue(raw json.RawMessage) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var value interface{}
+	err := decoder.Decode(&value)
+	return value, err
+}
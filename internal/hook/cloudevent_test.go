@@ -0,0 +1,119 @@
+package hook
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseCloudEventPayload(t *testing.T) {
+	tests := []struct {
+		description string
+		contentType string
+		body        string
+		attributes  map[string]interface{}
+		payload     map[string]interface{}
+		ok          bool
+	}{
+		{
+			"json data",
+			"application/cloudevents+json",
+			`{"specversion": "1.0", "type": "com.example.push", "source": "/repos/hello", "id": "1", "retries": 2, "data": {"ref": "main"}}`,
+			map[string]interface{}{"specversion": "1.0", "type": "com.example.push", "source": "/repos/hello", "id": "1", "retries": json.Number("2")},
+			map[string]interface{}{"ref": "main"},
+			true,
+		},
+		{
+			"array data",
+			"application/cloudevents+json; charset=utf-8",
+			`{"specversion": "1.0", "id": "1", "data": [1, 2]}`,
+			map[string]interface{}{"specversion": "1.0", "id": "1"},
+			map[string]interface{}{"root": []interface{}{json.Number("1"), json.Number("2")}},
+			true,
+		},
+		{
+			"base64 data",
+			"application/cloudevents+json",
+			`{"specversion": "1.0", "id": "1", "datacontenttype": "application/json", "data_base64": "eyJyZWYiOiAibWFpbiJ9"}`,
+			map[string]interface{}{"specversion": "1.0", "id": "1", "datacontenttype": "application/json"},
+			map[string]interface{}{"ref": "main"},
+			true,
+		},
+		{
+			"xml data",
+			"application/cloudevents+json",
+			`{"specversion": "1.0", "id": "1", "datacontenttype": "application/xml", "data": "<ref>main</ref>"}`,
+			map[string]interface{}{"specversion": "1.0", "id": "1", "datacontenttype": "application/xml"},
+			map[string]interface{}{"ref": "main"},
+			true,
+		},
+		{
+			"no data",
+			"application/cloudevents+json",
+			`{"specversion": "1.0", "id": "1"}`,
+			map[string]interface{}{"specversion": "1.0", "id": "1"},
+			nil,
+			true,
+		},
+		{
+			"unsupported data",
+			"application/cloudevents+json",
+			`{"specversion": "1.0", "id": "1", "datacontenttype": "text/plain", "data": "hello"}`,
+			map[string]interface{}{"specversion": "1.0", "id": "1", "datacontenttype": "text/plain"},
+			nil,
+			false,
+		},
+		{"missing specversion", "application/cloudevents+json", `{"id": "1", "data": {}}`, nil, nil, false},
+		{"batch", "application/cloudevents-batch+json", `[{"specversion": "1.0", "id": "1"}]`, nil, nil, false},
+		{"invalid", "application/cloudevents+json", `{"specversion": `, nil, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			r := Request{ContentType: tt.contentType, Body: []byte(tt.body)}
+			err := r.ParseCloudEventPayload()
+			if (err == nil) != tt.ok {
+				t.Errorf("got error %v, want ok=%v", err, tt.ok)
+			}
+			if !reflect.DeepEqual(r.CloudEvent, tt.attributes) {
+				t.Errorf("got attributes %#v, want %#v", r.CloudEvent, tt.attributes)
+			}
+			if !reflect.DeepEqual(r.Payload, tt.payload) {
+				t.Errorf("got payload %#v, want %#v", r.Payload, tt.payload)
+			}
+		})
+	}
+}
+
+func TestParseCloudEventHeaders(t *testing.T) {
+	r := Request{ContentType: "application/json"}
+	r.ParseHeaders(map[string][]string{
+		"Ce-Specversion": {"1.0"},
+		"Ce-Type":        {"com.example.push"},
+		"Ce-Subject":     {"refs%2Fheads%2Fmain"},
+		"X-Other":        {"ignored"},
+	})
+	r.ParseCloudEventHeaders()
+
+	want := map[string]interface{}{
+		"specversion":     "1.0",
+		"type":            "com.example.push",
+		"subject":         "refs/heads/main",
+		"datacontenttype": "application/json",
+	}
+	if !reflect.DeepEqual(r.CloudEvent, want) {
+		t.Errorf("got attributes %#v, want %#v", r.CloudEvent, want)
+	}
+
+	arg := Argument{Source: SourceCloudEvent, Name: "subject"}
+	if got, err := arg.Get(&r); err != nil || got != "refs/heads/main" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	r = Request{}
+	r.ParseHeaders(map[string][]string{"Ce-Type": {"com.example.push"}})
+	r.ParseCloudEventHeaders()
+	if r.CloudEvent != nil {
+		t.Errorf("got attributes %#v without ce-specversion", r.CloudEvent)
+	}
+}
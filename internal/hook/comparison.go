@@ -0,0 +1,145 @@
+package hook
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Constants for the MatchRule types comparing the parameter with Value or Values.
+const (
+	MatchGreaterThan        string = "gt"
+	MatchGreaterThanOrEqual string = "gte"
+	MatchLessThan           string = "lt"
+	MatchLessThanOrEqual    string = "lte"
+	MatchIn                 string = "in"
+	MatchNotIn              string = "not-in"
+	MatchExists             string = "exists"
+	MatchMissing            string = "missing"
+	MatchPrefix             string = "prefix"
+	MatchSuffix             string = "suffix"
+	MatchContains           string = "contains"
+	MatchSemverRange        string = "semver-range"
+)
+
+// IsComparisonMatch returns whether t is a numeric, set, existence, string or
+// semver-range MatchRule type.
+func IsComparisonMatch(t string) bool {
+	switch t {
+	case MatchGreaterThan, MatchGreaterThanOrEqual, MatchLessThan, MatchLessThanOrEqual,
+		MatchIn, MatchNotIn, MatchExists, MatchMissing,
+		MatchPrefix, MatchSuffix, MatchContains, MatchSemverRange:
+		return true
+	}
+	return false
+}
+
+// validateComparison checks the Value or Values of a comparison rule.
+func (r *MatchRule) validateComparison() error {
+	switch r.Type {
+	case MatchGreaterThan, MatchGreaterThanOrEqual, MatchLessThan, MatchLessThanOrEqual:
+		if _, err := strconv.ParseFloat(strings.TrimSpace(r.Value), 64); err != nil {
+			return errors.New("value must be a number")
+		}
+	case MatchIn, MatchNotIn:
+		if len(r.Values) == 0 {
+			return errors.New("values must not be empty")
+		}
+	case MatchPrefix, MatchSuffix, MatchContains:
+		if r.Value == "" {
+			return errors.New("value must not be empty")
+		}
+	case MatchSemverRange:
+		if _, err := parseSemverRange(r.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evaluateComparison evaluates a comparison rule. A missing parameter, and a
+// parameter that is not a number or version for the numeric and semver-range
+// types, returns a ParameterNodeError.
+func (r MatchRule) evaluateComparison(req *Request) (bool, error) {
+	arg, err := r.Parameter.Get(req)
+	if r.Type == MatchExists || r.Type == MatchMissing {
+		if err != nil && !IsParameterNodeError(err) {
+			return false, err
+		}
+		if r.Type == MatchMissing {
+			return err != nil, nil
+		}
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch r.Type {
+	case MatchGreaterThan, MatchGreaterThanOrEqual, MatchLessThan, MatchLessThanOrEqual:
+		value, ok := parameterNumber(arg)
+		if !ok {
+			return false, &ParameterNodeError{Key: r.Parameter.Name}
+		}
+		limit, err := strconv.ParseFloat(strings.TrimSpace(r.Value), 64)
+		if err != nil {
+			return false, err
+		}
+		switch r.Type {
+		case MatchGreaterThan:
+			return value > limit, nil
+		case MatchGreaterThanOrEqual:
+			return value >= limit, nil
+		case MatchLessThan:
+			return value < limit, nil
+		}
+		return value <= limit, nil
+
+	case MatchIn:
+		return slices.Contains(r.Values, arg), nil
+	case MatchNotIn:
+		return !slices.Contains(r.Values, arg), nil
+	case MatchPrefix:
+		return strings.HasPrefix(arg, r.Value), nil
+	case MatchSuffix:
+		return strings.HasSuffix(arg, r.Value), nil
+	case MatchContains:
+		return strings.Contains(arg, r.Value), nil
+
+	case MatchSemverRange:
+		version, err := parseSemver(arg)
+		if err != nil {
+			return false, &ParameterNodeError{Key: r.Parameter.Name}
+		}
+		constraint, err := parseSemverRange(r.Value)
+		if err != nil {
+			return false, err
+		}
+		return constraint.matches(version), nil
+	}
+	return false, nil
+}
+
+// parameterNumber parses a parameter value as a number. JSON lists and
+// objects count their elements, so that e.g. the number of commits can be
+// compared.
+func parameterNumber(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return n, true
+	}
+	if strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{") {
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err == nil {
+			switch v := v.(type) {
+			case []interface{}:
+				return float64(len(v)), true
+			case map[string]interface{}:
+				return float64(len(v)), true
+			}
+		}
+	}
+	return 0, false
+}
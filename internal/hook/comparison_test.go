@@ -0,0 +1,93 @@
+package hook
+
+import (
+	"testing"
+)
+
+func TestComparisonMatchRules(t *testing.T) {
+	r := &Request{Body: []byte(`{
+		"action": "synchronize",
+		"size": 3,
+		"ratio": 0.5,
+		"commits": [{"id": "a"}, {"id": "b"}],
+		"ref": "refs/heads/release/1.4",
+		"version": "v1.4.2",
+		"prerelease": "2.0.0-rc.1",
+		"draft": false
+	}`)}
+	if err := r.ParseJSONPayload(); err != nil {
+		t.Fatal(err)
+	}
+	payload := func(name string) Argument { return Argument{Source: SourcePayload, Name: name} }
+
+	tests := []struct {
+		description string
+		rule        MatchRule
+		ok          bool
+		missing     bool
+	}{
+		{"gt", MatchRule{Type: MatchGreaterThan, Value: "2", Parameter: payload("size")}, true, false},
+		{"gt equal", MatchRule{Type: MatchGreaterThan, Value: "3", Parameter: payload("size")}, false, false},
+		{"gte", MatchRule{Type: MatchGreaterThanOrEqual, Value: "3", Parameter: payload("size")}, true, false},
+		{"lt float", MatchRule{Type: MatchLessThan, Value: "0.75", Parameter: payload("ratio")}, true, false},
+		{"lte", MatchRule{Type: MatchLessThanOrEqual, Value: "2", Parameter: payload("size")}, false, false},
+		{"gt list length", MatchRule{Type: MatchGreaterThan, Value: "0", Parameter: payload("commits")}, true, false},
+		{"gt not a number", MatchRule{Type: MatchGreaterThan, Value: "0", Parameter: payload("action")}, false, true},
+		{"gt missing", MatchRule{Type: MatchGreaterThan, Value: "0", Parameter: payload("missing")}, false, true},
+		{"in", MatchRule{Type: MatchIn, Values: []string{"opened", "synchronize", "reopened"}, Parameter: payload("action")}, true, false},
+		{"in no match", MatchRule{Type: MatchIn, Values: []string{"opened", "reopened"}, Parameter: payload("action")}, false, false},
+		{"not-in", MatchRule{Type: MatchNotIn, Values: []string{"closed"}, Parameter: payload("action")}, true, false},
+		{"not-in match", MatchRule{Type: MatchNotIn, Values: []string{"synchronize"}, Parameter: payload("action")}, false, false},
+		{"not-in missing", MatchRule{Type: MatchNotIn, Values: []string{"closed"}, Parameter: payload("missing")}, false, true},
+		{"exists", MatchRule{Type: MatchExists, Parameter: payload("draft")}, true, false},
+		{"exists missing", MatchRule{Type: MatchExists, Parameter: payload("missing")}, false, true},
+		{"missing", MatchRule{Type: MatchMissing, Parameter: payload("missing")}, true, false},
+		{"missing present", MatchRule{Type: MatchMissing, Parameter: payload("draft")}, false, false},
+		{"prefix", MatchRule{Type: MatchPrefix, Value: "refs/heads/release/", Parameter: payload("ref")}, true, false},
+		{"suffix", MatchRule{Type: MatchSuffix, Value: "/main", Parameter: payload("ref")}, false, false},
+		{"contains", MatchRule{Type: MatchContains, Value: "release", Parameter: payload("ref")}, true, false},
+		{"semver-range", MatchRule{Type: MatchSemverRange, Value: "^1.2", Parameter: payload("version")}, true, false},
+		{"semver-range no match", MatchRule{Type: MatchSemverRange, Value: ">=2.0.0", Parameter: payload("version")}, false, false},
+		{"semver-range prerelease", MatchRule{Type: MatchSemverRange, Value: ">=2.0.0-rc.0 <2.0.0", Parameter: payload("prerelease")}, true, false},
+		{"semver-range invalid version", MatchRule{Type: MatchSemverRange, Value: "^1.2", Parameter: payload("action")}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			if err := tt.rule.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			ok, err := tt.rule.Evaluate(r)
+			if ok != tt.ok {
+				t.Errorf("got ok=%v, want %v", ok, tt.ok)
+			}
+			if tt.missing != IsParameterNodeError(err) || (!tt.missing && err != nil) {
+				t.Errorf("got err=%v, want ParameterNodeError=%v", err, tt.missing)
+			}
+		})
+	}
+}
+
+func TestComparisonMatchRuleValidate(t *testing.T) {
+	tests := []struct {
+		description string
+		rule        MatchRule
+		ok          bool
+	}{
+		{"numeric value", MatchRule{Type: MatchGreaterThan, Value: "-1.5"}, true},
+		{"non-numeric value", MatchRule{Type: MatchLessThan, Value: "ten"}, false},
+		{"empty values", MatchRule{Type: MatchIn}, false},
+		{"empty prefix", MatchRule{Type: MatchPrefix}, false},
+		{"exists without value", MatchRule{Type: MatchExists}, true},
+		{"semver range", MatchRule{Type: MatchSemverRange, Value: ">= 1.2.3 < 2 || ~3.1"}, true},
+		{"invalid semver range", MatchRule{Type: MatchSemverRange, Value: ">=one"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			if err := tt.rule.Validate(); (err == nil) != tt.ok {
+				t.Errorf("got error %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package hook
+
+import (
+	"fmt"
+	"time"
+)
+
+// Concurrency policies applied when a hook has reached its max-concurrent limit.
+const (
+	// ConcurrencyPolicyQueue waits for a running execution of the same hook to finish.
+	ConcurrencyPolicyQueue = "queue"
+	// ConcurrencyPolicyReject rejects the new execution immediately.
+	ConcurrencyPolicyReject = "reject"
+	// ConcurrencyPolicyCancelPrevious cancels the oldest running execution to make room.
+	ConcurrencyPolicyCancelPrevious = "cancel-previous"
+)
+
+// ExecutionTimeout returns the hook specific execution timeout, or fallback
+// when the hook does not configure one.
+func (h *Hook) ExecutionTimeout(fallback time.Duration) time.Duration {
+	if h == nil || h.Timeout <= 0 {
+		return fallback
+	}
+	return h.Timeout.Duration()
+}
+
+// Policy returns the configured concurrency policy, defaulting to
+// ConcurrencyPolicyQueue.
+func (h *Hook) Policy() string {
+	if h == nil || h.ConcurrencyPolicy == "" {
+		return ConcurrencyPolicyQueue
+	}
+	return h.ConcurrencyPolicy
+}
+
+// ValidateConcurrency checks the timeout, max-concurrent, concurrency-policy,
+// serialize-by and debounce settings of the hook.
+func (h *Hook) ValidateConcurrency() error {
+	if h.Timeout < 0 {
+		return fmt.Errorf("timeout must be >= 0, got %s", h.Timeout.Duration())
+	}
+	if h.MaxConcurrent < 0 {
+		return fmt.Errorf("max-concurrent must be >= 0, got %d", h.MaxConcurrent)
+	}
+	switch h.ConcurrencyPolicy {
+	case "", ConcurrencyPolicyQueue, ConcurrencyPolicyReject, ConcurrencyPolicyCancelPrevious:
+	default:
+		return fmt.Errorf("unknown concurrency-policy %q (must be %s, %s or %s)", h.ConcurrencyPolicy,
+			ConcurrencyPolicyQueue, ConcurrencyPolicyReject, ConcurrencyPolicyCancelPrevious)
+	}
+	if h.SerializeBy != nil && h.SerializeBy.Source == "" {
+		return fmt.Errorf("serialize-by requires a source")
+	}
+	if h.Debounce < 0 {
+		return fmt.Errorf("debounce must be >= 0, got %s", h.Debounce.Duration())
+	}
+	if h.Debounce > 0 && h.StreamCommandOutput {
+		return fmt.Errorf("debounce cannot be used together with stream-command-output")
+	}
+	return nil
+}
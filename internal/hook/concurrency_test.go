@@ -0,0 +1,78 @@
+package hook
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestHookExecutionTimeout(t *testing.T) {
+	var nilHook *Hook
+	tests := []struct {
+		h    *Hook
+		want time.Duration
+	}{
+		{nilHook, 30 * time.Second},
+		{&Hook{}, 30 * time.Second},
+		{&Hook{Timeout: Duration(5 * time.Minute)}, 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := tt.h.ExecutionTimeout(30 * time.Second); got != tt.want {
+			t.Errorf("ExecutionTimeout() for %+v: got %v, want %v", tt.h, got, tt.want)
+		}
+	}
+}
+
+func TestHookPolicy(t *testing.T) {
+	if got := (&Hook{}).Policy(); got != ConcurrencyPolicyQueue {
+		t.Errorf("default policy: got %q, want %q", got, ConcurrencyPolicyQueue)
+	}
+	if got := (&Hook{ConcurrencyPolicy: ConcurrencyPolicyReject}).Policy(); got != ConcurrencyPolicyReject {
+		t.Errorf("reject policy: got %q, want %q", got, ConcurrencyPolicyReject)
+	}
+}
+
+func TestHookValidateConcurrency(t *testing.T) {
+	tests := []struct {
+		name string
+		h    Hook
+		ok   bool
+	}{
+		{"empty", Hook{}, true},
+		{"queue", Hook{MaxConcurrent: 2, ConcurrencyPolicy: ConcurrencyPolicyQueue}, true},
+		{"reject", Hook{MaxConcurrent: 1, ConcurrencyPolicy: ConcurrencyPolicyReject}, true},
+		{"cancel-previous", Hook{MaxConcurrent: 1, ConcurrencyPolicy: ConcurrencyPolicyCancelPrevious}, true},
+		{"unknown policy", Hook{ConcurrencyPolicy: "drop"}, false},
+		{"negative max-concurrent", Hook{MaxConcurrent: -1}, false},
+		{"negative timeout", Hook{Timeout: Duration(-time.Second)}, false},
+		{"serialize-by", Hook{SerializeBy: &Argument{Source: SourcePayload, Name: "repository.full_name"}}, true},
+		{"serialize-by without source", Hook{SerializeBy: &Argument{Name: "ref"}}, false},
+		{"debounce", Hook{Debounce: Duration(5 * time.Second)}, true},
+		{"negative debounce", Hook{Debounce: Duration(-time.Second)}, false},
+		{"debounce with streaming", Hook{Debounce: Duration(time.Second), StreamCommandOutput: true}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.h.ValidateConcurrency()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: unexpected error state: %v", tt.name, err)
+		}
+	}
+}
+
+func TestLoadHookWithConcurrency(t *testing.T) {
+	var hooks Hooks
+	data := []byte(`[{"id": "backup", "execute-command": "/bin/true", "timeout": "15m", "max-concurrent": 1, "concurrency-policy": "reject", "serialize-by": {"source": "payload", "name": "repository.full_name"}, "debounce": "10s"}]`)
+	if err := json.Unmarshal(data, &hooks); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	h := hooks[0]
+	if h.Timeout.Duration() != 15*time.Minute || h.MaxConcurrent != 1 || h.ConcurrencyPolicy != ConcurrencyPolicyReject {
+		t.Errorf("unexpected concurrency config: timeout=%v max-concurrent=%d policy=%q", h.Timeout.Duration(), h.MaxConcurrent, h.ConcurrencyPolicy)
+	}
+	if h.SerializeBy == nil || h.SerializeBy.Name != "repository.full_name" || h.Debounce.Duration() != 10*time.Second {
+		t.Errorf("unexpected serialization config: serialize-by=%+v debounce=%v", h.SerializeBy, h.Debounce.Duration())
+	}
+}
@@ -0,0 +1,125 @@
+package hook
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Constants used to specify which body signature rules verify
+const (
+	SignatureBodyDecompressed string = "decompressed"
+	SignatureBodyCompressed   string = "compressed"
+)
+
+// ContentEncodingError describes a Content-Encoding that cannot be decoded.
+type ContentEncodingError struct {
+	Encoding string
+}
+
+func (e *ContentEncodingError) Error() string {
+	if e == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("unsupported content encoding %q", e.Encoding)
+}
+
+// BodyTooLargeError describes a decoded body exceeding the size limit.
+type BodyTooLargeError struct {
+	Limit int64
+}
+
+func (e *BodyTooLargeError) Error() string {
+	if e == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("decompressed request body exceeds %d bytes", e.Limit)
+}
+
+// DecodeBody removes the content codings listed in the Content-Encoding
+// header, gzip, br and deflate, from the body. The decoded body is limited
+// to limit bytes so that small compressed requests cannot exhaust memory.
+// The body as received is kept in CompressedBody.
+func (r *Request) DecodeBody(contentEncoding string, limit int64) error {
+	var codings []string
+	for _, coding := range strings.Split(contentEncoding, ",") {
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "" && coding != "identity" {
+			codings = append(codings, coding)
+		}
+	}
+	if len(codings) == 0 {
+		return nil
+	}
+
+	// codings are listed in the order they were applied
+	body := r.Body
+	for i := len(codings) - 1; i >= 0; i-- {
+		decoded, err := decodeContent(codings[i], body, limit)
+		if err != nil {
+			return err
+		}
+		body = decoded
+	}
+
+	r.CompressedBody = r.Body
+	r.Body = body
+	return nil
+}
+
+func decodeContent(coding string, body []byte, limit int64) ([]byte, error) {
+	var reader io.ReadCloser
+
+	switch coding {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding gzip body: %w", err)
+		}
+		reader = zr
+
+	case "br":
+		reader = io.NopCloser(brotli.NewReader(bytes.NewReader(body)))
+
+	case "deflate":
+		// deflate is zlib wrapped, but some senders use raw deflate
+		zr, err := zlib.NewReader(bytes.NewReader(body))
+		if errors.Is(err, zlib.ErrHeader) {
+			zr, err = flate.NewReader(bytes.NewReader(body)), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error decoding deflate body: %w", err)
+		}
+		reader = zr
+
+	default:
+		return nil, &ContentEncodingError{coding}
+	}
+	defer reader.Close()
+
+	decoded, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s body: %w", coding, err)
+	}
+	if int64(len(decoded)) > limit {
+		return nil, &BodyTooLargeError{limit}
+	}
+	return decoded, nil
+}
+
+// SignedBody returns the body verified by signature rules: the body as
+// received for hooks with signature-body set to compressed, the decoded
+// body otherwise.
+func (r *Request) SignedBody() []byte {
+	if r.VerifyCompressedBody && r.CompressedBody != nil {
+		return r.CompressedBody
+	}
+	return r.Body
+}
@@ -0,0 +1,142 @@
+package hook
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func compressTestBody(t *testing.T, coding string, body []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	default:
+		t.Fatalf("unknown coding %q", coding)
+	}
+	if _, err := w.Write(body); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRequestDecodeBody(t *testing.T) {
+	payload := []byte(`{"ref": "refs/heads/main"}`)
+	bomb := bytes.Repeat([]byte{0}, 1<<20)
+
+	tests := []struct {
+		description string
+		encoding    string
+		body        []byte
+		limit       int64
+		want        []byte
+		wantErr     func(error) bool
+	}{
+		{"none", "", payload, 1024, payload, nil},
+		{"identity", "identity", payload, 1024, payload, nil},
+		{"gzip", "gzip", compressTestBody(t, "gzip", payload), 1024, payload, nil},
+		{"x-gzip", "X-GZIP", compressTestBody(t, "gzip", payload), 1024, payload, nil},
+		{"brotli", "br", compressTestBody(t, "br", payload), 1024, payload, nil},
+		{"deflate", "deflate", compressTestBody(t, "deflate", payload), 1024, payload, nil},
+		{"raw deflate", "deflate", compressTestBody(t, "raw-deflate", payload), 1024, payload, nil},
+		{"stacked", "deflate, br", compressTestBody(t, "br", compressTestBody(t, "deflate", payload)), 1024, payload, nil},
+		{"exact limit", "gzip", compressTestBody(t, "gzip", payload), int64(len(payload)), payload, nil},
+		{
+			"bomb", "gzip", compressTestBody(t, "gzip", bomb), 1024, nil,
+			func(err error) bool { e, ok := err.(*BodyTooLargeError); return ok && e.Limit == 1024 },
+		},
+		{
+			"unsupported", "compress", payload, 1024, nil,
+			func(err error) bool { e, ok := err.(*ContentEncodingError); return ok && e.Encoding == "compress" },
+		},
+		{"corrupt", "gzip", payload, 1024, nil, func(err error) bool { return err != nil }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			r := &Request{Body: tt.body}
+			err := r.DecodeBody(tt.encoding, tt.limit)
+			if tt.wantErr != nil {
+				if !tt.wantErr(err) {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if !bytes.Equal(r.Body, tt.body) || r.CompressedBody != nil {
+					t.Error("request modified after a decoding error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(r.Body, tt.want) {
+				t.Errorf("got body %q, want %q", r.Body, tt.want)
+			}
+			if tt.encoding != "" && tt.encoding != "identity" && !bytes.Equal(r.CompressedBody, tt.body) {
+				t.Error("compressed body not kept")
+			}
+		})
+	}
+}
+
+func TestRequestSignedBody(t *testing.T) {
+	payload := []byte(`{"ref": "refs/heads/main"}`)
+	compressed := compressTestBody(t, "gzip", payload)
+	secret := "secret"
+
+	tests := []struct {
+		description   string
+		verifyRaw     bool
+		signedPayload []byte
+		ok            bool
+	}{
+		{"decompressed", false, payload, true},
+		{"decompressed mismatch", false, compressed, false},
+		{"compressed", true, compressed, true},
+		{"compressed mismatch", true, payload, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			r := &Request{Body: compressed, VerifyCompressedBody: tt.verifyRaw}
+			if err := r.DecodeBody("gzip", 1024); err != nil {
+				t.Fatal(err)
+			}
+			r.ParseHeaders(map[string][]string{
+				"X-Hub-Signature-256": {"sha256=" + hmacSHA256Hex(secret, string(tt.signedPayload))},
+			})
+
+			ok, err := CheckGitHubSignature(r, secret)
+			if ok != tt.ok || (err == nil) != tt.ok {
+				t.Errorf("got ok=%v err=%v, want ok=%v", ok, err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestHookValidateSignatureBody(t *testing.T) {
+	for _, value := range []string{"", SignatureBodyDecompressed, SignatureBodyCompressed} {
+		if err := (&Hook{SignatureBody: value}).Validate(); err != nil {
+			t.Errorf("signature-body %q: %v", value, err)
+		}
+	}
+	if err := (&Hook{SignatureBody: "raw"}).Validate(); err == nil {
+		t.Error("expected an error for an unsupported signature-body")
+	}
+}
@@ -0,0 +1,790 @@
+package hook
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/soulteary/webhook/internal/logger"
+)
+
+// exprMaxSteps limits the number of nodes evaluated by one expression, so that
+// nested exists and all macros over large payloads stay cheap.
+const exprMaxSteps = 100000
+
+// ExprRule evaluates an expression over the request, for example
+//
+//	payload.ref == "refs/heads/main" && !(payload.pusher.name in ["bot"]) && size(payload.commits) < 100
+//
+// The expression has access to payload, headers, query and request and must
+// evaluate to a bool. It is compiled by Hooks.LoadFromFile; a rule built in
+// code is compiled on every evaluation.
+type ExprRule struct {
+	Source  string
+	program exprNode
+}
+
+// UnmarshalJSON reads the expression source from a JSON string.
+func (e *ExprRule) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &e.Source)
+}
+
+// MarshalJSON writes the expression source as a JSON string.
+func (e ExprRule) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.Source)
+}
+
+// Compile parses the expression, so that it is not parsed on every request.
+func (e *ExprRule) Compile() error {
+	program, err := compileExpr(e.Source)
+	if err != nil {
+		return fmt.Errorf("expr %q: %w", e.Source, err)
+	}
+	e.program = program
+	return nil
+}
+
+// Validate checks that the expression compiles.
+func (e *ExprRule) Validate() error {
+	if e.program != nil {
+		return nil
+	}
+	if _, err := compileExpr(e.Source); err != nil {
+		return fmt.Errorf("expr %q: %w", e.Source, err)
+	}
+	return nil
+}
+
+// Evaluate ExprRule will return true if the expression evaluates to true.
+// Referencing a missing payload, header or query value returns a
+// ParameterNodeError; other evaluation errors, such as comparing a string
+// with a number, make the rule evaluate to false.
+func (e *ExprRule) Evaluate(req *Request) (bool, error) {
+	program := e.program
+	if program == nil {
+		var err error
+		if program, err = compileExpr(e.Source); err != nil {
+			return false, fmt.Errorf("expr %q: %w", e.Source, err)
+		}
+	}
+
+	value, err := program.eval(newExprEnv(req))
+	if err != nil {
+		if IsParameterNodeError(err) {
+			return false, err
+		}
+		logger.Debugf("expr %q: %v", e.Source, err)
+		return false, nil
+	}
+	result, ok := value.(bool)
+	if !ok {
+		logger.Debugf("expr %q: result is %s, not bool", e.Source, exprTypeName(value))
+	}
+	return result, nil
+}
+
+// compileExpressions compiles the expressions of the rule tree.
+func (r *Rules) compileExpressions() error {
+	if r == nil {
+		return nil
+	}
+
+	var children []Rules
+	switch {
+	case r.And != nil:
+		children = *r.And
+	case r.Or != nil:
+		children = *r.Or
+	case r.Not != nil:
+		if err := (*Rules)(r.Not).compileExpressions(); err != nil {
+			return err
+		}
+	case r.Expr != nil:
+		return r.Expr.Compile()
+	}
+
+	for i := range children {
+		if err := children[i].compileExpressions(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exprHeaders is the headers variable; its keys are looked up case-insensitively.
+type exprHeaders map[string]interface{}
+
+// exprEnv holds the variables of one evaluation.
+type exprEnv struct {
+	vars  map[string]interface{}
+	steps int
+}
+
+func newExprEnv(req *Request) *exprEnv {
+	request := map[string]interface{}{}
+	if req == nil {
+		req = &Request{}
+	}
+	request["id"] = req.ID
+	request["content-type"] = req.ContentType
+	if raw := req.RawRequest; raw != nil {
+		request["method"] = raw.Method
+		request["remote-addr"] = raw.RemoteAddr
+		request["host"] = raw.Host
+		if raw.URL != nil {
+			request["path"] = raw.URL.Path
+		}
+	}
+
+	headers := exprHeaders{}
+	for k, v := range req.Headers {
+		headers[textproto.CanonicalMIMEHeaderKey(k)] = v
+	}
+
+	return &exprEnv{vars: map[string]interface{}{
+		"payload": orEmpty(req.Payload),
+		"headers": headers,
+		"query":   orEmpty(req.Query),
+		"request": request,
+	}}
+}
+
+func orEmpty(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return map[string]interface{}{}
+	}
+	return m
+}
+
+// step counts an evaluated node against exprMaxSteps.
+func (env *exprEnv) step() error {
+	env.steps++
+	if env.steps > exprMaxSteps {
+		return errors.New("expression exceeds evaluation limit")
+	}
+	return nil
+}
+
+// exprNode is a compiled expression. Values are nil, bool, float64, string,
+// time.Time, time.Duration, []interface{}, map[string]interface{} or
+// exprHeaders.
+type exprNode interface {
+	eval(env *exprEnv) (interface{}, error)
+}
+
+type exprLiteral struct {
+	value interface{}
+}
+
+func (n *exprLiteral) eval(env *exprEnv) (interface{}, error) {
+	return n.value, env.step()
+}
+
+type exprIdent struct {
+	name string
+}
+
+func (n *exprIdent) eval(env *exprEnv) (interface{}, error) {
+	return exprNormalize(env.vars[n.name]), env.step()
+}
+
+type exprList struct {
+	elems []exprNode
+}
+
+func (n *exprList) eval(env *exprEnv) (interface{}, error) {
+	list := make([]interface{}, 0, len(n.elems))
+	for _, elem := range n.elems {
+		v, err := elem.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, env.step()
+}
+
+type exprSelect struct {
+	operand exprNode
+	field   string
+}
+
+func (n *exprSelect) eval(env *exprEnv) (interface{}, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.step(); err != nil {
+		return nil, err
+	}
+	return exprField(v, n.field)
+}
+
+type exprIndex struct {
+	operand, index exprNode
+}
+
+func (n *exprIndex) eval(env *exprEnv) (interface{}, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	index, err := n.index.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.step(); err != nil {
+		return nil, err
+	}
+
+	if list, ok := v.([]interface{}); ok {
+		i, ok := index.(float64)
+		if !ok || i != math.Trunc(i) {
+			return nil, fmt.Errorf("list index must be an integer, got %s", exprTypeName(index))
+		}
+		if i < 0 || int(i) >= len(list) {
+			return nil, &ParameterNodeError{Key: strconv.Itoa(int(i))}
+		}
+		return exprNormalize(list[int(i)]), nil
+	}
+	key, ok := index.(string)
+	if !ok {
+		return nil, fmt.Errorf("cannot index %s with %s", exprTypeName(v), exprTypeName(index))
+	}
+	return exprField(v, key)
+}
+
+// exprField returns the value of key in the map v.
+func exprField(v interface{}, key string) (interface{}, error) {
+	var (
+		value interface{}
+		ok    bool
+	)
+	switch m := v.(type) {
+	case exprHeaders:
+		value, ok = m[textproto.CanonicalMIMEHeaderKey(key)]
+	case map[string]interface{}:
+		value, ok = m[key]
+	default:
+		return nil, fmt.Errorf("cannot select %q from %s", key, exprTypeName(v))
+	}
+	if !ok {
+		return nil, &ParameterNodeError{Key: key}
+	}
+	return exprNormalize(value), nil
+}
+
+type exprHas struct {
+	operand exprNode
+	field   string
+}
+
+func (n *exprHas) eval(env *exprEnv) (interface{}, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	_, err = exprField(v, n.field)
+	return err == nil, env.step()
+}
+
+type exprUnary struct {
+	op      string
+	operand exprNode
+}
+
+func (n *exprUnary) eval(env *exprEnv) (interface{}, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.step(); err != nil {
+		return nil, err
+	}
+	switch x := v.(type) {
+	case bool:
+		if n.op == "!" {
+			return !x, nil
+		}
+	case float64:
+		if n.op == "-" {
+			return -x, nil
+		}
+	case time.Duration:
+		if n.op == "-" {
+			return -x, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid operand %s for %s", exprTypeName(v), n.op)
+}
+
+type exprLogical struct {
+	and         bool
+	left, right exprNode
+}
+
+func (n *exprLogical) eval(env *exprEnv) (interface{}, error) {
+	left, err := exprBool(n.left, env)
+	if err != nil || left != n.and {
+		return left, err
+	}
+	return exprBool(n.right, env)
+}
+
+func exprBool(n exprNode, env *exprEnv) (bool, error) {
+	v, err := n.eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected bool, got %s", exprTypeName(v))
+	}
+	return b, nil
+}
+
+type exprCond struct {
+	cond, then, otherwise exprNode
+}
+
+func (n *exprCond) eval(env *exprEnv) (interface{}, error) {
+	cond, err := exprBool(n.cond, env)
+	if err != nil {
+		return nil, err
+	}
+	if cond {
+		return n.then.eval(env)
+	}
+	return n.otherwise.eval(env)
+}
+
+type exprBinary struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *exprBinary) eval(env *exprEnv) (interface{}, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.step(); err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return exprEqual(left, right), nil
+	case "!=":
+		return !exprEqual(left, right), nil
+	case "<", "<=", ">", ">=":
+		c, err := exprCompare(left, right)
+		if err != nil {
+			return nil, err
+		}
+		switch n.op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	case "in":
+		return exprIn(left, right)
+	}
+	return exprArithmetic(n.op, left, right)
+}
+
+type exprCall struct {
+	name string
+	args []exprNode
+	// re is the compiled pattern of matches with a literal pattern.
+	re *regexp.Regexp
+}
+
+func (n *exprCall) eval(env *exprEnv) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	if err := env.step(); err != nil {
+		return nil, err
+	}
+
+	switch n.name {
+	case "size":
+		switch v := args[0].(type) {
+		case string:
+			return float64(utf8.RuneCountInString(v)), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		case exprHeaders:
+			return float64(len(v)), nil
+		}
+	case "contains", "startsWith", "endsWith", "matches", "split":
+		s, ok1 := args[0].(string)
+		arg, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			break
+		}
+		switch n.name {
+		case "contains":
+			return strings.Contains(s, arg), nil
+		case "startsWith":
+			return strings.HasPrefix(s, arg), nil
+		case "endsWith":
+			return strings.HasSuffix(s, arg), nil
+		case "split":
+			parts := strings.Split(s, arg)
+			list := make([]interface{}, len(parts))
+			for i, p := range parts {
+				list[i] = p
+			}
+			return list, nil
+		}
+		re := n.re
+		if re == nil {
+			// patterns computed from the request are not cached, so that
+			// requests cannot grow the regex cache without bound
+			var err error
+			if re, err = regexp.Compile(arg); err != nil {
+				return nil, err
+			}
+		}
+		return re.MatchString(s), nil
+	case "lower", "upper", "trim":
+		s, ok := args[0].(string)
+		if !ok {
+			break
+		}
+		switch n.name {
+		case "lower":
+			return strings.ToLower(s), nil
+		case "upper":
+			return strings.ToUpper(s), nil
+		}
+		return strings.TrimSpace(s), nil
+	case "int":
+		switch v := args[0].(type) {
+		case float64:
+			return math.Trunc(v), nil
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("int: %w", err)
+			}
+			return math.Trunc(f), nil
+		case time.Time:
+			return float64(v.Unix()), nil
+		}
+	case "double":
+		switch v := args[0].(type) {
+		case float64:
+			return v, nil
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("double: %w", err)
+			}
+			return f, nil
+		}
+	case "string":
+		switch v := args[0].(type) {
+		case string:
+			return v, nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		case time.Time:
+			return v.UTC().Format(time.RFC3339Nano), nil
+		case time.Duration:
+			return v.String(), nil
+		}
+	case "timestamp":
+		switch v := args[0].(type) {
+		case string:
+			return parseReplayTimestamp(v)
+		case float64:
+			sec, frac := math.Modf(v)
+			return time.Unix(int64(sec), int64(frac*1e9)), nil
+		case time.Time:
+			return v, nil
+		}
+	case "duration":
+		switch v := args[0].(type) {
+		case string:
+			return time.ParseDuration(v)
+		case float64:
+			return time.Duration(v * float64(time.Second)), nil
+		}
+	case "now":
+		return time.Now(), nil
+	}
+
+	types := make([]string, len(args))
+	for i, arg := range args {
+		types[i] = exprTypeName(arg)
+	}
+	return nil, fmt.Errorf("no overload of %s for (%s)", n.name, strings.Join(types, ", "))
+}
+
+type exprComprehension struct {
+	target    exprNode
+	variable  string
+	predicate exprNode
+	all       bool
+}
+
+func (n *exprComprehension) eval(env *exprEnv) (interface{}, error) {
+	target, err := n.target.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	var elems []interface{}
+	switch v := target.(type) {
+	case []interface{}:
+		elems = v
+	case map[string]interface{}:
+		for k := range v {
+			elems = append(elems, k)
+		}
+	case exprHeaders:
+		for k := range v {
+			elems = append(elems, k)
+		}
+	default:
+		return nil, fmt.Errorf("cannot iterate over %s", exprTypeName(target))
+	}
+
+	saved, shadowed := env.vars[n.variable]
+	defer func() {
+		if shadowed {
+			env.vars[n.variable] = saved
+		} else {
+			delete(env.vars, n.variable)
+		}
+	}()
+
+	for _, elem := range elems {
+		env.vars[n.variable] = elem
+		ok, err := exprBool(n.predicate, env)
+		if err != nil {
+			return nil, err
+		}
+		if ok != n.all {
+			return ok, nil
+		}
+	}
+	return n.all, nil
+}
+
+// exprNormalize converts payload values to expression values.
+func exprNormalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case json.Number:
+		if f, err := x.Float64(); err == nil {
+			return f
+		}
+		return x.String()
+	case int:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case float32:
+		return float64(x)
+	case []string:
+		list := make([]interface{}, len(x))
+		for i, s := range x {
+			list[i] = s
+		}
+		return list
+	}
+	return v
+}
+
+func exprTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case time.Time:
+		return "timestamp"
+	case time.Duration:
+		return "duration"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}, exprHeaders:
+		return "map"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// exprEqual reports whether a and b are equal; values of different types are
+// not equal.
+func exprEqual(a, b interface{}) bool {
+	a, b = exprNormalize(a), exprNormalize(b)
+	switch x := a.(type) {
+	case time.Time:
+		y, ok := b.(time.Time)
+		return ok && x.Equal(y)
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !exprEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			if w, ok := y[k]; !ok || !exprEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case nil, bool, float64, string, time.Duration:
+		return a == b
+	}
+	return false
+}
+
+// exprCompare orders two numbers, strings, timestamps or durations.
+func exprCompare(a, b interface{}) (int, error) {
+	switch x := a.(type) {
+	case float64:
+		if y, ok := b.(float64); ok {
+			return cmp.Compare(x, y), nil
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return cmp.Compare(x, y), nil
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y), nil
+		}
+	case time.Duration:
+		if y, ok := b.(time.Duration); ok {
+			return cmp.Compare(x, y), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %s with %s", exprTypeName(a), exprTypeName(b))
+}
+
+// exprIn reports whether a is an element of the list b or a key of the map b.
+func exprIn(a, b interface{}) (bool, error) {
+	switch y := b.(type) {
+	case []interface{}:
+		for _, elem := range y {
+			if exprEqual(a, elem) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]interface{}, exprHeaders:
+		key, ok := a.(string)
+		if !ok {
+			return false, nil
+		}
+		_, err := exprField(y, key)
+		return err == nil, nil
+	}
+	return false, fmt.Errorf("cannot use in with %s", exprTypeName(b))
+}
+
+func exprArithmetic(op string, a, b interface{}) (interface{}, error) {
+	switch x := a.(type) {
+	case float64:
+		y, ok := b.(float64)
+		if !ok {
+			break
+		}
+		switch op {
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		case "/", "%":
+			if y == 0 {
+				return nil, errors.New("division by zero")
+			}
+			if op == "/" {
+				return x / y, nil
+			}
+			return math.Mod(x, y), nil
+		}
+	case string:
+		if y, ok := b.(string); ok && op == "+" {
+			return x + y, nil
+		}
+	case []interface{}:
+		if y, ok := b.([]interface{}); ok && op == "+" {
+			return append(append([]interface{}{}, x...), y...), nil
+		}
+	case time.Time:
+		switch y := b.(type) {
+		case time.Duration:
+			if op == "+" {
+				return x.Add(y), nil
+			}
+			if op == "-" {
+				return x.Add(-y), nil
+			}
+		case time.Time:
+			if op == "-" {
+				return x.Sub(y), nil
+			}
+		}
+	case time.Duration:
+		switch y := b.(type) {
+		case time.Duration:
+			if op == "+" {
+				return x + y, nil
+			}
+			if op == "-" {
+				return x - y, nil
+			}
+		case time.Time:
+			if op == "+" {
+				return y.Add(x), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid operands %s and %s for %s", exprTypeName(a), exprTypeName(b), op)
+}
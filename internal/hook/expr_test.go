@@ -0,0 +1,228 @@
+package hook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func exprTestRequest(t *testing.T) *Request {
+	t.Helper()
+	r := &Request{
+		ID:          "req-1",
+		ContentType: "application/json",
+		Body: []byte(`{
+			"ref": "refs/heads/main",
+			"size": 3,
+			"pusher": {"name": "alice"},
+			"commits": [
+				{"id": "a1", "author": {"name": "alice"}, "added": ["README.md"]},
+				{"id": "b2", "author": {"name": "bob"}, "added": []}
+			],
+			"head_commit": {"timestamp": "` + time.Now().Add(-10*time.Minute).UTC().Format(time.RFC3339) + `"}
+		}`),
+		Headers: map[string]interface{}{"X-Github-Event": "push"},
+		Query:   map[string]interface{}{"token": "abc"},
+		RawRequest: &http.Request{
+			Method:     http.MethodPost,
+			RemoteAddr: "10.0.0.1:1234",
+			Host:       "hooks.example.org",
+			URL:        &url.URL{Path: "/hooks/deploy"},
+		},
+	}
+	if err := r.ParseJSONPayload(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestExprRule(t *testing.T) {
+	r := exprTestRequest(t)
+
+	tests := []struct {
+		expr string
+		ok   bool
+	}{
+		{`payload.ref == "refs/heads/main"`, true},
+		{`payload.ref == 'refs/heads/dev'`, false},
+		{`payload.ref == "refs/heads/main" && !(payload.pusher.name in ["bot", "ci"]) && payload.size < 100`, true},
+		{`payload.size >= 3 && payload.size * 2 == 6 && payload.size % 2 == 1`, true},
+		{`-payload.size < 0 && payload.size / 2 == 1.5`, true},
+		{`payload.commits[1].author.name == "bob"`, true},
+		{`payload.commits.exists(c, c.author.name == "bob")`, true},
+		{`payload.commits.all(c, c.author.name == "alice")`, false},
+		{`payload.commits.exists(c, size(c.added) > 0 && c.added.exists(f, f.endsWith(".md")))`, true},
+		{`size(payload.commits) == 2 && payload.commits.size() == 2`, true},
+		{`headers["x-github-event"] == "push" && headers["X-GitHub-Event"] == "push"`, true},
+		{`"X-GitHub-Event" in headers && !("X-Other" in headers)`, true},
+		{`query.token == "abc"`, true},
+		{`request.method == "POST" && request.path.startsWith("/hooks/") && request["remote-addr"].startsWith("10.")`, true},
+		{`request.host == "hooks.example.org" && request.id == "req-1"`, true},
+		{`payload.ref.contains("heads") && payload.ref.matches("^refs/heads/(main|master)$")`, true},
+		{`matches(payload.ref, "^refs/tags/")`, false},
+		{`payload.ref.split("/")[2] == "main" && payload.pusher.name.upper() == "ALICE"`, true},
+		{`" Main ".trim().lower() == "main"`, true},
+		{`int("42") == 42 && double("1.5") == 1.5 && string(3) == "3" && string(true) == "true"`, true},
+		{`"ref" + "s" == "refs" && [1] + [2] == [1, 2]`, true},
+		{`now() - timestamp(payload.head_commit.timestamp) < duration("1h")`, true},
+		{`timestamp(payload.head_commit.timestamp) + duration("1h") > now()`, true},
+		{`timestamp(0) < timestamp("2024-01-01T00:00:00Z") && duration(60) == duration("1m")`, true},
+		{`has(payload.pusher.name) && !has(payload.pusher.email)`, true},
+		{`payload.size > 10 ? false : true`, true},
+		{`null == null && payload.ref != null`, true},
+		{`false && payload.missing == 1`, false},
+		{`true || payload.missing == 1`, true},
+		{`payload.ref == 1`, false},
+		{`payload.ref < 1`, false},
+		{`payload.ref`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			rule := Rules{Expr: &ExprRule{Source: tt.expr}}
+			if err := rule.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if err := rule.compileExpressions(); err != nil {
+				t.Fatal(err)
+			}
+			ok, err := rule.Evaluate(r)
+			if ok != tt.ok || err != nil {
+				t.Errorf("got ok=%v err=%v, want ok=%v", ok, err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestExprRuleMissingValue(t *testing.T) {
+	r := exprTestRequest(t)
+
+	for _, expr := range []string{
+		`payload.missing == "x"`,
+		`payload.commits[5].id == "x"`,
+		`headers["X-Missing"] == "x"`,
+	} {
+		rule := &ExprRule{Source: expr}
+		ok, err := rule.Evaluate(r)
+		if ok || !IsParameterNodeError(err) {
+			t.Errorf("%s: got ok=%v err=%v, want ParameterNodeError", expr, ok, err)
+		}
+	}
+
+	// A missing value in an or rule does not prevent other rules from matching.
+	rules := Rules{Or: &OrRule{
+		{Expr: &ExprRule{Source: `payload.missing == "x"`}},
+		{Expr: &ExprRule{Source: `payload.size == 3`}},
+	}}
+	if ok, err := rules.Evaluate(r); !ok || err != nil {
+		t.Errorf("got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestExprRuleEvaluationLimit(t *testing.T) {
+	items := make([]interface{}, 1000)
+	for i := range items {
+		items[i] = float64(i)
+	}
+	r := &Request{Payload: map[string]interface{}{"items": items}}
+
+	rule := &ExprRule{Source: `payload.items.all(a, payload.items.all(b, a >= 0))`}
+	if ok, err := rule.Evaluate(r); ok || err != nil {
+		t.Errorf("got ok=%v err=%v, want the evaluation limit to stop the rule", ok, err)
+	}
+}
+
+func TestExprRuleDynamicPattern(t *testing.T) {
+	r := &Request{Payload: map[string]interface{}{"ref": "refs/heads/main", "pattern": "^refs/heads/(dynamic)?main$"}}
+
+	rule := &ExprRule{Source: `payload.ref.matches(payload.pattern)`}
+	if ok, err := rule.Evaluate(r); !ok || err != nil {
+		t.Errorf("got ok=%v err=%v", ok, err)
+	}
+	if _, cached := regexCache.Load("^refs/heads/(dynamic)?main$"); cached {
+		t.Error("patterns from the request must not be cached")
+	}
+
+	r.Payload["pattern"] = "("
+	if ok, _ := rule.Evaluate(r); ok {
+		t.Error("an invalid pattern must not match")
+	}
+}
+
+func TestExprCompileErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{``, "empty expression"},
+		{`payload.ref ==`, "unexpected end of expression"},
+		{`payload.ref == "main`, "unterminated string"},
+		{`env.HOME == "/root"`, `undeclared reference "env"`},
+		{`exec("rm")`, `unknown function "exec"`},
+		{`payload.ref.size(1)`, "size expects 1 argument(s)"},
+		{`payload.ref.matches("(")`, "invalid regex"},
+		{`payload.items.exists(x, y > 1)`, `undeclared reference "y"`},
+		{`has(payload)`, "has expects a field selection"},
+		{`payload.ref # 1`, "unexpected character"},
+		{`(payload.ref == "main"`, `expected ")"`},
+		{`payload.ref == "main" payload`, `unexpected "payload"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			rules := Rules{Expr: &ExprRule{Source: tt.expr}}
+			err := rules.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got %v, want error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestExprRuleLoadFromFile(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "hooks.yaml")
+	content := `
+- id: deploy
+  execute-command: /bin/true
+  trigger-rule:
+    and:
+      - expr: payload.ref == "refs/heads/main"
+      - not:
+          expr: payload.pusher.name in ["bot"]
+`
+	if err := os.WriteFile(valid, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var hooks Hooks
+	if err := hooks.LoadFromFile(valid, false); err != nil {
+		t.Fatal(err)
+	}
+	rules := *hooks[0].TriggerRule.And
+	if rules[0].Expr.program == nil || rules[1].Not.Expr.program == nil {
+		t.Error("expected expressions to be compiled on load")
+	}
+	if ok, err := hooks[0].TriggerRule.Evaluate(exprTestRequest(t)); !ok || err != nil {
+		t.Errorf("got ok=%v err=%v", ok, err)
+	}
+
+	out, err := json.Marshal(rules[0])
+	if err != nil || string(out) != `{"expr":"payload.ref == \"refs/heads/main\""}` {
+		t.Errorf("got %s, %v", out, err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`[{"id": "deploy", "trigger-rule": {"expr": "payload.ref =="}}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Hooks{}).LoadFromFile(invalid, false); err == nil || !strings.Contains(err.Error(), "hook deploy") {
+		t.Errorf("expected compile error, got %v", err)
+	}
+}
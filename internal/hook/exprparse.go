@@ -0,0 +1,543 @@
+package hook
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// exprVariables are the variables available to every expression.
+var exprVariables = []string{"payload", "headers", "query", "request"}
+
+// exprFunction describes a function callable from an expression. Methods
+// receive their target as the first argument.
+type exprFunction struct {
+	arity  int
+	global bool
+	method bool
+}
+
+var exprFunctions = map[string]exprFunction{
+	"size":       {arity: 1, global: true, method: true},
+	"contains":   {arity: 2, method: true},
+	"startsWith": {arity: 2, method: true},
+	"endsWith":   {arity: 2, method: true},
+	"matches":    {arity: 2, global: true, method: true},
+	"lower":      {arity: 1, method: true},
+	"upper":      {arity: 1, method: true},
+	"trim":       {arity: 1, method: true},
+	"split":      {arity: 2, method: true},
+	"int":        {arity: 1, global: true},
+	"double":     {arity: 1, global: true},
+	"string":     {arity: 1, global: true},
+	"timestamp":  {arity: 1, global: true},
+	"duration":   {arity: 1, global: true},
+	"now":        {arity: 0, global: true},
+}
+
+type exprTokenKind int
+
+const (
+	exprTokenEOF exprTokenKind = iota
+	exprTokenNumber
+	exprTokenString
+	exprTokenIdent
+	exprTokenPunct
+)
+
+type exprToken struct {
+	kind  exprTokenKind
+	text  string
+	value interface{}
+	pos   int
+}
+
+// lexExpr splits src into tokens.
+func lexExpr(src string) ([]exprToken, error) {
+	var tokens []exprToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case isDigit(c) || (c == '.' && i+1 < len(src) && isDigit(src[i+1])):
+			start := i
+			for i < len(src) && isDigit(src[i]) {
+				i++
+			}
+			if i < len(src) && src[i] == '.' && i+1 < len(src) && isDigit(src[i+1]) {
+				i++
+				for i < len(src) && isDigit(src[i]) {
+					i++
+				}
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				j := i + 1
+				if j < len(src) && (src[j] == '+' || src[j] == '-') {
+					j++
+				}
+				if j < len(src) && isDigit(src[j]) {
+					for i = j; i < len(src) && isDigit(src[i]); i++ {
+					}
+				}
+			}
+			n, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at %d", src[start:i], start)
+			}
+			tokens = append(tokens, exprToken{kind: exprTokenNumber, text: src[start:i], value: n, pos: start})
+
+		case c == '"' || c == '\'':
+			s, end, err := lexExprString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, exprToken{kind: exprTokenString, text: src[i:end], value: s, pos: i})
+			i = end
+
+		case isIdentByte(c) && !isDigit(c):
+			start := i
+			for i < len(src) && isIdentByte(src[i]) {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: exprTokenIdent, text: src[start:i], pos: start})
+
+		default:
+			if i+1 < len(src) {
+				switch two := src[i : i+2]; two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					tokens = append(tokens, exprToken{kind: exprTokenPunct, text: two, pos: i})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("()[].,?:!-+*/%<>", rune(c)) {
+				r, _ := utf8.DecodeRuneInString(src[i:])
+				return nil, fmt.Errorf("unexpected character %q at %d", r, i)
+			}
+			tokens = append(tokens, exprToken{kind: exprTokenPunct, text: string(c), pos: i})
+			i++
+		}
+	}
+	return append(tokens, exprToken{kind: exprTokenEOF, pos: len(src)}), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// lexExprString reads the quoted string starting at src[start] and returns
+// its value and the position after the closing quote.
+func lexExprString(src string, start int) (string, int, error) {
+	quote := src[start]
+	var b strings.Builder
+	for i := start + 1; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\\' && i+1 < len(src):
+			i++
+			switch src[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '\\', '"', '\'':
+				b.WriteByte(src[i])
+			case 'u':
+				if i+4 >= len(src) {
+					return "", 0, fmt.Errorf("invalid unicode escape at %d", i-1)
+				}
+				r, err := strconv.ParseUint(src[i+1:i+5], 16, 32)
+				if err != nil {
+					return "", 0, fmt.Errorf("invalid unicode escape at %d", i-1)
+				}
+				b.WriteRune(rune(r))
+				i += 4
+			default:
+				return "", 0, fmt.Errorf("invalid escape \\%c at %d", src[i], i-1)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string at %d", start)
+}
+
+// exprParser is a recursive descent parser for the expression grammar,
+// from lowest to highest precedence:
+//
+//	ternary    = or [ "?" ternary ":" ternary ]
+//	or         = and { "||" and }
+//	and        = relation { "&&" relation }
+//	relation   = additive { ("==" | "!=" | "<" | "<=" | ">" | ">=" | "in") additive }
+//	additive   = multiplicative { ("+" | "-") multiplicative }
+//	multiplicative = unary { ("*" | "/" | "%") unary }
+//	unary      = ("!" | "-") unary | postfix
+//	postfix    = primary { "." ident [ "(" args ")" ] | "[" ternary "]" }
+//	primary    = number | string | "true" | "false" | "null" | ident [ "(" args ")" ]
+//	           | "(" ternary ")" | "[" [ ternary { "," ternary } ] "]"
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+	// scope holds the variables bound by the enclosing exists and all macros.
+	scope []string
+}
+
+// compileExpr parses src into an evaluable program.
+func compileExpr(src string) (exprNode, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, errors.New("empty expression")
+	}
+	tokens, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	node, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != exprTokenEOF {
+		return nil, p.unexpected(t)
+	}
+	return node, nil
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[p.pos]
+	if t.kind != exprTokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the punctuation or keyword text.
+func (p *exprParser) accept(text string) bool {
+	t := p.peek()
+	if (t.kind == exprTokenPunct || t.kind == exprTokenIdent) && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(text string) error {
+	if !p.accept(text) {
+		return fmt.Errorf("expected %q, %v", text, p.unexpected(p.peek()))
+	}
+	return nil
+}
+
+func (p *exprParser) unexpected(t exprToken) error {
+	if t.kind == exprTokenEOF {
+		return errors.New("unexpected end of expression")
+	}
+	return fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+func (p *exprParser) parseTernary() (exprNode, error) {
+	cond, err := p.parseOr()
+	if err != nil || !p.accept("?") {
+		return cond, err
+	}
+	then, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	return &exprCond{cond: cond, then: then, otherwise: otherwise}, nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.accept("||") {
+		var right exprNode
+		if right, err = p.parseAnd(); err == nil {
+			left = &exprLogical{and: false, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseRelation()
+	for err == nil && p.accept("&&") {
+		var right exprNode
+		if right, err = p.parseRelation(); err == nil {
+			left = &exprLogical{and: true, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *exprParser) parseRelation() (exprNode, error) {
+	return p.parseBinary(p.parseAdditive, "==", "!=", "<", "<=", ">", ">=", "in")
+}
+
+func (p *exprParser) parseAdditive() (exprNode, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *exprParser) parseMultiplicative() (exprNode, error) {
+	return p.parseBinary(p.parseUnary, "*", "/", "%")
+}
+
+// parseBinary parses left-associative binary operators of one precedence level.
+func (p *exprParser) parseBinary(operand func() (exprNode, error), ops ...string) (exprNode, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, candidate := range ops {
+			if p.accept(candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	for _, op := range []string{"!", "-"} {
+		if p.accept(op) {
+			operand, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			return &exprUnary{op: op, operand: operand}, nil
+		}
+	}
+	return p.parsePostfix()
+}
+
+func (p *exprParser) parsePostfix() (exprNode, error) {
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept("."):
+			t := p.next()
+			if t.kind != exprTokenIdent {
+				return nil, p.unexpected(t)
+			}
+			if !p.accept("(") {
+				node = &exprSelect{operand: node, field: t.text}
+				continue
+			}
+			if t.text == "exists" || t.text == "all" {
+				node, err = p.parseComprehension(node, t.text == "all")
+			} else {
+				node, err = p.parseCall(t, node)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+		case p.accept("["):
+			index, err := p.parseTernary()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			node = &exprIndex{operand: node, index: index}
+
+		default:
+			return node, nil
+		}
+	}
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case exprTokenNumber, exprTokenString:
+		return &exprLiteral{value: t.value}, nil
+
+	case exprTokenIdent:
+		switch t.text {
+		case "true":
+			return &exprLiteral{value: true}, nil
+		case "false":
+			return &exprLiteral{value: false}, nil
+		case "null":
+			return &exprLiteral{value: nil}, nil
+		case "in":
+			return nil, p.unexpected(t)
+		}
+		if p.accept("(") {
+			if t.text == "has" {
+				return p.parseHas()
+			}
+			return p.parseCall(t, nil)
+		}
+		for i := len(p.scope) - 1; i >= 0; i-- {
+			if p.scope[i] == t.text {
+				return &exprIdent{name: t.text}, nil
+			}
+		}
+		for _, name := range exprVariables {
+			if name == t.text {
+				return &exprIdent{name: t.text}, nil
+			}
+		}
+		return nil, fmt.Errorf("undeclared reference %q at %d", t.text, t.pos)
+
+	case exprTokenPunct:
+		switch t.text {
+		case "(":
+			node, err := p.parseTernary()
+			if err != nil {
+				return nil, err
+			}
+			return node, p.expect(")")
+		case "[":
+			list := &exprList{}
+			if p.accept("]") {
+				return list, nil
+			}
+			for {
+				elem, err := p.parseTernary()
+				if err != nil {
+					return nil, err
+				}
+				list.elems = append(list.elems, elem)
+				if p.accept("]") {
+					return list, nil
+				}
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return nil, p.unexpected(t)
+}
+
+// parseArgs parses call arguments after the opening parenthesis.
+func (p *exprParser) parseArgs() ([]exprNode, error) {
+	var args []exprNode
+	if p.accept(")") {
+		return args, nil
+	}
+	for {
+		arg, err := p.parseTernary()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.accept(")") {
+			return args, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseCall parses a global function call, or a method call on target.
+func (p *exprParser) parseCall(name exprToken, target exprNode) (exprNode, error) {
+	fn, ok := exprFunctions[name.text]
+	if !ok || (target == nil && !fn.global) || (target != nil && !fn.method) {
+		return nil, fmt.Errorf("unknown function %q at %d", name.text, name.pos)
+	}
+	args, err := p.parseArgs()
+	if err != nil {
+		return nil, err
+	}
+	if target != nil {
+		args = append([]exprNode{target}, args...)
+	}
+	if len(args) != fn.arity {
+		return nil, fmt.Errorf("%s expects %d argument(s) at %d", name.text, fn.arity, name.pos)
+	}
+
+	call := &exprCall{name: name.text, args: args}
+	if name.text != "matches" {
+		return call, nil
+	}
+	// Literal patterns are compiled once, so that invalid ones are reported here.
+	if pattern, ok := args[1].(*exprLiteral); ok {
+		s, isString := pattern.value.(string)
+		if !isString {
+			return nil, fmt.Errorf("matches expects a string pattern at %d", name.pos)
+		}
+		if call.re, err = regexp.Compile(s); err != nil {
+			return nil, fmt.Errorf("invalid regex at %d: %w", name.pos, err)
+		}
+	}
+	return call, nil
+}
+
+// parseHas parses the has(x.field) macro after the opening parenthesis.
+func (p *exprParser) parseHas() (exprNode, error) {
+	arg, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	sel, ok := arg.(*exprSelect)
+	if !ok {
+		return nil, errors.New("has expects a field selection such as payload.field")
+	}
+	return &exprHas{operand: sel.operand, field: sel.field}, nil
+}
+
+// parseComprehension parses the exists(x, predicate) and all(x, predicate)
+// macros after the opening parenthesis.
+func (p *exprParser) parseComprehension(target exprNode, all bool) (exprNode, error) {
+	v := p.next()
+	if v.kind != exprTokenIdent {
+		return nil, p.unexpected(v)
+	}
+	if err := p.expect(","); err != nil {
+		return nil, err
+	}
+	p.scope = append(p.scope, v.text)
+	predicate, err := p.parseTernary()
+	p.scope = p.scope[:len(p.scope)-1]
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return &exprComprehension{target: target, variable: v.text, predicate: predicate, all: all}, nil
+}
@@ -0,0 +1,238 @@
+package hook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultForwardTimeout is the timeout of a single upstream request when the
+// forward configuration does not specify one.
+const DefaultForwardTimeout = 30 * time.Second
+
+// ForwardConfig describes an outbound HTTP request performed by a hook
+// instead of executing a command. URL, header values and body are Go
+// text/templates rendered with the incoming request (see ForwardData).
+type ForwardConfig struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is the request body template. When empty the incoming body is
+	// forwarded unchanged.
+	Body    string            `json:"body,omitempty"`
+	Timeout Duration          `json:"timeout,omitempty"`
+	Retry   *RetryConfig      `json:"retry,omitempty"`
+	TLS     *ForwardTLSConfig `json:"tls,omitempty"`
+
+	// templates are parsed once when the hooks file is loaded
+	templates *forwardTemplates
+}
+
+// ForwardTLSConfig configures TLS for upstream requests.
+type ForwardTLSConfig struct {
+	// CACert is a PEM file with the CAs used to verify the upstream.
+	CACert string `json:"ca-cert,omitempty"`
+	// ClientCert and ClientKey are PEM files presented for mutual TLS.
+	ClientCert         string `json:"client-cert,omitempty"`
+	ClientKey          string `json:"client-key,omitempty"`
+	ServerName         string `json:"server-name,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure-skip-verify,omitempty"`
+}
+
+// ForwardData is the data available to forward templates.
+type ForwardData struct {
+	ID          string
+	ContentType string
+	Body        string
+	Headers     map[string]interface{}
+	Query       map[string]interface{}
+	Payload     map[string]interface{}
+}
+
+// ForwardRequest is a rendered upstream request.
+type ForwardRequest struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+var forwardFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func parseForwardTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(forwardFuncs).Option("missingkey=error").Parse(text)
+}
+
+// forwardTemplates holds the parsed url, header and body templates of a
+// forward configuration.
+type forwardTemplates struct {
+	url     *template.Template
+	headers map[string]*template.Template
+	// body is nil when the incoming body is forwarded unchanged.
+	body *template.Template
+}
+
+func parseForwardTemplates(f *ForwardConfig) (*forwardTemplates, error) {
+	t := &forwardTemplates{headers: make(map[string]*template.Template, len(f.Headers))}
+
+	var err error
+	if t.url, err = parseForwardTemplate("url", f.URL); err != nil {
+		return nil, fmt.Errorf("invalid forward url template: %w", err)
+	}
+	for name, value := range f.Headers {
+		if t.headers[name], err = parseForwardTemplate("header "+name, value); err != nil {
+			return nil, fmt.Errorf("invalid forward header %s template: %w", name, err)
+		}
+	}
+	if f.Body != "" {
+		if t.body, err = parseForwardTemplate("body", f.Body); err != nil {
+			return nil, fmt.Errorf("invalid forward body template: %w", err)
+		}
+	}
+
+	return t, nil
+}
+
+// Compile parses the forward templates once, so that requests are rendered
+// without parsing them again.
+func (f *ForwardConfig) Compile() error {
+	if f == nil {
+		return nil
+	}
+	t, err := parseForwardTemplates(f)
+	if err != nil {
+		return err
+	}
+	f.templates = t
+	return nil
+}
+
+func (f *ForwardConfig) compiled() (*forwardTemplates, error) {
+	if f.templates != nil {
+		return f.templates, nil
+	}
+	// the hook was not loaded from a hooks file
+	return parseForwardTemplates(f)
+}
+
+func renderForwardTemplate(name, text string, data *ForwardData) (string, error) {
+	t, err := parseForwardTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+	return executeForwardTemplate(t, data)
+}
+
+func executeForwardTemplate(t *template.Template, data *ForwardData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RequestTimeout returns the timeout of a single upstream request.
+func (f *ForwardConfig) RequestTimeout() time.Duration {
+	if f.Timeout > 0 {
+		return f.Timeout.Duration()
+	}
+	return DefaultForwardTimeout
+}
+
+// Render renders the upstream request for r.
+func (f *ForwardConfig) Render(r *Request) (*ForwardRequest, error) {
+	data := &ForwardData{
+		ID:          r.ID,
+		ContentType: r.ContentType,
+		Body:        string(r.Body),
+		Headers:     r.Headers,
+		Query:       r.Query,
+		Payload:     r.Payload,
+	}
+
+	t, err := f.compiled()
+	if err != nil {
+		return nil, err
+	}
+
+	rawURL, err := executeForwardTemplate(t.url, data)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering forward url: %w", err)
+	}
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("invalid forward url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid forward url %q: scheme must be http or https", u.Redacted())
+	}
+
+	fr := &ForwardRequest{
+		Method: f.method(),
+		URL:    u.String(),
+		Header: make(http.Header),
+	}
+
+	for name, value := range t.headers {
+		rendered, err := executeForwardTemplate(value, data)
+		if err != nil {
+			return nil, fmt.Errorf("error rendering forward header %s: %w", name, err)
+		}
+		fr.Header.Set(name, rendered)
+	}
+
+	if t.body == nil {
+		fr.Body = r.Body
+		if r.ContentType != "" && fr.Header.Get("Content-Type") == "" {
+			fr.Header.Set("Content-Type", r.ContentType)
+		}
+	} else {
+		body, err := executeForwardTemplate(t.body, data)
+		if err != nil {
+			return nil, fmt.Errorf("error rendering forward body: %w", err)
+		}
+		fr.Body = []byte(body)
+	}
+
+	return fr, nil
+}
+
+func (f *ForwardConfig) method() string {
+	if f.Method == "" {
+		return http.MethodPost
+	}
+	return strings.ToUpper(f.Method)
+}
+
+// Validate checks the forward configuration and its templates.
+func (f *ForwardConfig) Validate() error {
+	if f == nil {
+		return nil
+	}
+	if f.URL == "" {
+		return errors.New("forward url is required")
+	}
+	if f.templates == nil {
+		if _, err := parseForwardTemplates(f); err != nil {
+			return err
+		}
+	}
+	if f.Timeout < 0 {
+		return errors.New("forward timeout must be >= 0")
+	}
+	if tls := f.TLS; tls != nil && (tls.ClientCert == "") != (tls.ClientKey == "") {
+		return errors.New("forward tls client-cert and client-key must be set together")
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package hook
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestForwardRender(t *testing.T) {
+	r := &Request{
+		ID:          "req-1",
+		ContentType: "application/json",
+		Body:        []byte(`{"ref": "main", "repository": {"name": "web"}}`),
+		Headers:     map[string]interface{}{"X-Event": "push"},
+		Query:       map[string]interface{}{"env": "prod"},
+	}
+	if err := r.ParseJSONPayload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		f      *ForwardConfig
+		method string
+		url    string
+		header http.Header
+		body   string
+		ok     bool
+	}{
+		{
+			name:   "templated request",
+			f:      &ForwardConfig{URL: "https://deploy.example.com/{{ .Query.env }}/{{ .Payload.repository.name }}", Method: "put", Headers: map[string]string{"X-Request-Id": "{{ .ID }}", "X-Event": `{{ index .Headers "X-Event" }}`}, Body: `{"ref": {{ json .Payload.ref }}}`},
+			method: http.MethodPut,
+			url:    "https://deploy.example.com/prod/web",
+			header: http.Header{"X-Request-Id": []string{"req-1"}, "X-Event": []string{"push"}},
+			body:   `{"ref": "main"}`,
+			ok:     true,
+		},
+		{
+			name:   "forwards original body",
+			f:      &ForwardConfig{URL: "http://127.0.0.1:8080/hook"},
+			method: http.MethodPost,
+			url:    "http://127.0.0.1:8080/hook",
+			header: http.Header{"Content-Type": []string{"application/json"}},
+			body:   string(r.Body),
+			ok:     true,
+		},
+		{name: "missing key", f: &ForwardConfig{URL: "https://example.com/{{ .Payload.missing }}"}},
+		{name: "unsupported scheme", f: &ForwardConfig{URL: "file:///etc/passwd"}},
+	}
+
+	for _, tt := range tests {
+		fr, err := tt.f.Render(r)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: unexpected error state: %v", tt.name, err)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if fr.Method != tt.method || fr.URL != tt.url || string(fr.Body) != tt.body {
+			t.Errorf("%s: got %s %s %q", tt.name, fr.Method, fr.URL, fr.Body)
+		}
+		for name := range tt.header {
+			if fr.Header.Get(name) != tt.header.Get(name) {
+				t.Errorf("%s: header %s: got %q, want %q", tt.name, name, fr.Header.Get(name), tt.header.Get(name))
+			}
+		}
+	}
+}
+
+func TestForwardCompile(t *testing.T) {
+	f := &ForwardConfig{URL: "https://example.com/{{ .ID }}", Headers: map[string]string{"X-Id": "{{ .ID }}"}}
+	if err := f.Compile(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the compiled templates are used for every request
+	f.URL = "{{ .Payload"
+	fr, err := f.Render(&Request{ID: "req-1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fr.URL != "https://example.com/req-1" || fr.Header.Get("X-Id") != "req-1" {
+		t.Errorf("unexpected request: %s %v", fr.URL, fr.Header)
+	}
+
+	if err := (&ForwardConfig{URL: "https://example.com", Headers: map[string]string{"X-Id": "{{ .ID"}}).Compile(); err == nil {
+		t.Error("expected error for invalid header template")
+	}
+}
+
+func TestForwardValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		h    Hook
+		ok   bool
+	}{
+		{"no forward", Hook{ExecuteCommand: "/bin/true"}, true},
+		{"valid", Hook{Forward: &ForwardConfig{URL: "https://example.com/{{ .ID }}", Timeout: Duration(time.Second), TLS: &ForwardTLSConfig{ClientCert: "c.pem", ClientKey: "k.pem"}}}, true},
+		{"missing url", Hook{Forward: &ForwardConfig{}}, false},
+		{"combined with command", Hook{ExecuteCommand: "/bin/true", Forward: &ForwardConfig{URL: "https://example.com"}}, false},
+		{"invalid template", Hook{Forward: &ForwardConfig{URL: "https://example.com", Body: "{{ .Payload"}}, false},
+		{"client cert without key", Hook{Forward: &ForwardConfig{URL: "https://example.com", TLS: &ForwardTLSConfig{ClientCert: "c.pem"}}}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.h.Validate()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: unexpected error state: %v", tt.name, err)
+		}
+	}
+}
+
+func TestLoadHookWithForward(t *testing.T) {
+	var hooks Hooks
+	data := []byte(`[{"id": "notify", "forward": {"url": "https://example.com", "method": "PATCH", "timeout": "5s", "retry": {"max-attempts": 3}, "tls": {"ca-cert": "ca.pem"}}}]`)
+	if err := json.Unmarshal(data, &hooks); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	f := hooks[0].Forward
+	if f == nil {
+		t.Fatal("expected forward to be set")
+	}
+	if f.method() != http.MethodPatch || f.RequestTimeout() != 5*time.Second || f.Retry.Attempts() != 3 || f.TLS.CACert != "ca.pem" {
+		t.Errorf("unexpected forward config: %+v", f)
+	}
+	if (&ForwardConfig{}).RequestTimeout() != DefaultForwardTimeout {
+		t.Error("expected default timeout")
+	}
+}
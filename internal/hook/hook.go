@@ -36,6 +36,9 @@ const (
 	SourceEntirePayload  string = "entire-payload"
 	SourceEntireQuery    string = "entire-query"
 	SourceEntireHeaders  string = "entire-headers"
+	SourcePreviousResult string = "previous-result"
+	SourceJWTClaim       string = "jwt-claim"
+	SourceCloudEvent     string = "cloudevent"
 )
 
 const (
@@ -110,7 +113,17 @@ func (e *ArgumentError) Error() string {
 	if e == nil {
 		return "<nil>"
 	}
-	return fmt.Sprintf("couldn't retrieve argument for %+v", e.Argument)
+	return fmt.Sprintf("couldn't retrieve argument for %s %s", e.Argument.Source, e.Argument.Name)
+}
+
+// IsArgumentError returns whether err is of type ArgumentError.
+func IsArgumentError(err error) bool {
+	switch err.(type) {
+	case *ArgumentError:
+		return true
+	default:
+		return false
+	}
 }
 
 // SourceError describes an invalid source passed to Hook.
@@ -122,7 +135,7 @@ func (e *SourceError) Error() string {
 	if e == nil {
 		return "<nil>"
 	}
-	return fmt.Sprintf("invalid source for argument %+v", e.Argument)
+	return fmt.Sprintf("invalid source %q for argument %s", e.Argument.Source, e.Argument.Name)
 }
 
 // ParseError describes an error parsing user input.
@@ -260,7 +273,7 @@ func CheckMSTeamsSignature(r *Request, signingKey string) (bool, error) {
 
 	// Use secure-kit for HMAC verification with base64 encoding
 	verifier := secure.NewHMACVerifierFromBytes(secure.HMACSHA256, secret)
-	if !verifier.VerifyBase64(r.Body, providedSignature) {
+	if !verifier.VerifyBase64(r.SignedBody(), providedSignature) {
 		return false, &SignatureError{Signature: providedSignature}
 	}
 	return true, nil
@@ -288,7 +301,7 @@ func CheckScalrSignature(r *Request, signingKey string, checkDate bool) (bool, e
 	dateHeader := r.Headers["Date"].(string)
 
 	// Scalr combines body + date for signature, use secure-kit for HMAC
-	combinedPayload := append(r.Body, []byte(dateHeader)...)
+	combinedPayload := append(r.SignedBody(), []byte(dateHeader)...)
 	expectedSignature := secure.ComputeHMACSHA1(combinedPayload, signingKey)
 
 	// Use constant-time comparison from secure-kit
@@ -469,6 +482,12 @@ func ExtractParameterAsString(s string, params interface{}) (string, error) {
 		return "", err
 	}
 
+	return parameterString(pValue)
+}
+
+// parameterString renders a parameter value as string, complex data types as
+// JSON.
+func parameterString(pValue interface{}) (string, error) {
 	switch v := reflect.ValueOf(pValue); v.Kind() {
 	case reflect.Array, reflect.Map, reflect.Slice:
 		r, err := json.Marshal(pValue)
@@ -490,11 +509,59 @@ type Argument struct {
 	Name         string `json:"name,omitempty"`
 	EnvName      string `json:"envname,omitempty"`
 	Base64Decode bool   `json:"base64decode,omitempty"`
+	// Syntax makes Name a query instead of a dotted path; only
+	// ArgumentSyntaxJSONPath is supported.
+	Syntax string `json:"syntax,omitempty"`
+	// Separator joins the values selected by a query; without it they are
+	// rendered as a JSON array.
+	Separator string `json:"separator,omitempty"`
+	// Transform is applied to the value in order.
+	Transform []ArgumentTransform `json:"transform,omitempty"`
+	// Required makes the hook reject requests missing the argument instead
+	// of passing an empty value to the command.
+	Required bool `json:"required,omitempty"`
+}
+
+// Validate checks the query settings and transforms of the argument.
+func (ha *Argument) Validate() error {
+	for i := range ha.Transform {
+		if err := ha.Transform[i].Validate(); err != nil {
+			return fmt.Errorf("argument %q: %w", ha.Name, err)
+		}
+	}
+
+	switch ha.Syntax {
+	case "":
+		if ha.Separator != "" {
+			return fmt.Errorf("argument %q: separator requires syntax", ha.Name)
+		}
+		return nil
+	case ArgumentSyntaxJSONPath:
+	default:
+		return fmt.Errorf("argument %q: unsupported syntax %q", ha.Name, ha.Syntax)
+	}
+
+	switch ha.Source {
+	case SourceHeader, SourceQuery, SourceQueryAlias, SourcePayload, SourceJWTClaim, SourceCloudEvent:
+	default:
+		return fmt.Errorf("argument %q: syntax %s is not supported for source %q", ha.Name, ha.Syntax, ha.Source)
+	}
+	_, err := compileJSONPath(ha.Source, ha.Name)
+	return err
 }
 
 // Get Argument method returns the value for the Argument's key name
 // based on the Argument's source
 func (ha *Argument) Get(r *Request) (string, error) {
+	value, err := ha.get(r)
+	if len(ha.Transform) > 0 {
+		return ha.applyTransforms(value, err)
+	}
+	return value, err
+}
+
+// get returns the untransformed value of the argument.
+func (ha *Argument) get(r *Request) (string, error) {
 	var source *map[string]interface{}
 	key := ha.Name
 
@@ -526,9 +593,21 @@ func (ha *Argument) Get(r *Request) (string, error) {
 		case "method":
 			return r.RawRequest.Method, nil
 		default:
+			if v, ok, err := clientCertValue(r, strings.ToLower(ha.Name)); ok {
+				return v, err
+			}
 			return "", fmt.Errorf("unsupported request key: %q", ha.Name)
 		}
 
+	case SourcePreviousResult:
+		return r.Previous.Get(ha.Name)
+
+	case SourceJWTClaim:
+		source = &r.JWTClaims
+
+	case SourceCloudEvent:
+		source = &r.CloudEvent
+
 	case SourceEntirePayload:
 		res, err := json.Marshal(&r.Payload)
 		if err != nil {
@@ -555,6 +634,9 @@ func (ha *Argument) Get(r *Request) (string, error) {
 	}
 
 	if source != nil {
+		if ha.Syntax == ArgumentSyntaxJSONPath {
+			return ha.query(*source)
+		}
 		return ExtractParameterAsString(key, *source)
 	}
 
@@ -634,6 +716,106 @@ type Hook struct {
 	IncomingPayloadContentType          string          `json:"incoming-payload-content-type,omitempty"`
 	SuccessHttpResponseCode             int             `json:"success-http-response-code,omitempty"`
 	HTTPMethods                         []string        `json:"http-methods"`
+	Retry                               *RetryConfig    `json:"retry,omitempty"`
+	Timeout                             Duration        `json:"timeout,omitempty"`
+	MaxConcurrent                       int             `json:"max-concurrent,omitempty"`
+	ConcurrencyPolicy                   string          `json:"concurrency-policy,omitempty"`
+	SerializeBy                         *Argument       `json:"serialize-by,omitempty"`
+	Debounce                            Duration        `json:"debounce,omitempty"`
+	KillSignal                          string          `json:"kill-signal,omitempty"`
+	KillGracePeriod                     Duration        `json:"kill-grace-period,omitempty"`
+	Sandbox                             *SandboxConfig  `json:"sandbox,omitempty"`
+	CommandResponse                     string          `json:"command-response,omitempty"`
+	ExitCodeMap                         map[int]int     `json:"exit-code-map,omitempty"`
+	Forward                             *ForwardConfig  `json:"forward,omitempty"`
+	Actions                             []Action        `json:"actions,omitempty"`
+	ActionsMode                         string          `json:"actions-mode,omitempty"`
+	OnSuccess                           []string        `json:"on-success,omitempty"`
+	OnFailure                           []string        `json:"on-failure,omitempty"`
+	OnTimeout                           []string        `json:"on-timeout,omitempty"`
+	PayloadSchema                       *PayloadSchema  `json:"payload-schema,omitempty"`
+	Protobuf                            *ProtobufConfig `json:"protobuf,omitempty"`
+	SignatureBody                       string          `json:"signature-body,omitempty"`
+
+	responseTemplates *responseTemplates
+}
+
+// Validate checks the execution related settings of the hook.
+func (h *Hook) Validate() error {
+	if err := h.ValidateConcurrency(); err != nil {
+		return err
+	}
+	if err := h.ValidateTermination(); err != nil {
+		return err
+	}
+	if err := h.ValidateCommandResponse(); err != nil {
+		return err
+	}
+	if h.Forward != nil && h.ExecuteCommand != "" {
+		return errors.New("execute-command and forward cannot be used together")
+	}
+	if h.Forward != nil && h.StreamCommandOutput {
+		return errors.New("forward cannot be combined with stream-command-output")
+	}
+	if err := h.Forward.Validate(); err != nil {
+		return err
+	}
+	if err := h.ValidateActions(); err != nil {
+		return err
+	}
+	if err := h.ValidateArguments(); err != nil {
+		return err
+	}
+	if err := h.TriggerRule.Validate(); err != nil {
+		return err
+	}
+	if err := h.PayloadSchema.Validate(); err != nil {
+		return err
+	}
+	if err := h.Protobuf.Validate(); err != nil {
+		return err
+	}
+	switch h.SignatureBody {
+	case "", SignatureBodyDecompressed, SignatureBodyCompressed:
+	default:
+		return fmt.Errorf("unsupported signature-body %q", h.SignatureBody)
+	}
+	if err := h.ValidateResponseTemplates(); err != nil {
+		return err
+	}
+	return h.Sandbox.Validate()
+}
+
+// ValidateArguments checks the arguments passed to the command and the JSON
+// string parameters.
+func (h *Hook) ValidateArguments() error {
+	for _, list := range [][]Argument{h.PassArgumentsToCommand, h.PassEnvironmentToCommand, h.PassFileToCommand, h.JSONStringParameters} {
+		for i := range list {
+			if err := list[i].Validate(); err != nil {
+				return err
+			}
+		}
+	}
+	if h.hasSyntaxWithoutEnvName(h.PassEnvironmentToCommand) || h.hasSyntaxWithoutEnvName(h.PassFileToCommand) {
+		return errors.New("jsonpath arguments passed as environment or file require envname")
+	}
+	for _, arg := range h.JSONStringParameters {
+		if arg.Syntax != "" {
+			return fmt.Errorf("parse-parameters-as-json argument %q does not support syntax", arg.Name)
+		}
+	}
+	return nil
+}
+
+// hasSyntaxWithoutEnvName reports whether an argument using a syntax, such as
+// jsonpath, has no envname to derive the variable or file name from.
+func (h *Hook) hasSyntaxWithoutEnvName(args []Argument) bool {
+	for _, arg := range args {
+		if arg.Syntax != "" && arg.EnvName == "" {
+			return true
+		}
+	}
+	return false
 }
 
 // ParseJSONParameters decodes specified arguments to JSON objects and replaces the
@@ -684,6 +866,22 @@ func (h *Hook) ParseJSONParameters(r *Request) []error {
 	return errs
 }
 
+// CheckRequiredArguments returns an ArgumentError for the first required
+// argument passed to the command that cannot be retrieved.
+func (h *Hook) CheckRequiredArguments(r *Request) error {
+	for _, list := range [][]Argument{h.PassArgumentsToCommand, h.PassEnvironmentToCommand, h.PassFileToCommand} {
+		for i := range list {
+			if !list[i].Required {
+				continue
+			}
+			if _, err := list[i].Get(r); err != nil {
+				return &ArgumentError{list[i]}
+			}
+		}
+	}
+	return nil
+}
+
 // ExtractCommandArguments creates a list of arguments, based on the
 // PassArgumentsToCommand property that is ready to be used with exec.Command()
 func (h *Hook) ExtractCommandArguments(r *Request) ([]string, []error) {
@@ -821,6 +1019,54 @@ func (h *Hooks) LoadFromFile(path string, asTemplate bool) error {
 		(*h)[i].SanitizeHTTPMethods()
 	}
 
+	// 预先编译触发规则中的表达式
+	for i := range *h {
+		if err := (*h)[i].TriggerRule.compileExpressions(); err != nil {
+			return fmt.Errorf("hook %s: %w", (*h)[i].ID, err)
+		}
+	}
+
+	// 预先解析响应内容与响应头模板
+	for i := range *h {
+		if err := (*h)[i].compileResponseTemplates(); err != nil {
+			return fmt.Errorf("hook %s: %w", (*h)[i].ID, err)
+		}
+	}
+
+	// 预先解析转发的 url、请求头与请求体模板
+	for i := range *h {
+		if err := (*h)[i].Forward.Compile(); err != nil {
+			return fmt.Errorf("hook %s: %w", (*h)[i].ID, err)
+		}
+	}
+
+	// 预先加载并编译请求体 schema，相对路径相对于 hook 配置文件所在目录
+	for i := range *h {
+		if (*h)[i].PayloadSchema == nil {
+			continue
+		}
+		if err := (*h)[i].PayloadSchema.Compile(filepath.Dir(path)); err != nil {
+			return fmt.Errorf("hook %s: %w", (*h)[i].ID, err)
+		}
+	}
+
+	// 预先加载 protobuf 描述符集合，相对路径同样相对于 hook 配置文件所在目录
+	for i := range *h {
+		if (*h)[i].Protobuf == nil {
+			continue
+		}
+		if err := (*h)[i].Protobuf.Compile(filepath.Dir(path)); err != nil {
+			return fmt.Errorf("hook %s: %w", (*h)[i].ID, err)
+		}
+	}
+
+	// 启动与热重载都拒绝无效的 hook 配置，避免例如没有 timestamp 与 nonce 的 replay-guard 被加载
+	for i := range *h {
+		if err := (*h)[i].Validate(); err != nil {
+			return fmt.Errorf("hook %s: %w", (*h)[i].ID, err)
+		}
+	}
+
 	return nil
 }
 
@@ -898,15 +1144,25 @@ func (h *Hooks) Match(id string) *Hook {
 
 // Rules is a structure that contains one of the valid rule types
 type Rules struct {
-	And   *AndRule   `json:"and,omitempty"`
-	Or    *OrRule    `json:"or,omitempty"`
-	Not   *NotRule   `json:"not,omitempty"`
-	Match *MatchRule `json:"match,omitempty"`
+	And         *AndRule         `json:"and,omitempty"`
+	Or          *OrRule          `json:"or,omitempty"`
+	Not         *NotRule         `json:"not,omitempty"`
+	Match       *MatchRule       `json:"match,omitempty"`
+	ReplayGuard *ReplayGuardRule `json:"replay-guard,omitempty"`
+	Expr        *ExprRule        `json:"expr,omitempty"`
 }
 
 // Evaluate finds the first rule property that is not nil and returns the value
-// it evaluates to
+// it evaluates to. When tracing is enabled on the request, the evaluation is
+// recorded in req.Trace.
 func (r Rules) Evaluate(req *Request) (bool, error) {
+	if req != nil && req.tracing {
+		return r.evaluateTraced(req)
+	}
+	return r.evaluate(req)
+}
+
+func (r Rules) evaluate(req *Request) (bool, error) {
 	switch {
 	case r.And != nil:
 		return r.And.Evaluate(req)
@@ -916,11 +1172,45 @@ func (r Rules) Evaluate(req *Request) (bool, error) {
 		return r.Not.Evaluate(req)
 	case r.Match != nil:
 		return r.Match.Evaluate(req)
+	case r.ReplayGuard != nil:
+		return r.ReplayGuard.Evaluate(req)
+	case r.Expr != nil:
+		return r.Expr.Evaluate(req)
 	}
 
 	return false, nil
 }
 
+// Validate checks the configuration of the rule and all of its child rules.
+func (r *Rules) Validate() error {
+	if r == nil {
+		return nil
+	}
+
+	var children []Rules
+	switch {
+	case r.And != nil:
+		children = *r.And
+	case r.Or != nil:
+		children = *r.Or
+	case r.Not != nil:
+		children = []Rules{Rules(*r.Not)}
+	case r.ReplayGuard != nil:
+		return r.ReplayGuard.Validate()
+	case r.Match != nil:
+		return r.Match.Validate()
+	case r.Expr != nil:
+		return r.Expr.Validate()
+	}
+
+	for i := range children {
+		if err := children[i].Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // AndRule will evaluate to true if and only if all of the ChildRules evaluate to true
 type AndRule []Rules
 
@@ -1011,6 +1301,16 @@ type MatchRule struct {
 	Value     string   `json:"value,omitempty"`
 	Parameter Argument `json:"parameter,omitempty"`
 	IPRange   string   `json:"ip-range,omitempty"`
+	// Values lists the accepted values of the in and not-in types.
+	Values []string `json:"values,omitempty"`
+	// PublicKey configures the public key signature types.
+	PublicKey *PublicKeyConfig `json:"public-key,omitempty"`
+	// JWT configures the jwt type.
+	JWT *JWTConfig `json:"jwt,omitempty"`
+	// ClientCert configures the client-cert type.
+	ClientCert *ClientCertRule `json:"client-cert,omitempty"`
+	// TimeWindow configures the time-window type.
+	TimeWindow *TimeWindowRule `json:"time-window,omitempty"`
 }
 
 // Constants for the MatchRule type
@@ -1028,6 +1328,39 @@ const (
 	MSTeamsSignature string = "msteams-signature"
 )
 
+// Validate checks the configuration of rule types that need more than a parameter.
+func (r *MatchRule) Validate() error {
+	if err := r.Parameter.Validate(); err != nil {
+		return fmt.Errorf("invalid %s rule: %w", r.Type, err)
+	}
+	if IsPublicKeySignature(r.Type) {
+		if err := r.PublicKey.Validate(); err != nil {
+			return fmt.Errorf("invalid %s rule: %w", r.Type, err)
+		}
+	}
+	if r.Type == MatchJWT {
+		if err := r.JWT.Validate(); err != nil {
+			return fmt.Errorf("invalid %s rule: %w", r.Type, err)
+		}
+	}
+	if r.Type == MatchClientCert {
+		if err := r.ClientCert.Validate(); err != nil {
+			return fmt.Errorf("invalid %s rule: %w", r.Type, err)
+		}
+	}
+	if r.Type == MatchTimeWindow {
+		if err := r.TimeWindow.Validate(); err != nil {
+			return fmt.Errorf("invalid %s rule: %w", r.Type, err)
+		}
+	}
+	if IsComparisonMatch(r.Type) {
+		if err := r.validateComparison(); err != nil {
+			return fmt.Errorf("invalid %s rule: %w", r.Type, err)
+		}
+	}
+	return nil
+}
+
 // Evaluate MatchRule will return based on the type
 func (r MatchRule) Evaluate(req *Request) (bool, error) {
 	if r.Type == IPWhitelist {
@@ -1039,6 +1372,24 @@ func (r MatchRule) Evaluate(req *Request) (bool, error) {
 	if r.Type == MSTeamsSignature {
 		return CheckMSTeamsSignature(req, r.Secret)
 	}
+	if IsProviderSignature(r.Type) {
+		return r.evaluateProviderSignature(req)
+	}
+	if IsPublicKeySignature(r.Type) {
+		return r.evaluatePublicKeySignature(req)
+	}
+	if r.Type == MatchJWT {
+		return r.evaluateJWT(req)
+	}
+	if r.Type == MatchClientCert {
+		return r.ClientCert.Evaluate(req), nil
+	}
+	if r.Type == MatchTimeWindow {
+		return r.TimeWindow.Evaluate(req)
+	}
+	if IsComparisonMatch(r.Type) {
+		return r.evaluateComparison(req)
+	}
 
 	arg, err := r.Parameter.Get(req)
 	if err == nil {
@@ -1055,19 +1406,19 @@ func (r MatchRule) Evaluate(req *Request) (bool, error) {
 			logger.Warn(`warn: use of deprecated option payload-hash-sha1; use payload-hmac-sha1 instead`)
 			fallthrough
 		case MatchHMACSHA1:
-			_, err := CheckPayloadSignature(req.Body, r.Secret, arg)
+			_, err := CheckPayloadSignature(req.SignedBody(), r.Secret, arg)
 			return err == nil, err
 		case MatchHashSHA256:
 			logger.Warn(`warn: use of deprecated option payload-hash-sha256: use payload-hmac-sha256 instead`)
 			fallthrough
 		case MatchHMACSHA256:
-			_, err := CheckPayloadSignature256(req.Body, r.Secret, arg)
+			_, err := CheckPayloadSignature256(req.SignedBody(), r.Secret, arg)
 			return err == nil, err
 		case MatchHashSHA512:
 			logger.Warn(`warn: use of deprecated option payload-hash-sha512: use payload-hmac-sha512 instead`)
 			fallthrough
 		case MatchHMACSHA512:
-			_, err := CheckPayloadSignature512(req.Body, r.Secret, arg)
+			_, err := CheckPayloadSignature512(req.SignedBody(), r.Secret, arg)
 			return err == nil, err
 		}
 	}
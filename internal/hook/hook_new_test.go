@@ -97,16 +97,16 @@ func TestIsSignatureError(t *testing.T) {
 }
 
 func TestArgumentError_Error(t *testing.T) {
-	argErr := &hook.ArgumentError{Argument: hook.Argument{Name: "arg_name"}}
-	expectedMessage := "couldn't retrieve argument for {Source: Name:arg_name EnvName: Base64Decode:false}"
+	argErr := &hook.ArgumentError{Argument: hook.Argument{Source: "header", Name: "arg_name", EnvName: "ARG", Required: true}}
+	expectedMessage := "couldn't retrieve argument for header arg_name"
 	if argErr.Error() != expectedMessage {
 		t.Errorf("Expected message %q, got %q", expectedMessage, argErr.Error())
 	}
 }
 
 func TestSourceError_Error(t *testing.T) {
-	srcErr := &hook.SourceError{Argument: hook.Argument{Name: "src_name"}}
-	expectedMessage := "invalid source for argument {Source: Name:src_name EnvName: Base64Decode:false}"
+	srcErr := &hook.SourceError{Argument: hook.Argument{Source: "unknown", Name: "src_name", EnvName: "SRC"}}
+	expectedMessage := "invalid source \"unknown\" for argument src_name"
 	if srcErr.Error() != expectedMessage {
 		t.Errorf("Expected message %q, got %q", expectedMessage, srcErr.Error())
 	}
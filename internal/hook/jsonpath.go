@@ -0,0 +1,697 @@
+package hook
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ArgumentSyntaxJSONPath makes an Argument name a JSONPath query (RFC 9535),
+// e.g. "$.commits[*].modified[*]" or "$.commits[?@.author.name == 'bot'].id".
+const ArgumentSyntaxJSONPath = "jsonpath"
+
+// jsonPathCache caches compiled queries by source and query.
+var jsonPathCache sync.Map // map[string]*jsonPath
+
+// jsonPath is a compiled JSONPath query.
+type jsonPath struct {
+	segments []jsonPathSegment
+}
+
+// jsonPathSegment selects children, or descendants, of the current nodes.
+type jsonPathSegment struct {
+	descendant bool
+	selectors  []jsonPathSelector
+}
+
+type jsonPathSelectorKind int
+
+const (
+	jsonPathName jsonPathSelectorKind = iota
+	jsonPathWildcard
+	jsonPathIndex
+	jsonPathSlice
+	jsonPathFilter
+)
+
+type jsonPathSelector struct {
+	kind  jsonPathSelectorKind
+	name  string
+	index int
+	// slice bounds; nil means the default bound for the step direction.
+	start, end *int
+	step       int
+	filter     jsonPathExpr
+}
+
+// singular reports whether the query selects at most one node.
+func (p *jsonPath) singular() bool {
+	for _, seg := range p.segments {
+		if seg.descendant || len(seg.selectors) != 1 {
+			return false
+		}
+		if k := seg.selectors[0].kind; k != jsonPathName && k != jsonPathIndex {
+			return false
+		}
+	}
+	return true
+}
+
+// compileJSONPath compiles and caches a query. For header arguments the
+// member names of the first segment are canonicalized like header names.
+func compileJSONPath(source, query string) (*jsonPath, error) {
+	cacheKey := source + "\x00" + query
+	if cached, ok := jsonPathCache.Load(cacheKey); ok {
+		return cached.(*jsonPath), nil
+	}
+
+	p := &jsonPathParser{src: query}
+	path, err := p.parseQuery()
+	if err != nil {
+		return nil, fmt.Errorf("invalid jsonpath %q: %w", query, err)
+	}
+	if source == SourceHeader && len(path.segments) > 0 && !path.segments[0].descendant {
+		for i := range path.segments[0].selectors {
+			if sel := &path.segments[0].selectors[i]; sel.kind == jsonPathName {
+				sel.name = textproto.CanonicalMIMEHeaderKey(sel.name)
+			}
+		}
+	}
+
+	jsonPathCache.Store(cacheKey, path)
+	return path, nil
+}
+
+// query evaluates a JSONPath argument against source. A singular query
+// returns the selected value and a ParameterNodeError when it is missing;
+// other queries return the selected nodes as a list, which may be empty.
+func (ha *Argument) query(source map[string]interface{}) (string, error) {
+	path, err := compileJSONPath(ha.Source, ha.Name)
+	if err != nil {
+		return "", err
+	}
+
+	nodes := path.evaluate(source, source)
+	if path.singular() {
+		if len(nodes) == 0 {
+			return "", &ParameterNodeError{ha.Name}
+		}
+		return parameterString(nodes[0])
+	}
+
+	if nodes == nil {
+		nodes = []interface{}{}
+	}
+	if ha.Separator == "" {
+		return parameterString(nodes)
+	}
+
+	parts := make([]string, len(nodes))
+	for i, node := range nodes {
+		if s, ok := node.(string); ok {
+			parts[i] = s
+			continue
+		}
+		if parts[i], err = parameterString(node); err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(parts, ha.Separator), nil
+}
+
+// evaluate returns the nodes selected from root; current is the node @
+// refers to in filters.
+func (p *jsonPath) evaluate(root, current interface{}) []interface{} {
+	nodes := []interface{}{current}
+	for _, seg := range p.segments {
+		var next []interface{}
+		for _, node := range nodes {
+			if seg.descendant {
+				for _, d := range jsonPathDescendants(node, nil) {
+					next = seg.apply(root, d, next)
+				}
+			} else {
+				next = seg.apply(root, node, next)
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// apply appends the nodes selected from node to out.
+func (seg jsonPathSegment) apply(root, node interface{}, out []interface{}) []interface{} {
+	for _, sel := range seg.selectors {
+		switch sel.kind {
+		case jsonPathName:
+			if m, ok := node.(map[string]interface{}); ok {
+				if v, ok := m[sel.name]; ok {
+					out = append(out, v)
+				}
+			}
+
+		case jsonPathWildcard:
+			out = append(out, jsonPathChildren(node)...)
+
+		case jsonPathIndex:
+			if list, ok := node.([]interface{}); ok {
+				i := sel.index
+				if i < 0 {
+					i += len(list)
+				}
+				if i >= 0 && i < len(list) {
+					out = append(out, list[i])
+				}
+			}
+
+		case jsonPathSlice:
+			if list, ok := node.([]interface{}); ok {
+				out = append(out, sel.slice(list)...)
+			}
+
+		case jsonPathFilter:
+			for _, child := range jsonPathChildren(node) {
+				if sel.filter.test(root, child) {
+					out = append(out, child)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// slice returns the elements selected by a slice selector.
+func (sel jsonPathSelector) slice(list []interface{}) []interface{} {
+	n, step := len(list), sel.step
+	if step == 0 {
+		return nil
+	}
+	bound := func(v *int, def int) int {
+		if v == nil {
+			return def
+		}
+		i := *v
+		if i < 0 {
+			i += n
+		}
+		if step > 0 {
+			return min(max(i, 0), n)
+		}
+		return min(max(i, -1), n-1)
+	}
+
+	var out []interface{}
+	if step > 0 {
+		for i := bound(sel.start, 0); i < bound(sel.end, n); i += step {
+			out = append(out, list[i])
+		}
+	} else {
+		for i := bound(sel.start, n-1); i > bound(sel.end, -1); i += step {
+			out = append(out, list[i])
+		}
+	}
+	return out
+}
+
+// jsonPathChildren returns the elements of a list or the member values of
+// an object ordered by name.
+func jsonPathChildren(node interface{}) []interface{} {
+	switch v := node.(type) {
+	case []interface{}:
+		return v
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		children := make([]interface{}, len(keys))
+		for i, k := range keys {
+			children[i] = v[k]
+		}
+		return children
+	}
+	return nil
+}
+
+// jsonPathDescendants appends node and all of its descendants to out.
+func jsonPathDescendants(node interface{}, out []interface{}) []interface{} {
+	out = append(out, node)
+	for _, child := range jsonPathChildren(node) {
+		out = jsonPathDescendants(child, out)
+	}
+	return out
+}
+
+// jsonPathExpr is a filter expression.
+type jsonPathExpr interface {
+	test(root, node interface{}) bool
+}
+
+type (
+	jsonPathOr  []jsonPathExpr
+	jsonPathAnd []jsonPathExpr
+	jsonPathNot struct{ expr jsonPathExpr }
+	// jsonPathExists tests whether a query selects at least one node.
+	jsonPathExists struct{ operand jsonPathOperand }
+	// jsonPathComparison compares a literal or singular query to another.
+	jsonPathComparison struct {
+		op          string
+		left, right jsonPathOperand
+	}
+)
+
+// jsonPathOperand is a literal or a query relative to @ or $.
+type jsonPathOperand struct {
+	literal  interface{}
+	query    *jsonPath
+	relative bool
+}
+
+func (o jsonPathOperand) nodes(root, node interface{}) []interface{} {
+	if o.relative {
+		return o.query.evaluate(root, node)
+	}
+	return o.query.evaluate(root, root)
+}
+
+// value returns the value of the operand; ok is false when a query does not
+// select exactly one node.
+func (o jsonPathOperand) value(root, node interface{}) (v interface{}, ok bool) {
+	if o.query == nil {
+		return o.literal, true
+	}
+	nodes := o.nodes(root, node)
+	if len(nodes) != 1 {
+		return nil, false
+	}
+	return nodes[0], true
+}
+
+func (e jsonPathOr) test(root, node interface{}) bool {
+	for _, expr := range e {
+		if expr.test(root, node) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e jsonPathAnd) test(root, node interface{}) bool {
+	for _, expr := range e {
+		if !expr.test(root, node) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e jsonPathNot) test(root, node interface{}) bool {
+	return !e.expr.test(root, node)
+}
+
+func (e jsonPathExists) test(root, node interface{}) bool {
+	return len(e.operand.nodes(root, node)) > 0
+}
+
+func (e jsonPathComparison) test(root, node interface{}) bool {
+	left, lok := e.left.value(root, node)
+	right, rok := e.right.value(root, node)
+
+	equal := func() bool {
+		if !lok || !rok {
+			return lok == rok
+		}
+		return exprEqual(left, right)
+	}
+	switch e.op {
+	case "==":
+		return equal()
+	case "!=":
+		return !equal()
+	}
+
+	if !lok || !rok {
+		return false
+	}
+	if (e.op == "<=" || e.op == ">=") && exprEqual(left, right) {
+		return true
+	}
+	c, err := exprCompare(exprNormalize(left), exprNormalize(right))
+	if err != nil {
+		return false
+	}
+	switch e.op {
+	case "<", "<=":
+		return c < 0
+	default:
+		return c > 0
+	}
+}
+
+// jsonPathParser parses the supported subset of RFC 9535: name, wildcard,
+// index, slice and filter selectors, descendant segments and filters with
+// comparisons, existence tests, &&, || and !. Function extensions are not
+// supported.
+type jsonPathParser struct {
+	src string
+	pos int
+}
+
+func (p *jsonPathParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s at %d", fmt.Sprintf(format, args...), p.pos)
+}
+
+func (p *jsonPathParser) skipSpace() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\n\r", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *jsonPathParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *jsonPathParser) consume(s string) bool {
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *jsonPathParser) parseQuery() (*jsonPath, error) {
+	if !p.consume("$") {
+		return nil, errors.New("query must start with $")
+	}
+	path, err := p.parseSegments()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+	return path, nil
+}
+
+// parseSegments parses the segments following $ or @.
+func (p *jsonPathParser) parseSegments() (*jsonPath, error) {
+	path := &jsonPath{}
+	for {
+		switch {
+		case p.consume(".."):
+			seg := jsonPathSegment{descendant: true}
+			switch {
+			case p.peek() == '[':
+				selectors, err := p.parseBracket()
+				if err != nil {
+					return nil, err
+				}
+				seg.selectors = selectors
+			case p.consume("*"):
+				seg.selectors = []jsonPathSelector{{kind: jsonPathWildcard}}
+			default:
+				name, err := p.parseMemberName()
+				if err != nil {
+					return nil, err
+				}
+				seg.selectors = []jsonPathSelector{{kind: jsonPathName, name: name}}
+			}
+			path.segments = append(path.segments, seg)
+
+		case p.consume("."):
+			if p.consume("*") {
+				path.segments = append(path.segments, jsonPathSegment{selectors: []jsonPathSelector{{kind: jsonPathWildcard}}})
+				continue
+			}
+			name, err := p.parseMemberName()
+			if err != nil {
+				return nil, err
+			}
+			path.segments = append(path.segments, jsonPathSegment{selectors: []jsonPathSelector{{kind: jsonPathName, name: name}}})
+
+		case p.peek() == '[':
+			selectors, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			path.segments = append(path.segments, jsonPathSegment{selectors: selectors})
+
+		default:
+			return path, nil
+		}
+	}
+}
+
+// parseMemberName parses a dot notation name. Besides the characters of
+// RFC 9535, hyphens are allowed so header names need no brackets.
+func (p *jsonPathParser) parseMemberName() (string, error) {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '_' || c == '-' || c >= 0x80 || isDigit(c) || (c|0x20 >= 'a' && c|0x20 <= 'z') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	if p.pos == start || isDigit(p.src[start]) || p.src[start] == '-' {
+		p.pos = start
+		return "", p.errorf("expected member name")
+	}
+	return p.src[start:p.pos], nil
+}
+
+// parseBracket parses a comma separated list of selectors in brackets.
+func (p *jsonPathParser) parseBracket() ([]jsonPathSelector, error) {
+	p.pos++ // [
+	var selectors []jsonPathSelector
+	for {
+		p.skipSpace()
+		sel, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, sel)
+		p.skipSpace()
+		if p.consume("]") {
+			return selectors, nil
+		}
+		if !p.consume(",") {
+			return nil, p.errorf("expected , or ]")
+		}
+	}
+}
+
+func (p *jsonPathParser) parseSelector() (jsonPathSelector, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		name, end, err := lexExprString(p.src, p.pos)
+		if err != nil {
+			return jsonPathSelector{}, err
+		}
+		p.pos = end
+		return jsonPathSelector{kind: jsonPathName, name: name}, nil
+
+	case c == '*':
+		p.pos++
+		return jsonPathSelector{kind: jsonPathWildcard}, nil
+
+	case c == '?':
+		p.pos++
+		filter, err := p.parseOr()
+		if err != nil {
+			return jsonPathSelector{}, err
+		}
+		return jsonPathSelector{kind: jsonPathFilter, filter: filter}, nil
+	}
+
+	start, err := p.parseOptionalInt()
+	if err != nil {
+		return jsonPathSelector{}, err
+	}
+	p.skipSpace()
+	if p.peek() != ':' {
+		if start == nil {
+			return jsonPathSelector{}, p.errorf("expected selector")
+		}
+		return jsonPathSelector{kind: jsonPathIndex, index: *start}, nil
+	}
+
+	sel := jsonPathSelector{kind: jsonPathSlice, start: start, step: 1}
+	p.pos++
+	p.skipSpace()
+	if sel.end, err = p.parseOptionalInt(); err != nil {
+		return jsonPathSelector{}, err
+	}
+	p.skipSpace()
+	if p.consume(":") {
+		p.skipSpace()
+		step, err := p.parseOptionalInt()
+		if err != nil {
+			return jsonPathSelector{}, err
+		}
+		if step != nil {
+			sel.step = *step
+		}
+	}
+	return sel, nil
+}
+
+// parseOptionalInt parses an integer if one follows.
+func (p *jsonPathParser) parseOptionalInt() (*int, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(p.src[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("invalid integer")
+	}
+	return &n, nil
+}
+
+func (p *jsonPathParser) parseOr() (jsonPathExpr, error) {
+	var or jsonPathOr
+	for {
+		expr, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, expr)
+		p.skipSpace()
+		if !p.consume("||") {
+			break
+		}
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *jsonPathParser) parseAnd() (jsonPathExpr, error) {
+	var and jsonPathAnd
+	for {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, expr)
+		p.skipSpace()
+		if !p.consume("&&") {
+			break
+		}
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *jsonPathParser) parseUnary() (jsonPathExpr, error) {
+	p.skipSpace()
+	if p.peek() == '!' && !strings.HasPrefix(p.src[p.pos:], "!=") {
+		p.pos++
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return jsonPathNot{expr}, nil
+	}
+	if p.consume("(") {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume(")") {
+			return nil, p.errorf("expected )")
+		}
+		return expr, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if !p.consume(op) {
+			continue
+		}
+		p.skipSpace()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range []jsonPathOperand{left, right} {
+			if o.query != nil && !o.query.singular() {
+				return nil, p.errorf("comparisons require singular queries")
+			}
+		}
+		return jsonPathComparison{op: op, left: left, right: right}, nil
+	}
+
+	if left.query == nil {
+		return nil, p.errorf("expected comparison")
+	}
+	return jsonPathExists{left}, nil
+}
+
+func (p *jsonPathParser) parseOperand() (jsonPathOperand, error) {
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		p.pos++
+		query, err := p.parseSegments()
+		if err != nil {
+			return jsonPathOperand{}, err
+		}
+		return jsonPathOperand{query: query, relative: c == '@'}, nil
+
+	case c == '\'' || c == '"':
+		s, end, err := lexExprString(p.src, p.pos)
+		if err != nil {
+			return jsonPathOperand{}, err
+		}
+		p.pos = end
+		return jsonPathOperand{literal: s}, nil
+
+	case c == '-' || isDigit(c):
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || strings.IndexByte(".eE+-", p.src[p.pos]) >= 0) {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			p.pos = start
+			return jsonPathOperand{}, p.errorf("invalid number")
+		}
+		return jsonPathOperand{literal: n}, nil
+	}
+
+	for _, lit := range []struct {
+		text  string
+		value interface{}
+	}{{"true", true}, {"false", false}, {"null", nil}} {
+		if p.consume(lit.text) {
+			return jsonPathOperand{literal: lit.value}, nil
+		}
+	}
+	return jsonPathOperand{}, p.errorf("expected operand")
+}
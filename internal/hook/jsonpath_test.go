@@ -0,0 +1,153 @@
+package hook
+
+import (
+	"testing"
+)
+
+var jsonPathTestPayload = `{
+	"ref": "refs/heads/main",
+	"repository": {"full.name": "octo/hello"},
+	"commits": [
+		{"id": "a1", "author": {"name": "bot"}, "added": 1, "modified": ["README.md"]},
+		{"id": "b2", "author": {"name": "alice"}, "added": 3, "modified": ["main.go", "go.mod"]},
+		{"id": "c3", "author": {"name": "bot"}, "added": 0, "modified": []}
+	]
+}`
+
+func TestArgumentJSONPath(t *testing.T) {
+	req := &Request{Body: []byte(jsonPathTestPayload), ContentType: "application/json"}
+	if err := req.ParseJSONPayload(); err != nil {
+		t.Fatal(err)
+	}
+	req.ParseHeaders(map[string][]string{"X-Github-Event": {"push"}})
+	req.ParseQuery(map[string][]string{"branch": {"main"}})
+
+	tests := []struct {
+		description string
+		arg         Argument
+		want        string
+		wantErr     bool
+	}{
+		{"singular", Argument{Source: SourcePayload, Name: "$.ref"}, "refs/heads/main", false},
+		{"key with dots", Argument{Source: SourcePayload, Name: "$.repository['full.name']"}, "octo/hello", false},
+		{"negative index", Argument{Source: SourcePayload, Name: "$.commits[-1].id"}, "c3", false},
+		{"number", Argument{Source: SourcePayload, Name: "$.commits[1].added"}, "3", false},
+		{"object", Argument{Source: SourcePayload, Name: "$.commits[0].author"}, `{"name":"bot"}`, false},
+		{"missing", Argument{Source: SourcePayload, Name: "$.commits[5].id"}, "", true},
+		{"projection", Argument{Source: SourcePayload, Name: "$.commits[*].id"}, `["a1","b2","c3"]`, false},
+		{"nested projection", Argument{Source: SourcePayload, Name: "$.commits[*].modified[*]"}, `["README.md","main.go","go.mod"]`, false},
+		{"joined", Argument{Source: SourcePayload, Name: "$.commits[*].modified[*]", Separator: " "}, "README.md main.go go.mod", false},
+		{"joined numbers", Argument{Source: SourcePayload, Name: "$.commits[*].added", Separator: ","}, "1,3,0", false},
+		{"empty projection", Argument{Source: SourcePayload, Name: "$.commits[*].removed[*]"}, "[]", false},
+		{"empty joined", Argument{Source: SourcePayload, Name: "$.commits[*].removed[*]", Separator: ","}, "", false},
+		{"filter", Argument{Source: SourcePayload, Name: "$.commits[?@.author.name == 'bot'].id", Separator: ","}, "a1,c3", false},
+		{"filter legacy parentheses", Argument{Source: SourcePayload, Name: "$.commits[?(@.author.name=='alice')].id"}, `["b2"]`, false},
+		{"filter comparison", Argument{Source: SourcePayload, Name: "$.commits[?@.added >= 1 && !(@.author.name == 'bot')].id"}, `["b2"]`, false},
+		{"filter existence", Argument{Source: SourcePayload, Name: "$.commits[?@.modified[1]].id"}, `["b2"]`, false},
+		{"filter root", Argument{Source: SourcePayload, Name: "$.commits[?@.id == $.commits[0].id].added"}, `[1]`, false},
+		{"slice", Argument{Source: SourcePayload, Name: "$.commits[1:].id"}, `["b2","c3"]`, false},
+		{"reverse slice", Argument{Source: SourcePayload, Name: "$.commits[::-2].id"}, `["c3","a1"]`, false},
+		{"union", Argument{Source: SourcePayload, Name: "$.commits[0,2].id"}, `["a1","c3"]`, false},
+		{"descendant", Argument{Source: SourcePayload, Name: "$..name", Separator: ","}, "bot,alice,bot", false},
+		{"header", Argument{Source: SourceHeader, Name: "$['x-github-event']"}, "push", false},
+		{"header dot notation", Argument{Source: SourceHeader, Name: "$.x-github-event"}, "push", false},
+		{"query", Argument{Source: SourceQuery, Name: "$.branch"}, "main", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			tt.arg.Syntax = ArgumentSyntaxJSONPath
+			if err := tt.arg.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			got, err := tt.arg.Get(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !IsParameterNodeError(err) {
+				t.Errorf("got error %T, want ParameterNodeError", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArgumentJSONPathMatchRule(t *testing.T) {
+	req := &Request{Body: []byte(jsonPathTestPayload), ContentType: "application/json"}
+	if err := req.ParseJSONPayload(); err != nil {
+		t.Fatal(err)
+	}
+
+	rule := MatchRule{
+		Type:      MatchRegex,
+		Regex:     `(^|,)main\.go(,|$)`,
+		Parameter: Argument{Source: SourcePayload, Name: "$.commits[*].modified[*]", Syntax: ArgumentSyntaxJSONPath, Separator: ","},
+	}
+	if err := rule.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := rule.Evaluate(req); !ok || err != nil {
+		t.Errorf("got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestArgumentValidate(t *testing.T) {
+	tests := []struct {
+		description string
+		arg         Argument
+		ok          bool
+	}{
+		{"plain", Argument{Source: SourcePayload, Name: "a.b"}, true},
+		{"separator without syntax", Argument{Source: SourcePayload, Name: "a", Separator: ","}, false},
+		{"unknown syntax", Argument{Source: SourcePayload, Name: "a", Syntax: "jmespath"}, false},
+		{"unsupported source", Argument{Source: SourceRequest, Name: "$.method", Syntax: ArgumentSyntaxJSONPath}, false},
+		{"missing root", Argument{Source: SourcePayload, Name: "a.b", Syntax: ArgumentSyntaxJSONPath}, false},
+		{"unclosed bracket", Argument{Source: SourcePayload, Name: "$.a[0", Syntax: ArgumentSyntaxJSONPath}, false},
+		{"unclosed string", Argument{Source: SourcePayload, Name: "$['a", Syntax: ArgumentSyntaxJSONPath}, false},
+		{"non-singular comparison", Argument{Source: SourcePayload, Name: "$.a[?@.b[*] == 1]", Syntax: ArgumentSyntaxJSONPath}, false},
+		{"literal filter", Argument{Source: SourcePayload, Name: "$.a[?1]", Syntax: ArgumentSyntaxJSONPath}, false},
+		{"trailing characters", Argument{Source: SourcePayload, Name: "$.a b", Syntax: ArgumentSyntaxJSONPath}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			if err := tt.arg.Validate(); (err == nil) != tt.ok {
+				t.Errorf("got error %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestHookValidateArguments(t *testing.T) {
+	query := Argument{Source: SourcePayload, Name: "$.commits[*].id", Syntax: ArgumentSyntaxJSONPath}
+	withEnv := query
+	withEnv.EnvName = "COMMIT_IDS"
+
+	tests := []struct {
+		description string
+		hook        Hook
+		ok          bool
+	}{
+		{"argument", Hook{PassArgumentsToCommand: []Argument{query}}, true},
+		{"environment with envname", Hook{PassEnvironmentToCommand: []Argument{withEnv}}, true},
+		{"environment without envname", Hook{PassEnvironmentToCommand: []Argument{query}}, false},
+		{"file without envname", Hook{PassFileToCommand: []Argument{query}}, false},
+		{"json parameter", Hook{JSONStringParameters: []Argument{query}}, false},
+		{"invalid query", Hook{PassArgumentsToCommand: []Argument{{Source: SourcePayload, Name: "$[", Syntax: ArgumentSyntaxJSONPath}}}, false},
+		{
+			"trigger rule",
+			Hook{TriggerRule: &Rules{Match: &MatchRule{Type: MatchValue, Parameter: Argument{Source: SourcePayload, Name: "ref", Separator: ","}}}},
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			if err := tt.hook.Validate(); (err == nil) != tt.ok {
+				t.Errorf("got error %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
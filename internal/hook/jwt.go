@@ -0,0 +1,312 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/soulteary/webhook/internal/logger"
+)
+
+// MatchJWT is the MatchRule type validating JWT bearer tokens.
+const MatchJWT string = "jwt"
+
+// Default values used by the jwt MatchRule.
+const (
+	DefaultJWTLeeway = time.Minute
+	// maxJWTSize is the maximum size of a bearer token.
+	maxJWTSize = 16 << 10
+)
+
+// DefaultJWTAlgorithms are the JWS algorithms accepted when none are configured.
+var DefaultJWTAlgorithms = []string{"RS256"}
+
+// JWTConfig configures the jwt MatchRule type. The token is read from the rule
+// parameter, or from the Authorization bearer token when no parameter is set.
+type JWTConfig struct {
+	// PublicKeySource are the keys that sign tokens. When no key source is
+	// set, the JWKS of Issuer is found through OpenID Connect discovery.
+	PublicKeySource
+	// Issuer must equal the "iss" claim. It is required.
+	Issuer string `json:"issuer,omitempty"`
+	// Audience must be one of the values of the "aud" claim. It is required,
+	// as a provider signs tokens with the same issuer for all its users.
+	Audience string `json:"audience,omitempty"`
+	// Algorithms lists the accepted "alg" header values, default RS256.
+	Algorithms []string `json:"algorithms,omitempty"`
+	// Leeway is the clock skew tolerated when checking "exp" and "nbf".
+	Leeway Duration `json:"leeway,omitempty"`
+}
+
+// Validate checks the jwt rule configuration.
+func (c *JWTConfig) Validate() error {
+	if c == nil {
+		return errors.New("jwt is required")
+	}
+	if c.Issuer == "" {
+		return errors.New("issuer is required")
+	}
+	if c.Audience == "" {
+		return errors.New("audience is required")
+	}
+	if c.Key == "" && c.File == "" && c.JWKSURL == "" {
+		if !strings.HasPrefix(c.Issuer, "https://") {
+			return fmt.Errorf("invalid issuer %q: OpenID Connect discovery requires https", c.Issuer)
+		}
+	} else if err := c.PublicKeySource.Validate(); err != nil {
+		return err
+	}
+	for _, alg := range c.Algorithms {
+		if _, ok := jwsAlgorithms[alg]; !ok {
+			return fmt.Errorf("unsupported jwt algorithm %q", alg)
+		}
+	}
+	if c.Leeway < 0 {
+		return errors.New("leeway must not be negative")
+	}
+	return nil
+}
+
+func (c *JWTConfig) algorithms() []string {
+	if len(c.Algorithms) > 0 {
+		return c.Algorithms
+	}
+	return DefaultJWTAlgorithms
+}
+
+func (c *JWTConfig) leeway() time.Duration {
+	if c.Leeway > 0 {
+		return c.Leeway.Duration()
+	}
+	return DefaultJWTLeeway
+}
+
+// keys returns the signing keys, using OpenID Connect discovery when no key
+// source is configured.
+func (c *JWTConfig) keys(ctx context.Context, kid string) ([]PublicKey, error) {
+	if c.Key != "" || c.File != "" || c.JWKSURL != "" {
+		return c.PublicKeySource.Keys(ctx, kid)
+	}
+	jwksURL, err := discoverJWKSURL(ctx, c.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	ttl := DefaultJWKSCacheTTL
+	if c.CacheTTL > 0 {
+		ttl = c.CacheTTL.Duration()
+	}
+	return jwksKeys(ctx, jwksURL, ttl, kid)
+}
+
+// evaluateJWT validates the bearer token referenced by the rule and makes its
+// claims available through the jwt-claim argument source.
+func (r MatchRule) evaluateJWT(req *Request) (bool, error) {
+	token, ok := r.bearerToken(req)
+	if !ok {
+		req.recordSignatureCheck(r.Type, false, nil)
+		return false, nil
+	}
+
+	claims, err := ValidateJWT(req, r.JWT, token)
+	req.recordSignatureCheck(r.Type, err == nil, err)
+	if err != nil {
+		return false, err
+	}
+	req.JWTClaims = claims
+	return true, nil
+}
+
+// bearerToken returns the token referenced by the rule parameter, defaulting
+// to the bearer token of the Authorization header.
+func (r MatchRule) bearerToken(req *Request) (string, bool) {
+	param := r.Parameter
+	if param.Source == "" {
+		param = Argument{Source: SourceHeader, Name: "Authorization"}
+	}
+	value, err := param.Get(req)
+	if err != nil {
+		return "", false
+	}
+
+	value = strings.TrimSpace(value)
+	if scheme, token, found := strings.Cut(value, " "); found && strings.EqualFold(scheme, "Bearer") {
+		value = strings.TrimSpace(token)
+	} else if r.Parameter.Source == "" {
+		// Other Authorization schemes do not carry a JWT.
+		return "", false
+	}
+	return value, value != ""
+}
+
+// ValidateJWT verifies the signature and the registered claims of a compact
+// JWT and returns its claims. Tokens that are invalid or expired result in a
+// SignatureError that does not disclose the token.
+func ValidateJWT(r *Request, c *JWTConfig, token string) (map[string]interface{}, error) {
+	if c == nil {
+		return nil, errors.New("jwt validation config can not be empty")
+	}
+	invalid := func(reason string, args ...interface{}) error {
+		logger.Debugf("[%s] invalid jwt: "+reason, append([]interface{}{r.ID}, args...)...)
+		return &SignatureError{Signature: MatchJWT}
+	}
+
+	if len(token) > maxJWTSize {
+		return nil, invalid("token too large")
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalid("malformed token")
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, invalid("malformed header")
+	}
+	var header jwsHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, invalid("malformed header")
+	}
+	if !slices.Contains(c.algorithms(), header.Alg) {
+		return nil, invalid("algorithm %q not allowed", header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalid("malformed signature")
+	}
+
+	ctx := context.Background()
+	if r.RawRequest != nil {
+		ctx = r.RawRequest.Context()
+	}
+	keys, err := c.keys(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if header.Kid != "" {
+		keys = SelectPublicKeys(keys, header.Kid)
+	}
+	if !VerifyJWS(header.Alg, keys, []byte(parts[0]+"."+parts[1]), sig) {
+		return nil, invalid("signature verification failed (kid %q)", header.Kid)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, invalid("malformed payload")
+	}
+	var claims map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&claims); err != nil || claims == nil {
+		return nil, invalid("malformed claims")
+	}
+
+	now := time.Now()
+	leeway := c.leeway()
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return nil, invalid("missing exp claim")
+	}
+	if now.After(exp.Add(leeway)) {
+		logger.Debugf("[%s] invalid jwt: token expired at %s", r.ID, exp)
+		return nil, &SignatureError{Signature: "outdated"}
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(leeway).Before(nbf) {
+		return nil, invalid("token not valid before %s", nbf)
+	}
+	// issuer and audience are required, a configuration without them
+	// matches no token
+	if c.Issuer == "" || claims["iss"] != c.Issuer {
+		return nil, invalid("issuer %v does not match", claims["iss"])
+	}
+	if c.Audience == "" || !hasAudience(claims["aud"], c.Audience) {
+		return nil, invalid("audience %v does not match", claims["aud"])
+	}
+
+	return claims, nil
+}
+
+// numericDate converts a JWT NumericDate claim decoded as json.Number.
+func numericDate(v interface{}) (time.Time, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+// hasAudience reports whether the "aud" claim, a string or an array of strings, contains audience.
+func hasAudience(aud interface{}, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []interface{}:
+		for _, a := range v {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type cachedDiscovery struct {
+	jwksURL string
+	fetched time.Time
+}
+
+var discoveryCache sync.Map // map[string]*cachedDiscovery
+
+// discoverJWKSURL returns the jwks_uri of an OpenID Connect issuer.
+func discoverJWKSURL(ctx context.Context, issuer string) (string, error) {
+	if v, ok := discoveryCache.Load(issuer); ok {
+		c := v.(*cachedDiscovery)
+		if time.Since(c.fetched) < DefaultJWKSCacheTTL {
+			return c.jwksURL, nil
+		}
+	}
+
+	url := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := jwksClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error fetching OpenID configuration %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error fetching OpenID configuration %s: unexpected status %d", url, resp.StatusCode)
+	}
+	var config struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJWKSSize)).Decode(&config); err != nil {
+		return "", fmt.Errorf("error parsing OpenID configuration %s: %w", url, err)
+	}
+	if config.Issuer != issuer {
+		return "", fmt.Errorf("OpenID configuration %s is for issuer %q", url, config.Issuer)
+	}
+	if !isSecureKeyURL(config.JWKSURI) {
+		return "", fmt.Errorf("OpenID configuration %s has invalid jwks_uri %q", url, config.JWKSURI)
+	}
+
+	discoveryCache.Store(issuer, &cachedDiscovery{jwksURL: config.JWKSURI, fetched: time.Now()})
+	return config.JWKSURI, nil
+}
@@ -0,0 +1,245 @@
+package hook
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func signJWT(t *testing.T, header, claims map[string]interface{}, sign func([]byte) []byte) string {
+	t.Helper()
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	return input + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(input)))
+}
+
+func TestValidateJWT(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []interface{}{
+		jwkFromKey("rsa-1", &rsaKey.PublicKey),
+		jwkFromKey("ed-1", edPub),
+	}})
+
+	signRS256 := func(in []byte) []byte {
+		sig, _ := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, sha256Sum(string(in)))
+		return sig
+	}
+	signEdDSA := func(in []byte) []byte { return ed25519.Sign(edKey, in) }
+
+	now := time.Now().Unix()
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":        "https://token.actions.githubusercontent.com",
+			"aud":        "webhook",
+			"exp":        now + 300,
+			"repository": "org/repo",
+			"ref":        "refs/heads/main",
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+	rs256 := map[string]interface{}{"alg": "RS256", "kid": "rsa-1"}
+
+	config := &JWTConfig{
+		PublicKeySource: PublicKeySource{Key: string(jwks)},
+		Issuer:          "https://token.actions.githubusercontent.com",
+		Audience:        "webhook",
+	}
+
+	tests := []struct {
+		description string
+		token       string
+		outdated    bool
+		ok          bool
+	}{
+		{"valid", signJWT(t, rs256, claims(nil), signRS256), false, true},
+		{"audience in list", signJWT(t, rs256, claims(map[string]interface{}{"aud": []string{"other", "webhook"}}), signRS256), false, true},
+		{"expired within leeway", signJWT(t, rs256, claims(map[string]interface{}{"exp": now - 30}), signRS256), false, true},
+		{"expired", signJWT(t, rs256, claims(map[string]interface{}{"exp": now - 600}), signRS256), true, false},
+		{"missing exp", signJWT(t, rs256, claims(map[string]interface{}{"exp": nil}), signRS256), false, false},
+		{"not yet valid", signJWT(t, rs256, claims(map[string]interface{}{"nbf": now + 600}), signRS256), false, false},
+		{"wrong issuer", signJWT(t, rs256, claims(map[string]interface{}{"iss": "https://gitlab.com"}), signRS256), false, false},
+		{"wrong audience", signJWT(t, rs256, claims(map[string]interface{}{"aud": "other"}), signRS256), false, false},
+		{"algorithm not allowed", signJWT(t, map[string]interface{}{"alg": "EdDSA", "kid": "ed-1"}, claims(nil), signEdDSA), false, false},
+		{"alg none", signJWT(t, map[string]interface{}{"alg": "none"}, claims(nil), func([]byte) []byte { return nil }), false, false},
+		{"wrong key", signJWT(t, map[string]interface{}{"alg": "RS256", "kid": "ed-1"}, claims(nil), signRS256), false, false},
+		{"tampered claims", signJWT(t, rs256, claims(nil), signRS256)[:10] + "x" + signJWT(t, rs256, claims(nil), signRS256)[11:], false, false},
+		{"malformed", "not.a-jwt", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			got, err := ValidateJWT(&Request{}, config, tt.token)
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got["repository"] != "org/repo" {
+					t.Errorf("unexpected claims: %v", got)
+				}
+				return
+			}
+			se, isSignatureError := err.(*SignatureError)
+			if !isSignatureError {
+				t.Fatalf("expected SignatureError, got %v", err)
+			}
+			if (se.Signature == "outdated") != tt.outdated {
+				t.Errorf("got %v, want outdated=%v", err, tt.outdated)
+			}
+		})
+	}
+
+	t.Run("EdDSA allowed", func(t *testing.T) {
+		c := *config
+		c.Algorithms = []string{"RS256", "EdDSA"}
+		token := signJWT(t, map[string]interface{}{"alg": "EdDSA", "kid": "ed-1"}, claims(nil), signEdDSA)
+		if _, err := ValidateJWT(&Request{}, &c, token); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestJWTRuleAndClaimArgument(t *testing.T) {
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []interface{}{jwkFromKey("ed-1", edPub)}})
+	token := signJWT(t, map[string]interface{}{"alg": "EdDSA", "kid": "ed-1"}, map[string]interface{}{
+		"iss":        "https://issuer.example",
+		"aud":        "webhook",
+		"exp":        time.Now().Add(time.Minute).Unix(),
+		"repository": "org/repo",
+		"job":        map[string]interface{}{"ref": "refs/heads/main"},
+	}, func(in []byte) []byte { return ed25519.Sign(edKey, in) })
+
+	rules := Rules{And: &AndRule{
+		{Match: &MatchRule{
+			Type: MatchJWT,
+			JWT:  &JWTConfig{PublicKeySource: PublicKeySource{Key: string(jwks)}, Issuer: "https://issuer.example", Audience: "webhook", Algorithms: []string{"EdDSA"}},
+		}},
+		{Match: &MatchRule{Type: MatchValue, Value: "org/repo", Parameter: Argument{Source: SourceJWTClaim, Name: "repository"}}},
+	}}
+	if err := rules.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		description string
+		headers     map[string]interface{}
+		ok          bool
+		reason      string
+	}{
+		{"bearer token", map[string]interface{}{"Authorization": "Bearer " + token}, true, ""},
+		{"lowercase scheme", map[string]interface{}{"Authorization": "bearer " + token}, true, ""},
+		{"other scheme", map[string]interface{}{"Authorization": "Basic " + token}, false, SignatureReasonMissing},
+		{"missing", map[string]interface{}{}, false, SignatureReasonMissing},
+		{"invalid", map[string]interface{}{"Authorization": "Bearer " + token + "x"}, false, SignatureReasonInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			r := &Request{Headers: tt.headers}
+			ok, _ := rules.Evaluate(r)
+			if ok != tt.ok {
+				t.Errorf("got ok=%v, want %v", ok, tt.ok)
+			}
+			want := SignatureCheck{Provider: "jwt", Valid: tt.ok, Reason: tt.reason}
+			if len(r.SignatureChecks) != 1 || r.SignatureChecks[0] != want {
+				t.Errorf("got checks %+v, want %+v", r.SignatureChecks, want)
+			}
+			if !tt.ok {
+				return
+			}
+
+			arg := Argument{Source: SourceJWTClaim, Name: "job.ref"}
+			if v, err := arg.Get(r); err != nil || v != "refs/heads/main" {
+				t.Errorf("got jwt-claim %q, %v", v, err)
+			}
+		})
+	}
+
+	// Without a validated token, jwt-claim values are missing.
+	arg := Argument{Source: SourceJWTClaim, Name: "repository"}
+	if _, err := arg.Get(&Request{}); !IsParameterNodeError(err) {
+		t.Errorf("expected ParameterNodeError, got %v", err)
+	}
+}
+
+func TestJWTOpenIDConnectDiscovery(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []interface{}{jwkFromKey("rsa-1", &rsaKey.PublicKey)}})
+
+	mux := http.NewServeMux()
+	server := httptest.NewTLSServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": server.URL, "jwks_uri": server.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) { w.Write(jwks) })
+
+	client := jwksClient
+	jwksClient = server.Client()
+	defer func() { jwksClient = client }()
+
+	config := &JWTConfig{Issuer: server.URL, Audience: "webhook"}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	token := signJWT(t, map[string]interface{}{"alg": "RS256", "kid": "rsa-1"}, map[string]interface{}{
+		"iss": server.URL,
+		"aud": "webhook",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}, func(in []byte) []byte {
+		sig, _ := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, sha256Sum(string(in)))
+		return sig
+	})
+
+	if _, err := ValidateJWT(&Request{}, config, token); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A discovery document for another issuer is rejected.
+	if _, err := ValidateJWT(&Request{}, &JWTConfig{Issuer: server.URL + "/other", Audience: "webhook"}, token); err == nil || IsSignatureError(err) {
+		t.Errorf("expected discovery error, got %v", err)
+	}
+}
+
+func TestJWTConfigValidate(t *testing.T) {
+	tests := []struct {
+		description string
+		config      *JWTConfig
+		ok          bool
+	}{
+		{"jwks url", &JWTConfig{PublicKeySource: PublicKeySource{JWKSURL: "https://gitlab.com/oauth/discovery/keys"}, Issuer: "https://gitlab.com", Audience: "webhook"}, true},
+		{"issuer discovery", &JWTConfig{Issuer: "https://token.actions.githubusercontent.com", Audience: "https://github.com/octo-org"}, true},
+		{"missing", nil, false},
+		{"no keys", &JWTConfig{Audience: "webhook"}, false},
+		{"no audience", &JWTConfig{Issuer: "https://token.actions.githubusercontent.com"}, false},
+		{"insecure jwks url", &JWTConfig{PublicKeySource: PublicKeySource{JWKSURL: "http://gitlab.com/oauth/discovery/keys"}, Issuer: "https://gitlab.com", Audience: "webhook"}, false},
+		{"jwks url without issuer", &JWTConfig{PublicKeySource: PublicKeySource{JWKSURL: "https://gitlab.com/oauth/discovery/keys"}, Audience: "webhook"}, false},
+		{"insecure issuer discovery", &JWTConfig{Issuer: "http://issuer.example", Audience: "webhook"}, false},
+		{"unknown algorithm", &JWTConfig{Issuer: "https://issuer.example", Audience: "webhook", Algorithms: []string{"HS256"}}, false},
+		{"negative leeway", &JWTConfig{Issuer: "https://issuer.example", Audience: "webhook", Leeway: -1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			if err := tt.config.Validate(); (err == nil) != tt.ok {
+				t.Errorf("got error %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package hook
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Outcomes of a hook execution that can trigger follow-up hooks.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeTimeout = "timeout"
+)
+
+// PreviousResult is the outcome of the hook that triggered a follow-up hook.
+// Its fields are available through the previous-result argument source.
+type PreviousResult struct {
+	HookID   string `json:"hook_id"`
+	Status   string `json:"status"`
+	ExitCode int    `json:"exit_code"`
+	Output   string `json:"output,omitempty"`
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Get returns the named field of the previous result.
+func (p *PreviousResult) Get(name string) (string, error) {
+	if p == nil {
+		return "", &ParameterNodeError{Key: name}
+	}
+
+	switch strings.ToLower(name) {
+	case "hook-id":
+		return p.HookID, nil
+	case "status":
+		return p.Status, nil
+	case "exit-code":
+		return strconv.Itoa(p.ExitCode), nil
+	case "output":
+		return p.Output, nil
+	case "stdout":
+		return p.Stdout, nil
+	case "stderr":
+		return p.Stderr, nil
+	case "error":
+		return p.Error, nil
+	default:
+		return "", &ParameterNodeError{Key: name}
+	}
+}
+
+// FollowUps returns the IDs of the hooks to trigger for the given outcome.
+// A timeout triggers on-failure when on-timeout is not configured.
+func (h *Hook) FollowUps(outcome string) []string {
+	switch outcome {
+	case OutcomeSuccess:
+		return h.OnSuccess
+	case OutcomeTimeout:
+		if len(h.OnTimeout) > 0 {
+			return h.OnTimeout
+		}
+		return h.OnFailure
+	case OutcomeFailure:
+		return h.OnFailure
+	default:
+		return nil
+	}
+}
+
+// References returns the IDs of all hooks h can run: follow-up hooks and
+// hooks referenced by its actions.
+func (h *Hook) References() []string {
+	refs := make([]string, 0, len(h.OnSuccess)+len(h.OnFailure)+len(h.OnTimeout))
+	refs = append(refs, h.OnSuccess...)
+	refs = append(refs, h.OnFailure...)
+	refs = append(refs, h.OnTimeout...)
+	for _, a := range h.Actions {
+		if a.Hook != "" {
+			refs = append(refs, a.Hook)
+		}
+	}
+	return refs
+}
+
+// FindReferenceCycle returns a cycle of hook references, e.g. [a b a], or
+// nil when the hooks form no cycle. References to unknown hooks are ignored.
+func FindReferenceCycle(hooks []Hook) []string {
+	byID := make(map[string]*Hook, len(hooks))
+	for i := range hooks {
+		byID[hooks[i].ID] = &hooks[i]
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(hooks))
+	var path []string
+
+	var visit func(id string) []string
+	visit = func(id string) []string {
+		switch state[id] {
+		case visiting:
+			for i, p := range path {
+				if p == id {
+					return append(append([]string(nil), path[i:]...), id)
+				}
+			}
+		case done:
+			return nil
+		}
+
+		h, ok := byID[id]
+		if !ok {
+			return nil
+		}
+		state[id] = visiting
+		path = append(path, id)
+		for _, ref := range h.References() {
+			if cycle := visit(ref); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = done
+		return nil
+	}
+
+	for i := range hooks {
+		if cycle := visit(hooks[i].ID); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
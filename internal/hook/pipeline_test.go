@@ -0,0 +1,78 @@
+package hook
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPreviousResultArgument(t *testing.T) {
+	r := &Request{Previous: &PreviousResult{HookID: "build", Status: OutcomeFailure, ExitCode: 3, Output: "out+err", Stdout: "out", Stderr: "err", Error: "exit status 3"}}
+
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"hook-id", "build", true},
+		{"status", OutcomeFailure, true},
+		{"exit-code", "3", true},
+		{"output", "out+err", true},
+		{"stdout", "out", true},
+		{"stderr", "err", true},
+		{"error", "exit status 3", true},
+		{"signal", "", false},
+	}
+
+	for _, tt := range tests {
+		a := Argument{Source: SourcePreviousResult, Name: tt.name}
+		got, err := a.Get(r)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("%s: got %q, %v", tt.name, got, err)
+		}
+	}
+
+	a := Argument{Source: SourcePreviousResult, Name: "exit-code"}
+	if _, err := a.Get(&Request{}); !IsParameterNodeError(err) {
+		t.Errorf("expected parameter node error without previous result, got %v", err)
+	}
+}
+
+func TestFollowUps(t *testing.T) {
+	h := &Hook{OnSuccess: []string{"notify"}, OnFailure: []string{"rollback"}}
+	if got := h.FollowUps(OutcomeSuccess); !reflect.DeepEqual(got, []string{"notify"}) {
+		t.Errorf("success: got %v", got)
+	}
+	if got := h.FollowUps(OutcomeTimeout); !reflect.DeepEqual(got, []string{"rollback"}) {
+		t.Errorf("timeout falls back to on-failure: got %v", got)
+	}
+
+	h.OnTimeout = []string{"page"}
+	if got := h.FollowUps(OutcomeTimeout); !reflect.DeepEqual(got, []string{"page"}) {
+		t.Errorf("timeout: got %v", got)
+	}
+	if got := h.FollowUps("cancelled"); got != nil {
+		t.Errorf("unknown outcome: got %v", got)
+	}
+}
+
+func TestFindReferenceCycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		hooks []Hook
+		want  []string
+	}{
+		{"no references", []Hook{{ID: "a"}, {ID: "b"}}, nil},
+		{"chain", []Hook{{ID: "a", OnSuccess: []string{"b"}}, {ID: "b", OnFailure: []string{"c"}}, {ID: "c"}}, nil},
+		{"diamond", []Hook{{ID: "a", OnSuccess: []string{"b", "c"}}, {ID: "b", OnSuccess: []string{"d"}}, {ID: "c", OnSuccess: []string{"d"}}, {ID: "d"}}, nil},
+		{"unknown reference", []Hook{{ID: "a", OnSuccess: []string{"missing"}}}, nil},
+		{"self", []Hook{{ID: "a", OnTimeout: []string{"a"}}}, []string{"a", "a"}},
+		{"cycle", []Hook{{ID: "a", OnSuccess: []string{"b"}}, {ID: "b", OnFailure: []string{"c"}}, {ID: "c", OnSuccess: []string{"b"}}}, []string{"b", "c", "b"}},
+		{"through actions", []Hook{{ID: "a", Actions: []Action{{Hook: "b"}}}, {ID: "b", OnSuccess: []string{"a"}}}, []string{"a", "b", "a"}},
+	}
+
+	for _, tt := range tests {
+		if got := FindReferenceCycle(tt.hooks); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package hook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ProtobufConfig describes how protobuf payloads of a hook are decoded, e.g.
+// {"descriptor-set": "protos/events.pb", "message": "acme.events.Push"}.
+type ProtobufConfig struct {
+	// DescriptorSet is the path of a serialized FileDescriptorSet, as written
+	// by protoc --include_imports --descriptor_set_out, relative to the
+	// hooks file.
+	DescriptorSet string `json:"descriptor-set"`
+	// Message is the fully qualified name of the payload message.
+	Message string `json:"message"`
+
+	message protoreflect.MessageDescriptor
+	types   *dynamicpb.Types
+}
+
+// Validate checks that the descriptor set and the message are set.
+func (c *ProtobufConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.DescriptorSet == "" {
+		return errors.New("protobuf: missing descriptor-set")
+	}
+	if c.Message == "" {
+		return errors.New("protobuf: missing message")
+	}
+	return nil
+}
+
+// Compile loads the descriptor set and resolves the message. A relative
+// descriptor-set path is resolved against dir.
+func (c *ProtobufConfig) Compile(dir string) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	path := c.DescriptorSet
+	if !filepath.IsAbs(path) && dir != "" {
+		path = filepath.Join(dir, path)
+	}
+
+	message, types, err := loadProtobufMessage(path, c.Message)
+	if err != nil {
+		return err
+	}
+	c.message, c.types = message, types
+	return nil
+}
+
+// loadProtobufMessage reads a descriptor set and looks up the named message.
+func loadProtobufMessage(path, name string) (protoreflect.MessageDescriptor, *dynamicpb.Types, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, nil, fmt.Errorf("protobuf: %w", err)
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, nil, fmt.Errorf("protobuf: invalid descriptor set %s: %w", path, err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, nil, fmt.Errorf("protobuf: invalid descriptor set %s: %w", path, err)
+	}
+
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, nil, fmt.Errorf("protobuf: message %q: %w", name, err)
+	}
+	message, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, nil, fmt.Errorf("protobuf: %q is not a message", name)
+	}
+
+	return message, dynamicpb.NewTypes(files), nil
+}
+
+// ParseProtobufPayload decodes the body as the message configured for the
+// hook. The payload is the protobuf JSON mapping of the message, using the
+// field names of the .proto file and including fields with default values.
+func (r *Request) ParseProtobufPayload(c *ProtobufConfig) error {
+	if c == nil {
+		return errors.New("error parsing protobuf payload: hook has no protobuf descriptor")
+	}
+
+	message, types := c.message, c.types
+	if message == nil {
+		// the hook was not loaded from a hooks file
+		var err error
+		if message, types, err = loadProtobufMessage(c.DescriptorSet, c.Message); err != nil {
+			return err
+		}
+	}
+
+	msg := dynamicpb.NewMessage(message)
+	if err := (proto.UnmarshalOptions{Resolver: types}).Unmarshal(r.Body, msg); err != nil {
+		return fmt.Errorf("error parsing protobuf payload %+v", err)
+	}
+
+	body, err := protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true, Resolver: types}.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("error parsing protobuf payload %+v", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var payload map[string]interface{}
+	if err := decoder.Decode(&payload); err != nil {
+		return fmt.Errorf("error parsing protobuf payload %+v", err)
+	}
+
+	r.Payload = payload
+	return nil
+}
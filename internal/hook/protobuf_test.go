@@ -0,0 +1,155 @@
+package hook
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// writeTestDescriptorSet writes a descriptor set for
+//
+//	package acme.events;
+//	message Push { string ref = 1; int64 size = 2; repeated Commit commits = 3; }
+//	message Commit { string id = 1; }
+func writeTestDescriptorSet(t *testing.T, path string) *descriptorpb.FileDescriptorProto {
+	t.Helper()
+
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Type:     typ.Enum(),
+			Label:    label.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("events.proto"),
+		Package: proto.String("acme.events"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Push"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("ref", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+					field("size", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64, optional, ""),
+					field("commits", 3, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, descriptorpb.FieldDescriptorProto_LABEL_REPEATED, ".acme.events.Commit"),
+				},
+			},
+			{
+				Name:  proto.String("Commit"),
+				Field: []*descriptorpb.FieldDescriptorProto{field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, "")},
+			},
+		},
+	}
+
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{file}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestParseProtobufPayload(t *testing.T) {
+	dir := t.TempDir()
+	file := writeTestDescriptorSet(t, filepath.Join(dir, "events.pb"))
+
+	fd, err := protodesc.NewFile(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	push := dynamicpb.NewMessage(fd.Messages().ByName("Push"))
+	push.Set(push.Descriptor().Fields().ByName("ref"), protoreflect.ValueOfString("refs/heads/main"))
+	commits := push.Mutable(push.Descriptor().Fields().ByName("commits")).List()
+	commit := commits.NewElement()
+	commit.Message().Set(fd.Messages().ByName("Commit").Fields().ByName("id"), protoreflect.ValueOfString("a1"))
+	commits.Append(commit)
+	body, err := proto.Marshal(push)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hooksFile := filepath.Join(dir, "hooks.json")
+	err = os.WriteFile(hooksFile, []byte(`[{"id": "push", "protobuf": {"descriptor-set": "events.pb", "message": "acme.events.Push"}}]`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hooks Hooks
+	if err := hooks.LoadFromFile(hooksFile, false); err != nil {
+		t.Fatal(err)
+	}
+
+	r := Request{Body: body}
+	if err := r.ParseProtobufPayload(hooks.Match("push").Protobuf); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"ref":     "refs/heads/main",
+		"size":    "0",
+		"commits": []interface{}{map[string]interface{}{"id": "a1"}},
+	}
+	if !reflect.DeepEqual(r.Payload, want) {
+		t.Errorf("got payload %#v, want %#v", r.Payload, want)
+	}
+
+	arg := Argument{Source: SourcePayload, Name: "commits.0.id"}
+	if got, err := arg.Get(&r); err != nil || got != "a1" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	r = Request{Body: []byte{0xff}}
+	if err := r.ParseProtobufPayload(hooks.Match("push").Protobuf); err == nil {
+		t.Error("expected an error for an invalid message")
+	}
+	if err := r.ParseProtobufPayload(nil); err == nil {
+		t.Error("expected an error without a descriptor")
+	}
+}
+
+func TestProtobufConfigCompile(t *testing.T) {
+	dir := t.TempDir()
+	writeTestDescriptorSet(t, filepath.Join(dir, "events.pb"))
+
+	tests := []struct {
+		description string
+		config      ProtobufConfig
+		ok          bool
+	}{
+		{"message", ProtobufConfig{DescriptorSet: "events.pb", Message: "acme.events.Commit"}, true},
+		{"missing message", ProtobufConfig{DescriptorSet: "events.pb"}, false},
+		{"missing descriptor set", ProtobufConfig{Message: "acme.events.Push"}, false},
+		{"unknown message", ProtobufConfig{DescriptorSet: "events.pb", Message: "acme.events.Tag"}, false},
+		{"not a message", ProtobufConfig{DescriptorSet: "events.pb", Message: "acme.events.Push.ref"}, false},
+		{"missing file", ProtobufConfig{DescriptorSet: "missing.pb", Message: "acme.events.Push"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			if err := tt.config.Compile(dir); (err == nil) != tt.ok {
+				t.Errorf("got error %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+
+	out, err := json.Marshal(&ProtobufConfig{DescriptorSet: "events.pb", Message: "acme.events.Push"})
+	if err != nil || string(out) != `{"descriptor-set":"events.pb","message":"acme.events.Push"}` {
+		t.Errorf("got %s, %v", out, err)
+	}
+}
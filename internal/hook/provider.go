@@ -0,0 +1,240 @@
+package hook
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	secure "github.com/soulteary/secure-kit"
+)
+
+// Constants for the provider signature MatchRule types
+const (
+	GitHubSignature    string = "github-signature"
+	GitLabToken        string = "gitlab-token"
+	StripeSignature    string = "stripe-signature"
+	SlackSignature     string = "slack-signature"
+	BitbucketSignature string = "bitbucket-signature"
+	GiteaSignature     string = "gitea-signature"
+)
+
+// SignatureTolerance is the maximum age of timestamped signatures (Stripe, Slack),
+// matching the window recommended by both providers.
+const SignatureTolerance = 5 * time.Minute
+
+// Reasons recorded for failed signature checks.
+const (
+	SignatureReasonMissing  string = "missing_signature"
+	SignatureReasonInvalid  string = "invalid_signature"
+	SignatureReasonOutdated string = "outdated_signature"
+	SignatureReasonError    string = "error"
+)
+
+// SignatureCheck is the outcome of a provider or public key signature rule,
+// recorded on the request so that the caller can emit audit logs and metrics.
+type SignatureCheck struct {
+	// Provider is the provider name, e.g. "github" or "stripe".
+	Provider string
+	Valid    bool
+	// Reason is one of the SignatureReason constants when Valid is false.
+	Reason string
+}
+
+// providerSignatureChecks maps provider rule types to their verifiers.
+var providerSignatureChecks = map[string]func(r *Request, rule MatchRule) (bool, error){
+	GitHubSignature: func(r *Request, rule MatchRule) (bool, error) {
+		return CheckGitHubSignature(r, rule.Secret)
+	},
+	GitLabToken: func(r *Request, rule MatchRule) (bool, error) {
+		return CheckGitLabToken(r, rule.Secret)
+	},
+	StripeSignature: func(r *Request, rule MatchRule) (bool, error) {
+		return CheckStripeSignature(r, rule.Secret, SignatureTolerance)
+	},
+	SlackSignature: func(r *Request, rule MatchRule) (bool, error) {
+		return CheckSlackSignature(r, rule.Secret, SignatureTolerance)
+	},
+	BitbucketSignature: func(r *Request, rule MatchRule) (bool, error) {
+		return CheckBitbucketSignature(r, rule.Secret)
+	},
+	GiteaSignature: func(r *Request, rule MatchRule) (bool, error) {
+		return CheckGiteaSignature(r, rule.Secret)
+	},
+}
+
+// IsProviderSignature returns whether the MatchRule type is a provider signature preset.
+func IsProviderSignature(ruleType string) bool {
+	_, ok := providerSignatureChecks[ruleType]
+	return ok
+}
+
+// evaluateProviderSignature runs the provider verifier for the rule and
+// records the outcome on the request.
+func (r MatchRule) evaluateProviderSignature(req *Request) (bool, error) {
+	ok, err := providerSignatureChecks[r.Type](req, r)
+	req.recordSignatureCheck(r.Type, ok, err)
+	return ok, err
+}
+
+// recordSignatureCheck records the outcome of the signature rule of type ruleType.
+func (r *Request) recordSignatureCheck(ruleType string, ok bool, err error) {
+	check := SignatureCheck{
+		Provider: strings.TrimSuffix(strings.TrimSuffix(ruleType, "-signature"), "-token"),
+		Valid:    ok && err == nil,
+	}
+	if !check.Valid {
+		var se *SignatureError
+		switch {
+		case err == nil, IsParameterNodeError(err):
+			check.Reason = SignatureReasonMissing
+		case errors.As(err, &se) && se.Signature == "outdated":
+			check.Reason = SignatureReasonOutdated
+		case errors.As(err, &se):
+			check.Reason = SignatureReasonInvalid
+		default:
+			check.Reason = SignatureReasonError
+		}
+	}
+	r.SignatureChecks = append(r.SignatureChecks, check)
+}
+
+// CheckGitHubSignature verifies GitHub webhook signatures.
+// GitHub sends the HMAC-SHA256 of the body in the X-Hub-Signature-256 header
+// with a "sha256=" prefix.
+func CheckGitHubSignature(r *Request, secret string) (bool, error) {
+	return checkHeaderSignature256(r, "X-Hub-Signature-256", secret)
+}
+
+// CheckBitbucketSignature verifies Bitbucket webhook signatures.
+// Bitbucket sends the HMAC-SHA256 of the body in the X-Hub-Signature header
+// with a "sha256=" prefix.
+func CheckBitbucketSignature(r *Request, secret string) (bool, error) {
+	return checkHeaderSignature256(r, "X-Hub-Signature", secret)
+}
+
+// CheckGiteaSignature verifies Gitea webhook signatures.
+// Gitea sends the hex encoded HMAC-SHA256 of the body in the X-Gitea-Signature header.
+func CheckGiteaSignature(r *Request, secret string) (bool, error) {
+	return checkHeaderSignature256(r, "X-Gitea-Signature", secret)
+}
+
+func checkHeaderSignature256(r *Request, header, secret string) (bool, error) {
+	signature, ok := headerValue(r, header)
+	if !ok {
+		return false, nil
+	}
+
+	if _, err := CheckPayloadSignature256(r.SignedBody(), secret, signature); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CheckGitLabToken verifies the secret token GitLab sends in the X-Gitlab-Token header.
+func CheckGitLabToken(r *Request, secret string) (bool, error) {
+	token, ok := headerValue(r, "X-Gitlab-Token")
+	if !ok {
+		return false, nil
+	}
+	if secret == "" {
+		return false, errors.New("signature validation secret can not be empty")
+	}
+
+	if !compare(token, secret) {
+		// Do not echo the provided token in logs.
+		return false, &SignatureError{Signature: "X-Gitlab-Token"}
+	}
+	return true, nil
+}
+
+// CheckStripeSignature verifies Stripe webhook signatures.
+// The Stripe-Signature header has the form "t=<unix time>,v1=<signature>[,v1=...]",
+// where each v1 signature is the HMAC-SHA256 of "<t>.<body>". Requests signed
+// longer than tolerance ago are rejected.
+func CheckStripeSignature(r *Request, secret string, tolerance time.Duration) (bool, error) {
+	header, ok := headerValue(r, "Stripe-Signature")
+	if !ok {
+		return false, nil
+	}
+	if secret == "" {
+		return false, errors.New("signature validation secret can not be empty")
+	}
+
+	timestamps := ExtractCommaSeparatedValues(header, "t=")
+	signatures := ExtractCommaSeparatedValues(header, "v1=")
+	if len(timestamps) != 1 || len(signatures) == 0 {
+		return false, errors.New("malformed 'Stripe-Signature' header")
+	}
+
+	body := r.SignedBody()
+	payload := make([]byte, 0, len(timestamps[0])+1+len(body))
+	payload = append(payload, timestamps[0]+"."...)
+	payload = append(payload, body...)
+
+	verifier := secure.NewHMACVerifier(secure.HMACSHA256, secret)
+	if valid, _ := verifier.VerifyAny(payload, signatures); !valid {
+		return false, &SignatureError{Signatures: signatures}
+	}
+
+	return checkSignatureTimestamp(timestamps[0], tolerance)
+}
+
+// CheckSlackSignature verifies Slack request signatures.
+// The X-Slack-Signature header carries "v0=" followed by the HMAC-SHA256 of
+// "v0:<X-Slack-Request-Timestamp>:<body>". Requests signed longer than
+// tolerance ago are rejected.
+func CheckSlackSignature(r *Request, secret string, tolerance time.Duration) (bool, error) {
+	signature, ok := headerValue(r, "X-Slack-Signature")
+	if !ok {
+		return false, nil
+	}
+	timestamp, ok := headerValue(r, "X-Slack-Request-Timestamp")
+	if !ok {
+		return false, nil
+	}
+	if secret == "" {
+		return false, errors.New("signature validation secret can not be empty")
+	}
+
+	if !strings.HasPrefix(signature, "v0=") {
+		return false, errors.New("malformed 'X-Slack-Signature' header")
+	}
+	signature = strings.TrimPrefix(signature, "v0=")
+
+	body := r.SignedBody()
+	payload := make([]byte, 0, len(timestamp)+4+len(body))
+	payload = append(payload, "v0:"+timestamp+":"...)
+	payload = append(payload, body...)
+
+	verifier := secure.NewHMACVerifier(secure.HMACSHA256, secret)
+	if !verifier.Verify(payload, signature) {
+		return false, &SignatureError{Signature: signature}
+	}
+
+	return checkSignatureTimestamp(timestamp, tolerance)
+}
+
+// checkSignatureTimestamp makes sure the unix timestamp is within tolerance of now.
+func checkSignatureTimestamp(timestamp string, tolerance time.Duration) (bool, error) {
+	secs, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid signature timestamp %q", timestamp)
+	}
+
+	if math.Abs(time.Since(time.Unix(secs, 0)).Seconds()) > tolerance.Seconds() {
+		return false, &SignatureError{Signature: "outdated"}
+	}
+	return true, nil
+}
+
+// headerValue returns the value of the canonical header name from the parsed headers.
+func headerValue(r *Request, name string) (string, bool) {
+	if r.Headers == nil {
+		return "", false
+	}
+	v, ok := r.Headers[name].(string)
+	return v, ok
+}
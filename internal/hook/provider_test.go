@@ -0,0 +1,209 @@
+package hook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func hmacSHA256Hex(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestCheckHeaderProviderSignatures(t *testing.T) {
+	body := `{"ref":"refs/heads/main"}`
+	signature := hmacSHA256Hex("secret", body)
+
+	tests := []struct {
+		description string
+		check       func(r *Request, secret string) (bool, error)
+		headers     map[string]interface{}
+		secret      string
+		ok          bool
+		wantErr     bool
+	}{
+		{"github valid", CheckGitHubSignature, map[string]interface{}{"X-Hub-Signature-256": "sha256=" + signature}, "secret", true, false},
+		{"github wrong secret", CheckGitHubSignature, map[string]interface{}{"X-Hub-Signature-256": "sha256=" + signature}, "other", false, true},
+		{"github missing header", CheckGitHubSignature, map[string]interface{}{"X-Hub-Signature": "sha256=" + signature}, "secret", false, false},
+		{"github missing secret", CheckGitHubSignature, map[string]interface{}{"X-Hub-Signature-256": "sha256=" + signature}, "", false, true},
+		{"bitbucket valid", CheckBitbucketSignature, map[string]interface{}{"X-Hub-Signature": "sha256=" + signature}, "secret", true, false},
+		{"bitbucket wrong signature", CheckBitbucketSignature, map[string]interface{}{"X-Hub-Signature": "sha256=00"}, "secret", false, true},
+		{"gitea valid", CheckGiteaSignature, map[string]interface{}{"X-Gitea-Signature": signature}, "secret", true, false},
+		{"gitea missing header", CheckGiteaSignature, map[string]interface{}{}, "secret", false, false},
+		{"gitlab valid", CheckGitLabToken, map[string]interface{}{"X-Gitlab-Token": "secret"}, "secret", true, false},
+		{"gitlab wrong token", CheckGitLabToken, map[string]interface{}{"X-Gitlab-Token": "guess"}, "secret", false, true},
+		{"gitlab missing header", CheckGitLabToken, nil, "secret", false, false},
+		{"gitlab missing secret", CheckGitLabToken, map[string]interface{}{"X-Gitlab-Token": "secret"}, "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			r := &Request{Headers: tt.headers, Body: []byte(body)}
+			ok, err := tt.check(r, tt.secret)
+			if ok != tt.ok || (err != nil) != tt.wantErr {
+				t.Errorf("got ok=%v err=%v, want ok=%v wantErr=%v", ok, err, tt.ok, tt.wantErr)
+			}
+			if err != nil && strings.Contains(err.Error(), "guess") {
+				t.Errorf("error message should not disclose the provided token: %s", err)
+			}
+		})
+	}
+}
+
+func TestCheckStripeSignature(t *testing.T) {
+	body := `{"id":"evt_1","type":"charge.succeeded"}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+	sign := func(ts string) string { return hmacSHA256Hex("whsec_test", ts+"."+body) }
+
+	tests := []struct {
+		description string
+		header      string
+		tolerance   time.Duration
+		ok          bool
+		wantErr     bool
+	}{
+		{"valid", "t=" + now + ",v1=" + sign(now), SignatureTolerance, true, false},
+		{"valid among several signatures", "t=" + now + ",v1=deadbeef,v1=" + sign(now) + ",v0=ignored", SignatureTolerance, true, false},
+		{"wrong signature", "t=" + now + ",v1=deadbeef", SignatureTolerance, false, true},
+		{"signature for other timestamp", "t=" + now + ",v1=" + sign(old), SignatureTolerance, false, true},
+		{"outdated", "t=" + old + ",v1=" + sign(old), SignatureTolerance, false, true},
+		{"outdated within custom tolerance", "t=" + old + ",v1=" + sign(old), time.Hour, true, false},
+		{"missing timestamp", "v1=" + sign(now), SignatureTolerance, false, true},
+		{"missing v1", "t=" + now, SignatureTolerance, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			r := &Request{Headers: map[string]interface{}{"Stripe-Signature": tt.header}, Body: []byte(body)}
+			ok, err := CheckStripeSignature(r, "whsec_test", tt.tolerance)
+			if ok != tt.ok || (err != nil) != tt.wantErr {
+				t.Errorf("got ok=%v err=%v, want ok=%v wantErr=%v", ok, err, tt.ok, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckSlackSignature(t *testing.T) {
+	// Example request from the Slack documentation.
+	body := "token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebhook-collect&text=&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c"
+	secret := "8f742231b10e8888abcd99yyyzzz85a5"
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	tests := []struct {
+		description string
+		headers     map[string]interface{}
+		tolerance   time.Duration
+		ok          bool
+		wantErr     bool
+	}{
+		{
+			"documented example",
+			map[string]interface{}{"X-Slack-Request-Timestamp": "1531420618", "X-Slack-Signature": "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503"},
+			time.Since(time.Unix(1531420618, 0)) + time.Hour, true, false,
+		},
+		{
+			"documented example is outdated",
+			map[string]interface{}{"X-Slack-Request-Timestamp": "1531420618", "X-Slack-Signature": "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503"},
+			SignatureTolerance, false, true,
+		},
+		{
+			"valid",
+			map[string]interface{}{"X-Slack-Request-Timestamp": now, "X-Slack-Signature": "v0=" + hmacSHA256Hex(secret, "v0:"+now+":"+body)},
+			SignatureTolerance, true, false,
+		},
+		{
+			"wrong signature",
+			map[string]interface{}{"X-Slack-Request-Timestamp": now, "X-Slack-Signature": "v0=deadbeef"},
+			SignatureTolerance, false, true,
+		},
+		{
+			"missing version prefix",
+			map[string]interface{}{"X-Slack-Request-Timestamp": now, "X-Slack-Signature": hmacSHA256Hex(secret, "v0:"+now+":"+body)},
+			SignatureTolerance, false, true,
+		},
+		{
+			"invalid timestamp",
+			map[string]interface{}{"X-Slack-Request-Timestamp": "yesterday", "X-Slack-Signature": "v0=" + hmacSHA256Hex(secret, "v0:yesterday:"+body)},
+			SignatureTolerance, false, true,
+		},
+		{
+			"missing timestamp header",
+			map[string]interface{}{"X-Slack-Signature": "v0=deadbeef"},
+			SignatureTolerance, false, false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			r := &Request{Headers: tt.headers, Body: []byte(body)}
+			ok, err := CheckSlackSignature(r, secret, tt.tolerance)
+			if ok != tt.ok || (err != nil) != tt.wantErr {
+				t.Errorf("got ok=%v err=%v, want ok=%v wantErr=%v", ok, err, tt.ok, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMatchRule_ProviderSignatureRecordsCheck(t *testing.T) {
+	body := `{"action":"opened"}`
+	now := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	tests := []struct {
+		description string
+		rule        MatchRule
+		headers     map[string]interface{}
+		ok          bool
+		want        SignatureCheck
+	}{
+		{
+			"valid",
+			MatchRule{Type: GitHubSignature, Secret: "secret"},
+			map[string]interface{}{"X-Hub-Signature-256": "sha256=" + hmacSHA256Hex("secret", body)},
+			true, SignatureCheck{Provider: "github", Valid: true},
+		},
+		{
+			"missing header",
+			MatchRule{Type: GiteaSignature, Secret: "secret"},
+			map[string]interface{}{},
+			false, SignatureCheck{Provider: "gitea", Reason: SignatureReasonMissing},
+		},
+		{
+			"invalid token",
+			MatchRule{Type: GitLabToken, Secret: "secret"},
+			map[string]interface{}{"X-Gitlab-Token": "wrong"},
+			false, SignatureCheck{Provider: "gitlab", Reason: SignatureReasonInvalid},
+		},
+		{
+			"outdated",
+			MatchRule{Type: StripeSignature, Secret: "secret"},
+			map[string]interface{}{"Stripe-Signature": "t=" + now + ",v1=" + hmacSHA256Hex("secret", now+"."+body)},
+			false, SignatureCheck{Provider: "stripe", Reason: SignatureReasonOutdated},
+		},
+		{
+			"configuration error",
+			MatchRule{Type: BitbucketSignature},
+			map[string]interface{}{"X-Hub-Signature": "sha256=00"},
+			false, SignatureCheck{Provider: "bitbucket", Reason: SignatureReasonError},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			r := &Request{Headers: tt.headers, Body: []byte(body)}
+			ok, _ := tt.rule.Evaluate(r)
+			if ok != tt.ok {
+				t.Errorf("got ok=%v, want %v", ok, tt.ok)
+			}
+			if len(r.SignatureChecks) != 1 || r.SignatureChecks[0] != tt.want {
+				t.Errorf("got signature checks %+v, want [%+v]", r.SignatureChecks, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,397 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default values used when loading public keys.
+const (
+	// DefaultJWKSCacheTTL is how long keys fetched from a JWKS URL are cached.
+	DefaultJWKSCacheTTL = time.Hour
+	// jwksMinRefresh limits how often an unknown key ID triggers a refetch.
+	jwksMinRefresh = time.Minute
+	// maxJWKSSize is the maximum size of a JWKS document.
+	maxJWKSSize = 1 << 20
+)
+
+// PublicKey is a public key used to verify asymmetric signatures.
+type PublicKey struct {
+	// ID is the key ID ("kid") of JWK keys.
+	ID  string
+	Key crypto.PublicKey
+}
+
+// PublicKeySource describes where public keys are loaded from. Exactly one of
+// Key, File and JWKSURL must be set.
+type PublicKeySource struct {
+	// Key is an inline PEM block, JWK or JWKS document, or a hex/base64
+	// encoded raw Ed25519 key.
+	Key string `json:"key,omitempty"`
+	// File is a file with the same formats as Key.
+	File string `json:"file,omitempty"`
+	// JWKSURL is an https URL of a JWKS document; http is only accepted for
+	// loopback hosts.
+	JWKSURL string `json:"jwks-url,omitempty"`
+	// CacheTTL is how long keys fetched from JWKSURL are cached.
+	CacheTTL Duration `json:"cache-ttl,omitempty"`
+}
+
+// Validate checks the key source configuration.
+func (s *PublicKeySource) Validate() error {
+	set := 0
+	for _, v := range []string{s.Key, s.File, s.JWKSURL} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("exactly one of key, file and jwks-url must be set")
+	}
+	if s.JWKSURL != "" && !isSecureKeyURL(s.JWKSURL) {
+		return fmt.Errorf("invalid jwks-url %q: scheme must be https", s.JWKSURL)
+	}
+	if s.CacheTTL < 0 {
+		return errors.New("cache-ttl must not be negative")
+	}
+	if s.Key != "" {
+		if _, err := ParsePublicKeys([]byte(s.Key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Keys returns the public keys of the source. Keys loaded from files are
+// reloaded when the file changes; keys fetched from a JWKS URL are cached for
+// CacheTTL and refetched early when no key matches kid.
+func (s *PublicKeySource) Keys(ctx context.Context, kid string) ([]PublicKey, error) {
+	switch {
+	case s.Key != "":
+		return ParsePublicKeys([]byte(s.Key))
+	case s.File != "":
+		return loadPublicKeyFile(s.File)
+	case s.JWKSURL != "":
+		ttl := DefaultJWKSCacheTTL
+		if s.CacheTTL > 0 {
+			ttl = s.CacheTTL.Duration()
+		}
+		return jwksKeys(ctx, s.JWKSURL, ttl, kid)
+	}
+	return nil, errors.New("no public key configured")
+}
+
+// SelectPublicKeys returns the keys with the given ID, or all keys if kid is empty.
+func SelectPublicKeys(keys []PublicKey, kid string) []PublicKey {
+	if kid == "" {
+		return keys
+	}
+	var selected []PublicKey
+	for _, k := range keys {
+		if k.ID == kid {
+			selected = append(selected, k)
+		}
+	}
+	return selected
+}
+
+type cachedKeyFile struct {
+	modTime time.Time
+	size    int64
+	keys    []PublicKey
+}
+
+var keyFileCache sync.Map // map[string]*cachedKeyFile
+
+func loadPublicKeyFile(path string) ([]PublicKey, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if cached, ok := keyFileCache.Load(path); ok {
+		c := cached.(*cachedKeyFile)
+		if c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+			return c.keys, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := ParsePublicKeys(data)
+	if err != nil {
+		return nil, fmt.Errorf("error loading public key file %s: %w", path, err)
+	}
+	keyFileCache.Store(path, &cachedKeyFile{modTime: info.ModTime(), size: info.Size(), keys: keys})
+	return keys, nil
+}
+
+type cachedJWKS struct {
+	mu      sync.Mutex
+	fetched time.Time
+	keys    []PublicKey
+}
+
+var (
+	jwksCache  sync.Map // map[string]*cachedJWKS
+	jwksClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// isSecureKeyURL reports whether keys can be fetched from rawURL: anyone who
+// can tamper with a plain http connection could replace the keys, so http is
+// only accepted for loopback hosts.
+func isSecureKeyURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		if u.Hostname() == "localhost" {
+			return true
+		}
+		ip := net.ParseIP(u.Hostname())
+		return ip != nil && ip.IsLoopback()
+	}
+	return false
+}
+
+func jwksKeys(ctx context.Context, url string, ttl time.Duration, kid string) ([]PublicKey, error) {
+	v, _ := jwksCache.LoadOrStore(url, &cachedJWKS{})
+	c := v.(*cachedJWKS)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	age := time.Since(c.fetched)
+	stale := c.keys == nil || age > ttl
+	// An unknown key ID usually means the provider rotated its keys.
+	rotated := kid != "" && len(SelectPublicKeys(c.keys, kid)) == 0 && age > jwksMinRefresh
+	if !stale && !rotated {
+		return c.keys, nil
+	}
+
+	keys, err := fetchJWKS(ctx, url)
+	if err != nil {
+		if c.keys != nil && !stale {
+			return c.keys, nil
+		}
+		return nil, err
+	}
+	c.keys, c.fetched = keys, time.Now()
+	return keys, nil
+}
+
+func fetchJWKS(ctx context.Context, url string) ([]PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := jwksClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching JWKS %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching JWKS %s: unexpected status %d", url, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching JWKS %s: %w", url, err)
+	}
+	keys, err := ParsePublicKeys(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing JWKS %s: %w", url, err)
+	}
+	return keys, nil
+}
+
+// ParsePublicKeys parses PEM blocks (PKIX public keys, PKCS #1 RSA public keys
+// and certificates), a JWK or JWKS document, or a hex/base64 encoded raw
+// Ed25519 public key.
+func ParsePublicKeys(data []byte) ([]PublicKey, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("empty public key")
+	}
+
+	switch {
+	case bytes.HasPrefix(data, []byte("-----BEGIN")):
+		return parsePEMPublicKeys(data)
+	case data[0] == '{':
+		return parseJWKS(data)
+	}
+
+	raw, err := hex.DecodeString(string(data))
+	if err != nil {
+		raw, err = decodeBase64(string(data))
+	}
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, errors.New("unsupported public key format")
+	}
+	return []PublicKey{{Key: ed25519.PublicKey(raw)}}, nil
+}
+
+func parsePEMPublicKeys(data []byte) ([]PublicKey, error) {
+	var keys []PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		var key crypto.PublicKey
+		var err error
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			cert, err = x509.ParseCertificate(block.Bytes)
+			if err == nil {
+				key = cert.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s PEM block: %w", block.Type, err)
+		}
+		keys = append(keys, PublicKey{Key: key})
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no public key found in PEM data")
+	}
+	return keys, nil
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func parseJWKS(data []byte) ([]PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWK: %w", err)
+	}
+	if set.Keys == nil {
+		var single jsonWebKey
+		if err := json.Unmarshal(data, &single); err != nil {
+			return nil, fmt.Errorf("invalid JWK: %w", err)
+		}
+		set.Keys = []jsonWebKey{single}
+	}
+
+	keys := make([]PublicKey, 0, len(set.Keys))
+	for _, jwk := range set.Keys {
+		// Keys for encryption cannot verify signatures.
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWK %q: %w", jwk.Kid, err)
+		}
+		keys = append(keys, PublicKey{ID: jwk.Kid, Key: key})
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no signing key found in JWKS")
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, errors.New("invalid modulus")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		size := (curve.Params().BitSize + 7) / 8
+		if errX != nil || errY != nil || len(x) != size || len(y) != size {
+			return nil, errors.New("invalid coordinates")
+		}
+		point := append(append([]byte{4}, x...), y...)
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// decodeBase64 decodes standard or URL-safe base64, with or without padding.
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	}
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,138 @@
+package hook
+
+import (
+	"net/textproto"
+	"strings"
+)
+
+// RequestValue names a header or query value of a request.
+type RequestValue struct {
+	Source string `json:"source"`
+	Name   string `json:"name"`
+}
+
+func (v RequestValue) String() string {
+	return v.Source + " " + v.Name
+}
+
+// UsesRedactedValue returns the first value of r.Redacted that the command
+// arguments, forward templates or actions of h depend on. lookup resolves
+// actions referencing other hooks; it may be nil.
+func (h *Hook) UsesRedactedValue(r *Request, lookup func(id string) *Hook) (RequestValue, bool) {
+	if r == nil || len(r.Redacted) == 0 {
+		return RequestValue{}, false
+	}
+	return h.usesRedactedValue(r.Redacted, lookup, map[string]bool{})
+}
+
+func (h *Hook) usesRedactedValue(redacted []RequestValue, lookup func(id string) *Hook, seen map[string]bool) (RequestValue, bool) {
+	if h == nil || seen[h.ID] {
+		return RequestValue{}, false
+	}
+	seen[h.ID] = true
+
+	for i, a := range h.Actions {
+		if a.Hook == "" {
+			if v, ok := h.ActionHook(i).usesRedactedValue(redacted, lookup, seen); ok {
+				return v, true
+			}
+			continue
+		}
+		if lookup == nil {
+			continue
+		}
+		if v, ok := lookup(a.Hook).usesRedactedValue(redacted, lookup, seen); ok {
+			return v, true
+		}
+	}
+
+	for _, v := range redacted {
+		for _, args := range [][]Argument{h.PassArgumentsToCommand, h.PassEnvironmentToCommand, h.PassFileToCommand, h.JSONStringParameters} {
+			for _, arg := range args {
+				if arg.uses(v) {
+					return v, true
+				}
+			}
+		}
+		if h.Forward.uses(v) {
+			return v, true
+		}
+	}
+	return RequestValue{}, false
+}
+
+// uses reports whether the argument reads v.
+func (ha *Argument) uses(v RequestValue) bool {
+	switch ha.Source {
+	case SourceEntireHeaders:
+		return v.Source == SourceHeader
+	case SourceEntireQuery:
+		return v.Source == SourceQuery
+	case SourceHeader:
+		if v.Source != SourceHeader {
+			return false
+		}
+		return ha.Syntax == ArgumentSyntaxJSONPath || nameUses(textproto.CanonicalMIMEHeaderKey(ha.Name), textproto.CanonicalMIMEHeaderKey(v.Name))
+	case SourceQuery, SourceQueryAlias:
+		if v.Source != SourceQuery {
+			return false
+		}
+		return ha.Syntax == ArgumentSyntaxJSONPath || nameUses(ha.Name, v.Name)
+	}
+	return false
+}
+
+// nameUses reports whether the dotted parameter name key reads the value
+// stored under name.
+func nameUses(key, name string) bool {
+	return key == name || strings.HasPrefix(key, name+".")
+}
+
+// uses reports whether the url, header or body template of the forward
+// configuration may read v, either by name or through the whole Headers or
+// Query map.
+func (f *ForwardConfig) uses(v RequestValue) bool {
+	if f == nil {
+		return false
+	}
+
+	field := ".Headers"
+	if v.Source == SourceQuery {
+		field = ".Query"
+	}
+
+	texts := []string{f.URL, f.Body}
+	for _, text := range f.Headers {
+		texts = append(texts, text)
+	}
+	for _, text := range texts {
+		if templateUses(text, field, v.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// templateUses reports whether the template text reads name from field.
+// Values selected with another name, such as {{ index .Headers "X-Event" }},
+// don't count; passing the whole map, such as {{ json .Headers }}, does.
+func templateUses(text, field, name string) bool {
+	if !strings.Contains(text, field) {
+		return false
+	}
+	if strings.Contains(strings.ToLower(text), strings.ToLower(name)) {
+		return true
+	}
+
+	for rest := text; ; {
+		i := strings.Index(rest, field)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(field):]
+		next := strings.TrimLeft(rest, " ")
+		if !strings.HasPrefix(next, ".") && !strings.HasPrefix(next, `"`) && !strings.HasPrefix(next, "`") {
+			return true
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package hook
+
+import "testing"
+
+func TestUsesRedactedValue(t *testing.T) {
+	auth := RequestValue{Source: SourceHeader, Name: "Authorization"}
+	token := RequestValue{Source: SourceQuery, Name: "token"}
+
+	forward := func(headers map[string]string, body string) *ForwardConfig {
+		return &ForwardConfig{URL: "https://example.com/hook", Headers: headers, Body: body}
+	}
+
+	for _, tt := range []struct {
+		desc string
+		hook *Hook
+		want bool
+	}{
+		{"header argument", &Hook{ID: "h", PassEnvironmentToCommand: []Argument{{Source: SourceHeader, Name: "authorization"}}}, true},
+		{"other header argument", &Hook{ID: "h", PassArgumentsToCommand: []Argument{{Source: SourceHeader, Name: "X-Event"}}}, false},
+		{"query argument", &Hook{ID: "h", PassFileToCommand: []Argument{{Source: SourceQueryAlias, Name: "token"}}}, true},
+		{"entire headers", &Hook{ID: "h", PassArgumentsToCommand: []Argument{{Source: SourceEntireHeaders}}}, true},
+		{"payload argument", &Hook{ID: "h", PassArgumentsToCommand: []Argument{{Source: SourcePayload, Name: "token"}}}, false},
+		{"forward header by name", &Hook{ID: "h", Forward: forward(map[string]string{"Authorization": `{{ index .Headers "Authorization" }}`}, "")}, true},
+		{"forward other header", &Hook{ID: "h", Forward: forward(map[string]string{"X-Event": `{{ index .Headers "X-Event" }}`}, "")}, false},
+		{"forward all headers", &Hook{ID: "h", Forward: forward(nil, `{{ json .Headers }}`)}, true},
+		{"forward query", &Hook{ID: "h", Forward: &ForwardConfig{URL: "https://example.com/?t={{ .Query.token }}"}}, true},
+		{"action", &Hook{ID: "h", Actions: []Action{{ExecuteCommand: "true"}, {ExecuteCommand: "true", PassArgumentsToCommand: []Argument{{Source: SourceHeader, Name: "Authorization"}}}}}, true},
+		{"referenced hook", &Hook{ID: "h", Actions: []Action{{Hook: "target"}}}, true},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			lookup := func(id string) *Hook {
+				if id == "target" {
+					return &Hook{ID: "target", PassArgumentsToCommand: []Argument{{Source: SourceEntireQuery}}}
+				}
+				return nil
+			}
+			_, got := tt.hook.UsesRedactedValue(&Request{Redacted: []RequestValue{auth, token}}, lookup)
+			if got != tt.want {
+				t.Errorf("UsesRedactedValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := (&Hook{ID: "h", PassArgumentsToCommand: []Argument{{Source: SourceEntireHeaders}}}).UsesRedactedValue(&Request{}, nil); ok {
+		t.Error("UsesRedactedValue() = true for a request without redacted values")
+	}
+}
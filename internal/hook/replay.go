@@ -0,0 +1,347 @@
+package hook
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/soulteary/webhook/internal/logger"
+)
+
+// Default values used by ReplayGuardRule.
+const (
+	DefaultReplayMaxSkew  = 5 * time.Minute
+	DefaultReplayNonceTTL = 24 * time.Hour
+	// DefaultNonceStoreSize is the number of nonces kept by the in-memory store.
+	DefaultNonceStoreSize = 100000
+	// nonceStoreWarnRatio is the fill ratio of the in-memory store at which a
+	// warning is logged.
+	nonceStoreWarnRatio = 0.9
+)
+
+// Reasons recorded for requests rejected by a ReplayGuardRule.
+const (
+	ReplayReasonMissingTimestamp string = "missing_timestamp"
+	ReplayReasonInvalidTimestamp string = "invalid_timestamp"
+	ReplayReasonTimestampSkew    string = "timestamp_skew"
+	ReplayReasonMissingNonce     string = "missing_nonce"
+	ReplayReasonDuplicateNonce   string = "duplicate_nonce"
+)
+
+// ReplayRejection describes a request rejected by a ReplayGuardRule, recorded
+// on the request so that the caller can emit audit logs.
+type ReplayRejection struct {
+	Reason string
+	Nonce  string
+}
+
+// ReplayGuardRule rejects requests whose timestamp is too far from the current
+// time and requests whose nonce (e.g. a delivery ID) has already been seen.
+type ReplayGuardRule struct {
+	// Timestamp references the time the request was signed, as unix seconds,
+	// unix milliseconds, RFC 3339 or RFC 1123.
+	Timestamp *Argument `json:"timestamp,omitempty"`
+	// MaxSkew is the maximum allowed difference between Timestamp and now.
+	MaxSkew Duration `json:"max-skew,omitempty"`
+	// Nonce references a value that must be unique per request.
+	Nonce *Argument `json:"nonce,omitempty"`
+	// NonceTTL is how long a nonce is remembered.
+	NonceTTL Duration `json:"nonce-ttl,omitempty"`
+}
+
+// Validate checks the replay guard configuration.
+func (r *ReplayGuardRule) Validate() error {
+	if r.Timestamp == nil && r.Nonce == nil {
+		return errors.New("replay-guard requires timestamp or nonce")
+	}
+	if r.MaxSkew < 0 {
+		return errors.New("replay-guard max-skew must not be negative")
+	}
+	if r.NonceTTL < 0 {
+		return errors.New("replay-guard nonce-ttl must not be negative")
+	}
+	for _, arg := range []*Argument{r.Timestamp, r.Nonce} {
+		if arg == nil {
+			continue
+		}
+		if err := arg.Validate(); err != nil {
+			return fmt.Errorf("invalid replay-guard rule: %w", err)
+		}
+	}
+	return nil
+}
+
+// maxSkew returns the configured max-skew or DefaultReplayMaxSkew.
+func (r *ReplayGuardRule) maxSkew() time.Duration {
+	if r.MaxSkew > 0 {
+		return time.Duration(r.MaxSkew)
+	}
+	return DefaultReplayMaxSkew
+}
+
+// nonceTTL returns the configured nonce-ttl or DefaultReplayNonceTTL.
+func (r *ReplayGuardRule) nonceTTL() time.Duration {
+	if r.NonceTTL > 0 {
+		return time.Duration(r.NonceTTL)
+	}
+	return DefaultReplayNonceTTL
+}
+
+// Evaluate ReplayGuardRule will return true if the request is fresh and its
+// nonce has not been seen before. The nonce is not remembered here: it is
+// kept on the request and remembered by CommitNonces once the whole trigger
+// rule matched, and never in a dry run.
+func (r ReplayGuardRule) Evaluate(req *Request) (bool, error) {
+	if r.Timestamp != nil {
+		value, err := r.Timestamp.Get(req)
+		if err != nil {
+			req.rejectReplay(ReplayReasonMissingTimestamp, "")
+			return false, nil
+		}
+		ts, err := parseReplayTimestamp(value)
+		if err != nil {
+			req.rejectReplay(ReplayReasonInvalidTimestamp, "")
+			return false, nil
+		}
+		if math.Abs(float64(time.Since(ts))) > float64(r.maxSkew()) {
+			req.rejectReplay(ReplayReasonTimestampSkew, "")
+			return false, nil
+		}
+	}
+
+	if r.Nonce != nil {
+		nonce, err := r.Nonce.Get(req)
+		if err != nil || nonce == "" {
+			req.rejectReplay(ReplayReasonMissingNonce, "")
+			return false, nil
+		}
+		// A dry run must not use up the nonce of the request.
+		if req.DryRun {
+			return true, nil
+		}
+
+		key := nonceKey(req.HookID, nonce)
+		seen, err := CurrentNonceStore().Seen(req.context(), key)
+		if err != nil {
+			return false, err
+		}
+		if seen {
+			req.rejectReplay(ReplayReasonDuplicateNonce, nonce)
+			return false, nil
+		}
+		req.pendingNonces = append(req.pendingNonces, pendingNonce{key: key, nonce: nonce, ttl: r.nonceTTL()})
+	}
+
+	return true, nil
+}
+
+// pendingNonce is a nonce accepted by a replay-guard rule that has not been
+// remembered yet.
+type pendingNonce struct {
+	key   string
+	nonce string
+	ttl   time.Duration
+}
+
+// nonceKey scopes nonce to the hook, so that deliveries to different hooks
+// sharing a nonce, e.g. a counter, don't reject each other.
+func nonceKey(hookID, nonce string) string {
+	return hookID + ":" + nonce
+}
+
+// CommitNonces remembers the nonces accepted by the replay-guard rules of
+// the request. It must be called once the whole trigger rule matched, so
+// that requests failing other rules, e.g. a signature check, do not use up
+// the nonce of a genuine delivery. It returns false if a concurrent request
+// used one of the nonces first.
+func (r *Request) CommitNonces() (bool, error) {
+	pending := r.pendingNonces
+	r.pendingNonces = nil
+
+	for _, p := range pending {
+		duplicate, err := CurrentNonceStore().Remember(r.context(), p.key, p.ttl)
+		if err != nil {
+			return false, err
+		}
+		if duplicate {
+			r.rejectReplay(ReplayReasonDuplicateNonce, p.nonce)
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (r *Request) context() context.Context {
+	if r.RawRequest != nil {
+		return r.RawRequest.Context()
+	}
+	return context.Background()
+}
+
+func (r *Request) rejectReplay(reason, nonce string) {
+	r.ReplayRejections = append(r.ReplayRejections, ReplayRejection{Reason: reason, Nonce: nonce})
+}
+
+// parseReplayTimestamp parses unix seconds (optionally fractional), unix
+// milliseconds, RFC 3339 and RFC 1123 timestamps.
+func parseReplayTimestamp(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		// Values this large are milliseconds (seconds would be after the year 33000).
+		if n > 1e12 {
+			return time.UnixMilli(int64(n)), nil
+		}
+		sec, frac := math.Modf(n)
+		return time.Unix(int64(sec), int64(frac*1e9)), nil
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, time.RFC1123Z, time.RFC1123} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("unsupported timestamp format")
+}
+
+// NonceStore remembers nonces used by ReplayGuardRule. Nonces are passed
+// prefixed with the ID of the hook, e.g. "github-push:<delivery ID>".
+type NonceStore interface {
+	// Seen reports whether nonce is stored and has not expired.
+	Seen(ctx context.Context, nonce string) (bool, error)
+	// Remember stores nonce for ttl and reports whether it was already stored.
+	Remember(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// ErrNonceStoreFull is returned by MemoryNonceStore when it holds its
+// capacity of unexpired nonces.
+var ErrNonceStoreFull = errors.New("nonce store is full")
+
+var (
+	nonceStoreMu sync.RWMutex
+	nonceStore   NonceStore = NewMemoryNonceStore(DefaultNonceStoreSize)
+)
+
+// SetNonceStore replaces the store used by replay-guard rules, e.g. with a
+// store shared by several webhook instances.
+func SetNonceStore(store NonceStore) {
+	nonceStoreMu.Lock()
+	defer nonceStoreMu.Unlock()
+	nonceStore = store
+}
+
+// CurrentNonceStore returns the store used by replay-guard rules.
+func CurrentNonceStore() NonceStore {
+	nonceStoreMu.RLock()
+	defer nonceStoreMu.RUnlock()
+	return nonceStore
+}
+
+// MemoryNonceStore is an in-memory NonceStore holding at most capacity
+// nonces. Only expired nonces are evicted: once it is full of unexpired
+// nonces, new nonces are rejected with ErrNonceStoreFull, so that flooding
+// the store cannot make a captured request replayable again. A warning is
+// logged when the store is nearly full.
+type MemoryNonceStore struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*nonceEntry
+	expiry   nonceHeap
+	warned   bool
+}
+
+type nonceEntry struct {
+	nonce   string
+	expires time.Time
+	index   int
+}
+
+// nonceHeap orders nonce entries by expiry time.
+type nonceHeap []*nonceEntry
+
+func (h nonceHeap) Len() int           { return len(h) }
+func (h nonceHeap) Less(i, j int) bool { return h[i].expires.Before(h[j].expires) }
+func (h nonceHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *nonceHeap) Push(x interface{}) {
+	entry := x.(*nonceEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *nonceHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// NewMemoryNonceStore creates an in-memory NonceStore holding at most capacity nonces.
+func NewMemoryNonceStore(capacity int) *MemoryNonceStore {
+	if capacity <= 0 {
+		capacity = DefaultNonceStoreSize
+	}
+	return &MemoryNonceStore{
+		capacity: capacity,
+		entries:  make(map[string]*nonceEntry),
+	}
+}
+
+// Seen implements NonceStore.
+func (s *MemoryNonceStore) Seen(_ context.Context, nonce string) (bool, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[nonce]
+	return ok && now.Before(entry.expires), nil
+}
+
+// Remember implements NonceStore.
+func (s *MemoryNonceStore) Remember(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[nonce]; ok {
+		if now.Before(entry.expires) {
+			return true, nil
+		}
+		entry.expires = now.Add(ttl)
+		heap.Fix(&s.expiry, entry.index)
+		return false, nil
+	}
+
+	for s.expiry.Len() > 0 && !now.Before(s.expiry[0].expires) {
+		expired := heap.Pop(&s.expiry).(*nonceEntry)
+		delete(s.entries, expired.nonce)
+	}
+	if len(s.entries) >= s.capacity {
+		return false, ErrNonceStoreFull
+	}
+
+	entry := &nonceEntry{nonce: nonce, expires: now.Add(ttl)}
+	heap.Push(&s.expiry, entry)
+	s.entries[nonce] = entry
+
+	// Warn once each time the store fills up, until expired nonces are evicted.
+	nearlyFull := float64(len(s.entries)) >= nonceStoreWarnRatio*float64(s.capacity)
+	if nearlyFull && !s.warned {
+		logger.Warnf("replay-guard nonce store holds %d of %d nonces; requests with new nonces are rejected once it is full (see -nonce-store-size)", len(s.entries), s.capacity)
+	}
+	s.warned = nearlyFull
+	return false, nil
+}
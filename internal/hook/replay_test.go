@@ -0,0 +1,231 @@
+package hook
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseReplayTimestamp(t *testing.T) {
+	ref := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Time
+		ok    bool
+	}{
+		{strconv.FormatInt(ref.Unix(), 10), ref, true},
+		{strconv.FormatInt(ref.UnixMilli(), 10), ref, true},
+		{strconv.FormatInt(ref.Unix(), 10) + ".5", ref.Add(500 * time.Millisecond), true},
+		{"2024-05-01T12:00:00Z", ref, true},
+		{"Wed, 01 May 2024 12:00:00 GMT", ref, true},
+		{"Wed, 01 May 2024 14:00:00 +0200", ref, true},
+		{"yesterday", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseReplayTimestamp(tt.value)
+			if (err == nil) != tt.ok {
+				t.Fatalf("parseReplayTimestamp(%q) error = %v, want ok=%v", tt.value, err, tt.ok)
+			}
+			if tt.ok && !got.Equal(tt.want) {
+				t.Errorf("parseReplayTimestamp(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayGuardRule_Evaluate(t *testing.T) {
+	defer SetNonceStore(CurrentNonceStore())
+	SetNonceStore(NewMemoryNonceStore(10))
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	timestamp := &Argument{Source: SourceHeader, Name: "X-Timestamp"}
+	nonce := &Argument{Source: SourceHeader, Name: "X-Github-Delivery"}
+
+	tests := []struct {
+		description string
+		rule        ReplayGuardRule
+		headers     map[string]interface{}
+		ok          bool
+		reason      string
+	}{
+		{"fresh timestamp", ReplayGuardRule{Timestamp: timestamp}, map[string]interface{}{"X-Timestamp": now}, true, ""},
+		{"outdated timestamp", ReplayGuardRule{Timestamp: timestamp}, map[string]interface{}{"X-Timestamp": old}, false, ReplayReasonTimestampSkew},
+		{"outdated timestamp within max-skew", ReplayGuardRule{Timestamp: timestamp, MaxSkew: Duration(2 * time.Hour)}, map[string]interface{}{"X-Timestamp": old}, true, ""},
+		{"missing timestamp", ReplayGuardRule{Timestamp: timestamp}, map[string]interface{}{}, false, ReplayReasonMissingTimestamp},
+		{"invalid timestamp", ReplayGuardRule{Timestamp: timestamp}, map[string]interface{}{"X-Timestamp": "soon"}, false, ReplayReasonInvalidTimestamp},
+		{"first delivery", ReplayGuardRule{Nonce: nonce}, map[string]interface{}{"X-Github-Delivery": "delivery-1"}, true, ""},
+		{"redelivery", ReplayGuardRule{Nonce: nonce}, map[string]interface{}{"X-Github-Delivery": "delivery-1"}, false, ReplayReasonDuplicateNonce},
+		{"missing nonce", ReplayGuardRule{Nonce: nonce}, map[string]interface{}{}, false, ReplayReasonMissingNonce},
+		{"stale request does not consume nonce", ReplayGuardRule{Timestamp: timestamp, Nonce: nonce}, map[string]interface{}{"X-Timestamp": old, "X-Github-Delivery": "delivery-2"}, false, ReplayReasonTimestampSkew},
+		{"nonce still usable", ReplayGuardRule{Timestamp: timestamp, Nonce: nonce}, map[string]interface{}{"X-Timestamp": now, "X-Github-Delivery": "delivery-2"}, true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			req := &Request{Headers: tt.headers}
+			ok, err := Rules{ReplayGuard: &tt.rule}.Evaluate(req)
+			if ok && err == nil {
+				ok, err = req.CommitNonces()
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != tt.ok {
+				t.Errorf("got ok=%v, want %v", ok, tt.ok)
+			}
+			if tt.reason == "" {
+				if len(req.ReplayRejections) != 0 {
+					t.Errorf("unexpected rejections %+v", req.ReplayRejections)
+				}
+				return
+			}
+			if len(req.ReplayRejections) != 1 || req.ReplayRejections[0].Reason != tt.reason {
+				t.Errorf("got rejections %+v, want reason %s", req.ReplayRejections, tt.reason)
+			}
+		})
+	}
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryNonceStore(2)
+
+	remember := func(nonce string, ttl time.Duration) bool {
+		t.Helper()
+		seen, err := store.Remember(ctx, nonce, ttl)
+		if err != nil {
+			t.Fatalf("Remember(%q) error: %v", nonce, err)
+		}
+		return seen
+	}
+
+	if remember("a", time.Hour) {
+		t.Error("first use of a should not be a duplicate")
+	}
+	if !remember("a", time.Hour) {
+		t.Error("second use of a should be a duplicate")
+	}
+
+	if seen, _ := store.Seen(ctx, "a"); !seen {
+		t.Error("a should be seen")
+	}
+	if seen, _ := store.Seen(ctx, "b"); seen {
+		t.Error("b should not be seen before it is remembered")
+	}
+	if store.warned {
+		t.Error("half full store should not warn")
+	}
+
+	// unexpired nonces are never evicted, new nonces are rejected instead
+	if remember("expiring", 50*time.Millisecond) {
+		t.Error("first use of expiring should not be a duplicate")
+	}
+	if !store.warned {
+		t.Error("full store should warn")
+	}
+	if _, err := store.Remember(ctx, "c", time.Hour); err != ErrNonceStoreFull {
+		t.Errorf("got error %v, want ErrNonceStoreFull", err)
+	}
+	if !remember("a", time.Hour) {
+		t.Error("a should still be a duplicate when the store is full")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if seen, _ := store.Seen(ctx, "expiring"); seen {
+		t.Error("expired nonce should not be seen")
+	}
+	if remember("c", time.Hour) {
+		t.Error("c should be stored once the expired nonce is evicted")
+	}
+}
+
+func TestReplayGuardRule_CommitAfterTriggerRule(t *testing.T) {
+	defer SetNonceStore(CurrentNonceStore())
+	SetNonceStore(NewMemoryNonceStore(10))
+
+	rule := Rules{And: &AndRule{
+		{ReplayGuard: &ReplayGuardRule{Nonce: &Argument{Source: SourceHeader, Name: "X-Delivery"}}},
+		{Match: &MatchRule{Type: MatchValue, Value: "secret", Parameter: Argument{Source: SourceHeader, Name: "X-Token"}}},
+	}}
+	evaluate := func(token string) bool {
+		t.Helper()
+		req := &Request{Headers: map[string]interface{}{"X-Delivery": "delivery-1", "X-Token": token}}
+		ok, err := rule.Evaluate(req)
+		if ok && err == nil {
+			ok, err = req.CommitNonces()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	// a forged request replaying the delivery ID must not use it up
+	if evaluate("forged") {
+		t.Fatal("forged request matched")
+	}
+	if !evaluate("secret") {
+		t.Fatal("genuine delivery rejected")
+	}
+	if evaluate("secret") {
+		t.Error("replayed delivery matched")
+	}
+}
+
+func TestReplayGuardRule_NoncePerHook(t *testing.T) {
+	defer SetNonceStore(CurrentNonceStore())
+	store := NewMemoryNonceStore(10)
+	SetNonceStore(store)
+
+	rule := Rules{ReplayGuard: &ReplayGuardRule{Nonce: &Argument{Source: SourceHeader, Name: "X-Delivery"}}}
+	evaluate := func(hookID string) bool {
+		t.Helper()
+		req := &Request{HookID: hookID, Headers: map[string]interface{}{"X-Delivery": "1"}}
+		ok, err := rule.Evaluate(req)
+		if ok && err == nil {
+			ok, err = req.CommitNonces()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	if !evaluate("build") || !evaluate("deploy") {
+		t.Fatal("same nonce rejected for another hook")
+	}
+	if evaluate("deploy") {
+		t.Error("replayed delivery matched")
+	}
+	if seen, _ := store.Seen(context.Background(), "build:1"); !seen {
+		t.Error("nonce should be stored with the hook ID prefix")
+	}
+}
+
+func TestReplayGuardRule_Validate(t *testing.T) {
+	tests := []struct {
+		description string
+		rule        *Rules
+		ok          bool
+	}{
+		{"nil", nil, true},
+		{"nonce only", &Rules{ReplayGuard: &ReplayGuardRule{Nonce: &Argument{Source: SourceHeader, Name: "X-Id"}}}, true},
+		{"empty", &Rules{ReplayGuard: &ReplayGuardRule{}}, false},
+		{"negative max-skew", &Rules{ReplayGuard: &ReplayGuardRule{Timestamp: &Argument{Source: SourceHeader, Name: "X-Ts"}, MaxSkew: Duration(-time.Second)}}, false},
+		{"nested in and", &Rules{And: &AndRule{{Match: &MatchRule{Type: MatchValue}}, {Not: &NotRule{ReplayGuard: &ReplayGuardRule{}}}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if (err == nil) != tt.ok {
+				t.Errorf("Validate() error = %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
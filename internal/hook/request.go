@@ -8,6 +8,7 @@ import (
 	"net/url"
 
 	"github.com/clbanning/mxj/v2"
+	"github.com/invopop/yaml"
 )
 
 // Request represents a webhook request.
@@ -15,12 +16,22 @@ type Request struct {
 	// The request ID set by the RequestID middleware.
 	ID string
 
+	// HookID is the ID of the hook whose trigger rule is evaluated.
+	// Replay-guard nonces are remembered per hook.
+	HookID string
+
 	// The Content-Type of the request.
 	ContentType string
 
-	// The raw request body.
+	// The raw request body, decoded when it was sent with a Content-Encoding.
 	Body []byte
 
+	// CompressedBody is the request body as received when Body was decoded.
+	CompressedBody []byte
+
+	// Verify the compressed body in signature rules.
+	VerifyCompressedBody bool
+
 	// Headers is a map of the parsed headers.
 	Headers map[string]interface{}
 
@@ -35,6 +46,48 @@ type Request struct {
 
 	// Treat signature errors as simple validate failures.
 	AllowSignatureErrors bool
+
+	// Previous is the result of the hook that triggered this one through
+	// on-success, on-failure or on-timeout.
+	Previous *PreviousResult
+
+	// Chain lists the IDs of the hooks that led to this execution.
+	Chain []string
+
+	// SignatureChecks records the provider signature rules evaluated for this request.
+	SignatureChecks []SignatureCheck
+
+	// ReplayRejections records why replay-guard rules rejected this request.
+	ReplayRejections []ReplayRejection
+
+	// pendingNonces are the nonces accepted by replay-guard rules, remembered
+	// by CommitNonces.
+	pendingNonces []pendingNonce
+
+	// MismatchReason records why a rule with a dedicated reason, such as
+	// time-window, did not match.
+	MismatchReason string
+
+	// DryRun evaluates the trigger rules without side effects, such as
+	// remembering replay-guard nonces.
+	DryRun bool
+
+	// Trace is the trigger rule evaluation trace, recorded after EnableTrace.
+	Trace *RuleTrace
+
+	tracing    bool
+	traceStack []*RuleTrace
+
+	// JWTClaims holds the claims of the token validated by a jwt rule.
+	JWTClaims map[string]interface{}
+
+	// CloudEvent holds the context attributes of a CloudEvents request, in
+	// structured or binary mode.
+	CloudEvent map[string]interface{}
+
+	// Redacted lists the header and query values replaced with RedactedValue,
+	// e.g. when the request was restored from the job queue log.
+	Redacted []RequestValue
 }
 
 func (r *Request) ParseJSONPayload() error {
@@ -112,3 +165,49 @@ func (r *Request) ParseXMLPayload() error {
 
 	return nil
 }
+
+func (r *Request) ParseYAMLPayload() error {
+	body, err := yaml.YAMLToJSON(r.Body)
+	if err != nil {
+		return fmt.Errorf("error parsing YAML payload: %+v", err)
+	}
+
+	// the converted document is handled like a JSON payload, so numbers
+	// are kept as json.Number and a top-level sequence is stored under root
+	converted := Request{Body: body}
+	if err := converted.ParseJSONPayload(); err != nil {
+		return fmt.Errorf("error parsing YAML payload: %+v", err)
+	}
+
+	r.Payload = converted.Payload
+	return nil
+}
+
+// ParseNDJSONPayload parses newline delimited JSON. The records are stored
+// as an array under root, like a JSON array payload; blank lines are skipped.
+func (r *Request) ParseNDJSONPayload() error {
+	records := []interface{}{}
+
+	for i, line := range bytes.Split(r.Body, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.UseNumber()
+
+		var record interface{}
+		if err := decoder.Decode(&record); err != nil {
+			return fmt.Errorf("error parsing NDJSON payload on line %d: %+v", i+1, err)
+		}
+		if decoder.More() {
+			return fmt.Errorf("error parsing NDJSON payload on line %d: unexpected data after record", i+1)
+		}
+
+		records = append(records, record)
+	}
+
+	r.Payload = map[string]interface{}{"root": records}
+	return nil
+}
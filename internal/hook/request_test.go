@@ -162,3 +162,84 @@ func TestParseXMLPayload(t *testing.T) {
 		}
 	}
 }
+
+var parseYAMLPayloadTests = []struct {
+	body            []byte
+	expectedPayload map[string]interface{}
+	ok              bool
+}{
+	{
+		[]byte("ref: refs/heads/main\ncommits:\n  - id: 1\n    message: fix\n"),
+		map[string]interface{}{"ref": "refs/heads/main", "commits": []interface{}{map[string]interface{}{"id": json.Number("1"), "message": "fix"}}},
+		true,
+	},
+	{
+		[]byte("- a\n- b\n"),
+		map[string]interface{}{"root": []interface{}{"a", "b"}},
+		true,
+	},
+	{
+		[]byte("key: [unclosed"),
+		map[string]interface{}(nil),
+		false,
+	},
+}
+
+func TestParseYAMLPayload(t *testing.T) {
+	for _, tt := range parseYAMLPayloadTests {
+		r := Request{
+			Body: tt.body,
+		}
+		err := r.ParseYAMLPayload()
+		if (err == nil) != tt.ok {
+			t.Errorf("unexpected result given %q: %s\n", string(tt.body), err)
+		}
+
+		if !reflect.DeepEqual(tt.expectedPayload, r.Payload) {
+			t.Errorf("failed to parse yaml %q:\nexpected %#v,\ngot %#v", string(tt.body), tt.expectedPayload, r.Payload)
+		}
+	}
+}
+
+var parseNDJSONPayloadTests = []struct {
+	body            []byte
+	expectedPayload map[string]interface{}
+	ok              bool
+}{
+	{
+		[]byte("{\"id\": 1}\r\n\n{\"id\": 2}\n"),
+		map[string]interface{}{"root": []interface{}{map[string]interface{}{"id": json.Number("1")}, map[string]interface{}{"id": json.Number("2")}}},
+		true,
+	},
+	{
+		[]byte(""),
+		map[string]interface{}{"root": []interface{}{}},
+		true,
+	},
+	{
+		[]byte("{\"id\": 1}\n{\"id\": "),
+		map[string]interface{}(nil),
+		false,
+	},
+	{
+		[]byte("{\"id\": 1} {\"id\": 2}\n"),
+		map[string]interface{}(nil),
+		false,
+	},
+}
+
+func TestParseNDJSONPayload(t *testing.T) {
+	for _, tt := range parseNDJSONPayloadTests {
+		r := Request{
+			Body: tt.body,
+		}
+		err := r.ParseNDJSONPayload()
+		if (err == nil) != tt.ok {
+			t.Errorf("unexpected result given %q: %s\n", string(tt.body), err)
+		}
+
+		if !reflect.DeepEqual(tt.expectedPayload, r.Payload) {
+			t.Errorf("failed to parse ndjson %q:\nexpected %#v,\ngot %#v", string(tt.body), tt.expectedPayload, r.Payload)
+		}
+	}
+}
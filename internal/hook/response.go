@@ -0,0 +1,98 @@
+package hook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Sources of a structured command response.
+const (
+	CommandResponseStdout = "stdout"
+	CommandResponseFile   = "file"
+)
+
+// CommandResponseFileEnv is the environment variable holding the path of the
+// file a command writes its structured response to.
+const CommandResponseFileEnv = "WEBHOOK_RESPONSE_FILE"
+
+// MaxCommandResponseSize caps the size of a structured response file.
+const MaxCommandResponseSize = 1 << 20
+
+// CommandResponse is the JSON document a command can produce to control the
+// HTTP response of its hook.
+type CommandResponse struct {
+	Status  int               `json:"status,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// ParseCommandResponse decodes a structured command response.
+func ParseCommandResponse(data []byte) (*CommandResponse, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("empty command response")
+	}
+
+	var cr CommandResponse
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cr); err != nil {
+		return nil, fmt.Errorf("invalid command response: %w", err)
+	}
+	if dec.More() {
+		return nil, errors.New("invalid command response: unexpected data after the JSON document")
+	}
+	if cr.Status != 0 && !validHTTPStatus(cr.Status) {
+		return nil, fmt.Errorf("invalid command response status %d", cr.Status)
+	}
+
+	return &cr, nil
+}
+
+// BodyBytes returns the response body. A JSON string body is returned as its
+// plain text value; any other JSON value is returned as is and isJSON is true.
+func (cr *CommandResponse) BodyBytes() (body []byte, isJSON bool) {
+	raw := bytes.TrimSpace(cr.Body)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, false
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return []byte(s), false
+	}
+	return raw, true
+}
+
+// StatusForExitCode returns the HTTP status configured in exit-code-map for
+// the given exit code of the command.
+func (h *Hook) StatusForExitCode(code int) (int, bool) {
+	status, ok := h.ExitCodeMap[code]
+	return status, ok
+}
+
+// ValidateCommandResponse checks the command-response and exit-code-map settings.
+func (h *Hook) ValidateCommandResponse() error {
+	switch h.CommandResponse {
+	case "", CommandResponseStdout, CommandResponseFile:
+	default:
+		return fmt.Errorf("unknown command-response %q (must be %s or %s)", h.CommandResponse, CommandResponseStdout, CommandResponseFile)
+	}
+	if h.CommandResponse != "" && h.StreamCommandOutput {
+		return errors.New("command-response cannot be combined with stream-command-output")
+	}
+
+	for code, status := range h.ExitCodeMap {
+		if !validHTTPStatus(status) {
+			return fmt.Errorf("exit-code-map entry for exit code %d has invalid HTTP status %d", code, status)
+		}
+	}
+	return nil
+}
+
+func validHTTPStatus(status int) bool {
+	return status >= 100 && status <= 599 && http.StatusText(status) != ""
+}
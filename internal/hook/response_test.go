@@ -0,0 +1,85 @@
+package hook
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+var parseCommandResponseTests = []struct {
+	input    string
+	status   int
+	headers  map[string]string
+	body     string
+	bodyJSON bool
+	ok       bool
+}{
+	{`{"status": 201, "headers": {"X-Build": "42"}, "body": "created"}`, 201, map[string]string{"X-Build": "42"}, "created", false, true},
+	{`{"body": {"ok": true}}`, 0, nil, `{"ok": true}`, true, true},
+	{"\n{\"status\": 202}\n", 202, nil, "", false, true},
+	{`{"body": null}`, 0, nil, "", false, true},
+	{``, 0, nil, "", false, false},
+	{`building...`, 0, nil, "", false, false},
+	{`{"status": 999}`, 0, nil, "", false, false},
+	{`{"code": 200}`, 0, nil, "", false, false},
+	{`{"status": 200} {"status": 500}`, 0, nil, "", false, false},
+}
+
+func TestParseCommandResponse(t *testing.T) {
+	for _, tt := range parseCommandResponseTests {
+		cr, err := ParseCommandResponse([]byte(tt.input))
+		if (err == nil) != tt.ok {
+			t.Errorf("unexpected error state for %q: %v", tt.input, err)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+
+		body, isJSON := cr.BodyBytes()
+		if cr.Status != tt.status || !reflect.DeepEqual(cr.Headers, tt.headers) || string(body) != tt.body || isJSON != tt.bodyJSON {
+			t.Errorf("for %q: got status %d, headers %v, body %q (json: %t)", tt.input, cr.Status, cr.Headers, body, isJSON)
+		}
+	}
+}
+
+func TestValidateCommandResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		h    Hook
+		ok   bool
+	}{
+		{"disabled", Hook{}, true},
+		{"stdout", Hook{CommandResponse: CommandResponseStdout, ExitCodeMap: map[int]int{2: 409, 3: 503}}, true},
+		{"file", Hook{CommandResponse: CommandResponseFile}, true},
+		{"unknown source", Hook{CommandResponse: "stderr"}, false},
+		{"combined with streaming", Hook{CommandResponse: CommandResponseStdout, StreamCommandOutput: true}, false},
+		{"invalid status", Hook{ExitCodeMap: map[int]int{1: 42}}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.h.ValidateCommandResponse()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: unexpected error state: %v", tt.name, err)
+		}
+	}
+}
+
+func TestLoadHookWithExitCodeMap(t *testing.T) {
+	var hooks Hooks
+	data := []byte(`[{"id": "deploy", "execute-command": "/bin/true", "command-response": "file", "exit-code-map": {"2": 409, "75": 503}}]`)
+	if err := json.Unmarshal(data, &hooks); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	h := hooks[0]
+	if h.CommandResponse != CommandResponseFile {
+		t.Errorf("command-response: got %q", h.CommandResponse)
+	}
+	if status, ok := h.StatusForExitCode(75); !ok || status != 503 {
+		t.Errorf("exit code 75: got %d, %t", status, ok)
+	}
+	if _, ok := h.StatusForExitCode(1); ok {
+		t.Error("exit code 1 should not be mapped")
+	}
+}
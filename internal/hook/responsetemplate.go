@@ -0,0 +1,148 @@
+package hook
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// ResponseData is the data available to response-message and
+// response-headers templates.
+type ResponseData struct {
+	ForwardData
+	HookID string
+	// ExitCode and Output are set for hooks that include the command output
+	// in the response.
+	ExitCode int
+	Output   string
+}
+
+// NewResponseData returns the response template data for r.
+func NewResponseData(h *Hook, r *Request) *ResponseData {
+	return &ResponseData{
+		ForwardData: ForwardData{
+			ID:          r.ID,
+			ContentType: r.ContentType,
+			Body:        string(r.Body),
+			Headers:     r.Headers,
+			Query:       r.Query,
+			Payload:     r.Payload,
+		},
+		HookID: h.ID,
+	}
+}
+
+// isResponseTemplate reports whether a response value contains template
+// actions; other values are used as is.
+func isResponseTemplate(text string) bool {
+	return strings.Contains(text, "{{")
+}
+
+// responseTemplates holds the parsed response-message and response-headers
+// templates of a hook.
+type responseTemplates struct {
+	message *template.Template
+	// headers has an entry per response header, nil for static values.
+	headers []*template.Template
+}
+
+func parseResponseTemplates(h *Hook) (*responseTemplates, error) {
+	t := &responseTemplates{headers: make([]*template.Template, len(h.ResponseHeaders))}
+
+	if isResponseTemplate(h.ResponseMessage) {
+		message, err := parseForwardTemplate("response-message", h.ResponseMessage)
+		if err != nil {
+			return nil, fmt.Errorf("invalid response-message template: %w", err)
+		}
+		t.message = message
+	}
+
+	for i, header := range h.ResponseHeaders {
+		if !isResponseTemplate(header.Value) {
+			continue
+		}
+		value, err := parseForwardTemplate("response header "+header.Name, header.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid response header %s template: %w", header.Name, err)
+		}
+		t.headers[i] = value
+	}
+
+	return t, nil
+}
+
+// compileResponseTemplates parses the response templates once, so that
+// responses are rendered without parsing them again.
+func (h *Hook) compileResponseTemplates() error {
+	t, err := parseResponseTemplates(h)
+	if err != nil {
+		return err
+	}
+	h.responseTemplates = t
+	return nil
+}
+
+// ValidateResponseTemplates checks the response-message and response-headers
+// templates.
+func (h *Hook) ValidateResponseTemplates() error {
+	if h.responseTemplates != nil {
+		return nil
+	}
+	_, err := parseResponseTemplates(h)
+	return err
+}
+
+// HasResponseMessageTemplate reports whether response-message is a template.
+func (h *Hook) HasResponseMessageTemplate() bool {
+	return isResponseTemplate(h.ResponseMessage)
+}
+
+func (h *Hook) templates() (*responseTemplates, error) {
+	if h.responseTemplates != nil {
+		return h.responseTemplates, nil
+	}
+	// the hook was not loaded from a hooks file
+	return parseResponseTemplates(h)
+}
+
+// RenderResponseMessage renders response-message with data.
+func (h *Hook) RenderResponseMessage(data *ResponseData) (string, error) {
+	if !h.HasResponseMessageTemplate() {
+		return h.ResponseMessage, nil
+	}
+
+	t, err := h.templates()
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.message.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("error rendering response-message: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// RenderResponseHeaders renders the values of response-headers with data.
+func (h *Hook) RenderResponseHeaders(data *ResponseData) (ResponseHeaders, error) {
+	t, err := h.templates()
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(ResponseHeaders, len(h.ResponseHeaders))
+	for i, header := range h.ResponseHeaders {
+		headers[i] = header
+		if t.headers[i] == nil {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := t.headers[i].Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("error rendering response header %s: %w", header.Name, err)
+		}
+		headers[i].Value = buf.String()
+	}
+	return headers, nil
+}
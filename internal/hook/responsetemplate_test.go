@@ -0,0 +1,118 @@
+package hook
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHookRenderResponseMessage(t *testing.T) {
+	r := &Request{
+		ID:          "req-1",
+		ContentType: "application/json",
+		Body:        []byte(`{"ref": "main"}`),
+		Headers:     map[string]interface{}{"X-Event": "push"},
+		Query:       map[string]interface{}{"token": "abc"},
+		Payload:     map[string]interface{}{"ref": "main", "commits": []interface{}{"a1", "b2"}},
+	}
+
+	tests := []struct {
+		description string
+		message     string
+		exitCode    int
+		output      string
+		want        string
+		ok          bool
+	}{
+		{"static", "Hook executed", 0, "", "Hook executed", true},
+		{"parse error", "{{.HookID", 0, "", "", false},
+		{"payload", `{{.HookID}} {{.ID}} {{index .Headers "X-Event"}} {{.Query.token}} {{.Payload.ref}}`, 0, "", "deploy req-1 push abc main", true},
+		{"json", `{"text": {{json .Payload.commits}}}`, 0, "", `{"text": ["a1","b2"]}`, true},
+		{"output", `{{.ExitCode}}: {{.Output}}`, 3, "failed", "3: failed", true},
+		{"optional key", `{{with index .Payload "missing"}}{{.}}{{else}}none{{end}}`, 0, "", "none", true},
+		{"missing key", `{{.Payload.missing}}`, 0, "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			h := &Hook{ID: "deploy", ResponseMessage: tt.message}
+			data := NewResponseData(h, r)
+			data.ExitCode = tt.exitCode
+			data.Output = tt.output
+
+			got, err := h.RenderResponseMessage(data)
+			if (err == nil) != tt.ok {
+				t.Fatalf("got error %v, want ok=%v", err, tt.ok)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHookRenderResponseHeaders(t *testing.T) {
+	h := &Hook{
+		ID: "deploy",
+		ResponseHeaders: ResponseHeaders{
+			{Name: "Content-Type", Value: "application/json"},
+			{Name: "X-Request-Id", Value: "{{.ID}}"},
+			{Name: "X-Ref", Value: "{{.Payload.ref}}"},
+		},
+	}
+	r := &Request{ID: "req-1", Payload: map[string]interface{}{"ref": "main"}}
+
+	got, err := h.RenderResponseHeaders(NewResponseData(h, r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ResponseHeaders{
+		{Name: "Content-Type", Value: "application/json"},
+		{Name: "X-Request-Id", Value: "req-1"},
+		{Name: "X-Ref", Value: "main"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got headers %#v, want %#v", got, want)
+	}
+	if h.ResponseHeaders[1].Value != "{{.ID}}" {
+		t.Error("hook response headers modified")
+	}
+
+	r.Payload = nil
+	if _, err := h.RenderResponseHeaders(NewResponseData(h, r)); err == nil {
+		t.Error("expected an error for a missing payload value")
+	}
+}
+
+func TestHookValidateResponseTemplates(t *testing.T) {
+	tests := []struct {
+		description string
+		hook        Hook
+		ok          bool
+	}{
+		{"static", Hook{ResponseMessage: "ok", ResponseHeaders: ResponseHeaders{{Name: "X-A", Value: "b"}}}, true},
+		{"templates", Hook{ResponseMessage: "{{.HookID}}", ResponseHeaders: ResponseHeaders{{Name: "X-A", Value: "{{.ID}}"}}}, true},
+		{"invalid message", Hook{ResponseMessage: "{{.HookID"}, false},
+		{"invalid header", Hook{ResponseHeaders: ResponseHeaders{{Name: "X-A", Value: "{{end}}"}}}, false},
+		{"unknown function", Hook{ResponseMessage: "{{yaml .Payload}}"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			if err := tt.hook.Validate(); (err == nil) != tt.ok {
+				t.Errorf("got error %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+
+	hooksFile := filepath.Join(t.TempDir(), "hooks.json")
+	err := os.WriteFile(hooksFile, []byte(`[{"id": "deploy", "response-message": "{{.Payload.ref"}]`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hooks Hooks
+	if err := hooks.LoadFromFile(hooksFile, false); err == nil {
+		t.Error("expected an error loading an invalid response-message template")
+	}
+}
@@ -0,0 +1,129 @@
+package hook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Duration is a time.Duration that can be written in hook files either as a
+// Go duration string ("1m30s") or as a plain number of seconds.
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 || string(b) == "null" {
+		return nil
+	}
+
+	if b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		if s == "" {
+			*d = 0
+			return nil
+		}
+		if v, err := time.ParseDuration(s); err == nil {
+			*d = Duration(v)
+			return nil
+		}
+		// "30" 这类纯数字字符串按秒处理
+		secs, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+		*d = Duration(secs * float64(time.Second))
+		return nil
+	}
+
+	secs, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return fmt.Errorf("invalid duration %s", b)
+	}
+	*d = Duration(secs * float64(time.Second))
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Duration returns the value as a time.Duration.
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+const (
+	// DefaultRetryBackoff is the delay before the first retry when none is configured.
+	DefaultRetryBackoff = time.Second
+	// DefaultRetryMaxBackoff caps the delay between two attempts.
+	DefaultRetryMaxBackoff = 5 * time.Minute
+	// DefaultRetryMultiplier is the exponential backoff factor.
+	DefaultRetryMultiplier = 2.0
+)
+
+// RetryConfig describes how a failed asynchronous execution of a hook is retried.
+type RetryConfig struct {
+	MaxAttempts int      `json:"max-attempts,omitempty"`
+	Backoff     Duration `json:"backoff,omitempty"`
+	MaxBackoff  Duration `json:"max-backoff,omitempty"`
+	Multiplier  float64  `json:"multiplier,omitempty"`
+	Jitter      float64  `json:"jitter,omitempty"`
+}
+
+// Attempts returns the total number of attempts (first run included) allowed
+// by the configuration. A nil configuration means a single attempt.
+func (rc *RetryConfig) Attempts() int {
+	if rc == nil || rc.MaxAttempts <= 1 {
+		return 1
+	}
+	return rc.MaxAttempts
+}
+
+// Delay returns how long to wait after the given (1-based) failed attempt
+// before running the next one. rnd must return a value in [0, 1) and is only
+// used when Jitter is set.
+func (rc *RetryConfig) Delay(attempt int, rnd func() float64) time.Duration {
+	base := DefaultRetryBackoff
+	maxDelay := DefaultRetryMaxBackoff
+	multiplier := DefaultRetryMultiplier
+	var jitter float64
+
+	if rc != nil {
+		if rc.Backoff > 0 {
+			base = rc.Backoff.Duration()
+		}
+		if rc.MaxBackoff > 0 {
+			maxDelay = rc.MaxBackoff.Duration()
+		}
+		if rc.Multiplier >= 1 {
+			multiplier = rc.Multiplier
+		}
+		jitter = math.Min(math.Max(rc.Jitter, 0), 1)
+	}
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := float64(base) * math.Pow(multiplier, float64(attempt-1))
+	if delay > float64(maxDelay) || math.IsInf(delay, 0) || math.IsNaN(delay) {
+		delay = float64(maxDelay)
+	}
+
+	if jitter > 0 && rnd != nil {
+		// 在 [delay*(1-jitter), delay*(1+jitter)] 范围内随机取值，避免重试风暴
+		delay += delay * jitter * (2*rnd() - 1)
+	}
+
+	if delay < 0 {
+		delay = 0
+	}
+	return time.Duration(delay)
+}
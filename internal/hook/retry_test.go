@@ -0,0 +1,111 @@
+package hook
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+var durationUnmarshalTests = []struct {
+	input string
+	want  time.Duration
+	ok    bool
+}{
+	{`"1m30s"`, 90 * time.Second, true},
+	{`"500ms"`, 500 * time.Millisecond, true},
+	{`"30"`, 30 * time.Second, true},
+	{`2`, 2 * time.Second, true},
+	{`0.5`, 500 * time.Millisecond, true},
+	{`""`, 0, true},
+	{`null`, 0, true},
+	{`"soon"`, 0, false},
+	{`true`, 0, false},
+}
+
+func TestDurationUnmarshalJSON(t *testing.T) {
+	for _, tt := range durationUnmarshalTests {
+		var d Duration
+		err := json.Unmarshal([]byte(tt.input), &d)
+		if (err == nil) != tt.ok {
+			t.Errorf("unexpected error state for %s: %v", tt.input, err)
+			continue
+		}
+		if tt.ok && d.Duration() != tt.want {
+			t.Errorf("duration for %s: got %v, want %v", tt.input, d.Duration(), tt.want)
+		}
+	}
+}
+
+func TestDurationMarshalJSON(t *testing.T) {
+	b, err := json.Marshal(Duration(90 * time.Second))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(b) != `"1m30s"` {
+		t.Errorf("got %s, want \"1m30s\"", b)
+	}
+}
+
+func TestRetryConfigAttempts(t *testing.T) {
+	var nilConfig *RetryConfig
+	tests := []struct {
+		rc   *RetryConfig
+		want int
+	}{
+		{nilConfig, 1},
+		{&RetryConfig{}, 1},
+		{&RetryConfig{MaxAttempts: -3}, 1},
+		{&RetryConfig{MaxAttempts: 5}, 5},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rc.Attempts(); got != tt.want {
+			t.Errorf("Attempts() for %+v: got %d, want %d", tt.rc, got, tt.want)
+		}
+	}
+}
+
+func TestRetryConfigDelay(t *testing.T) {
+	half := func() float64 { return 0.5 }
+	low := func() float64 { return 0 }
+
+	tests := []struct {
+		name    string
+		rc      *RetryConfig
+		attempt int
+		rnd     func() float64
+		want    time.Duration
+	}{
+		{"nil config uses defaults", nil, 1, nil, DefaultRetryBackoff},
+		{"exponential growth", &RetryConfig{Backoff: Duration(time.Second)}, 3, nil, 4 * time.Second},
+		{"custom multiplier", &RetryConfig{Backoff: Duration(time.Second), Multiplier: 3}, 3, nil, 9 * time.Second},
+		{"capped at max backoff", &RetryConfig{Backoff: Duration(time.Second), MaxBackoff: Duration(5 * time.Second)}, 10, nil, 5 * time.Second},
+		{"huge attempt is capped", &RetryConfig{Backoff: Duration(time.Second)}, 100000, nil, DefaultRetryMaxBackoff},
+		{"attempt below one", &RetryConfig{Backoff: Duration(2 * time.Second)}, 0, nil, 2 * time.Second},
+		{"jitter centred", &RetryConfig{Backoff: Duration(time.Second), Jitter: 0.5}, 1, half, time.Second},
+		{"jitter lower bound", &RetryConfig{Backoff: Duration(time.Second), Jitter: 0.5}, 1, low, 500 * time.Millisecond},
+		{"jitter clamped to one", &RetryConfig{Backoff: Duration(time.Second), Jitter: 3}, 1, low, 0},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rc.Delay(tt.attempt, tt.rnd); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoadHookWithRetry(t *testing.T) {
+	var hooks Hooks
+	data := []byte(`[{"id": "deploy", "execute-command": "/bin/true", "retry": {"max-attempts": 3, "backoff": "2s", "max-backoff": 60, "jitter": 0.2}}]`)
+	if err := json.Unmarshal(data, &hooks); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rc := hooks[0].Retry
+	if rc == nil {
+		t.Fatal("expected retry config to be set")
+	}
+	if rc.MaxAttempts != 3 || rc.Backoff.Duration() != 2*time.Second || rc.MaxBackoff.Duration() != time.Minute || rc.Jitter != 0.2 {
+		t.Errorf("unexpected retry config: %+v", rc)
+	}
+}
@@ -0,0 +1,105 @@
+package hook
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Namespaces that can be requested for a sandboxed command (Linux only).
+const (
+	NamespaceMount = "mount"
+	NamespacePID   = "pid"
+)
+
+// SandboxConfig restricts the privileges, environment and resources of the
+// command executed by a hook.
+type SandboxConfig struct {
+	// UID and GID run the command as the given user and group.
+	UID *int `json:"uid,omitempty"`
+	GID *int `json:"gid,omitempty"`
+
+	// CleanEnv starts the command without the webhook's environment; only
+	// variables listed in EnvAllowlist and the ones passed by the hook are set.
+	CleanEnv bool `json:"clean-env,omitempty"`
+	// EnvAllowlist limits the inherited environment to the listed names.
+	// A trailing "*" matches a prefix, e.g. "LC_*".
+	EnvAllowlist []string `json:"env-allowlist,omitempty"`
+
+	// CPUSeconds, AddressSpace (bytes) and OpenFiles set RLIMIT_CPU,
+	// RLIMIT_AS and RLIMIT_NOFILE for the command (Linux only).
+	CPUSeconds   uint64 `json:"cpu-seconds,omitempty"`
+	AddressSpace uint64 `json:"address-space,omitempty"`
+	OpenFiles    uint64 `json:"open-files,omitempty"`
+
+	// MaxOutputBytes terminates the command once stdout and stderr together
+	// exceed the given number of bytes.
+	MaxOutputBytes int64 `json:"max-output-bytes,omitempty"`
+
+	// Namespaces runs the command in new Linux namespaces ("mount", "pid").
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// HasResourceLimits reports whether any rlimit is configured.
+func (s *SandboxConfig) HasResourceLimits() bool {
+	return s != nil && (s.CPUSeconds > 0 || s.AddressSpace > 0 || s.OpenFiles > 0)
+}
+
+// OutputLimit returns the configured max-output-bytes, 0 meaning unlimited.
+func (s *SandboxConfig) OutputLimit() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.MaxOutputBytes
+}
+
+// Environ returns the part of environ ("KEY=value" entries) the command may
+// inherit.
+func (s *SandboxConfig) Environ(environ []string) []string {
+	if s == nil || (!s.CleanEnv && len(s.EnvAllowlist) == 0) {
+		return environ
+	}
+
+	filtered := make([]string, 0, len(s.EnvAllowlist))
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		if s.allowsEnv(name) {
+			filtered = append(filtered, kv)
+		}
+	}
+	return filtered
+}
+
+func (s *SandboxConfig) allowsEnv(name string) bool {
+	for _, pattern := range s.EnvAllowlist {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the sandbox settings.
+func (s *SandboxConfig) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if s.UID != nil && *s.UID < 0 {
+		return fmt.Errorf("sandbox uid must be >= 0, got %d", *s.UID)
+	}
+	if s.GID != nil && *s.GID < 0 {
+		return fmt.Errorf("sandbox gid must be >= 0, got %d", *s.GID)
+	}
+	if s.MaxOutputBytes < 0 {
+		return fmt.Errorf("sandbox max-output-bytes must be >= 0, got %d", s.MaxOutputBytes)
+	}
+	for _, ns := range s.Namespaces {
+		if ns != NamespaceMount && ns != NamespacePID {
+			return fmt.Errorf("unknown sandbox namespace %q (must be %s or %s)", ns, NamespaceMount, NamespacePID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package hook
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSandboxEnviron(t *testing.T) {
+	environ := []string{"PATH=/usr/bin", "HOME=/root", "AWS_SECRET_ACCESS_KEY=secret", "LC_ALL=C", "LC_CTYPE=UTF-8"}
+
+	var nilSandbox *SandboxConfig
+	tests := []struct {
+		name string
+		s    *SandboxConfig
+		want []string
+	}{
+		{"nil sandbox inherits everything", nilSandbox, environ},
+		{"no environment settings", &SandboxConfig{}, environ},
+		{"clean", &SandboxConfig{CleanEnv: true}, []string{}},
+		{"clean with allowlist", &SandboxConfig{CleanEnv: true, EnvAllowlist: []string{"PATH"}}, []string{"PATH=/usr/bin"}},
+		{"allowlist with prefix", &SandboxConfig{EnvAllowlist: []string{"PATH", "LC_*"}}, []string{"PATH=/usr/bin", "LC_ALL=C", "LC_CTYPE=UTF-8"}},
+	}
+
+	for _, tt := range tests {
+		if got := tt.s.Environ(environ); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSandboxValidate(t *testing.T) {
+	negative := -1
+	uid := 1000
+
+	tests := []struct {
+		name string
+		s    *SandboxConfig
+		ok   bool
+	}{
+		{"nil", nil, true},
+		{"valid", &SandboxConfig{UID: &uid, GID: &uid, CPUSeconds: 60, MaxOutputBytes: 1 << 20, Namespaces: []string{NamespaceMount, NamespacePID}}, true},
+		{"negative uid", &SandboxConfig{UID: &negative}, false},
+		{"negative gid", &SandboxConfig{GID: &negative}, false},
+		{"negative output limit", &SandboxConfig{MaxOutputBytes: -1}, false},
+		{"unknown namespace", &SandboxConfig{Namespaces: []string{"net"}}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.s.Validate()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: unexpected error state: %v", tt.name, err)
+		}
+	}
+}
+
+func TestLoadHookWithSandbox(t *testing.T) {
+	var hooks Hooks
+	data := []byte(`[{"id": "deploy", "execute-command": "/bin/true", "sandbox": {"uid": 1000, "gid": 1000, "clean-env": true, "env-allowlist": ["PATH"], "cpu-seconds": 30, "open-files": 64, "max-output-bytes": 1048576, "namespaces": ["pid"]}}]`)
+	if err := json.Unmarshal(data, &hooks); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s := hooks[0].Sandbox
+	if s == nil {
+		t.Fatal("expected sandbox to be set")
+	}
+	if *s.UID != 1000 || *s.GID != 1000 || !s.CleanEnv || s.CPUSeconds != 30 || s.OpenFiles != 64 || s.OutputLimit() != 1048576 || !s.HasResourceLimits() {
+		t.Errorf("unexpected sandbox config: %+v", s)
+	}
+}
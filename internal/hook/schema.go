@@ -0,0 +1,1016 @@
+package hook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/invopop/yaml"
+)
+
+// MaxSchemaViolations limits the violations reported for one payload.
+const MaxSchemaViolations = 20
+
+// maxSchemaSteps bounds the number of subschema evaluations of one check, so
+// that large payloads cannot keep the validator busy for long.
+var maxSchemaSteps = 1000000
+
+// maxSchemaNumberLength and maxSchemaExponent bound the numbers compared
+// exactly; converting a number to a big.Rat takes time proportional to its
+// exponent.
+const (
+	maxSchemaNumberLength = 1000
+	maxSchemaExponent     = 1000
+)
+
+// PayloadSchema is a JSON Schema the parsed payload of a hook has to match.
+// In the hook definition it is an inline schema or the path of a JSON or YAML
+// schema file, relative to the hooks file. The supported keywords are those
+// of draft 2020-12 and draft-07 except for unevaluatedProperties,
+// unevaluatedItems, dependentSchemas and dynamic references; $ref can only
+// point into the schema itself and format is not validated.
+type PayloadSchema struct {
+	File   string
+	Schema interface{}
+	root   *schemaNode
+}
+
+// UnmarshalJSON reads a schema file path from a JSON string or an inline
+// schema from any other JSON value.
+func (s *PayloadSchema) UnmarshalJSON(b []byte) error {
+	*s = PayloadSchema{}
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '"' {
+		return json.Unmarshal(b, &s.File)
+	}
+	return json.Unmarshal(b, &s.Schema)
+}
+
+// MarshalJSON writes the schema file path or the inline schema.
+func (s PayloadSchema) MarshalJSON() ([]byte, error) {
+	if s.File != "" {
+		return json.Marshal(s.File)
+	}
+	return json.Marshal(s.Schema)
+}
+
+// Compile loads the schema file, resolving relative paths against dir, and
+// compiles the schema, so that it is not compiled on every request.
+func (s *PayloadSchema) Compile(dir string) error {
+	if s.File != "" {
+		path := s.File
+		if !filepath.IsAbs(path) && dir != "" {
+			path = filepath.Join(dir, path)
+		}
+		data, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return fmt.Errorf("payload-schema: %w", err)
+		}
+		var schema interface{}
+		if err := yaml.Unmarshal(data, &schema); err != nil {
+			return fmt.Errorf("payload-schema %s: %w", s.File, err)
+		}
+		s.Schema = schema
+	}
+
+	root, err := compileSchema(s.Schema)
+	if err != nil {
+		if s.File != "" {
+			return fmt.Errorf("payload-schema %s: %w", s.File, err)
+		}
+		return fmt.Errorf("payload-schema: %w", err)
+	}
+	s.root = root
+	return nil
+}
+
+// Validate checks that the schema compiles.
+func (s *PayloadSchema) Validate() error {
+	if s == nil || s.root != nil {
+		return nil
+	}
+	if s.File != "" && s.Schema == nil {
+		return fmt.Errorf("payload-schema %s is not loaded", s.File)
+	}
+	if _, err := compileSchema(s.Schema); err != nil {
+		return fmt.Errorf("payload-schema: %w", err)
+	}
+	return nil
+}
+
+// Check returns the violations of the schema by value, at most
+// MaxSchemaViolations.
+func (s *PayloadSchema) Check(value interface{}) ([]SchemaViolation, error) {
+	root := s.root
+	if root == nil {
+		// hooks not loaded by Hooks.LoadFromFile are compiled on every check
+		if err := s.Validate(); err != nil {
+			return nil, err
+		}
+		root, _ = compileSchema(s.Schema)
+	}
+
+	res := &schemaResult{run: &schemaRun{}, limit: MaxSchemaViolations}
+	root.validate(value, "", res)
+	if res.run.exhausted {
+		// the payload is rejected rather than accepted partially validated
+		violations := append(res.violations[:min(len(res.violations), MaxSchemaViolations-1)],
+			SchemaViolation{Message: "payload is too large or complex to validate"})
+		return violations, nil
+	}
+	return res.violations, nil
+}
+
+// SchemaViolation describes a value not matching the payload schema. Path is
+// the JSON Pointer of the value, empty for the whole payload.
+type SchemaViolation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// SchemaError describes a payload not matching the payload schema of a hook.
+type SchemaError struct {
+	Violations []SchemaViolation
+}
+
+func (e *SchemaError) Error() string {
+	if e == nil {
+		return "<nil>"
+	}
+	if len(e.Violations) == 0 {
+		return "payload does not match schema"
+	}
+	v := e.Violations[0]
+	msg := fmt.Sprintf("payload does not match schema: %s: %s", v.Path, v.Message)
+	if n := len(e.Violations) - 1; n > 0 {
+		msg += fmt.Sprintf(" (and %d more)", n)
+	}
+	return msg
+}
+
+// IsSchemaError returns whether err is of type SchemaError.
+func IsSchemaError(err error) bool {
+	switch err.(type) {
+	case *SchemaError:
+		return true
+	default:
+		return false
+	}
+}
+
+// ValidatePayload checks the parsed payload against the payload schema of
+// the hook and returns a SchemaError listing the violations.
+func (h *Hook) ValidatePayload(r *Request) error {
+	if h.PayloadSchema == nil {
+		return nil
+	}
+	violations, err := h.PayloadSchema.Check(payloadDocument(r))
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return &SchemaError{violations}
+	}
+	return nil
+}
+
+// payloadDocument returns the parsed payload, unwrapping JSON array payloads
+// stored under "root".
+func payloadDocument(r *Request) interface{} {
+	if r.Payload == nil {
+		return nil
+	}
+	if list, ok := r.Payload["root"].([]interface{}); ok && len(r.Payload) == 1 && bytes.HasPrefix(bytes.TrimSpace(r.Body), []byte("[")) {
+		return list
+	}
+	return r.Payload
+}
+
+// schemaNumber is a number of a schema keyword.
+type schemaNumber struct {
+	value *big.Rat
+	text  string
+}
+
+type schemaPattern struct {
+	re   *regexp.Regexp
+	node *schemaNode
+}
+
+// schemaNode is a compiled schema.
+type schemaNode struct {
+	// ptr is the location of the schema in the schema document.
+	ptr string
+	// always is set for the boolean schemas true and false.
+	always *bool
+
+	refNode              *schemaNode
+	types                []string
+	enum                 []interface{}
+	constValue           interface{}
+	hasConst             bool
+	properties           map[string]*schemaNode
+	patternProperties    []schemaPattern
+	additionalProperties *schemaNode
+	propertyNames        *schemaNode
+	required             []string
+	dependentRequired    map[string][]string
+	minProperties        *int
+	maxProperties        *int
+	prefixItems          []*schemaNode
+	items                *schemaNode
+	contains             *schemaNode
+	minItems             *int
+	maxItems             *int
+	uniqueItems          bool
+	minLength            *int
+	maxLength            *int
+	pattern              *regexp.Regexp
+	minimum              *schemaNumber
+	maximum              *schemaNumber
+	exclusiveMinimum     *schemaNumber
+	exclusiveMaximum     *schemaNumber
+	multipleOf           *schemaNumber
+	allOf, anyOf, oneOf  []*schemaNode
+	not, ifNode          *schemaNode
+	thenNode, elseNode   *schemaNode
+}
+
+var schemaTypes = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true, "number": true, "integer": true, "string": true,
+}
+
+var unsupportedSchemaKeywords = []string{
+	"unevaluatedProperties", "unevaluatedItems", "dependentSchemas", "$dynamicRef", "$recursiveRef",
+}
+
+// schemaCompiler compiles the subschemas of a document, sharing the nodes of
+// subschemas referenced more than once.
+type schemaCompiler struct {
+	doc   interface{}
+	nodes map[string]*schemaNode
+}
+
+func compileSchema(doc interface{}) (*schemaNode, error) {
+	if doc == nil {
+		return nil, errors.New("missing schema")
+	}
+	c := &schemaCompiler{doc: doc, nodes: map[string]*schemaNode{}}
+	root, err := c.compile(doc, "#")
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkCycles(); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// checkCycles rejects schemas referencing themselves without descending into
+// the payload, e.g. {"$ref": "#"}, as their validation would never end.
+func (c *schemaCompiler) checkCycles() error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[*schemaNode]int, len(c.nodes))
+
+	var visit func(n *schemaNode) error
+	visit = func(n *schemaNode) error {
+		switch state[n] {
+		case visiting:
+			return fmt.Errorf("%s: $ref cycle that does not descend into the payload", n.ptr)
+		case done:
+			return nil
+		}
+		state[n] = visiting
+		for _, s := range n.sameValueSchemas() {
+			if err := visit(s); err != nil {
+				return err
+			}
+		}
+		state[n] = done
+		return nil
+	}
+
+	ptrs := make([]string, 0, len(c.nodes))
+	for ptr := range c.nodes {
+		ptrs = append(ptrs, ptr)
+	}
+	sort.Strings(ptrs)
+	for _, ptr := range ptrs {
+		if err := visit(c.nodes[ptr]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameValueSchemas returns the subschemas applied to the same value as n.
+func (n *schemaNode) sameValueSchemas() []*schemaNode {
+	var list []*schemaNode
+	for _, s := range []*schemaNode{n.refNode, n.not, n.ifNode, n.thenNode, n.elseNode} {
+		if s != nil {
+			list = append(list, s)
+		}
+	}
+	list = append(list, n.allOf...)
+	list = append(list, n.anyOf...)
+	return append(list, n.oneOf...)
+}
+
+func (c *schemaCompiler) compile(v interface{}, ptr string) (*schemaNode, error) {
+	if node, ok := c.nodes[ptr]; ok {
+		return node, nil
+	}
+	node := &schemaNode{ptr: ptr}
+	c.nodes[ptr] = node
+
+	if b, ok := v.(bool); ok {
+		node.always = &b
+		return node, nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: schema must be an object or a boolean", ptr)
+	}
+	for _, keyword := range unsupportedSchemaKeywords {
+		if _, ok := m[keyword]; ok {
+			return nil, fmt.Errorf("%s: unsupported keyword %s", ptr, keyword)
+		}
+	}
+
+	var err error
+	sub := func(keyword string) (*schemaNode, error) {
+		if s, ok := m[keyword]; ok {
+			return c.compile(s, ptr+"/"+escapeJSONPointer(keyword))
+		}
+		return nil, nil
+	}
+	subList := func(keyword string) ([]*schemaNode, error) {
+		s, ok := m[keyword]
+		if !ok {
+			return nil, nil
+		}
+		list, ok := s.([]interface{})
+		if !ok || len(list) == 0 {
+			return nil, fmt.Errorf("%s/%s must be a non-empty array", ptr, keyword)
+		}
+		nodes := make([]*schemaNode, len(list))
+		for i := range list {
+			if nodes[i], err = c.compile(list[i], fmt.Sprintf("%s/%s/%d", ptr, keyword, i)); err != nil {
+				return nil, err
+			}
+		}
+		return nodes, nil
+	}
+	subMap := func(keyword string) (map[string]*schemaNode, error) {
+		s, ok := m[keyword]
+		if !ok {
+			return nil, nil
+		}
+		schemas, ok := s.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s/%s must be an object", ptr, keyword)
+		}
+		nodes := make(map[string]*schemaNode, len(schemas))
+		for k, s := range schemas {
+			if nodes[k], err = c.compile(s, ptr+"/"+keyword+"/"+escapeJSONPointer(k)); err != nil {
+				return nil, err
+			}
+		}
+		return nodes, nil
+	}
+	count := func(keyword string) (*int, error) {
+		s, ok := m[keyword]
+		if !ok {
+			return nil, nil
+		}
+		n, ok := schemaRat(s)
+		if !ok || !n.IsInt() || n.Sign() < 0 || !n.Num().IsInt64() {
+			return nil, fmt.Errorf("%s/%s must be a non-negative integer", ptr, keyword)
+		}
+		i := int(n.Num().Int64())
+		return &i, nil
+	}
+	number := func(keyword string) (*schemaNumber, error) {
+		s, ok := m[keyword]
+		if !ok {
+			return nil, nil
+		}
+		n, ok := schemaRat(s)
+		if !ok {
+			// draft-04 boolean exclusiveMinimum and exclusiveMaximum are ignored
+			if _, isBool := s.(bool); isBool && strings.HasPrefix(keyword, "exclusive") {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("%s/%s must be a number", ptr, keyword)
+		}
+		return &schemaNumber{value: n, text: schemaNumberText(s)}, nil
+	}
+
+	if ref, ok := m["$ref"]; ok {
+		s, ok := ref.(string)
+		if !ok || (s != "#" && !strings.HasPrefix(s, "#/")) {
+			return nil, fmt.Errorf("%s/$ref: only references within the schema are supported, got %v", ptr, ref)
+		}
+		target, err := resolveJSONPointer(c.doc, strings.TrimPrefix(s, "#"))
+		if err != nil {
+			return nil, fmt.Errorf("%s/$ref: %w", ptr, err)
+		}
+		if node.refNode, err = c.compile(target, s); err != nil {
+			return nil, err
+		}
+	}
+
+	if t, ok := m["type"]; ok {
+		switch t := t.(type) {
+		case string:
+			node.types = []string{t}
+		case []interface{}:
+			for _, name := range t {
+				s, _ := name.(string)
+				node.types = append(node.types, s)
+			}
+		}
+		if len(node.types) == 0 {
+			return nil, fmt.Errorf("%s/type must be a string or an array of strings", ptr)
+		}
+		for _, name := range node.types {
+			if !schemaTypes[name] {
+				return nil, fmt.Errorf("%s/type: unknown type %q", ptr, name)
+			}
+		}
+	}
+
+	if e, ok := m["enum"]; ok {
+		if node.enum, ok = e.([]interface{}); !ok {
+			return nil, fmt.Errorf("%s/enum must be an array", ptr)
+		}
+	}
+	node.constValue, node.hasConst = m["const"]
+
+	if node.properties, err = subMap("properties"); err != nil {
+		return nil, err
+	}
+	if pp, ok := m["patternProperties"].(map[string]interface{}); ok {
+		patterns := make([]string, 0, len(pp))
+		for p := range pp {
+			patterns = append(patterns, p)
+		}
+		sort.Strings(patterns)
+		for _, p := range patterns {
+			re, err := getCompiledRegex(p)
+			if err != nil {
+				return nil, fmt.Errorf("%s/patternProperties: %w", ptr, err)
+			}
+			n, err := c.compile(pp[p], ptr+"/patternProperties/"+escapeJSONPointer(p))
+			if err != nil {
+				return nil, err
+			}
+			node.patternProperties = append(node.patternProperties, schemaPattern{re, n})
+		}
+	}
+	if node.additionalProperties, err = sub("additionalProperties"); err != nil {
+		return nil, err
+	}
+	if node.propertyNames, err = sub("propertyNames"); err != nil {
+		return nil, err
+	}
+	if r, ok := m["required"]; ok {
+		list, ok := r.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s/required must be an array of strings", ptr)
+		}
+		for _, name := range list {
+			s, ok := name.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s/required must be an array of strings", ptr)
+			}
+			node.required = append(node.required, s)
+		}
+	}
+	if dr, ok := m["dependentRequired"]; ok {
+		deps, ok := dr.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s/dependentRequired must be an object", ptr)
+		}
+		node.dependentRequired = make(map[string][]string, len(deps))
+		for k, names := range deps {
+			list, _ := names.([]interface{})
+			for _, name := range list {
+				if s, ok := name.(string); ok {
+					node.dependentRequired[k] = append(node.dependentRequired[k], s)
+				}
+			}
+		}
+	}
+	if node.minProperties, err = count("minProperties"); err != nil {
+		return nil, err
+	}
+	if node.maxProperties, err = count("maxProperties"); err != nil {
+		return nil, err
+	}
+
+	// items is a list of schemas for each position in draft-07 and earlier
+	if _, ok := m["items"].([]interface{}); ok {
+		if node.prefixItems, err = subList("items"); err != nil {
+			return nil, err
+		}
+		if node.items, err = sub("additionalItems"); err != nil {
+			return nil, err
+		}
+	} else {
+		if node.prefixItems, err = subList("prefixItems"); err != nil {
+			return nil, err
+		}
+		if node.items, err = sub("items"); err != nil {
+			return nil, err
+		}
+	}
+	if node.contains, err = sub("contains"); err != nil {
+		return nil, err
+	}
+	if node.minItems, err = count("minItems"); err != nil {
+		return nil, err
+	}
+	if node.maxItems, err = count("maxItems"); err != nil {
+		return nil, err
+	}
+	node.uniqueItems, _ = m["uniqueItems"].(bool)
+
+	if node.minLength, err = count("minLength"); err != nil {
+		return nil, err
+	}
+	if node.maxLength, err = count("maxLength"); err != nil {
+		return nil, err
+	}
+	if p, ok := m["pattern"]; ok {
+		s, _ := p.(string)
+		if node.pattern, err = getCompiledRegex(s); err != nil {
+			return nil, fmt.Errorf("%s/pattern: %w", ptr, err)
+		}
+	}
+
+	for _, n := range []struct {
+		keyword string
+		target  **schemaNumber
+	}{
+		{"minimum", &node.minimum},
+		{"maximum", &node.maximum},
+		{"exclusiveMinimum", &node.exclusiveMinimum},
+		{"exclusiveMaximum", &node.exclusiveMaximum},
+		{"multipleOf", &node.multipleOf},
+	} {
+		if *n.target, err = number(n.keyword); err != nil {
+			return nil, err
+		}
+	}
+	if node.multipleOf != nil && node.multipleOf.value.Sign() <= 0 {
+		return nil, fmt.Errorf("%s/multipleOf must be greater than 0", ptr)
+	}
+
+	if node.allOf, err = subList("allOf"); err != nil {
+		return nil, err
+	}
+	if node.anyOf, err = subList("anyOf"); err != nil {
+		return nil, err
+	}
+	if node.oneOf, err = subList("oneOf"); err != nil {
+		return nil, err
+	}
+	if node.not, err = sub("not"); err != nil {
+		return nil, err
+	}
+	if node.ifNode, err = sub("if"); err != nil {
+		return nil, err
+	}
+	if node.thenNode, err = sub("then"); err != nil {
+		return nil, err
+	}
+	if node.elseNode, err = sub("else"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// schemaRun is the work budget shared by the results of one check.
+type schemaRun struct {
+	steps     int
+	exhausted bool
+}
+
+// schemaResult collects violations until limit violations were found.
+type schemaResult struct {
+	run        *schemaRun
+	limit      int
+	violations []SchemaViolation
+}
+
+// done reports whether the validation can stop.
+func (r *schemaResult) done() bool {
+	return len(r.violations) >= r.limit || r.run.exhausted
+}
+
+func (r *schemaResult) add(path, format string, args ...interface{}) {
+	if len(r.violations) < r.limit {
+		r.violations = append(r.violations, SchemaViolation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+// matches reports whether v matches the schema.
+func (n *schemaNode) matches(v interface{}, res *schemaResult) bool {
+	sub := &schemaResult{run: res.run, limit: 1}
+	n.validate(v, "", sub)
+	return len(sub.violations) == 0
+}
+
+// validate adds the violations of the schema by v, found at path, to res.
+func (n *schemaNode) validate(v interface{}, path string, res *schemaResult) {
+	if res.done() {
+		return
+	}
+	if res.run.steps++; res.run.steps > maxSchemaSteps {
+		res.run.exhausted = true
+		return
+	}
+	report := func(format string, args ...interface{}) {
+		res.add(path, format, args...)
+	}
+
+	if n.always != nil {
+		if !*n.always {
+			report("no value is allowed")
+		}
+		return
+	}
+	if n.refNode != nil {
+		n.refNode.validate(v, path, res)
+	}
+
+	// raw keeps json.Number values, so that numbers are compared exactly
+	raw := v
+	v = exprNormalize(v)
+	typ := schemaTypeOf(v)
+	if _, ok := raw.(json.Number); ok {
+		// numbers beyond the range of float64 are normalized to strings
+		typ = "number"
+	}
+	if len(n.types) > 0 && !n.hasType(raw, typ) {
+		report("expected %s, got %s", strings.Join(n.types, " or "), typ)
+		return
+	}
+	if n.enum != nil && !schemaContains(n.enum, v) {
+		enum, _ := json.Marshal(n.enum)
+		report("must be one of %s", enum)
+	}
+	if n.hasConst && !exprEqual(n.constValue, v) {
+		c, _ := json.Marshal(n.constValue)
+		report("must be %s", c)
+	}
+
+	switch x := v.(type) {
+	case map[string]interface{}:
+		n.validateObject(x, path, res, report)
+	case []interface{}:
+		n.validateArray(x, path, res, report)
+	case string:
+		if typ == "number" {
+			n.validateNumber(raw, report)
+			break
+		}
+		length := utf8.RuneCountInString(x)
+		if n.minLength != nil && length < *n.minLength {
+			report("must be at least %d characters long", *n.minLength)
+		}
+		if n.maxLength != nil && length > *n.maxLength {
+			report("must be at most %d characters long", *n.maxLength)
+		}
+		if n.pattern != nil && !n.pattern.MatchString(x) {
+			report("must match pattern %q", n.pattern.String())
+		}
+	case float64:
+		n.validateNumber(raw, report)
+	}
+
+	for _, s := range n.allOf {
+		s.validate(raw, path, res)
+	}
+	if n.anyOf != nil {
+		matched := false
+		for _, s := range n.anyOf {
+			if s.matches(raw, res) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			report("must match at least one schema of anyOf")
+		}
+	}
+	if n.oneOf != nil {
+		matched := 0
+		for _, s := range n.oneOf {
+			if s.matches(raw, res) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			report("must match exactly one schema of oneOf, matched %d", matched)
+		}
+	}
+	if n.not != nil && n.not.matches(raw, res) {
+		report("must not match the schema of not")
+	}
+	if n.ifNode != nil {
+		if n.ifNode.matches(raw, res) {
+			if n.thenNode != nil {
+				n.thenNode.validate(raw, path, res)
+			}
+		} else if n.elseNode != nil {
+			n.elseNode.validate(raw, path, res)
+		}
+	}
+}
+
+func (n *schemaNode) hasType(v interface{}, typ string) bool {
+	for _, t := range n.types {
+		if t == typ {
+			return true
+		}
+		if t == "integer" && typ == "number" {
+			if r, ok := schemaRat(v); ok && r.IsInt() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (n *schemaNode) validateObject(m map[string]interface{}, path string, res *schemaResult, report func(string, ...interface{})) {
+	for _, name := range n.required {
+		if _, ok := m[name]; !ok {
+			report("missing required property %q", name)
+		}
+	}
+	if n.dependentRequired != nil {
+		for _, name := range sortedKeys(m) {
+			for _, dep := range n.dependentRequired[name] {
+				if _, ok := m[dep]; !ok {
+					report("property %q requires property %q", name, dep)
+				}
+			}
+		}
+	}
+	if n.minProperties != nil && len(m) < *n.minProperties {
+		report("must have at least %d properties", *n.minProperties)
+	}
+	if n.maxProperties != nil && len(m) > *n.maxProperties {
+		report("must have at most %d properties", *n.maxProperties)
+	}
+
+	for _, name := range sortedKeys(m) {
+		childPath := path + "/" + escapeJSONPointer(name)
+		if n.propertyNames != nil && !n.propertyNames.matches(name, res) {
+			res.add(childPath, "property name does not match propertyNames")
+		}
+
+		evaluated := false
+		if s, ok := n.properties[name]; ok {
+			s.validate(m[name], childPath, res)
+			evaluated = true
+		}
+		for _, p := range n.patternProperties {
+			if p.re.MatchString(name) {
+				p.node.validate(m[name], childPath, res)
+				evaluated = true
+			}
+		}
+		if !evaluated && n.additionalProperties != nil {
+			if a := n.additionalProperties.always; a != nil && !*a {
+				res.add(childPath, "property is not allowed")
+				continue
+			}
+			n.additionalProperties.validate(m[name], childPath, res)
+		}
+	}
+}
+
+func (n *schemaNode) validateArray(list []interface{}, path string, res *schemaResult, report func(string, ...interface{})) {
+	if n.minItems != nil && len(list) < *n.minItems {
+		report("must have at least %d items", *n.minItems)
+	}
+	if n.maxItems != nil && len(list) > *n.maxItems {
+		report("must have at most %d items", *n.maxItems)
+	}
+	if n.uniqueItems {
+		// items are compared by their canonical encoding instead of pairwise
+		seen := make(map[string]int, len(list))
+		for j, item := range list {
+			key := schemaKey(item)
+			if i, ok := seen[key]; ok {
+				report("items %d and %d must be unique", i, j)
+				break
+			}
+			seen[key] = j
+		}
+	}
+	if n.contains != nil {
+		found := false
+		for _, item := range list {
+			if n.contains.matches(item, res) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			report("must contain an item matching contains")
+		}
+	}
+
+	for i, item := range list {
+		itemPath := path + "/" + strconv.Itoa(i)
+		if i < len(n.prefixItems) {
+			n.prefixItems[i].validate(item, itemPath, res)
+		} else if n.items != nil {
+			n.items.validate(item, itemPath, res)
+		}
+	}
+}
+
+func (n *schemaNode) validateNumber(v interface{}, report func(string, ...interface{})) {
+	if n.minimum == nil && n.maximum == nil && n.exclusiveMinimum == nil && n.exclusiveMaximum == nil && n.multipleOf == nil {
+		return
+	}
+	r, ok := schemaRat(v)
+	if !ok {
+		report("number is out of the supported range")
+		return
+	}
+	if n.minimum != nil && r.Cmp(n.minimum.value) < 0 {
+		report("must be >= %s", n.minimum.text)
+	}
+	if n.maximum != nil && r.Cmp(n.maximum.value) > 0 {
+		report("must be <= %s", n.maximum.text)
+	}
+	if n.exclusiveMinimum != nil && r.Cmp(n.exclusiveMinimum.value) <= 0 {
+		report("must be > %s", n.exclusiveMinimum.text)
+	}
+	if n.exclusiveMaximum != nil && r.Cmp(n.exclusiveMaximum.value) >= 0 {
+		report("must be < %s", n.exclusiveMaximum.text)
+	}
+	if n.multipleOf != nil && !new(big.Rat).Quo(r, n.multipleOf.value).IsInt() {
+		report("must be a multiple of %s", n.multipleOf.text)
+	}
+}
+
+// schemaTypeOf returns the JSON type of a normalized value.
+func schemaTypeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// schemaRat converts a JSON number to a big.Rat, so that multipleOf and
+// comparisons of decimals are exact. Numbers longer than
+// maxSchemaNumberLength or with an exponent beyond maxSchemaExponent are not
+// converted.
+func schemaRat(v interface{}) (*big.Rat, bool) {
+	text := schemaNumberText(v)
+	if text == "" || len(text) > maxSchemaNumberLength {
+		return nil, false
+	}
+	if i := strings.IndexAny(text, "eE"); i >= 0 {
+		exp, err := strconv.Atoi(text[i+1:])
+		if err != nil || exp > maxSchemaExponent || exp < -maxSchemaExponent {
+			return nil, false
+		}
+	}
+	return new(big.Rat).SetString(text)
+}
+
+// schemaKey returns an encoding of v that is the same for values exprEqual
+// considers equal.
+func schemaKey(v interface{}) string {
+	var b strings.Builder
+	writeSchemaKey(&b, v)
+	return b.String()
+}
+
+func writeSchemaKey(b *strings.Builder, v interface{}) {
+	switch x := exprNormalize(v).(type) {
+	case []interface{}:
+		b.WriteByte('[')
+		for i, item := range x {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeSchemaKey(b, item)
+		}
+		b.WriteByte(']')
+	case map[string]interface{}:
+		b.WriteByte('{')
+		for i, k := range sortedKeys(x) {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(strconv.Quote(k))
+			b.WriteByte(':')
+			writeSchemaKey(b, x[k])
+		}
+		b.WriteByte('}')
+	case float64:
+		if x == 0 {
+			x = 0 // -0 equals 0
+		}
+		b.WriteString(strconv.FormatFloat(x, 'g', -1, 64))
+	case string:
+		b.WriteString(strconv.Quote(x))
+	default:
+		fmt.Fprintf(b, "%T(%v)", x, x)
+	}
+}
+
+func schemaNumberText(v interface{}) string {
+	switch x := v.(type) {
+	case json.Number:
+		return x.String()
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(x)
+	case int64:
+		return strconv.FormatInt(x, 10)
+	}
+	return ""
+}
+
+func schemaContains(list []interface{}, v interface{}) bool {
+	for _, item := range list {
+		if exprEqual(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func escapeJSONPointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+// resolveJSONPointer returns the value ptr points to in doc.
+func resolveJSONPointer(doc interface{}, ptr string) (interface{}, error) {
+	if ptr == "" {
+		return doc, nil
+	}
+	v := doc
+	for _, token := range strings.Split(strings.TrimPrefix(ptr, "/"), "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch x := v.(type) {
+		case map[string]interface{}:
+			next, ok := x[token]
+			if !ok {
+				return nil, fmt.Errorf("%q not found", ptr)
+			}
+			v = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(x) {
+				return nil, fmt.Errorf("%q not found", ptr)
+			}
+			v = x[i]
+		default:
+			return nil, fmt.Errorf("%q not found", ptr)
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,274 @@
+package hook
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPayloadSchemaCheck(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"required": ["ref", "commits"],
+		"properties": {
+			"ref": {"type": "string", "pattern": "^refs/"},
+			"size": {"type": "integer", "minimum": 1, "maximum": 10},
+			"ratio": {"type": "number", "multipleOf": 0.01, "exclusiveMaximum": 1},
+			"action": {"enum": ["opened", "closed"]},
+			"commits": {"type": "array", "minItems": 1, "items": {"$ref": "#/$defs/commit"}},
+			"labels": {"type": "array", "uniqueItems": true, "maxItems": 2},
+			"sender": {"oneOf": [{"type": "string"}, {"type": "object", "required": ["login"]}]}
+		},
+		"additionalProperties": false,
+		"$defs": {
+			"commit": {
+				"type": "object",
+				"required": ["id"],
+				"properties": {"id": {"type": "string", "minLength": 7}}
+			}
+		}
+	}`
+
+	tests := []struct {
+		description string
+		payload     string
+		want        []SchemaViolation
+	}{
+		{"valid", `{"ref": "refs/heads/main", "size": 3, "ratio": 0.25, "commits": [{"id": "1234567"}], "sender": "bot"}`, nil},
+		{"missing", `{}`, []SchemaViolation{
+			{"", `missing required property "ref"`},
+			{"", `missing required property "commits"`},
+		}},
+		{"wrong types", `{"ref": 1, "commits": {}}`, []SchemaViolation{
+			{"/commits", "expected array, got object"},
+			{"/ref", "expected string, got number"},
+		}},
+		{"nested", `{"ref": "main", "commits": [{"id": "1234567"}, {"id": "123"}, {}]}`, []SchemaViolation{
+			{"/commits/1/id", "must be at least 7 characters long"},
+			{"/commits/2", `missing required property "id"`},
+			{"/ref", `must match pattern "^refs/"`},
+		}},
+		{"numbers", `{"ref": "refs/x", "commits": [{"id": "1234567"}], "size": 2.5, "ratio": 0.125}`, []SchemaViolation{
+			{"/ratio", "must be a multiple of 0.01"},
+			{"/size", "expected integer, got number"},
+		}},
+		{"bounds", `{"ref": "refs/x", "commits": [{"id": "1234567"}], "size": 11, "ratio": 1}`, []SchemaViolation{
+			{"/ratio", "must be < 1"},
+			{"/size", "must be <= 10"},
+		}},
+		{"enum and unique", `{"ref": "refs/x", "commits": [{"id": "1234567"}], "action": "merged", "labels": ["a", "a"]}`, []SchemaViolation{
+			{"/action", `must be one of ["opened","closed"]`},
+			{"/labels", "items 0 and 1 must be unique"},
+		}},
+		{"one of", `{"ref": "refs/x", "commits": [{"id": "1234567"}], "sender": {"id": 1}}`, []SchemaViolation{
+			{"/sender", "must match exactly one schema of oneOf, matched 0"},
+		}},
+		{"additional property", `{"ref": "refs/x", "commits": [{"id": "1234567"}], "extra/key": true}`, []SchemaViolation{
+			{"/extra~1key", "property is not allowed"},
+		}},
+	}
+
+	var s PayloadSchema
+	if err := json.Unmarshal([]byte(schema), &s); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Compile(""); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			req := &Request{Body: []byte(tt.payload), ContentType: "application/json"}
+			if err := req.ParseJSONPayload(); err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.Check(payloadDocument(req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPayloadSchemaKeywords(t *testing.T) {
+	tests := []struct {
+		description string
+		schema      string
+		value       string
+		ok          bool
+	}{
+		{"false schema", `false`, `{}`, false},
+		{"true schema", `true`, `{}`, true},
+		{"type list", `{"type": ["string", "null"]}`, `null`, true},
+		{"integer as decimal", `{"type": "integer"}`, `3.0`, true},
+		{"const", `{"const": {"a": [1, 2]}}`, `{"a": [1, 2]}`, true},
+		{"const mismatch", `{"const": "x"}`, `"y"`, false},
+		{"array payload", `{"type": "array", "items": {"type": "integer"}}`, `[1, 2]`, true},
+		{"prefix items", `{"prefixItems": [{"type": "string"}], "items": false}`, `["a", 1]`, false},
+		{"draft-07 items", `{"items": [{"type": "string"}], "additionalItems": {"type": "integer"}}`, `["a", 1]`, true},
+		{"contains", `{"contains": {"const": "b"}}`, `["a", "c"]`, false},
+		{"pattern properties", `{"patternProperties": {"^x-": {"type": "string"}}, "additionalProperties": false}`, `{"x-a": "1"}`, true},
+		{"property names", `{"propertyNames": {"maxLength": 3}}`, `{"long": 1}`, false},
+		{"dependent required", `{"dependentRequired": {"a": ["b"]}}`, `{"a": 1}`, false},
+		{"min properties", `{"minProperties": 2}`, `{"a": 1}`, false},
+		{"any of", `{"anyOf": [{"type": "string"}, {"minimum": 5}]}`, `7`, true},
+		{"all of", `{"allOf": [{"type": "number"}, {"maximum": 5}]}`, `7`, false},
+		{"not", `{"not": {"type": "string"}}`, `"a"`, false},
+		{"if then", `{"if": {"properties": {"a": {"const": 1}}}, "then": {"required": ["b"]}}`, `{"a": 1}`, false},
+		{"if else", `{"if": {"properties": {"a": {"const": 1}}}, "then": {"required": ["b"]}, "else": {"required": ["c"]}}`, `{"a": 2, "c": 0}`, true},
+		{"recursive ref", `{"type": "object", "properties": {"child": {"$ref": "#"}}, "required": ["name"]}`, `{"name": "a", "child": {"child": {}}}`, false},
+		{"unicode length", `{"maxLength": 2}`, `"日本"`, true},
+		{"big decimal", `{"multipleOf": 0.1}`, `12345678.9`, true},
+		{"huge exponent integer", `{"type": "integer"}`, `1e1000000`, false},
+		{"huge exponent bound", `{"minimum": 0}`, `1e1000000`, false},
+		{"huge exponent number", `{"type": "number"}`, `1e1000000`, true},
+		{"unique numbers", `{"uniqueItems": true}`, `[1, 1.0]`, false},
+		{"unique objects", `{"uniqueItems": true}`, `[{"a": 1, "b": [2]}, {"b": [2], "a": 1.0}]`, false},
+		{"unique mixed", `{"uniqueItems": true}`, `[1, "1", [1], {"1": 1}, null, false]`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			var s PayloadSchema
+			if err := json.Unmarshal([]byte(tt.schema), &s); err != nil {
+				t.Fatal(err)
+			}
+			var value interface{}
+			decoder := json.NewDecoder(strings.NewReader(tt.value))
+			decoder.UseNumber()
+			if err := decoder.Decode(&value); err != nil {
+				t.Fatal(err)
+			}
+			violations, err := s.Check(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (len(violations) == 0) != tt.ok {
+				t.Errorf("got violations %v, want ok=%v", violations, tt.ok)
+			}
+		})
+	}
+}
+
+func TestPayloadSchemaValidate(t *testing.T) {
+	tests := []struct {
+		description string
+		schema      string
+		ok          bool
+	}{
+		{"valid", `{"type": "object", "$defs": {"a": {"type": "string"}}, "properties": {"a": {"$ref": "#/$defs/a"}}}`, true},
+		{"unknown type", `{"type": "text"}`, false},
+		{"invalid pattern", `{"pattern": "("}`, false},
+		{"negative count", `{"minItems": -1}`, false},
+		{"zero multiple", `{"multipleOf": 0}`, false},
+		{"remote ref", `{"$ref": "https://example.com/schema.json"}`, false},
+		{"missing ref", `{"$ref": "#/$defs/missing"}`, false},
+		{"unsupported keyword", `{"unevaluatedProperties": false}`, false},
+		{"invalid subschema", `{"properties": {"a": 1}}`, false},
+		{"empty any of", `{"anyOf": []}`, false},
+		{"self ref", `{"$ref": "#"}`, false},
+		{"ref cycle", `{"allOf": [{"$ref": "#/$defs/a"}], "$defs": {"a": {"anyOf": [{"$ref": "#"}]}}}`, false},
+		{"ref cycle in property", `{"properties": {"a": {"not": {"$ref": "#/properties/a"}}}}`, false},
+		{"recursion into payload", `{"properties": {"child": {"$ref": "#"}}, "items": {"$ref": "#"}}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			var s PayloadSchema
+			if err := json.Unmarshal([]byte(tt.schema), &s); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Validate(); (err == nil) != tt.ok {
+				t.Errorf("got error %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestPayloadSchemaCheckLimits(t *testing.T) {
+	var s PayloadSchema
+	if err := json.Unmarshal([]byte(`{"items": {"type": "integer"}}`), &s); err != nil {
+		t.Fatal(err)
+	}
+
+	list := make([]interface{}, 100)
+	for i := range list {
+		list[i] = "x"
+	}
+	violations, err := s.Check(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != MaxSchemaViolations || violations[0].Path != "/0" {
+		t.Errorf("got %d violations starting with %v, want %d", len(violations), violations[0], MaxSchemaViolations)
+	}
+
+	defer func(steps int) { maxSchemaSteps = steps }(maxSchemaSteps)
+	maxSchemaSteps = 1000
+	large := make([]interface{}, maxSchemaSteps)
+	for i := range large {
+		large[i] = json.Number("1")
+	}
+	violations, err = s.Check(large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 1 || violations[0].Message != "payload is too large or complex to validate" {
+		t.Errorf("got violations %v", violations)
+	}
+}
+
+func TestHooksLoadFromFilePayloadSchema(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "schemas"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	err := os.WriteFile(filepath.Join(dir, "schemas", "push.yaml"), []byte("type: object\nrequired: [ref]\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hooksFile := filepath.Join(dir, "hooks.json")
+	err = os.WriteFile(hooksFile, []byte(`[
+		{"id": "file", "execute-command": "true", "payload-schema": "schemas/push.yaml"},
+		{"id": "inline", "execute-command": "true", "payload-schema": {"type": "object", "required": ["action"]}}
+	]`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hooks Hooks
+	if err := hooks.LoadFromFile(hooksFile, false); err != nil {
+		t.Fatal(err)
+	}
+
+	req := &Request{Payload: map[string]interface{}{"action": "opened"}}
+	err = hooks.Match("file").ValidatePayload(req)
+	if !IsSchemaError(err) {
+		t.Fatalf("got error %v, want SchemaError", err)
+	}
+	if got := err.Error(); got != `payload does not match schema: : missing required property "ref"` {
+		t.Errorf("got error %q", got)
+	}
+	if err := hooks.Match("inline").ValidatePayload(req); err != nil {
+		t.Errorf("got error %v", err)
+	}
+
+	out, err := json.Marshal(hooks.Match("file").PayloadSchema)
+	if err != nil || string(out) != `"schemas/push.yaml"` {
+		t.Errorf("got %s, %v", out, err)
+	}
+
+	err = os.WriteFile(hooksFile, []byte(`[{"id": "missing", "payload-schema": "schemas/missing.json"}]`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Hooks{}).LoadFromFile(hooksFile, false); err == nil {
+		t.Error("expected an error for a missing schema file")
+	}
+}
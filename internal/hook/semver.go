@@ -0,0 +1,315 @@
+package hook
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// semver is a semantic version; build metadata is ignored.
+type semver struct {
+	major, minor, patch uint64
+	pre                 []string
+}
+
+// parseSemver parses versions such as "1.2.3", "v1.2.3-rc.1" or "1.2".
+// Missing minor and patch numbers are zero.
+func parseSemver(s string) (semver, error) {
+	p, err := parsePartialSemver(s)
+	if err != nil {
+		return semver{}, err
+	}
+	if p.parts == 0 || p.x {
+		return semver{}, fmt.Errorf("invalid version %q", s)
+	}
+	return p.version, nil
+}
+
+// compare returns -1, 0 or +1 following semver precedence.
+func (v semver) compare(o semver) int {
+	for _, pair := range [][2]uint64{{v.major, o.major}, {v.minor, o.minor}, {v.patch, o.patch}} {
+		if pair[0] != pair[1] {
+			if pair[0] < pair[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	// A version without pre-release has higher precedence.
+	switch {
+	case len(v.pre) == 0 && len(o.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(o.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.pre) && i < len(o.pre); i++ {
+		if c := comparePrerelease(v.pre[i], o.pre[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(v.pre) < len(o.pre):
+		return -1
+	case len(v.pre) > len(o.pre):
+		return 1
+	}
+	return 0
+}
+
+// comparePrerelease compares pre-release identifiers: numeric identifiers
+// compare numerically and have lower precedence than alphanumeric ones.
+func comparePrerelease(a, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		switch {
+		case an < bn:
+			return -1
+		case an > bn:
+			return 1
+		}
+		return 0
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// partialSemver is a version of a range, where trailing parts may be omitted
+// or replaced by x, X or *.
+type partialSemver struct {
+	version semver
+	// parts is the number of specified numeric parts.
+	parts int
+	// wildcard reports whether a part was omitted or a wildcard.
+	wildcard bool
+	// x reports whether a part was x, X or *.
+	x bool
+}
+
+func parsePartialSemver(s string) (partialSemver, error) {
+	var p partialSemver
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "="), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	core, pre, hasPre := strings.Cut(s, "-")
+	if hasPre {
+		if pre == "" {
+			return p, fmt.Errorf("invalid version %q", s)
+		}
+		p.version.pre = strings.Split(pre, ".")
+	}
+	if core == "" {
+		return p, fmt.Errorf("invalid version %q", s)
+	}
+
+	fields := strings.Split(core, ".")
+	if len(fields) > 3 {
+		return p, fmt.Errorf("invalid version %q", s)
+	}
+	nums := []*uint64{&p.version.major, &p.version.minor, &p.version.patch}
+	for i, f := range fields {
+		if f == "x" || f == "X" || f == "*" {
+			p.x = true
+			break
+		}
+		n, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return p, fmt.Errorf("invalid version %q", s)
+		}
+		*nums[i] = n
+		p.parts++
+	}
+	if p.parts < 3 {
+		p.wildcard = true
+		if hasPre {
+			return p, fmt.Errorf("invalid version %q: pre-release requires major.minor.patch", s)
+		}
+	}
+	return p, nil
+}
+
+// next returns the lowest version above every version matching p, e.g.
+// 1.3.0 for 1.2.x. It must only be called for 0 < p.parts < 3.
+func (p partialSemver) next() semver {
+	if p.parts == 1 {
+		return semver{major: p.version.major + 1}
+	}
+	return semver{major: p.version.major, minor: p.version.minor + 1}
+}
+
+type semverComparator struct {
+	op      string
+	version semver
+}
+
+func (c semverComparator) matches(v semver) bool {
+	r := v.compare(c.version)
+	switch c.op {
+	case ">":
+		return r > 0
+	case ">=":
+		return r >= 0
+	case "<":
+		return r < 0
+	case "<=":
+		return r <= 0
+	}
+	return r == 0
+}
+
+// semverRange is a union of comparator sets that must all match.
+type semverRange [][]semverComparator
+
+// parseSemverRange parses ranges in the syntax used by npm and Composer, e.g.
+// ">=1.2.0 <2.0.0", "^1.4", "~2.3.1", "1.x || 2.x" or "1.0.0 - 1.5.0".
+func parseSemverRange(s string) (semverRange, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, errors.New("empty semver range")
+	}
+
+	var r semverRange
+	for _, alternative := range strings.Split(s, "||") {
+		set, err := parseSemverComparatorSet(alternative)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, set)
+	}
+	return r, nil
+}
+
+// matches reports whether v satisfies the range.
+func (r semverRange) matches(v semver) bool {
+	for _, set := range r {
+		ok := true
+		for _, c := range set {
+			if !c.matches(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func parseSemverComparatorSet(s string) ([]semverComparator, error) {
+	fields := strings.Fields(s)
+
+	// Hyphen range: "1.2.3 - 2.3.4".
+	if len(fields) == 3 && fields[1] == "-" {
+		lower, err := parsePartialSemver(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		upper, err := parsePartialSemver(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		set := []semverComparator{{">=", lower.version}}
+		switch {
+		case upper.parts == 0:
+		case upper.wildcard:
+			set = append(set, semverComparator{"<", upper.next()})
+		default:
+			set = append(set, semverComparator{"<=", upper.version})
+		}
+		return set, nil
+	}
+
+	// Operators may be separated from their version, e.g. ">= 1.2.3".
+	var tokens []string
+	for i := 0; i < len(fields); i++ {
+		if strings.Trim(fields[i], "<>=~^") == "" && i+1 < len(fields) {
+			tokens = append(tokens, fields[i]+fields[i+1])
+			i++
+			continue
+		}
+		tokens = append(tokens, fields[i])
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("empty semver range")
+	}
+
+	var set []semverComparator
+	for _, token := range tokens {
+		comparators, err := parseSemverComparator(token)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, comparators...)
+	}
+	return set, nil
+}
+
+// parseSemverComparator expands one comparator into primitive comparators.
+func parseSemverComparator(token string) ([]semverComparator, error) {
+	op := ""
+	for _, candidate := range []string{">=", "<=", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(token, candidate) {
+			op = candidate
+			break
+		}
+	}
+	p, err := parsePartialSemver(token[len(op):])
+	if err != nil {
+		return nil, err
+	}
+	v := p.version
+
+	if p.parts == 0 {
+		// "*", "x" or ">=*" match everything; "<*" and ">*" match nothing.
+		if op == "<" || op == ">" {
+			return []semverComparator{{"<", semver{}}}, nil
+		}
+		return nil, nil
+	}
+
+	switch op {
+	case ">":
+		if p.wildcard {
+			return []semverComparator{{">=", p.next()}}, nil
+		}
+		return []semverComparator{{">", v}}, nil
+	case ">=", "<":
+		return []semverComparator{{op, v}}, nil
+	case "<=":
+		if p.wildcard {
+			return []semverComparator{{"<", p.next()}}, nil
+		}
+		return []semverComparator{{"<=", v}}, nil
+	case "~":
+		upper := semver{major: v.major, minor: v.minor + 1}
+		if p.parts == 1 {
+			upper = semver{major: v.major + 1}
+		}
+		return []semverComparator{{">=", v}, {"<", upper}}, nil
+	case "^":
+		var upper semver
+		switch {
+		case v.major > 0 || p.parts == 1:
+			upper = semver{major: v.major + 1}
+		case v.minor > 0 || p.parts == 2:
+			upper = semver{minor: v.minor + 1}
+		default:
+			upper = semver{patch: v.patch + 1}
+		}
+		return []semverComparator{{">=", v}, {"<", upper}}, nil
+	}
+
+	if p.wildcard {
+		return []semverComparator{{">=", v}, {"<", p.next()}}, nil
+	}
+	return []semverComparator{{"=", v}}, nil
+}
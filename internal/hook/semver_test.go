@@ -0,0 +1,91 @@
+package hook
+
+import (
+	"testing"
+)
+
+func TestSemverCompare(t *testing.T) {
+	// Ordered by precedence, as in the semver specification.
+	versions := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta",
+		"1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "v1.0.1+build.5", "1.2", "2.0.0",
+	}
+	for i := range versions {
+		for j := range versions {
+			a, err := parseSemver(versions[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := parseSemver(versions[j])
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if got := a.compare(b); got != want {
+				t.Errorf("compare(%s, %s) = %d, want %d", versions[i], versions[j], got, want)
+			}
+		}
+	}
+
+	for _, invalid := range []string{"", "x", "1.x", "1.2.3.4", "1.a.3", "1.2-rc.1", "1.2.3-"} {
+		if _, err := parseSemver(invalid); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestSemverRange(t *testing.T) {
+	tests := []struct {
+		constraint string
+		matching   []string
+		other      []string
+	}{
+		{"1.2.3", []string{"1.2.3", "v1.2.3"}, []string{"1.2.4", "1.2.3-rc.1"}},
+		{">=1.2.0 <2.0.0", []string{"1.2.0", "1.9.9"}, []string{"1.1.9", "2.0.0"}},
+		{">= 1.2.0 < 2", []string{"1.2.0", "1.9.9"}, []string{"2.0.0"}},
+		{">1.2", []string{"1.3.0"}, []string{"1.2.9"}},
+		{"<=1.2", []string{"1.2.9"}, []string{"1.3.0"}},
+		{"1.x", []string{"1.0.0", "1.9.0"}, []string{"2.0.0", "0.9.0"}},
+		{"1.2.*", []string{"1.2.0", "1.2.7"}, []string{"1.3.0"}},
+		{"*", []string{"0.0.1", "9.9.9"}, nil},
+		{"~1.2.3", []string{"1.2.3", "1.2.9"}, []string{"1.3.0", "1.2.2"}},
+		{"~1", []string{"1.0.0", "1.9.0"}, []string{"2.0.0"}},
+		{"^1.2.3", []string{"1.2.3", "1.9.0"}, []string{"2.0.0", "1.2.2"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"^0.0", []string{"0.0.9"}, []string{"0.1.0"}},
+		{"1.0.0 - 1.5", []string{"1.0.0", "1.5.9"}, []string{"1.6.0", "0.9.9"}},
+		{"1.0.0 - 1.5.0", []string{"1.5.0"}, []string{"1.5.1"}},
+		{"1.x || >=3.0.0", []string{"1.4.0", "3.1.0"}, []string{"2.0.0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			r, err := parseSemverRange(tt.constraint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range tt.matching {
+				if version, _ := parseSemver(v); !r.matches(version) {
+					t.Errorf("%s should match %s", v, tt.constraint)
+				}
+			}
+			for _, v := range tt.other {
+				if version, _ := parseSemver(v); r.matches(version) {
+					t.Errorf("%s should not match %s", v, tt.constraint)
+				}
+			}
+		})
+	}
+
+	for _, invalid := range []string{"", ">=", "1.x ||", "^one", "1.2.3 - "} {
+		if _, err := parseSemverRange(invalid); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
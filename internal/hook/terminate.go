@@ -0,0 +1,63 @@
+package hook
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultKillSignal is sent to the command's process group when it has to be terminated.
+	DefaultKillSignal = "SIGTERM"
+	// DefaultKillGracePeriod is the time a command is given to exit before it is killed.
+	DefaultKillGracePeriod = 5 * time.Second
+)
+
+// killSignals lists the signals that can be used as kill-signal.
+var killSignals = map[string]bool{
+	"SIGTERM": true,
+	"SIGINT":  true,
+	"SIGHUP":  true,
+	"SIGQUIT": true,
+	"SIGUSR1": true,
+	"SIGUSR2": true,
+	"SIGKILL": true,
+}
+
+// normalizeSignal converts "term", "TERM" or "sigterm" to "SIGTERM".
+func normalizeSignal(name string) string {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if name != "" && !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	return name
+}
+
+// TerminationSignal returns the signal sent to the command's process group
+// when the execution is cancelled or times out.
+func (h *Hook) TerminationSignal() string {
+	if h == nil || h.KillSignal == "" {
+		return DefaultKillSignal
+	}
+	return normalizeSignal(h.KillSignal)
+}
+
+// TerminationGracePeriod returns how long the command may run after the
+// termination signal before it is killed with SIGKILL.
+func (h *Hook) TerminationGracePeriod() time.Duration {
+	if h == nil || h.KillGracePeriod <= 0 {
+		return DefaultKillGracePeriod
+	}
+	return h.KillGracePeriod.Duration()
+}
+
+// ValidateTermination checks the kill-signal and kill-grace-period settings of the hook.
+func (h *Hook) ValidateTermination() error {
+	if h.KillSignal != "" && !killSignals[normalizeSignal(h.KillSignal)] {
+		return fmt.Errorf("unsupported kill-signal %q", h.KillSignal)
+	}
+	if h.KillGracePeriod < 0 {
+		return fmt.Errorf("kill-grace-period must be >= 0, got %s", h.KillGracePeriod.Duration())
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package hook
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHookTerminationSignal(t *testing.T) {
+	var nilHook *Hook
+	tests := []struct {
+		h    *Hook
+		want string
+	}{
+		{nilHook, DefaultKillSignal},
+		{&Hook{}, DefaultKillSignal},
+		{&Hook{KillSignal: "SIGINT"}, "SIGINT"},
+		{&Hook{KillSignal: "quit"}, "SIGQUIT"},
+		{&Hook{KillSignal: " sigusr1 "}, "SIGUSR1"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.h.TerminationSignal(); got != tt.want {
+			t.Errorf("TerminationSignal() for %+v: got %q, want %q", tt.h, got, tt.want)
+		}
+	}
+}
+
+func TestHookTerminationGracePeriod(t *testing.T) {
+	if got := (&Hook{}).TerminationGracePeriod(); got != DefaultKillGracePeriod {
+		t.Errorf("default grace period: got %v, want %v", got, DefaultKillGracePeriod)
+	}
+	if got := (&Hook{KillGracePeriod: Duration(30 * time.Second)}).TerminationGracePeriod(); got != 30*time.Second {
+		t.Errorf("custom grace period: got %v, want 30s", got)
+	}
+}
+
+func TestHookValidateTermination(t *testing.T) {
+	tests := []struct {
+		name string
+		h    Hook
+		ok   bool
+	}{
+		{"empty", Hook{}, true},
+		{"term", Hook{KillSignal: "TERM", KillGracePeriod: Duration(10 * time.Second)}, true},
+		{"kill", Hook{KillSignal: "SIGKILL"}, true},
+		{"unknown signal", Hook{KillSignal: "SIGSTOP"}, false},
+		{"negative grace period", Hook{KillGracePeriod: Duration(-time.Second)}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.h.ValidateTermination()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: unexpected error state: %v", tt.name, err)
+		}
+	}
+}
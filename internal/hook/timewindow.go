@@ -0,0 +1,259 @@
+package hook
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	// The scratch container image has no zoneinfo database.
+	_ "time/tzdata"
+)
+
+// MatchTimeWindow is the MatchRule type matching the time a request is received.
+const MatchTimeWindow string = "time-window"
+
+// Reasons recorded for requests rejected by a TimeWindowRule.
+const (
+	TimeWindowReasonOutside  string = "outside_time_window"
+	TimeWindowReasonBlackout string = "blackout_window"
+)
+
+// TimeWindowRule matches requests received on the allowed days of week,
+// within the allowed time ranges and outside every blackout window, in the
+// configured time zone.
+type TimeWindowRule struct {
+	// Days lists the allowed days of week, e.g. "mon", "Friday" or the range
+	// "mon-fri". An empty list allows every day.
+	Days []string `json:"days,omitempty"`
+	// Hours lists the allowed time ranges, e.g. "09:00-17:30". The end is
+	// exclusive; a range ending before it starts spans midnight and belongs to
+	// the day it starts on. An empty list allows the whole day.
+	Hours []string `json:"hours,omitempty"`
+	// TimeZone is the IANA time zone name of days, hours and blackout
+	// windows, e.g. "Europe/Berlin". It defaults to UTC.
+	TimeZone string `json:"timezone,omitempty"`
+	// Blackouts lists date ranges during which the rule never matches.
+	Blackouts []BlackoutWindow `json:"blackouts,omitempty"`
+}
+
+// BlackoutWindow is a date range during which a TimeWindowRule never matches,
+// e.g. a release freeze. From and To are dates ("2024-12-20") or local times
+// ("2024-12-20T18:00"); a To date includes the whole day.
+type BlackoutWindow struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// timeRange is a time of day range in minutes after midnight.
+type timeRange struct {
+	start, end int
+}
+
+// timeWindow is the parsed form of a TimeWindowRule.
+type timeWindow struct {
+	location  *time.Location
+	days      [7]bool
+	hours     []timeRange
+	blackouts [][2]time.Time
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// Validate checks the time-window rule configuration.
+func (r *TimeWindowRule) Validate() error {
+	if r == nil {
+		return errors.New("time-window configuration is required")
+	}
+	_, err := r.parse()
+	return err
+}
+
+// Evaluate TimeWindowRule will return true if the request is received within
+// the window. A rejected request records TimeWindowReasonOutside or
+// TimeWindowReasonBlackout as its MismatchReason.
+func (r *TimeWindowRule) Evaluate(req *Request) (bool, error) {
+	return r.evaluateAt(req, time.Now())
+}
+
+func (r *TimeWindowRule) evaluateAt(req *Request, now time.Time) (bool, error) {
+	if r == nil {
+		return false, errors.New("time-window configuration is required")
+	}
+	w, err := r.parse()
+	if err != nil {
+		return false, err
+	}
+
+	if reason := w.reject(now); reason != "" {
+		if req != nil && req.MismatchReason == "" {
+			req.MismatchReason = reason
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// reject returns why t is outside the window, or an empty string.
+func (w *timeWindow) reject(t time.Time) string {
+	t = t.In(w.location)
+
+	for _, b := range w.blackouts {
+		if !t.Before(b[0]) && t.Before(b[1]) {
+			return TimeWindowReasonBlackout
+		}
+	}
+
+	day := t.Weekday()
+	previous := (day + 6) % 7
+	minute := t.Hour()*60 + t.Minute()
+
+	if len(w.hours) == 0 {
+		if w.days[day] {
+			return ""
+		}
+		return TimeWindowReasonOutside
+	}
+	for _, h := range w.hours {
+		if h.start < h.end {
+			if w.days[day] && minute >= h.start && minute < h.end {
+				return ""
+			}
+			continue
+		}
+		// The range spans midnight: the part after midnight belongs to the previous day.
+		if (w.days[day] && minute >= h.start) || (w.days[previous] && minute < h.end) {
+			return ""
+		}
+	}
+	return TimeWindowReasonOutside
+}
+
+func (r *TimeWindowRule) parse() (*timeWindow, error) {
+	w := &timeWindow{location: time.UTC}
+
+	if r.TimeZone != "" {
+		loc, err := time.LoadLocation(r.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", r.TimeZone, err)
+		}
+		w.location = loc
+	}
+
+	if len(r.Days) == 0 {
+		for i := range w.days {
+			w.days[i] = true
+		}
+	}
+	for _, d := range r.Days {
+		if err := w.addDays(d); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, h := range r.Hours {
+		tr, err := parseTimeRange(h)
+		if err != nil {
+			return nil, err
+		}
+		w.hours = append(w.hours, tr)
+	}
+
+	for _, b := range r.Blackouts {
+		from, _, err := parseWindowTime(b.From, w.location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blackout from %q: %w", b.From, err)
+		}
+		to, dateOnly, err := parseWindowTime(b.To, w.location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blackout to %q: %w", b.To, err)
+		}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		if !from.Before(to) {
+			return nil, fmt.Errorf("blackout from %q must be before to %q", b.From, b.To)
+		}
+		w.blackouts = append(w.blackouts, [2]time.Time{from, to})
+	}
+
+	return w, nil
+}
+
+// addDays allows a day of week or a range of days such as "mon-fri" or "fri-mon".
+func (w *timeWindow) addDays(s string) error {
+	first, last, isRange := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "-")
+	start, ok := weekdays[strings.TrimSpace(first)]
+	if !ok {
+		return fmt.Errorf("invalid day %q", s)
+	}
+	end := start
+	if isRange {
+		if end, ok = weekdays[strings.TrimSpace(last)]; !ok {
+			return fmt.Errorf("invalid day %q", s)
+		}
+	}
+	for d := start; ; d = (d + 1) % 7 {
+		w.days[d] = true
+		if d == end {
+			return nil
+		}
+	}
+}
+
+// parseTimeRange parses "HH:MM-HH:MM"; "24:00" may be used as an end.
+func parseTimeRange(s string) (timeRange, error) {
+	first, last, ok := strings.Cut(s, "-")
+	if !ok {
+		return timeRange{}, fmt.Errorf("invalid time range %q", s)
+	}
+	start, err := parseTimeOfDay(first, false)
+	if err != nil {
+		return timeRange{}, fmt.Errorf("invalid time range %q: %w", s, err)
+	}
+	end, err := parseTimeOfDay(last, true)
+	if err != nil {
+		return timeRange{}, fmt.Errorf("invalid time range %q: %w", s, err)
+	}
+	if start == end {
+		return timeRange{}, fmt.Errorf("invalid time range %q: empty range", s)
+	}
+	return timeRange{start: start, end: end}, nil
+}
+
+func parseTimeOfDay(s string, end bool) (int, error) {
+	s = strings.TrimSpace(s)
+	if end && s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("time %q must be HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parseWindowTime parses a date, a local time or an RFC 3339 time, and
+// reports whether s was a date.
+func parseWindowTime(s string, loc *time.Location) (time.Time, bool, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		return t, true, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02T15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, false, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	return time.Time{}, false, errors.New("expected a date or time such as 2024-12-20 or 2024-12-20T18:00")
+}
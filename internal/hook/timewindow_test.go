@@ -0,0 +1,127 @@
+package hook
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimeWindowRule(t *testing.T) {
+	rule := &TimeWindowRule{
+		Days:     []string{"mon-fri"},
+		Hours:    []string{"09:00-17:30", "22:00-02:00"},
+		TimeZone: "Europe/Berlin",
+		Blackouts: []BlackoutWindow{
+			{From: "2024-12-20", To: "2025-01-06"},
+			{From: "2024-11-14T12:00", To: "2024-11-14T15:00"},
+		},
+	}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(s string) time.Time {
+		tm, err := time.ParseInLocation("2006-01-02 15:04", s, berlin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+
+	tests := []struct {
+		description string
+		now         time.Time
+		ok          bool
+		reason      string
+	}{
+		{"business hours", at("2024-11-13 10:00"), true, ""},
+		{"business hours in UTC", at("2024-11-13 16:45").UTC(), true, ""},
+		{"end is exclusive", at("2024-11-13 17:30"), false, TimeWindowReasonOutside},
+		{"before hours", at("2024-11-13 08:59"), false, TimeWindowReasonOutside},
+		{"weekend", at("2024-11-16 10:00"), false, TimeWindowReasonOutside},
+		{"overnight", at("2024-11-13 23:00"), true, ""},
+		{"overnight after midnight", at("2024-11-14 01:00"), true, ""},
+		{"overnight from friday", at("2024-11-16 01:00"), true, ""},
+		{"overnight from sunday", at("2024-11-18 01:00"), false, TimeWindowReasonOutside},
+		{"blackout time", at("2024-11-14 13:00"), false, TimeWindowReasonBlackout},
+		{"after blackout time", at("2024-11-14 15:00"), true, ""},
+		{"blackout date", at("2024-12-23 10:00"), false, TimeWindowReasonBlackout},
+		{"blackout last day", at("2025-01-06 16:00"), false, TimeWindowReasonBlackout},
+		{"after blackout date", at("2025-01-07 10:00"), true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			req := &Request{}
+			ok, err := rule.evaluateAt(req, tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.ok {
+				t.Errorf("got ok=%v, want %v", ok, tt.ok)
+			}
+			if req.MismatchReason != tt.reason {
+				t.Errorf("got reason %q, want %q", req.MismatchReason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestTimeWindowRuleDefaults(t *testing.T) {
+	rule := &TimeWindowRule{Days: []string{"Saturday", "sun"}}
+	saturday := time.Date(2024, 11, 16, 23, 59, 0, 0, time.UTC)
+
+	if ok, _ := rule.evaluateAt(&Request{}, saturday); !ok {
+		t.Error("expected the whole day to be allowed")
+	}
+	if ok, _ := rule.evaluateAt(&Request{}, saturday.Add(-24*time.Hour)); ok {
+		t.Error("expected friday to be rejected")
+	}
+	if ok, _ := (&TimeWindowRule{Hours: []string{"00:00-24:00"}}).evaluateAt(&Request{}, saturday); !ok {
+		t.Error("expected every day to be allowed")
+	}
+}
+
+func TestTimeWindowRuleValidate(t *testing.T) {
+	tests := []struct {
+		description string
+		rule        *TimeWindowRule
+		ok          bool
+	}{
+		{"empty", &TimeWindowRule{}, true},
+		{"missing", nil, false},
+		{"day range wrapping the week", &TimeWindowRule{Days: []string{"fri-mon"}}, true},
+		{"invalid day", &TimeWindowRule{Days: []string{"funday"}}, false},
+		{"invalid time", &TimeWindowRule{Hours: []string{"9am-5pm"}}, false},
+		{"empty time range", &TimeWindowRule{Hours: []string{"09:00-09:00"}}, false},
+		{"invalid timezone", &TimeWindowRule{TimeZone: "Mars/Olympus"}, false},
+		{"rfc3339 blackout", &TimeWindowRule{Blackouts: []BlackoutWindow{{From: "2024-12-20T18:00:00Z", To: "2025-01-06"}}}, true},
+		{"reversed blackout", &TimeWindowRule{Blackouts: []BlackoutWindow{{From: "2025-01-06", To: "2024-12-20"}}}, false},
+		{"invalid blackout", &TimeWindowRule{Blackouts: []BlackoutWindow{{From: "next week", To: "2024-12-20"}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			rule := MatchRule{Type: MatchTimeWindow, TimeWindow: tt.rule}
+			if err := rule.Validate(); (err == nil) != tt.ok {
+				t.Errorf("got error %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestTimeWindowRuleJSON(t *testing.T) {
+	var rules Rules
+	err := json.Unmarshal([]byte(`{"match": {"type": "time-window", "time-window": {
+		"days": ["mon-fri"], "hours": ["09:00-17:00"], "timezone": "America/New_York",
+		"blackouts": [{"from": "2024-12-24", "to": "2024-12-26"}]}}}`), &rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rules.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := rules.Match.TimeWindow.Blackouts[0].To; got != "2024-12-26" {
+		t.Errorf("got blackout to %q", got)
+	}
+}
@@ -0,0 +1,152 @@
+package hook
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RedactedValue replaces sensitive values in rule traces.
+const RedactedValue = "***"
+
+// RuleTrace is a node of a trigger rule evaluation trace. Child rules that
+// were not evaluated, e.g. after the first false rule of an and rule, have no
+// node.
+type RuleTrace struct {
+	// Rule is the kind of the rule: and, or, not, match, replay-guard or expr.
+	Rule string `json:"rule"`
+	// Type is the type of a match rule.
+	Type string `json:"type,omitempty"`
+	// Parameter references the request value of a match rule, e.g. "header:X-Event".
+	Parameter string `json:"parameter,omitempty"`
+	// Value is the extracted parameter value. Values of signature rules and
+	// of parameters with sensitive names are replaced with RedactedValue.
+	Value *string `json:"value,omitempty"`
+	// Expr is the source of an expr rule.
+	Expr     string       `json:"expr,omitempty"`
+	Result   bool         `json:"result"`
+	Error    string       `json:"error,omitempty"`
+	Children []*RuleTrace `json:"children,omitempty"`
+}
+
+// EnableTrace makes the following trigger rule evaluations record a trace in
+// r.Trace.
+func (r *Request) EnableTrace() {
+	r.tracing = true
+}
+
+func (r Rules) evaluateTraced(req *Request) (bool, error) {
+	node := r.traceNode(req)
+	if n := len(req.traceStack); n > 0 {
+		parent := req.traceStack[n-1]
+		parent.Children = append(parent.Children, node)
+	} else {
+		req.Trace = node
+	}
+
+	req.traceStack = append(req.traceStack, node)
+	ok, err := r.evaluate(req)
+	req.traceStack = req.traceStack[:len(req.traceStack)-1]
+
+	node.Result = ok
+	if err != nil {
+		node.Error = err.Error()
+	}
+	return ok, err
+}
+
+// traceNode describes the rule before it is evaluated.
+func (r Rules) traceNode(req *Request) *RuleTrace {
+	switch {
+	case r.And != nil:
+		return &RuleTrace{Rule: "and"}
+	case r.Or != nil:
+		return &RuleTrace{Rule: "or"}
+	case r.Not != nil:
+		return &RuleTrace{Rule: "not"}
+	case r.Match != nil:
+		node := &RuleTrace{Rule: "match", Type: r.Match.Type}
+		p := r.Match.Parameter
+		if p.Source == "" {
+			return node
+		}
+		node.Parameter = p.Source + ":" + p.Name
+		if value, err := p.Get(req); err == nil {
+			if IsSignatureMatch(r.Match.Type) || IsSensitiveName(p.Name) {
+				value = RedactedValue
+			}
+			node.Value = &value
+		}
+		return node
+	case r.ReplayGuard != nil:
+		return &RuleTrace{Rule: "replay-guard"}
+	case r.Expr != nil:
+		return &RuleTrace{Rule: "expr", Expr: r.Expr.Source}
+	}
+	return &RuleTrace{Rule: "empty"}
+}
+
+// FailedRules returns the paths of the leaf rules that made the trace
+// evaluate to false, e.g. "and[1].match(value)". Under a not rule, the leaf
+// rules that evaluated to true are returned.
+func (t *RuleTrace) FailedRules() []string {
+	if t == nil || t.Result {
+		return nil
+	}
+	return t.blame(true, "")
+}
+
+// blame returns the leaf rules whose result differs from want.
+func (t *RuleTrace) blame(want bool, prefix string) []string {
+	name := t.Rule
+	if t.Type != "" {
+		name += "(" + t.Type + ")"
+	}
+	path := prefix + name
+	if t.Result == want {
+		return nil
+	}
+
+	switch t.Rule {
+	case "and", "or":
+		var paths []string
+		for i, child := range t.Children {
+			paths = append(paths, child.blame(want, fmt.Sprintf("%s[%d].", path, i))...)
+		}
+		return paths
+	case "not":
+		var paths []string
+		for _, child := range t.Children {
+			paths = append(paths, child.blame(!want, path+".")...)
+		}
+		return paths
+	}
+	return []string{path}
+}
+
+// IsSignatureMatch returns whether t is a MatchRule type verifying a
+// signature or token, whose parameter is secret.
+func IsSignatureMatch(t string) bool {
+	switch t {
+	case MatchHMACSHA1, MatchHMACSHA256, MatchHMACSHA512,
+		MatchHashSHA1, MatchHashSHA256, MatchHashSHA512,
+		ScalrSignature, MSTeamsSignature, MatchJWT:
+		return true
+	}
+	return IsProviderSignature(t) || IsPublicKeySignature(t)
+}
+
+var sensitiveNameKeywords = []string{
+	"password", "secret", "token", "auth", "signature", "cookie", "session", "credential", "api-key", "api_key", "apikey",
+}
+
+// IsSensitiveName reports whether a parameter name suggests a secret value,
+// e.g. the X-Gitlab-Token header.
+func IsSensitiveName(name string) bool {
+	name = strings.ToLower(name)
+	for _, keyword := range sensitiveNameKeywords {
+		if strings.Contains(name, keyword) {
+			return true
+		}
+	}
+	return false
+}
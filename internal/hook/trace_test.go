@@ -0,0 +1,123 @@
+package hook
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestRulesEvaluateTrace(t *testing.T) {
+	var rules Rules
+	err := json.Unmarshal([]byte(`{"and": [
+		{"match": {"type": "payload-hmac-sha256", "secret": "secret", "parameter": {"source": "header", "name": "X-Signature"}}},
+		{"or": [
+			{"match": {"type": "value", "value": "refs/heads/main", "parameter": {"source": "payload", "name": "ref"}}},
+			{"match": {"type": "value", "value": "push", "parameter": {"source": "header", "name": "X-Event"}}},
+			{"match": {"type": "value", "value": "deploy", "parameter": {"source": "header", "name": "X-Gitlab-Token"}}}
+		]},
+		{"not": {"match": {"type": "value", "value": "bot", "parameter": {"source": "payload", "name": "sender"}}}}
+	]}`), &rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`{"ref": "refs/heads/dev", "sender": "bot"}`)
+	req := &Request{Body: body, RawRequest: &http.Request{Header: http.Header{}}}
+	req.ParseHeaders(map[string][]string{
+		"X-Signature":    {"sha256=" + hmacSHA256Hex("secret", string(body))},
+		"X-Event":        {"pull_request"},
+		"X-Gitlab-Token": {"wrong"},
+	})
+	if err := req.ParseJSONPayload(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without tracing no trace is recorded.
+	if ok, err := rules.Evaluate(req); ok || err != nil || req.Trace != nil {
+		t.Fatalf("got ok=%v err=%v trace=%v", ok, err, req.Trace)
+	}
+
+	req.EnableTrace()
+	if ok, err := rules.Evaluate(req); ok || err != nil {
+		t.Fatalf("got ok=%v err=%v", ok, err)
+	}
+
+	root := req.Trace
+	if root == nil || root.Rule != "and" || root.Result || len(root.Children) != 2 {
+		t.Fatalf("unexpected root %+v", root)
+	}
+	signature := root.Children[0]
+	if !signature.Result || signature.Parameter != "header:X-Signature" || *signature.Value != RedactedValue {
+		t.Errorf("unexpected signature node %+v", signature)
+	}
+	or := root.Children[1]
+	if or.Rule != "or" || or.Result || len(or.Children) != 3 {
+		t.Fatalf("unexpected or node %+v", or)
+	}
+	if got := *or.Children[0].Value; got != "refs/heads/dev" {
+		t.Errorf("got ref value %q", got)
+	}
+	if got := *or.Children[2].Value; got != RedactedValue {
+		t.Errorf("got token value %q", got)
+	}
+
+	want := []string{"and[1].or[0].match(value)", "and[1].or[1].match(value)", "and[1].or[2].match(value)"}
+	if got := root.FailedRules(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got failed rules %v, want %v", got, want)
+	}
+}
+
+func TestRuleTraceFailedRules(t *testing.T) {
+	leaf := func(typ string, result bool) *RuleTrace {
+		return &RuleTrace{Rule: "match", Type: typ, Result: result}
+	}
+
+	tests := []struct {
+		description string
+		trace       *RuleTrace
+		want        []string
+	}{
+		{"matched", &RuleTrace{Rule: "and", Result: true, Children: []*RuleTrace{leaf("value", true)}}, nil},
+		{"leaf", leaf("regex", false), []string{"match(regex)"}},
+		{"and", &RuleTrace{Rule: "and", Children: []*RuleTrace{leaf("value", true), leaf("regex", false)}}, []string{"and[1].match(regex)"}},
+		{"not", &RuleTrace{Rule: "not", Children: []*RuleTrace{leaf("ip-whitelist", true)}}, []string{"not.match(ip-whitelist)"}},
+		{
+			"not or",
+			&RuleTrace{Rule: "not", Children: []*RuleTrace{
+				{Rule: "or", Result: true, Children: []*RuleTrace{leaf("value", false), leaf("regex", true)}},
+			}},
+			[]string{"not.or[1].match(regex)"},
+		},
+		{"expr", &RuleTrace{Rule: "expr", Expr: "size(payload.commits) > 0"}, []string{"expr"}},
+		{"nil", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			if got := tt.trace.FailedRules(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayGuardDryRun(t *testing.T) {
+	SetNonceStore(NewMemoryNonceStore(10))
+	defer SetNonceStore(NewMemoryNonceStore(DefaultNonceStoreSize))
+
+	rule := ReplayGuardRule{Nonce: &Argument{Source: SourceHeader, Name: "X-Delivery"}}
+	req := &Request{DryRun: true}
+	req.ParseHeaders(map[string][]string{"X-Delivery": {"delivery-1"}})
+
+	for i := 0; i < 2; i++ {
+		if ok, err := rule.Evaluate(req); !ok || err != nil {
+			t.Fatalf("dry run %d: got ok=%v err=%v", i, ok, err)
+		}
+	}
+
+	req.DryRun = false
+	if ok, _ := rule.Evaluate(req); !ok {
+		t.Error("expected the nonce not to be remembered by dry runs")
+	}
+}
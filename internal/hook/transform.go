@@ -0,0 +1,237 @@
+package hook
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Constants used to specify the type of an ArgumentTransform
+const (
+	TransformTrim         string = "trim"
+	TransformLower        string = "lower"
+	TransformUpper        string = "upper"
+	TransformURLDecode    string = "url-decode"
+	TransformRegexCapture string = "regex-capture"
+	TransformSplit        string = "split"
+	TransformJoin         string = "join"
+	TransformDefault      string = "default"
+	TransformSHA256       string = "sha256"
+	TransformJSONEncode   string = "json-encode"
+)
+
+// DefaultTransformSeparator is used by split and join without a separator.
+const DefaultTransformSeparator = ","
+
+// ArgumentTransform is a step of the transform pipeline of an Argument. In
+// JSON it is an object, or just the type for transforms without options,
+// e.g. ["trim", {"type": "split", "separator": " "}].
+//
+// split turns the value into a list; following transforms apply to each
+// element and join turns it back into a string. A list left at the end of
+// the pipeline is rendered as a JSON array.
+type ArgumentTransform struct {
+	Type string `json:"type"`
+	// Pattern is the regular expression of regex-capture.
+	Pattern string `json:"pattern,omitempty"`
+	// Group is the capture group of regex-capture, by default the first
+	// group or the whole match for patterns without groups.
+	Group *int `json:"group,omitempty"`
+	// Separator is used by split and join.
+	Separator string `json:"separator,omitempty"`
+	// Value replaces a missing or empty value for default.
+	Value string `json:"value,omitempty"`
+}
+
+// UnmarshalJSON accepts a transform object or the transform type.
+func (t *ArgumentTransform) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = ArgumentTransform{Type: name}
+		return nil
+	}
+
+	type plain ArgumentTransform
+	return json.Unmarshal(data, (*plain)(t))
+}
+
+// Validate checks the options of the transform.
+func (t *ArgumentTransform) Validate() error {
+	switch t.Type {
+	case TransformTrim, TransformLower, TransformUpper, TransformURLDecode,
+		TransformSplit, TransformJoin, TransformDefault, TransformSHA256, TransformJSONEncode:
+		return nil
+	case TransformRegexCapture:
+		re, err := getCompiledRegex(t.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid regex-capture pattern: %w", err)
+		}
+		if t.Group != nil && (*t.Group < 0 || *t.Group > re.NumSubexp()) {
+			return fmt.Errorf("regex-capture group %d out of range", *t.Group)
+		}
+		return nil
+	case "":
+		return errors.New("missing transform type")
+	}
+	return fmt.Errorf("unsupported transform %q", t.Type)
+}
+
+func (t *ArgumentTransform) separator() string {
+	if t.Separator == "" {
+		return DefaultTransformSeparator
+	}
+	return t.Separator
+}
+
+// transformValue is the value passed through a transform pipeline.
+type transformValue struct {
+	s      string
+	list   []string
+	isList bool
+}
+
+func (v transformValue) empty() bool {
+	if v.isList {
+		return len(v.list) == 0
+	}
+	return v.s == ""
+}
+
+func (v transformValue) String() (string, error) {
+	if !v.isList {
+		return v.s, nil
+	}
+	list := v.list
+	if list == nil {
+		list = []string{}
+	}
+	r, err := json.Marshal(list)
+	return string(r), err
+}
+
+// each applies f to the value or to each element of a list; elements for
+// which f reports !ok are dropped, a single value is treated as missing.
+func (v transformValue) each(key string, f func(string) (string, bool, error)) (transformValue, error) {
+	if !v.isList {
+		s, ok, err := f(v.s)
+		if err != nil {
+			return v, err
+		}
+		if !ok {
+			return v, &ParameterNodeError{key}
+		}
+		return transformValue{s: s}, nil
+	}
+
+	list := make([]string, 0, len(v.list))
+	for _, elem := range v.list {
+		s, ok, err := f(elem)
+		if err != nil {
+			return v, err
+		}
+		if ok {
+			list = append(list, s)
+		}
+	}
+	return transformValue{list: list, isList: true}, nil
+}
+
+// applyTransforms passes the value retrieved for the argument through its
+// transforms. Transforms other than default are skipped once retrieving or
+// transforming the value failed; default replaces failed and empty values.
+func (ha *Argument) applyTransforms(value string, err error) (string, error) {
+	v := transformValue{s: value}
+	for i := range ha.Transform {
+		t := &ha.Transform[i]
+		if t.Type == TransformDefault {
+			if err != nil || v.empty() {
+				v, err = transformValue{s: t.Value}, nil
+			}
+			continue
+		}
+		if err == nil {
+			v, err = t.apply(ha.Name, v)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return v.String()
+}
+
+func (t *ArgumentTransform) apply(key string, v transformValue) (transformValue, error) {
+	switch t.Type {
+	case TransformTrim:
+		return v.each(key, func(s string) (string, bool, error) { return strings.TrimSpace(s), true, nil })
+
+	case TransformLower:
+		return v.each(key, func(s string) (string, bool, error) { return strings.ToLower(s), true, nil })
+
+	case TransformUpper:
+		return v.each(key, func(s string) (string, bool, error) { return strings.ToUpper(s), true, nil })
+
+	case TransformURLDecode:
+		return v.each(key, func(s string) (string, bool, error) {
+			decoded, err := url.QueryUnescape(s)
+			return decoded, err == nil, err
+		})
+
+	case TransformSHA256:
+		return v.each(key, func(s string) (string, bool, error) {
+			sum := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(sum[:]), true, nil
+		})
+
+	case TransformRegexCapture:
+		re, err := getCompiledRegex(t.Pattern)
+		if err != nil {
+			return v, err
+		}
+		group := 0
+		if t.Group != nil {
+			group = *t.Group
+		} else if re.NumSubexp() > 0 {
+			group = 1
+		}
+		return v.each(key, func(s string) (string, bool, error) {
+			m := re.FindStringSubmatch(s)
+			if m == nil || group >= len(m) {
+				return "", false, nil
+			}
+			return m[group], true, nil
+		})
+
+	case TransformSplit:
+		sep := t.separator()
+		if !v.isList {
+			if v.s == "" {
+				return transformValue{isList: true}, nil
+			}
+			return transformValue{list: strings.Split(v.s, sep), isList: true}, nil
+		}
+		var list []string
+		for _, elem := range v.list {
+			list = append(list, strings.Split(elem, sep)...)
+		}
+		return transformValue{list: list, isList: true}, nil
+
+	case TransformJoin:
+		if !v.isList {
+			return v, nil
+		}
+		return transformValue{s: strings.Join(v.list, t.separator())}, nil
+
+	case TransformJSONEncode:
+		if v.isList {
+			s, err := v.String()
+			return transformValue{s: s}, err
+		}
+		r, err := json.Marshal(v.s)
+		return transformValue{s: string(r)}, err
+	}
+	return v, fmt.Errorf("unsupported transform %q", t.Type)
+}
@@ -0,0 +1,125 @@
+package hook
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestArgumentTransform(t *testing.T) {
+	req := &Request{}
+	req.ParseHeaders(map[string][]string{
+		"X-Ref":   {"  refs/heads/Main  "},
+		"X-Files": {"a.go b.go  c.md"},
+		"X-Query": {"name%3Dhello%20world"},
+	})
+
+	group0 := 0
+	tests := []struct {
+		description string
+		name        string
+		transform   []ArgumentTransform
+		want        string
+		wantErr     bool
+	}{
+		{"trim lower", "X-Ref", []ArgumentTransform{{Type: "trim"}, {Type: "lower"}}, "refs/heads/main", false},
+		{"upper", "X-Ref", []ArgumentTransform{{Type: "trim"}, {Type: "upper"}}, "REFS/HEADS/MAIN", false},
+		{"url decode", "X-Query", []ArgumentTransform{{Type: "url-decode"}}, "name=hello world", false},
+		{"regex capture", "X-Ref", []ArgumentTransform{{Type: "regex-capture", Pattern: `refs/heads/(\S+)`}}, "Main", false},
+		{"regex capture group 0", "X-Ref", []ArgumentTransform{{Type: "regex-capture", Pattern: `heads/\S+`, Group: &group0}}, "heads/Main", false},
+		{"regex capture no match", "X-Ref", []ArgumentTransform{{Type: "regex-capture", Pattern: `refs/tags/(\S+)`}}, "", true},
+		{"regex capture default", "X-Ref", []ArgumentTransform{{Type: "regex-capture", Pattern: `refs/tags/(\S+)`}, {Type: "default", Value: "latest"}}, "latest", false},
+		{"split", "X-Files", []ArgumentTransform{{Type: "split", Separator: " "}}, `["a.go","b.go","","c.md"]`, false},
+		{
+			"split filter join",
+			"X-Files",
+			[]ArgumentTransform{{Type: "split", Separator: " "}, {Type: "regex-capture", Pattern: `^(\w+)\.go$`}, {Type: "join"}},
+			"a,b",
+			false,
+		},
+		{"split empty", "X-Empty", []ArgumentTransform{{Type: "default"}, {Type: "split"}}, "[]", false},
+		{"default missing", "X-Missing", []ArgumentTransform{{Type: "trim"}, {Type: "default", Value: "main"}}, "main", false},
+		{"default empty", "X-Ref", []ArgumentTransform{{Type: "regex-capture", Pattern: `^(\d*)`}, {Type: "default", Value: "0"}}, "0", false},
+		{"default unused", "X-Ref", []ArgumentTransform{{Type: "default", Value: "main"}, {Type: "trim"}}, "refs/heads/Main", false},
+		{"missing", "X-Missing", []ArgumentTransform{{Type: "trim"}}, "", true},
+		{"sha256", "X-Ref", []ArgumentTransform{{Type: "trim"}, {Type: "sha256"}}, "82c0992a6e20b803af8c10a9f23f6b7453c10ee5e4fdfa8f55d859c9ee63a460", false},
+		{"json encode", "X-Ref", []ArgumentTransform{{Type: "trim"}, {Type: "json-encode"}}, `"refs/heads/Main"`, false},
+		{"json encode list", "X-Files", []ArgumentTransform{{Type: "split", Separator: "  "}, {Type: "json-encode"}}, `["a.go b.go","c.md"]`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			arg := Argument{Source: SourceHeader, Name: tt.name, Transform: tt.transform}
+			if err := arg.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			got, err := arg.Get(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArgumentTransformJSON(t *testing.T) {
+	var arg Argument
+	err := json.Unmarshal([]byte(`{"source": "payload", "name": "ref", "required": true,
+		"transform": ["trim", {"type": "regex-capture", "pattern": "refs/heads/(.+)"}, {"type": "default", "value": "main"}]}`), &arg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !arg.Required || len(arg.Transform) != 3 {
+		t.Fatalf("unexpected argument %+v", arg)
+	}
+	if arg.Transform[0].Type != TransformTrim || arg.Transform[1].Pattern != "refs/heads/(.+)" || arg.Transform[2].Value != "main" {
+		t.Errorf("unexpected transforms %+v", arg.Transform)
+	}
+}
+
+func TestArgumentTransformValidate(t *testing.T) {
+	group2 := 2
+	tests := []struct {
+		description string
+		transform   ArgumentTransform
+		ok          bool
+	}{
+		{"trim", ArgumentTransform{Type: "trim"}, true},
+		{"missing type", ArgumentTransform{}, false},
+		{"unknown type", ArgumentTransform{Type: "reverse"}, false},
+		{"missing pattern", ArgumentTransform{Type: "regex-capture"}, false},
+		{"invalid pattern", ArgumentTransform{Type: "regex-capture", Pattern: "("}, false},
+		{"group out of range", ArgumentTransform{Type: "regex-capture", Pattern: "(a)", Group: &group2}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			if err := tt.transform.Validate(); (err == nil) != tt.ok {
+				t.Errorf("got error %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestHookCheckRequiredArguments(t *testing.T) {
+	req := &Request{}
+	req.ParseHeaders(map[string][]string{"X-Ref": {"main"}})
+
+	h := Hook{
+		PassArgumentsToCommand:   []Argument{{Source: SourceHeader, Name: "X-Ref", Required: true}, {Source: SourceHeader, Name: "X-Optional"}},
+		PassEnvironmentToCommand: []Argument{{Source: SourceHeader, Name: "X-Token", Required: true, Transform: []ArgumentTransform{{Type: "default", Value: "none"}}}},
+	}
+	if err := h.CheckRequiredArguments(req); err != nil {
+		t.Fatal(err)
+	}
+
+	h.PassFileToCommand = []Argument{{Source: SourceHeader, Name: "X-Body", Required: true}}
+	err := h.CheckRequiredArguments(req)
+	if !IsArgumentError(err) {
+		t.Fatalf("got error %v, want ArgumentError", err)
+	}
+	if name := err.(*ArgumentError).Argument.Name; name != "X-Body" {
+		t.Errorf("got argument %q", name)
+	}
+}
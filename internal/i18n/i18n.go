@@ -176,4 +176,12 @@ const (
 	ERR_VALIDATE_HOOK_FILE_LOAD_ERROR = "ERR_VALIDATE_HOOK_FILE_LOAD_ERROR"
 	ERR_VALIDATE_HOOK_ID_EMPTY        = "ERR_VALIDATE_HOOK_ID_EMPTY"
 	ERR_VALIDATE_HOOK_ID_DUPLICATE    = "ERR_VALIDATE_HOOK_ID_DUPLICATE"
+	ERR_VALIDATE_HOOK_INVALID_CONFIG  = "ERR_VALIDATE_HOOK_INVALID_CONFIG"
+	ERR_VALIDATE_HOOK_UNKNOWN_REF     = "ERR_VALIDATE_HOOK_UNKNOWN_REF"
+	ERR_VALIDATE_HOOK_REF_CYCLE       = "ERR_VALIDATE_HOOK_REF_CYCLE"
+
+	ERR_VALIDATE_TLS_CERT_KEY_PAIR          = "ERR_VALIDATE_TLS_CERT_KEY_PAIR"
+	ERR_VALIDATE_TLS_CLIENT_CA_WITHOUT_CERT = "ERR_VALIDATE_TLS_CLIENT_CA_WITHOUT_CERT"
+	ERR_VALIDATE_INVALID_TLS_CLIENT_AUTH    = "ERR_VALIDATE_INVALID_TLS_CLIENT_AUTH"
+	ERR_SERVER_TLS_CONFIG                   = "ERR_SERVER_TLS_CONFIG"
 )
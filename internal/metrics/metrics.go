@@ -45,6 +45,18 @@ var (
 	// TriggerRules 触发规则评估指标
 	TriggerRules *prometheus.CounterVec
 
+	// TriggerRuleFailures 导致触发规则不满足的叶子规则指标
+	TriggerRuleFailures *prometheus.CounterVec
+
+	// HookRetries 异步 hook 重试次数指标
+	HookRetries *prometheus.CounterVec
+
+	// HookKills hook 命令被终止的次数指标
+	HookKills *prometheus.CounterVec
+
+	// HookForwards 转发请求指标
+	HookForwards *prometheus.CounterVec
+
 	// 用于跟踪并发 hook 执行的计数器
 	concurrentHooksMap = make(map[string]int)
 	concurrentHooksMu  sync.Mutex
@@ -130,6 +142,30 @@ func initMetrics() {
 			Labels("hook_id", "result").
 			BuildVec()
 
+		// 新增：导致触发规则不满足的叶子规则指标，rule 为规则在规则树中的路径
+		TriggerRuleFailures = registry.Counter("trigger_rule_failures_total").
+			Help("Total number of trigger rule evaluations that failed by the leaf rule that failed").
+			Labels("hook_id", "rule").
+			BuildVec()
+
+		// 新增：异步 hook 重试指标
+		HookRetries = registry.Counter("hook_retries_total").
+			Help("Total number of scheduled retries of asynchronous hook executions").
+			Labels("hook_id").
+			BuildVec()
+
+		// 新增：hook 命令终止指标
+		HookKills = registry.Counter("hook_kills_total").
+			Help("Total number of terminated hook commands by reason and final signal").
+			Labels("hook_id", "reason", "signal").
+			BuildVec()
+
+		// 新增：转发请求指标
+		HookForwards = registry.Counter("hook_forward_requests_total").
+			Help("Total number of upstream requests sent by forward hooks by status code").
+			Labels("hook_id", "status").
+			BuildVec()
+
 		// 注册所有指标到默认 Prometheus registry
 		prometheus.MustRegister(
 			HookExecutions,
@@ -143,6 +179,10 @@ func initMetrics() {
 			SignatureVerify,
 			RateLimitHits,
 			TriggerRules,
+			TriggerRuleFailures,
+			HookRetries,
+			HookKills,
+			HookForwards,
 		)
 	})
 }
@@ -153,6 +193,21 @@ func RecordHookExecution(hookID, status string, duration time.Duration) {
 	HookDuration.WithLabelValues(hookID).Observe(duration.Seconds())
 }
 
+// RecordHookRetry 记录一次异步 hook 重试调度
+func RecordHookRetry(hookID string) {
+	HookRetries.WithLabelValues(hookID).Inc()
+}
+
+// RecordHookKill 记录一次 hook 命令终止，signal 为最后发送的信号
+func RecordHookKill(hookID, reason, signal string) {
+	HookKills.WithLabelValues(hookID, reason, signal).Inc()
+}
+
+// RecordHookForward 记录一次转发请求，status 为上游状态码，请求失败时为 "error"
+func RecordHookForward(hookID, status string) {
+	HookForwards.WithLabelValues(hookID, status).Inc()
+}
+
 // IncrementConcurrentHooks 增加并发 hook 计数
 func IncrementConcurrentHooks(hookID string) {
 	concurrentHooksMu.Lock()
@@ -205,6 +260,14 @@ func RecordTriggerRuleEvaluation(hookID, result string) {
 	}
 }
 
+// RecordTriggerRuleFailure 记录导致触发规则不满足的叶子规则
+// rule: 规则在规则树中的路径，例如 "and[1].match(value)"
+func RecordTriggerRuleFailure(hookID, rule string) {
+	if TriggerRuleFailures != nil {
+		TriggerRuleFailures.WithLabelValues(hookID, rule).Inc()
+	}
+}
+
 // UpdateSystemMetrics 更新系统指标（内存、CPU、goroutine）
 func UpdateSystemMetrics() {
 	var m runtime.MemStats
@@ -322,6 +385,14 @@ func (TriggerRuleMetrics) RecordError(hookID string) {
 	RecordTriggerRuleEvaluation(hookID, "error")
 }
 
+// RecordFailedRules 记录规则不匹配，以及评估轨迹中导致不匹配的叶子规则
+func (TriggerRuleMetrics) RecordFailedRules(hookID string, rules []string) {
+	RecordTriggerRuleEvaluation(hookID, "not_matched")
+	for _, rule := range rules {
+		RecordTriggerRuleFailure(hookID, rule)
+	}
+}
+
 // 全局便捷实例
 var (
 	Signature   SignatureMetrics
@@ -15,6 +15,32 @@ func TestRecordHookExecution(t *testing.T) {
 	RecordHookExecution(hookID, "failure", duration)
 }
 
+func TestRecordHookRetry(t *testing.T) {
+	// 这个测试主要确保函数不会 panic
+	RecordHookRetry("test-hook-retry")
+	RecordHookRetry("test-hook-retry")
+}
+
+func TestRecordHookKill(t *testing.T) {
+	// 这个测试主要确保函数不会 panic
+	RecordHookKill("test-hook-kill", "timeout", "SIGTERM")
+	RecordHookKill("test-hook-kill", "shutdown", "SIGKILL")
+}
+
+func TestRecordHookForward(t *testing.T) {
+	// 这个测试主要确保函数不会 panic
+	RecordHookForward("test-hook-forward", "200")
+	RecordHookForward("test-hook-forward", "error")
+}
+
+func TestTriggerRuleMetrics(t *testing.T) {
+	// 这个测试主要确保函数不会 panic
+	TriggerRule.RecordMatched("test-hook-rules")
+	TriggerRule.RecordError("test-hook-rules")
+	TriggerRule.RecordFailedRules("test-hook-rules", []string{"and[1].match(value)", "and[1].not.match(regex)"})
+	TriggerRule.RecordFailedRules("test-hook-rules", nil)
+}
+
 func TestIncrementDecrementConcurrentHooks(t *testing.T) {
 	hookID := "test-hook-1"
 
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	rediskit "github.com/soulteary/redis-kit/client"
+)
+
+// DefaultNonceKeyPrefix 是重放保护 nonce 在 Redis 中的默认键前缀
+const DefaultNonceKeyPrefix = "webhook:nonce:"
+
+// NewRedisClient 按地址、密码与数据库索引创建 Redis 连接
+func NewRedisClient(addr, password string, db int) (*redis.Client, error) {
+	cfg := rediskit.DefaultConfig().
+		WithAddr(addr).
+		WithPassword(password).
+		WithDB(db)
+
+	client, err := rediskit.NewClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	return client, nil
+}
+
+// NonceKeyPrefix 在配置的 Redis 键前缀后追加 nonce:，使共享同一 Redis 的实例可以通过前缀隔离；
+// 未配置前缀时使用 DefaultNonceKeyPrefix
+func NonceKeyPrefix(redisKeyPrefix string) string {
+	if redisKeyPrefix == "" {
+		return DefaultNonceKeyPrefix
+	}
+	return redisKeyPrefix + "nonce:"
+}
+
+// RedisNonceStore 使用 Redis 记录 replay-guard 规则的 nonce，多个实例可共享
+type RedisNonceStore struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+// NewRedisNonceStore 创建基于 Redis 的 nonce 存储
+func NewRedisNonceStore(client *redis.Client, keyPrefix string) *RedisNonceStore {
+	if keyPrefix == "" {
+		keyPrefix = DefaultNonceKeyPrefix
+	}
+	return &RedisNonceStore{client: client, keyPrefix: keyPrefix}
+}
+
+// Seen 返回 nonce 是否已经存在
+func (s *RedisNonceStore) Seen(ctx context.Context, nonce string) (bool, error) {
+	n, err := s.client.Exists(ctx, s.keyPrefix+nonce).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Remember 记录 nonce 并返回它是否已经存在（SET NX 保证多实例间的原子性）
+func (s *RedisNonceStore) Remember(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	stored, err := s.client.SetNX(ctx, s.keyPrefix+nonce, 1, ttl).Result()
+	if err != nil {
+		return false, err
+	}
+	return !stored, nil
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRedisNonceStore_DefaultPrefix(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1"})
+	defer client.Close()
+
+	store := NewRedisNonceStore(client, "")
+	assert.Equal(t, DefaultNonceKeyPrefix, store.keyPrefix)
+}
+
+func TestNonceKeyPrefix(t *testing.T) {
+	assert.Equal(t, DefaultNonceKeyPrefix, NonceKeyPrefix(""))
+	assert.Equal(t, "tenant-a:nonce:", NonceKeyPrefix("tenant-a:"))
+}
+
+func TestRedisNonceStore_Unavailable(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 100 * time.Millisecond, MaxRetries: -1})
+	defer client.Close()
+
+	store := NewRedisNonceStore(client, "test:nonce:")
+	seen, err := store.Remember(context.Background(), "delivery-1", time.Minute)
+	assert.Error(t, err, "an unavailable Redis must not accept the request as new")
+	assert.False(t, seen)
+
+	seen, err = store.Seen(context.Background(), "delivery-1")
+	assert.Error(t, err)
+	assert.False(t, seen)
+}
+
+func TestRateLimiter_RedisClient(t *testing.T) {
+	var nilLimiter *RateLimiter
+	assert.Nil(t, nilLimiter.RedisClient())
+
+	rl := NewRateLimiter(RateLimitConfig{Enabled: true, RPS: 10, Burst: 10})
+	assert.Nil(t, rl.RedisClient(), "in-memory rate limiter has no Redis connection")
+}
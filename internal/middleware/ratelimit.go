@@ -11,7 +11,6 @@ import (
 
 	"github.com/redis/go-redis/v9"
 	loggerkit "github.com/soulteary/logger-kit"
-	rediskit "github.com/soulteary/redis-kit/client"
 	redisratelimit "github.com/soulteary/redis-kit/ratelimit"
 	"github.com/soulteary/webhook/internal/logger"
 	"golang.org/x/time/rate"
@@ -96,14 +95,9 @@ func NewRateLimiter(config RateLimitConfig) *RateLimiter {
 
 // initRedis 初始化 Redis 连接
 func (rl *RateLimiter) initRedis() error {
-	cfg := rediskit.DefaultConfig().
-		WithAddr(rl.config.RedisAddr).
-		WithPassword(rl.config.RedisPassword).
-		WithDB(rl.config.RedisDB)
-
-	client, err := rediskit.NewClient(cfg)
+	client, err := NewRedisClient(rl.config.RedisAddr, rl.config.RedisPassword, rl.config.RedisDB)
 	if err != nil {
-		return fmt.Errorf("failed to connect to Redis: %w", err)
+		return err
 	}
 
 	// 设置键前缀，如果未配置则使用默认值
@@ -130,6 +124,14 @@ func (rl *RateLimiter) Close() error {
 	return nil
 }
 
+// RedisClient 返回限流器使用的 Redis 连接，未启用 Redis 时返回 nil
+func (rl *RateLimiter) RedisClient() *redis.Client {
+	if rl == nil || !rl.useRedis {
+		return nil
+	}
+	return rl.redisClient
+}
+
 // IsRedisEnabled 返回是否启用了 Redis 限流
 func (rl *RateLimiter) IsRedisEnabled() bool {
 	if rl == nil {
@@ -5,7 +5,9 @@ import (
 	"strings"
 
 	"github.com/soulteary/webhook/internal/flags"
+	"github.com/soulteary/webhook/internal/hook"
 	"github.com/soulteary/webhook/internal/link"
+	"github.com/soulteary/webhook/internal/rules"
 	"github.com/soulteary/webhook/internal/version"
 )
 
@@ -37,7 +39,25 @@ func Spec(appFlags flags.AppFlags, serverURL string) ([]byte, error) {
 		"/metrics": map[string]any{
 			"get": op("Metrics", "Prometheus metrics.", "text/plain"),
 		},
-		hookPath: hooksPathOp(appFlags),
+		hookPath:            hooksPathOp(appFlags),
+		"/jobs/{id}":        jobsPathOp("Job status", "Returns state, attempts, exit code, duration and captured output of an asynchronous hook execution.", "application/json"),
+		"/jobs/{id}/stdout": jobsPathOp("Job stdout", "Returns the captured standard output of an asynchronous hook execution.", "text/plain"),
+		"/jobs/{id}/stderr": jobsPathOp("Job stderr", "Returns the captured standard error of an asynchronous hook execution.", "text/plain"),
+	}
+
+	if appFlags.ExplainToken != "" {
+		paths["/explain/{id}"] = explainPathOp()
+	}
+
+	// Hooks declaring a payload-schema get their own path using the schema as request body.
+	schemas := map[string]any{}
+	for _, h := range rules.LoadedHooks() {
+		if h.PayloadSchema == nil || h.PayloadSchema.Schema == nil {
+			continue
+		}
+		name := schemaComponentName(h.ID)
+		schemas[name] = rebaseRefs(h.PayloadSchema.Schema, "#/components/schemas/"+name)
+		paths[hookBase+"/"+h.ID] = hookSchemaPathOp(appFlags, h, name)
 	}
 
 	spec := map[string]any{
@@ -49,6 +69,9 @@ func Spec(appFlags flags.AppFlags, serverURL string) ([]byte, error) {
 		},
 		"paths": paths,
 	}
+	if len(schemas) > 0 {
+		spec["components"] = map[string]any{"schemas": schemas}
+	}
 
 	if serverURL != "" {
 		spec["servers"] = []map[string]any{
@@ -74,7 +97,7 @@ func op(summary, description, contentType string) map[string]any {
 	}
 }
 
-func hooksPathOp(appFlags flags.AppFlags) map[string]any {
+func hookMethods(appFlags flags.AppFlags) []string {
 	methods := appFlags.HttpMethods
 	if methods == "" {
 		methods = "POST,PUT,PATCH"
@@ -86,9 +109,30 @@ func hooksPathOp(appFlags flags.AppFlags) map[string]any {
 	if len(parts) == 0 {
 		parts = []string{"POST"}
 	}
+	return parts
+}
 
+func hookResponses() map[string]any {
+	return map[string]any{
+		"200": map[string]any{
+			"description": "Hook executed successfully (or rule not matched; body depends on hook config)",
+			"content": map[string]any{
+				"text/plain":       map[string]any{"schema": map[string]any{"type": "string"}},
+				"application/json": map[string]any{"schema": map[string]any{"type": "object"}},
+			},
+		},
+		"400": map[string]any{"description": "Bad request or hook rules not satisfied"},
+		"404": map[string]any{"description": "Hook not found"},
+		"405": map[string]any{"description": "Method not allowed for this hook"},
+		"422": map[string]any{"description": "Request payload does not match the payload schema of the hook"},
+		"500": map[string]any{"description": "Internal server error during hook execution"},
+		"503": map[string]any{"description": "Server shutting down"},
+	}
+}
+
+func hooksPathOp(appFlags flags.AppFlags) map[string]any {
 	ops := make(map[string]any)
-	for _, m := range parts {
+	for _, m := range hookMethods(appFlags) {
 		if m == "" {
 			continue
 		}
@@ -112,22 +156,125 @@ func hooksPathOp(appFlags flags.AppFlags) map[string]any {
 					"multipart/form-data":               map[string]any{"schema": map[string]any{"type": "object"}},
 				},
 			},
-			"responses": map[string]any{
-				"200": map[string]any{
-					"description": "Hook executed successfully (or rule not matched; body depends on hook config)",
-					"content": map[string]any{
-						"text/plain":       map[string]any{"schema": map[string]any{"type": "string"}},
-						"application/json": map[string]any{"schema": map[string]any{"type": "object"}},
-					},
+			"responses": hookResponses(),
+		}
+	}
+
+	return ops
+}
+
+// hookSchemaPathOp describes a hook declaring a payload-schema, published as
+// the component schema name.
+func hookSchemaPathOp(appFlags flags.AppFlags, h hook.Hook, name string) map[string]any {
+	methods := h.HTTPMethods
+	if len(methods) == 0 {
+		methods = hookMethods(appFlags)
+	}
+
+	ops := make(map[string]any)
+	for _, m := range methods {
+		if m == "" {
+			continue
+		}
+		ops[strings.ToLower(m)] = map[string]any{
+			"summary":     "Trigger hook " + h.ID,
+			"description": "Executes the hook " + h.ID + ". The JSON payload is validated against the payload schema of the hook.",
+			"requestBody": map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/" + name}},
 				},
-				"400": map[string]any{"description": "Bad request or hook rules not satisfied"},
-				"404": map[string]any{"description": "Hook not found"},
-				"405": map[string]any{"description": "Method not allowed for this hook"},
-				"500": map[string]any{"description": "Internal server error during hook execution"},
-				"503": map[string]any{"description": "Server shutting down"},
 			},
+			"responses": hookResponses(),
 		}
 	}
-
 	return ops
 }
+
+// schemaComponentName returns a component name for the payload schema of the
+// hook; characters not allowed in component names are replaced.
+func schemaComponentName(hookID string) string {
+	name := []byte(hookID)
+	for i, c := range name {
+		if !(c == '.' || c == '-' || c == '_' || (c >= '0' && c <= '9') || (c|0x20 >= 'a' && c|0x20 <= 'z')) {
+			name[i] = '_'
+		}
+	}
+	return string(name) + ".payload"
+}
+
+// rebaseRefs copies a schema, rewriting references within the schema to
+// point below base.
+func rebaseRefs(v any, base string) any {
+	switch x := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, val := range x {
+			if ref, ok := val.(string); ok && k == "$ref" && strings.HasPrefix(ref, "#") {
+				out[k] = base + strings.TrimPrefix(ref, "#")
+				continue
+			}
+			out[k] = rebaseRefs(val, base)
+		}
+		return out
+	case []any:
+		out := make([]any, len(x))
+		for i := range x {
+			out[i] = rebaseRefs(x[i], base)
+		}
+		return out
+	}
+	return v
+}
+
+func jobsPathOp(summary, description, contentType string) map[string]any {
+	get := op(summary, description, contentType)
+	get["parameters"] = []map[string]any{
+		{
+			"name":        "id",
+			"in":          "path",
+			"required":    true,
+			"description": "Job identifier returned in the Location and X-Job-Id headers of an asynchronous hook response",
+			"schema":      map[string]any{"type": "string"},
+		},
+	}
+	responses := get["responses"].(map[string]any)
+	responses["404"] = map[string]any{"description": "Job not found or no longer retained"}
+	responses["405"] = map[string]any{"description": "Method not allowed"}
+
+	return map[string]any{"get": get}
+}
+
+func explainPathOp() map[string]any {
+	post := op("Explain trigger rules", "Evaluates the trigger rules of the hook against a sample request without executing the hook, and returns the evaluation trace. Requires the explain token as a bearer token.", "application/json")
+	post["parameters"] = []map[string]any{
+		{
+			"name":        "id",
+			"in":          "path",
+			"required":    true,
+			"description": "Hook identifier",
+			"schema":      map[string]any{"type": "string"},
+		},
+	}
+	post["requestBody"] = map[string]any{
+		"required": false,
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"method":      map[string]any{"type": "string"},
+					"headers":     map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}},
+					"query":       map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}},
+					"body":        map[string]any{"description": "raw body as a string, or a JSON body"},
+					"remote-addr": map[string]any{"type": "string"},
+				},
+			}},
+		},
+	}
+	responses := post["responses"].(map[string]any)
+	responses["400"] = map[string]any{"description": "Invalid sample request"}
+	responses["401"] = map[string]any{"description": "Missing or invalid explain token"}
+	responses["404"] = map[string]any{"description": "Hook not found"}
+
+	return map[string]any{"post": post}
+}
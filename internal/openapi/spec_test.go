@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/soulteary/webhook/internal/flags"
+	"github.com/soulteary/webhook/internal/hook"
+	"github.com/soulteary/webhook/internal/rules"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -36,6 +38,26 @@ func TestSpec(t *testing.T) {
 	assert.Contains(t, paths, "/version")
 	assert.Contains(t, paths, "/metrics")
 	assert.Contains(t, paths, "/hooks/{id}")
+	assert.Contains(t, paths, "/jobs/{id}")
+	assert.Contains(t, paths, "/jobs/{id}/stdout")
+	assert.Contains(t, paths, "/jobs/{id}/stderr")
+	assert.NotContains(t, paths, "/explain/{id}")
+}
+
+func TestSpec_Explain(t *testing.T) {
+	appFlags := flags.AppFlags{HooksURLPrefix: "hooks", ExplainToken: "secret"}
+	out, err := Spec(appFlags, "")
+	require.NoError(t, err)
+
+	var spec map[string]any
+	err = json.Unmarshal(out, &spec)
+	require.NoError(t, err)
+
+	paths, ok := spec["paths"].(map[string]any)
+	require.True(t, ok)
+	explain, ok := paths["/explain/{id}"].(map[string]any)
+	require.True(t, ok)
+	assert.Contains(t, explain, "post")
 }
 
 func TestSpec_WithServerURL(t *testing.T) {
@@ -70,3 +92,40 @@ func TestSpec_CustomURLPrefix(t *testing.T) {
 	require.True(t, ok)
 	assert.Contains(t, paths, "/api/webhooks/{id}")
 }
+
+func TestSpec_PayloadSchema(t *testing.T) {
+	var schema hook.PayloadSchema
+	require.NoError(t, json.Unmarshal([]byte(`{"type": "object", "properties": {"commit": {"$ref": "#/$defs/commit"}}, "$defs": {"commit": {"type": "string"}}}`), &schema))
+	rules.LoadedHooksFromFiles = map[string]hook.Hooks{
+		"test.json": {
+			{ID: "github/push", HTTPMethods: []string{"POST"}, PayloadSchema: &schema},
+			{ID: "plain"},
+		},
+	}
+	rules.BuildIndex()
+	defer func() {
+		rules.LoadedHooksFromFiles = map[string]hook.Hooks{}
+		rules.BuildIndex()
+	}()
+
+	out, err := Spec(flags.AppFlags{HooksURLPrefix: "hooks"}, "")
+	require.NoError(t, err)
+
+	var spec map[string]any
+	require.NoError(t, json.Unmarshal(out, &spec))
+
+	paths := spec["paths"].(map[string]any)
+	assert.NotContains(t, paths, "/hooks/plain")
+	push, ok := paths["/hooks/github/push"].(map[string]any)
+	require.True(t, ok)
+	require.Contains(t, push, "post")
+	assert.NotContains(t, push, "put")
+	body := push["post"].(map[string]any)["requestBody"].(map[string]any)
+	content := body["content"].(map[string]any)["application/json"].(map[string]any)
+	assert.Equal(t, "#/components/schemas/github_push.payload", content["schema"].(map[string]any)["$ref"])
+
+	schemas := spec["components"].(map[string]any)["schemas"].(map[string]any)
+	published := schemas["github_push.payload"].(map[string]any)
+	commit := published["properties"].(map[string]any)["commit"].(map[string]any)
+	assert.Equal(t, "#/components/schemas/github_push.payload/$defs/commit", commit["$ref"])
+}
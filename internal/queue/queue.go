@@ -0,0 +1,379 @@
+// Package queue implements the job queue used for asynchronous hook
+// executions. Jobs are kept in memory and, when a path is configured, mirrored
+// to an append-only log so that unfinished work survives a restart. Finished
+// jobs stay available for status queries until their retention window ends.
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// State 表示任务所处的阶段
+type State string
+
+// Job states
+const (
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+	StateTimeout   State = "timeout"
+)
+
+// IsTerminal reports whether no further attempt will be made for a job in this state.
+func (s State) IsTerminal() bool {
+	return s == StateSucceeded || s == StateFailed || s == StateTimeout
+}
+
+// DefaultRetention 已结束任务的默认保留时间
+const DefaultRetention = time.Hour
+
+// compactThreshold 追加写入的记录数超过该值且明显多于存活任务时重写日志
+const compactThreshold = 1000
+
+// ErrClosed is returned when writing to a closed queue.
+var ErrClosed = errors.New("job queue is closed")
+
+// Job 描述一次异步 hook 执行及其重试进度
+type Job struct {
+	ID          string    `json:"id"`
+	HookID      string    `json:"hook_id"`
+	RequestID   string    `json:"request_id,omitempty"`
+	State       State     `json:"state"`
+	Attempt     int       `json:"attempt"`
+	MaxAttempts int       `json:"max_attempts"`
+	LastError   string    `json:"last_error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	NextRunAt   time.Time `json:"next_run_at,omitempty"`
+	FinishedAt  time.Time `json:"finished_at,omitempty"`
+	Request     *Snapshot `json:"request,omitempty"`
+
+	// 最近一次尝试的执行结果
+	ExitCode   *int   `json:"exit_code,omitempty"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+	Stdout     string `json:"stdout,omitempty"`
+	Stderr     string `json:"stderr,omitempty"`
+}
+
+// Clone returns a copy of the job that can be modified without affecting the queue.
+func (j *Job) Clone() *Job {
+	if j == nil {
+		return nil
+	}
+	c := *j
+	if j.ExitCode != nil {
+		code := *j.ExitCode
+		c.ExitCode = &code
+	}
+	return &c
+}
+
+// expired 判断已结束的任务是否超出保留时间
+func (j *Job) expired(now time.Time, retention time.Duration) bool {
+	if !j.State.IsTerminal() {
+		return false
+	}
+	finished := j.FinishedAt
+	if finished.IsZero() {
+		finished = j.UpdatedAt
+	}
+	return !now.Before(finished.Add(retention))
+}
+
+// NewJobID 生成随机的任务 ID
+func NewJobID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// record 是追加日志中的一行
+type record struct {
+	Job *Job `json:"job"`
+}
+
+// Queue 保存任务状态，并在配置了路径时写入追加日志
+type Queue struct {
+	mu        sync.Mutex
+	path      string
+	file      *os.File
+	jobs      map[string]*Job
+	retention time.Duration
+	appended  int
+	lastPrune time.Time
+	closed    bool
+}
+
+// Open 打开（或创建）位于 path 的任务日志，并回放其中的记录。
+// path 为空时返回仅驻留内存的队列。已结束的任务在 retention 内仍可查询，
+// retention 为 0 时结束即删除。
+func Open(path string, retention time.Duration) (*Queue, error) {
+	if retention < 0 {
+		retention = 0
+	}
+	q := &Queue{
+		path:      path,
+		jobs:      make(map[string]*Job),
+		retention: retention,
+	}
+	if path == "" {
+		return q, nil
+	}
+
+	q.path = filepath.Clean(path)
+	if err := q.replay(); err != nil {
+		return nil, err
+	}
+	if err := q.compact(); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// Path returns the log file path, or an empty string for an in-memory queue.
+func (q *Queue) Path() string {
+	return q.path
+}
+
+// replay 读取日志，每个任务以最后一条记录为准
+func (q *Queue) replay() error {
+	f, err := os.Open(q.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var rec record
+			decoder := json.NewDecoder(bytes.NewReader(line))
+			decoder.UseNumber()
+			// 进程崩溃时最后一行可能只写了一半，跳过无法解析的行
+			if decodeErr := decoder.Decode(&rec); decodeErr == nil && rec.Job != nil && rec.Job.ID != "" {
+				q.jobs[rec.Job.ID] = rec.Job
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	q.pruneLocked(time.Now())
+
+	return nil
+}
+
+// pruneLocked 删除超出保留时间的已结束任务
+func (q *Queue) pruneLocked(now time.Time) {
+	for id, job := range q.jobs {
+		if job.expired(now, q.retention) {
+			delete(q.jobs, id)
+		}
+	}
+	q.lastPrune = now
+}
+
+// compact 用当前状态重写日志，丢弃已完成任务的历史记录
+func (q *Queue) compact() error {
+	dir := filepath.Dir(q.path)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(q.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	writer := bufio.NewWriter(tmp)
+	for _, job := range q.sortedLocked() {
+		if err := writeRecord(writer, job); err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmpName)
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, q.path); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+
+	f, err := os.OpenFile(q.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if q.file != nil {
+		_ = q.file.Close()
+	}
+	q.file = f
+	q.appended = 0
+	return nil
+}
+
+// writeRecord 写入一条任务记录，请求快照中的敏感值不会落盘
+func writeRecord(w interface{ Write([]byte) (int, error) }, job *Job) error {
+	persisted := *job
+	persisted.Request = job.Request.redacted()
+	b, err := json.Marshal(record{Job: &persisted})
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	_, err = w.Write(b)
+	return err
+}
+
+// Put 保存任务的最新状态；持久化队列会在返回前将记录落盘
+func (q *Queue) Put(job *Job) error {
+	if job == nil || job.ID == "" {
+		return errors.New("job must have an ID")
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+
+	now := time.Now()
+	stored := job.Clone()
+	stored.UpdatedAt = now
+	if stored.CreatedAt.IsZero() {
+		stored.CreatedAt = now
+	}
+	if stored.State.IsTerminal() && stored.FinishedAt.IsZero() {
+		stored.FinishedAt = now
+	}
+
+	if q.file != nil {
+		if err := writeRecord(q.file, stored); err != nil {
+			return fmt.Errorf("error writing job %s to queue log: %w", stored.ID, err)
+		}
+		if err := q.file.Sync(); err != nil {
+			return fmt.Errorf("error syncing queue log: %w", err)
+		}
+		q.appended++
+	}
+
+	if stored.expired(now, q.retention) {
+		delete(q.jobs, stored.ID)
+	} else {
+		q.jobs[stored.ID] = stored
+	}
+
+	// 定期清理过期任务，并在日志中的历史记录过多时重写日志
+	if now.Sub(q.lastPrune) >= time.Minute {
+		q.pruneLocked(now)
+	}
+	if q.file != nil && q.appended > compactThreshold && q.appended > 4*len(q.jobs) {
+		q.pruneLocked(now)
+		if err := q.compact(); err != nil {
+			return fmt.Errorf("error compacting queue log: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Get 返回指定任务的副本
+func (q *Queue) Get(id string) (*Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok || job.expired(time.Now(), q.retention) {
+		return nil, false
+	}
+	return job.Clone(), true
+}
+
+// Unfinished 返回所有尚未结束的任务（按创建时间排序）
+func (q *Queue) Unfinished() []*Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := q.sortedLocked()
+	result := make([]*Job, 0, len(jobs))
+	for _, job := range jobs {
+		if !job.State.IsTerminal() {
+			result = append(result, job.Clone())
+		}
+	}
+	return result
+}
+
+// Len returns the number of jobs held by the queue, finished jobs still in
+// their retention window included.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.jobs)
+}
+
+func (q *Queue) sortedLocked() []*Job {
+	jobs := make([]*Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].ID < jobs[j].ID
+		}
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// Close 关闭日志文件，之后的写入将返回 ErrClosed
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+
+	if q.file != nil {
+		err := q.file.Close()
+		q.file = nil
+		return err
+	}
+	return nil
+}
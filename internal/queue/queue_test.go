@@ -0,0 +1,363 @@
+package queue
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/soulteary/webhook/internal/hook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen_InMemory(t *testing.T) {
+	q, err := Open("", 0)
+	require.NoError(t, err)
+	defer func() { _ = q.Close() }()
+
+	assert.Equal(t, "", q.Path())
+	assert.Equal(t, 0, q.Len())
+
+	job := &Job{ID: NewJobID(), HookID: "test-hook", State: StateQueued, MaxAttempts: 1}
+	require.NoError(t, q.Put(job))
+
+	got, ok := q.Get(job.ID)
+	require.True(t, ok)
+	assert.Equal(t, "test-hook", got.HookID)
+	assert.False(t, got.CreatedAt.IsZero())
+	assert.False(t, got.UpdatedAt.IsZero())
+}
+
+func TestPut_RequiresID(t *testing.T) {
+	q, err := Open("", 0)
+	require.NoError(t, err)
+
+	assert.Error(t, q.Put(nil))
+	assert.Error(t, q.Put(&Job{}))
+}
+
+func TestPut_TerminalJobsAreDroppedWithoutRetention(t *testing.T) {
+	q, err := Open("", 0)
+	require.NoError(t, err)
+
+	job := &Job{ID: "job-1", HookID: "test-hook", State: StateQueued}
+	require.NoError(t, q.Put(job))
+	assert.Equal(t, 1, q.Len())
+
+	job.State = StateSucceeded
+	require.NoError(t, q.Put(job))
+	assert.Equal(t, 0, q.Len())
+
+	_, ok := q.Get("job-1")
+	assert.False(t, ok)
+}
+
+func TestPut_RetainsFinishedJobs(t *testing.T) {
+	q, err := Open("", time.Hour)
+	require.NoError(t, err)
+
+	code := 0
+	job := &Job{ID: "job-1", HookID: "test-hook", State: StateSucceeded, ExitCode: &code, Stdout: "done"}
+	require.NoError(t, q.Put(job))
+
+	got, ok := q.Get("job-1")
+	require.True(t, ok)
+	assert.Equal(t, StateSucceeded, got.State)
+	assert.False(t, got.FinishedAt.IsZero())
+	require.NotNil(t, got.ExitCode)
+	assert.Equal(t, 0, *got.ExitCode)
+	assert.Equal(t, "done", got.Stdout)
+	assert.Empty(t, q.Unfinished())
+
+	// 超出保留时间后不再可见
+	expired := &Job{ID: "job-2", State: StateFailed, FinishedAt: time.Now().Add(-2 * time.Hour)}
+	require.NoError(t, q.Put(expired))
+	_, ok = q.Get("job-2")
+	assert.False(t, ok)
+}
+
+func TestOpen_ReplaysRetainedFinishedJobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.log")
+
+	q, err := Open(path, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, q.Put(&Job{ID: "recent", State: StateTimeout}))
+	require.NoError(t, q.Put(&Job{ID: "old", State: StateSucceeded, FinishedAt: time.Now().Add(-2 * time.Hour)}))
+	require.NoError(t, q.Close())
+
+	reopened, err := Open(path, time.Hour)
+	require.NoError(t, err)
+	defer func() { _ = reopened.Close() }()
+
+	got, ok := reopened.Get("recent")
+	require.True(t, ok)
+	assert.Equal(t, StateTimeout, got.State)
+	_, ok = reopened.Get("old")
+	assert.False(t, ok)
+	assert.Empty(t, reopened.Unfinished())
+}
+
+func TestGet_ReturnsCopy(t *testing.T) {
+	q, err := Open("", 0)
+	require.NoError(t, err)
+
+	require.NoError(t, q.Put(&Job{ID: "job-1", State: StateQueued}))
+
+	got, ok := q.Get("job-1")
+	require.True(t, ok)
+	got.State = StateRunning
+
+	again, _ := q.Get("job-1")
+	assert.Equal(t, StateQueued, again.State)
+}
+
+func TestPut_AfterClose(t *testing.T) {
+	q, err := Open("", 0)
+	require.NoError(t, err)
+	require.NoError(t, q.Close())
+	require.NoError(t, q.Close())
+
+	assert.ErrorIs(t, q.Put(&Job{ID: "job-1"}), ErrClosed)
+}
+
+func TestOpen_ReplaysUnfinishedJobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.log")
+
+	q, err := Open(path, 0)
+	require.NoError(t, err)
+
+	base := time.Now()
+	queued := &Job{ID: "queued", HookID: "a", State: StateQueued, CreatedAt: base, Attempt: 1, MaxAttempts: 3, NextRunAt: base.Add(time.Minute)}
+	running := &Job{ID: "running", HookID: "b", State: StateQueued, CreatedAt: base.Add(time.Second), MaxAttempts: 1}
+	done := &Job{ID: "done", HookID: "c", State: StateQueued, CreatedAt: base.Add(2 * time.Second), MaxAttempts: 1}
+
+	require.NoError(t, q.Put(queued))
+	require.NoError(t, q.Put(running))
+	require.NoError(t, q.Put(done))
+
+	running.State = StateRunning
+	running.Attempt = 1
+	require.NoError(t, q.Put(running))
+
+	done.State = StateFailed
+	done.LastError = "exit status 1"
+	require.NoError(t, q.Put(done))
+	require.NoError(t, q.Close())
+
+	reopened, err := Open(path, 0)
+	require.NoError(t, err)
+	defer func() { _ = reopened.Close() }()
+
+	jobs := reopened.Unfinished()
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "queued", jobs[0].ID)
+	assert.Equal(t, 1, jobs[0].Attempt)
+	assert.Equal(t, 3, jobs[0].MaxAttempts)
+	assert.WithinDuration(t, base.Add(time.Minute), jobs[0].NextRunAt, time.Millisecond)
+	assert.Equal(t, "running", jobs[1].ID)
+	assert.Equal(t, StateRunning, jobs[1].State)
+
+	// 打开时会压缩日志：每个未完成的任务只保留一行
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := 0
+	for _, b := range data {
+		if b == '\n' {
+			lines++
+		}
+	}
+	assert.Equal(t, 2, lines)
+}
+
+func TestOpen_SkipsCorruptLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.log")
+
+	b, err := json.Marshal(record{Job: &Job{ID: "job-1", HookID: "a", State: StateQueued}})
+	require.NoError(t, err)
+	content := append(b, '\n')
+	content = append(content, []byte(`{"job": {"id": "job-2", "hook_`)...)
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+
+	q, err := Open(path, 0)
+	require.NoError(t, err)
+	defer func() { _ = q.Close() }()
+
+	jobs := q.Unfinished()
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "job-1", jobs[0].ID)
+}
+
+func TestOpen_CreatesParentDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "dir", "jobs.log")
+
+	q, err := Open(path, 0)
+	require.NoError(t, err)
+	defer func() { _ = q.Close() }()
+
+	_, err = os.Stat(path)
+	assert.NoError(t, err)
+}
+
+func TestNewJobID(t *testing.T) {
+	a := NewJobID()
+	b := NewJobID()
+	assert.Len(t, a, 32)
+	assert.NotEqual(t, a, b)
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	raw := &http.Request{
+		Method:     http.MethodPost,
+		RemoteAddr: "10.0.0.1:1234",
+		Header:     http.Header{"User-Agent": []string{"test-agent"}},
+		URL:        &url.URL{Path: "/hooks/deploy", RawQuery: "ref=main"},
+	}
+	r := &hook.Request{
+		ID:          "req-1",
+		ContentType: "application/json",
+		Body:        []byte(`{"count": 3}`),
+		Headers:     map[string]interface{}{"X-Event": "push"},
+		Query:       map[string]interface{}{"ref": "main"},
+		RawRequest:  raw,
+		Previous:    &hook.PreviousResult{HookID: "build", Status: hook.OutcomeFailure, ExitCode: 2},
+		Chain:       []string{"build"},
+		JWTClaims:   map[string]interface{}{"repository": "org/repo"},
+		CloudEvent:  map[string]interface{}{"type": "com.example.push"},
+	}
+	require.NoError(t, r.ParseJSONPayload())
+
+	s := SnapshotRequest(r)
+	assert.Equal(t, "10.0.0.1:1234", s.RemoteAddr)
+	assert.Equal(t, "test-agent", s.UserAgent)
+
+	path := filepath.Join(t.TempDir(), "jobs.log")
+	q, err := Open(path, 0)
+	require.NoError(t, err)
+	require.NoError(t, q.Put(&Job{ID: "job-1", HookID: "deploy", State: StateQueued, Request: s}))
+	require.NoError(t, q.Close())
+
+	reopened, err := Open(path, 0)
+	require.NoError(t, err)
+	defer func() { _ = reopened.Close() }()
+
+	job, ok := reopened.Get("job-1")
+	require.True(t, ok)
+
+	restored := job.Request.Request()
+	assert.Equal(t, "req-1", restored.ID)
+	assert.Equal(t, r.Body, restored.Body)
+	assert.Equal(t, r.Payload, restored.Payload)
+	assert.Equal(t, "push", restored.Headers["X-Event"])
+	assert.Equal(t, "main", restored.Query["ref"])
+	assert.Equal(t, r.Previous, restored.Previous)
+	assert.Equal(t, []string{"build"}, restored.Chain)
+	assert.Equal(t, "org/repo", restored.JWTClaims["repository"])
+	assert.Equal(t, "com.example.push", restored.CloudEvent["type"])
+	require.NotNil(t, restored.RawRequest)
+	assert.Equal(t, http.MethodPost, restored.RawRequest.Method)
+	assert.Equal(t, "10.0.0.1:1234", restored.RawRequest.RemoteAddr)
+	assert.Equal(t, "/hooks/deploy", restored.RawRequest.URL.Path)
+}
+
+func TestSnapshotRoundTrip_ClientCert(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ci-runner"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	r := &hook.Request{
+		ID: "req-1",
+		RawRequest: &http.Request{
+			Method: http.MethodPost,
+			URL:    &url.URL{Path: "/hooks/deploy"},
+			TLS:    &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}},
+		},
+	}
+
+	s := SnapshotRequest(r)
+	assert.Equal(t, der, s.ClientCert)
+
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+	var decoded Snapshot
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	restored := hook.ClientCertificate(decoded.Request())
+	require.NotNil(t, restored)
+	assert.Equal(t, "ci-runner", restored.Subject.CommonName)
+}
+
+func TestSnapshotRedactedInLog(t *testing.T) {
+	r := &hook.Request{
+		ID:      "req-1",
+		Headers: map[string]interface{}{"X-Event": "push", "X-Gitlab-Token": "s3cr3t"},
+		Query:   map[string]interface{}{"ref": "main", "token": "s3cr3t"},
+		RawRequest: &http.Request{
+			Method: http.MethodPost,
+			URL:    &url.URL{Path: "/hooks/deploy", RawQuery: "ref=main&token=s3cr3t"},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "jobs.log")
+	q, err := Open(path, 0)
+	require.NoError(t, err)
+	require.NoError(t, q.Put(&Job{ID: "job-1", HookID: "deploy", State: StateQueued, Request: SnapshotRequest(r)}))
+
+	// 内存中的任务保留原始值，日志文件中的敏感值被替换
+	job, ok := q.Get("job-1")
+	require.True(t, ok)
+	assert.Equal(t, "s3cr3t", job.Request.Headers["X-Gitlab-Token"])
+	require.NoError(t, q.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "s3cr3t")
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	reopened, err := Open(path, 0)
+	require.NoError(t, err)
+	defer func() { _ = reopened.Close() }()
+
+	job, ok = reopened.Get("job-1")
+	require.True(t, ok)
+	restored := job.Request.Request()
+	assert.Equal(t, "push", restored.Headers["X-Event"])
+	assert.Equal(t, hook.RedactedValue, restored.Headers["X-Gitlab-Token"])
+	assert.Equal(t, "main", restored.Query["ref"])
+	assert.Equal(t, hook.RedactedValue, restored.Query["token"])
+	assert.ElementsMatch(t, []hook.RequestValue{
+		{Source: hook.SourceHeader, Name: "X-Gitlab-Token"},
+		{Source: hook.SourceQuery, Name: "token"},
+	}, restored.Redacted)
+}
+
+func TestSnapshotRequest_Nil(t *testing.T) {
+	assert.Nil(t, SnapshotRequest(nil))
+
+	var s *Snapshot
+	r := s.Request()
+	require.NotNil(t, r)
+	assert.Nil(t, r.RawRequest)
+}
@@ -0,0 +1,164 @@
+package queue
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/soulteary/webhook/internal/hook"
+)
+
+// Snapshot 保存执行 hook 所需的请求数据，使任务在重启后仍可重放
+type Snapshot struct {
+	ID          string                 `json:"id,omitempty"`
+	ContentType string                 `json:"content_type,omitempty"`
+	Body        []byte                 `json:"body,omitempty"`
+	Headers     map[string]interface{} `json:"headers,omitempty"`
+	Query       map[string]interface{} `json:"query,omitempty"`
+	Payload     map[string]interface{} `json:"payload,omitempty"`
+	Method      string                 `json:"method,omitempty"`
+	URL         string                 `json:"url,omitempty"`
+	RemoteAddr  string                 `json:"remote_addr,omitempty"`
+	UserAgent   string                 `json:"user_agent,omitempty"`
+	// ClientCert 为 mTLS 客户端证书（DER），供 request 参数来源读取证书字段
+	ClientCert []byte `json:"client_cert,omitempty"`
+
+	AllowSignatureErrors bool `json:"allow_signature_errors,omitempty"`
+
+	// Previous 与 Chain 为链式触发的 hook 保存上一个 hook 的执行结果
+	Previous *hook.PreviousResult `json:"previous,omitempty"`
+	Chain    []string             `json:"chain,omitempty"`
+
+	// JWTClaims 为 jwt 规则校验通过的令牌声明，供 jwt-claim 参数使用
+	JWTClaims map[string]interface{} `json:"jwt_claims,omitempty"`
+
+	// CloudEvent 为 CloudEvents 请求的上下文属性，供 cloudevent 参数使用
+	CloudEvent map[string]interface{} `json:"cloudevent,omitempty"`
+
+	// Redacted 记录写入日志时被替换为 hook.RedactedValue 的请求头与查询参数
+	Redacted []hook.RequestValue `json:"redacted,omitempty"`
+}
+
+// SnapshotRequest captures the parts of r that are needed to run a hook later.
+func SnapshotRequest(r *hook.Request) *Snapshot {
+	if r == nil {
+		return nil
+	}
+
+	s := &Snapshot{
+		ID:                   r.ID,
+		ContentType:          r.ContentType,
+		Body:                 r.Body,
+		Headers:              r.Headers,
+		Query:                r.Query,
+		Payload:              r.Payload,
+		AllowSignatureErrors: r.AllowSignatureErrors,
+		Previous:             r.Previous,
+		Chain:                r.Chain,
+		JWTClaims:            r.JWTClaims,
+		CloudEvent:           r.CloudEvent,
+		Redacted:             r.Redacted,
+	}
+	if r.RawRequest != nil {
+		s.Method = r.RawRequest.Method
+		s.RemoteAddr = r.RawRequest.RemoteAddr
+		s.UserAgent = r.RawRequest.UserAgent()
+		if r.RawRequest.URL != nil {
+			s.URL = r.RawRequest.URL.String()
+		}
+	}
+	if cert := hook.ClientCertificate(r); cert != nil {
+		s.ClientCert = cert.Raw
+	}
+
+	return s
+}
+
+// redacted 返回写入日志文件的快照副本：名称敏感的请求头与查询参数
+// （例如 X-Gitlab-Token、token）替换为 hook.RedactedValue，并记录在 Redacted 中，
+// 回放时依赖这些值的任务会直接失败
+func (s *Snapshot) redacted() *Snapshot {
+	if s == nil {
+		return nil
+	}
+
+	c := *s
+	c.Redacted = append([]hook.RequestValue(nil), s.Redacted...)
+	c.Headers = c.redactValues(hook.SourceHeader, s.Headers)
+	c.Query = c.redactValues(hook.SourceQuery, s.Query)
+	if u, err := url.Parse(s.URL); err == nil && u.RawQuery != "" {
+		query := u.Query()
+		for name := range query {
+			if hook.IsSensitiveName(name) {
+				query[name] = []string{hook.RedactedValue}
+			}
+		}
+		u.RawQuery = query.Encode()
+		c.URL = u.String()
+	}
+	return &c
+}
+
+func (s *Snapshot) redactValues(source string, values map[string]interface{}) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+	redacted := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		if hook.IsSensitiveName(name) {
+			value = hook.RedactedValue
+			if v := (hook.RequestValue{Source: source, Name: name}); !slices.Contains(s.Redacted, v) {
+				s.Redacted = append(s.Redacted, v)
+			}
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+// Request 根据快照重建 hook.Request。
+// 从日志回放时数值会解码为 json.Number，这与 ParseJSONPayload 的行为一致。
+func (s *Snapshot) Request() *hook.Request {
+	if s == nil {
+		return &hook.Request{}
+	}
+
+	r := &hook.Request{
+		ID:                   s.ID,
+		ContentType:          s.ContentType,
+		Body:                 s.Body,
+		Headers:              s.Headers,
+		Query:                s.Query,
+		Payload:              s.Payload,
+		AllowSignatureErrors: s.AllowSignatureErrors,
+		Previous:             s.Previous,
+		Chain:                s.Chain,
+		JWTClaims:            s.JWTClaims,
+		CloudEvent:           s.CloudEvent,
+		Redacted:             s.Redacted,
+	}
+
+	raw := &http.Request{
+		Method:     s.Method,
+		RemoteAddr: s.RemoteAddr,
+		Header:     make(http.Header),
+		URL:        &url.URL{},
+	}
+	if u, err := url.Parse(s.URL); err == nil {
+		raw.URL = u
+	}
+	if s.ContentType != "" {
+		raw.Header.Set("Content-Type", s.ContentType)
+	}
+	if s.UserAgent != "" {
+		raw.Header.Set("User-Agent", s.UserAgent)
+	}
+	if cert, err := x509.ParseCertificate(s.ClientCert); err == nil {
+		raw.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	}
+	r.RawRequest = raw
+
+	return r
+}
@@ -101,6 +101,18 @@ func AddAndLoadHooksFile(hooksFilePath string, isAsTemplate bool) {
 		logger.Debugf("\tloaded: %s", h.ID)
 	}
 	hooksMutex.Lock()
+	if err := checkReferencesLocked(hooksFilePath, newHooks); err != nil {
+		logger.Errorf("error: %v, skipping file %s", err, hooksFilePath)
+		newList := HooksFiles[:0]
+		for _, p := range HooksFiles {
+			if p != hooksFilePath {
+				newList = append(newList, p)
+			}
+		}
+		HooksFiles = newList
+		hooksMutex.Unlock()
+		return
+	}
 	LoadedHooksFromFiles[hooksFilePath] = newHooks
 	updateIndexForFileLocked(hooksFilePath, newHooks)
 	hooksMutex.Unlock()
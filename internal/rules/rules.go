@@ -1,6 +1,9 @@
 package rules
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/soulteary/webhook/internal/hook"
@@ -104,6 +107,19 @@ func removeIndexForFileLocked(hooksFilePath string) {
 	}
 }
 
+// LoadedHooks 返回当前加载的所有 hook 的副本，按 ID 排序
+func LoadedHooks() []hook.Hook {
+	hooksMutex.RLock()
+	defer hooksMutex.RUnlock()
+
+	hooks := make([]hook.Hook, 0, lenLoadedHooksLocked())
+	for _, fileHooks := range LoadedHooksFromFiles {
+		hooks = append(hooks, fileHooks...)
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].ID < hooks[j].ID })
+	return hooks
+}
+
 func MatchLoadedHook(id string) *hook.Hook {
 	hooksMutex.RLock()
 
@@ -137,6 +153,34 @@ func MatchLoadedHook(id string) *hook.Hook {
 	return nil
 }
 
+// checkReferencesLocked 在已持有锁的情况下检查以 hooks 替换 hooksFilePath 中的 hook 后，
+// 这些 hook 的 on-success / on-failure / on-timeout 引用存在且所有 hook 的引用不构成循环
+func checkReferencesLocked(hooksFilePath string, hooks hook.Hooks) error {
+	all := make(hook.Hooks, 0, lenLoadedHooksLocked()+len(hooks))
+	for path, fileHooks := range LoadedHooksFromFiles {
+		if path != hooksFilePath {
+			all = append(all, fileHooks...)
+		}
+	}
+	all = append(all, hooks...)
+
+	ids := make(map[string]bool, len(all))
+	for i := range all {
+		ids[all[i].ID] = true
+	}
+	for i := range hooks {
+		for _, ref := range hooks[i].References() {
+			if !ids[ref] {
+				return fmt.Errorf("hook %s references unknown hook %s", hooks[i].ID, ref)
+			}
+		}
+	}
+	if cycle := hook.FindReferenceCycle(all); cycle != nil {
+		return fmt.Errorf("hook reference cycle: %s", strings.Join(cycle, " -> "))
+	}
+	return nil
+}
+
 func ReloadHooks(hooksFilePath string, asTemplate bool) {
 	hooksInFile := hook.Hooks{}
 
@@ -193,6 +237,12 @@ func ReloadHooks(hooksFilePath string, asTemplate bool) {
 
 			seenHooksIds[hook.ID] = true
 		}
+		if err := checkReferencesLocked(hooksFilePath, hooksInFile); err != nil {
+			hooksMutex.RUnlock()
+			logger.Errorf("error: %v in file %s", err, hooksFilePath)
+			logger.Warnf("reverting hooks back to the previous configuration (file: %s)", hooksFilePath)
+			return
+		}
 		hooksMutex.RUnlock()
 
 		// 加写锁进行更新
@@ -105,6 +105,41 @@ func TestReloadHooks(t *testing.T) {
 	assert.Equal(t, 1, rules.LenLoadedHooks())
 }
 
+func TestReloadHooks_InvalidHookKeepsPreviousConfig(t *testing.T) {
+	tempDir := t.TempDir()
+	hooksFile := filepath.Join(tempDir, "hooks.json")
+
+	err := os.WriteFile(hooksFile, []byte(`[{"id": "test-hook", "execute-command": "/bin/echo"}]`), 0644)
+	assert.NoError(t, err)
+
+	rules.HooksFiles = []string{hooksFile}
+	rules.LoadedHooksFromFiles = make(map[string]hook.Hooks)
+	rules.BuildIndex()
+	rules.ParseAndLoadHooks(false)
+	assert.Equal(t, 1, rules.LenLoadedHooks())
+
+	// replay-guard 既没有 timestamp 也没有 nonce，重载时应被拒绝
+	invalid := `[{"id": "test-hook", "execute-command": "/bin/true", "trigger-rule": {"replay-guard": {}}}]`
+	assert.NoError(t, os.WriteFile(hooksFile, []byte(invalid), 0644))
+	rules.ReloadHooks(hooksFile, false)
+
+	h := rules.MatchLoadedHook("test-hook")
+	if assert.NotNil(t, h) {
+		assert.Equal(t, "/bin/echo", h.ExecuteCommand)
+		assert.Nil(t, h.TriggerRule)
+	}
+
+	// 引用不存在的 hook 同样保留之前的配置
+	unknownRef := `[{"id": "test-hook", "execute-command": "/bin/true", "on-success": ["missing"]}]`
+	assert.NoError(t, os.WriteFile(hooksFile, []byte(unknownRef), 0644))
+	rules.ReloadHooks(hooksFile, false)
+
+	h = rules.MatchLoadedHook("test-hook")
+	if assert.NotNil(t, h) {
+		assert.Equal(t, "/bin/echo", h.ExecuteCommand)
+	}
+}
+
 func TestReloadHooks_WithTemplate(t *testing.T) {
 	// Setup
 	tempDir := t.TempDir()
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/soulteary/webhook/internal/flags"
+	"github.com/soulteary/webhook/internal/hook"
+	"github.com/soulteary/webhook/internal/logger"
+	"github.com/soulteary/webhook/internal/rules"
+)
+
+// 动作执行状态
+const (
+	ActionSucceeded = "succeeded"
+	ActionFailed    = "failed"
+	ActionSkipped   = "skipped"
+)
+
+// ActionResult 为多动作 hook 中单个动作的执行结果
+type ActionResult struct {
+	Name           string `json:"name"`
+	Status         string `json:"status"`
+	ExitCode       int    `json:"exit_code"`
+	UpstreamStatus int    `json:"upstream_status,omitempty"`
+	Output         string `json:"output,omitempty"`
+	Error          string `json:"error,omitempty"`
+	DurationMS     int64  `json:"duration_ms"`
+}
+
+// runHook 执行 hook：有 actions 时依次或并行执行各个动作，否则转发 HTTP 请求或执行命令
+func runHook(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter, appFlags flags.AppFlags) (*ExecutionResult, error) {
+	if len(h.Actions) > 0 {
+		ar := &actionRunner{
+			lookup: rules.MatchLoadedHook,
+			exec: func(ctx context.Context, h *hook.Hook, r *hook.Request) (*ExecutionResult, error) {
+				return runHookStep(ctx, h, r, nil, appFlags)
+			},
+		}
+		return ar.run(ctx, h, r, nil)
+	}
+	return runHookStep(ctx, h, r, w, appFlags)
+}
+
+// runHookStep 执行单个动作：转发 HTTP 请求或执行命令
+func runHookStep(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter, appFlags flags.AppFlags) (*ExecutionResult, error) {
+	if h.Forward != nil {
+		return runHookForward(ctx, h, r)
+	}
+	return runHookCommand(ctx, h, r, w, appFlags)
+}
+
+// actionRunner 执行 hook 的 actions，exec 用于执行单个命令或转发
+type actionRunner struct {
+	lookup func(id string) *hook.Hook
+	exec   func(ctx context.Context, h *hook.Hook, r *hook.Request) (*ExecutionResult, error)
+}
+
+// run 执行 hook：有 actions 时按顺序或并行执行各个动作，否则直接执行。
+// chain 为正在执行的 hook ID，用于检测通过 hook 引用形成的循环
+func (ar *actionRunner) run(ctx context.Context, h *hook.Hook, r *hook.Request, chain []string) (*ExecutionResult, error) {
+	if len(h.Actions) == 0 {
+		return ar.exec(ctx, h, r)
+	}
+	// 并行动作共享 chain，因此复制后再追加
+	next := append(append([]string(nil), chain...), h.ID)
+	return ar.runActions(ctx, h, r, next)
+}
+
+// runActions 执行所有动作并汇总结果。未设置 continue-on-error 的动作失败时，
+// 顺序模式下跳过后续动作，并行模式下取消仍在运行的动作
+func (ar *actionRunner) runActions(ctx context.Context, h *hook.Hook, r *hook.Request, chain []string) (*ExecutionResult, error) {
+	results := make([]ActionResult, len(h.Actions))
+	for i := range results {
+		results[i] = ActionResult{Name: h.ActionName(i), Status: ActionSkipped, ExitCode: -1}
+	}
+
+	var mu sync.Mutex
+	var failed error
+	failedExitCode := 0
+
+	// runOne 执行第 i 个动作，返回是否需要停止其余动作
+	runOne := func(ctx context.Context, i int) bool {
+		start := time.Now()
+		res, err := ar.runAction(ctx, h, i, r, chain)
+		if res == nil {
+			res = &ExecutionResult{ExitCode: -1}
+		}
+
+		entry := ActionResult{
+			Name:           h.ActionName(i),
+			Status:         ActionSucceeded,
+			ExitCode:       res.ExitCode,
+			UpstreamStatus: res.UpstreamStatus,
+			Output:         res.Output,
+			DurationMS:     time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			entry.Status = ActionFailed
+			entry.Error = err.Error()
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		results[i] = entry
+		if err == nil {
+			return false
+		}
+		if h.Actions[i].ContinueOnError {
+			logger.Warnf("[%s] action %s of hook %s failed, continuing: %v", r.ID, entry.Name, h.ID, err)
+			return false
+		}
+		if failed == nil {
+			failed = fmt.Errorf("action %s failed: %w", entry.Name, err)
+			failedExitCode = res.ExitCode
+		}
+		return true
+	}
+
+	if h.Parallel() {
+		actionCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var wg sync.WaitGroup
+		for i := range h.Actions {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if runOne(actionCtx, i) {
+					cancel()
+				}
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i := range h.Actions {
+			if runOne(ctx, i) || ctx.Err() != nil {
+				break
+			}
+		}
+	}
+
+	result := &ExecutionResult{ExitCode: 0, ContentType: "application/json", Actions: results}
+	if failed != nil {
+		result.ExitCode = failedExitCode
+	}
+	if out, err := json.Marshal(map[string]interface{}{"actions": results}); err == nil {
+		result.Output = string(out)
+		result.Stdout = result.Output
+	}
+	return result, failed
+}
+
+// runAction 执行第 i 个动作：引用其他 hook 时执行该 hook，否则执行动作自身的命令或转发
+func (ar *actionRunner) runAction(ctx context.Context, h *hook.Hook, i int, r *hook.Request, chain []string) (*ExecutionResult, error) {
+	ref := h.Actions[i].Hook
+	if ref == "" {
+		return ar.exec(ctx, h.ActionHook(i), r)
+	}
+
+	for _, id := range chain {
+		if id == ref {
+			return nil, fmt.Errorf("hook reference cycle: %s -> %s", strings.Join(chain, " -> "), ref)
+		}
+	}
+	target := ar.lookup(ref)
+	if target == nil {
+		return nil, fmt.Errorf("hook %s not found", ref)
+	}
+	return ar.run(ctx, target, r, chain)
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/soulteary/webhook/internal/hook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeActionRunner 按命令名返回预设结果，"fail" 返回退出码 2，"block" 等待 context 结束
+func fakeActionRunner(hooks ...*hook.Hook) (*actionRunner, *[]string) {
+	var mu sync.Mutex
+	var ran []string
+	byID := make(map[string]*hook.Hook)
+	for _, h := range hooks {
+		byID[h.ID] = h
+	}
+
+	return &actionRunner{
+		lookup: func(id string) *hook.Hook { return byID[id] },
+		exec: func(ctx context.Context, h *hook.Hook, r *hook.Request) (*ExecutionResult, error) {
+			mu.Lock()
+			ran = append(ran, h.ID)
+			mu.Unlock()
+
+			switch h.ExecuteCommand {
+			case "fail":
+				return &ExecutionResult{ExitCode: 2, Output: "boom"}, errors.New("exit status 2")
+			case "block":
+				<-ctx.Done()
+				return &ExecutionResult{ExitCode: -1}, ctx.Err()
+			default:
+				return &ExecutionResult{ExitCode: 0, Output: h.ExecuteCommand}, nil
+			}
+		},
+	}, &ran
+}
+
+func TestActionRunner_Sequential(t *testing.T) {
+	h := &hook.Hook{ID: "push", Actions: []hook.Action{
+		{Name: "lint", ExecuteCommand: "fail", ContinueOnError: true},
+		{Name: "deploy", ExecuteCommand: "deploy"},
+		{Name: "migrate", ExecuteCommand: "fail"},
+		{Name: "notify", ExecuteCommand: "notify"},
+	}}
+	ar, ran := fakeActionRunner()
+
+	result, err := ar.run(context.Background(), h, &hook.Request{ID: "req-1"}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "action migrate failed")
+	assert.Equal(t, 2, result.ExitCode)
+	assert.Equal(t, []string{"push/lint", "push/deploy", "push/migrate"}, *ran)
+
+	require.Len(t, result.Actions, 4)
+	assert.Equal(t, ActionFailed, result.Actions[0].Status)
+	assert.Equal(t, ActionSucceeded, result.Actions[1].Status)
+	assert.Equal(t, "deploy", result.Actions[1].Output)
+	assert.Equal(t, ActionFailed, result.Actions[2].Status)
+	assert.Equal(t, ActionSkipped, result.Actions[3].Status)
+
+	var body struct {
+		Actions []ActionResult `json:"actions"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(result.Output), &body))
+	assert.Equal(t, result.Actions, body.Actions)
+	assert.Equal(t, "application/json", result.ContentType)
+}
+
+func TestActionRunner_ContinueOnErrorSucceeds(t *testing.T) {
+	h := &hook.Hook{ID: "push", Actions: []hook.Action{
+		{ExecuteCommand: "deploy"},
+		{ExecuteCommand: "fail", ContinueOnError: true},
+	}}
+	ar, _ := fakeActionRunner()
+
+	result, err := ar.run(context.Background(), h, &hook.Request{ID: "req-2"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.ExitCode)
+	assert.Equal(t, ActionFailed, result.Actions[1].Status)
+}
+
+func TestActionRunner_ParallelCancelsOnFailure(t *testing.T) {
+	h := &hook.Hook{ID: "push", ActionsMode: hook.ActionsParallel, Actions: []hook.Action{
+		{Name: "slow", ExecuteCommand: "block"},
+		{Name: "broken", ExecuteCommand: "fail"},
+	}}
+	ar, _ := fakeActionRunner()
+
+	done := make(chan struct{})
+	var result *ExecutionResult
+	var err error
+	go func() {
+		result, err = ar.run(context.Background(), h, &hook.Request{ID: "req-3"}, nil)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("parallel actions were not cancelled")
+	}
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "action broken failed")
+	assert.Equal(t, ActionFailed, result.Actions[0].Status)
+	assert.Contains(t, result.Actions[0].Error, context.Canceled.Error())
+}
+
+func TestActionRunner_HookReferences(t *testing.T) {
+	notify := &hook.Hook{ID: "notify", ExecuteCommand: "notify"}
+	loopA := &hook.Hook{ID: "loop-a", Actions: []hook.Action{{Hook: "loop-b"}}}
+	loopB := &hook.Hook{ID: "loop-b", Actions: []hook.Action{{Hook: "loop-a"}}}
+	ar, ran := fakeActionRunner(notify, loopA, loopB)
+
+	h := &hook.Hook{ID: "push", Actions: []hook.Action{{Hook: "notify"}}}
+	result, err := ar.run(context.Background(), h, &hook.Request{ID: "req-4"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"notify"}, *ran)
+	assert.Equal(t, "notify", result.Actions[0].Output)
+
+	_, err = ar.run(context.Background(), loopA, &hook.Request{ID: "req-5"}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "loop-a -> loop-b -> loop-a")
+
+	missing := &hook.Hook{ID: "push", Actions: []hook.Action{{Hook: "removed"}}}
+	_, err = ar.run(context.Background(), missing, &hook.Request{ID: "req-6"}, nil)
+	assert.ErrorContains(t, err, "hook removed not found")
+}
+
+func TestExecuteCapturingHook_Actions(t *testing.T) {
+	ar, _ := fakeActionRunner()
+	executor := NewHookExecutorWithResultFunc(1, time.Second, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (*ExecutionResult, error) {
+		return ar.run(ctx, h, r, nil)
+	})
+
+	h := &hook.Hook{ID: "push", CaptureCommandOutput: true, Actions: []hook.Action{
+		{Name: "deploy", ExecuteCommand: "deploy"},
+		{Name: "notify", ExecuteCommand: "notify"},
+	}}
+	rec := httptest.NewRecorder()
+	executeCapturingHook(rec, context.Background(), h, &hook.Request{ID: "req-7"}, executor, time.Second, "req-7", h.ID, time.Now())
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var body struct {
+		Actions []ActionResult `json:"actions"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Actions, 2)
+	assert.Equal(t, "notify", body.Actions[1].Name)
+	assert.Equal(t, ActionSucceeded, body.Actions[1].Status)
+}
@@ -32,6 +32,8 @@ type ErrorResponse struct {
 	Message   string `json:"message,omitempty"`
 	RequestID string `json:"request_id,omitempty"`
 	HookID    string `json:"hook_id,omitempty"`
+	// Violations 列出请求体不符合 payload-schema 的位置
+	Violations []hook.SchemaViolation `json:"violations,omitempty"`
 }
 
 // HTTPError 封装HTTP错误信息
@@ -98,6 +100,13 @@ func ClassifyError(err error, requestID, hookID string) *HTTPError {
 			WithRequestID(requestID).WithHookID(hookID)
 	}
 
+	// 检查是否是 hook 并发限制错误（concurrency-policy 为 reject）
+	if errors.Is(err, ErrHookConcurrencyLimit) {
+		return NewHTTPError(ErrorTypeClient, http.StatusTooManyRequests,
+			"Hook is already running. Please retry later.", err).
+			WithRequestID(requestID).WithHookID(hookID)
+	}
+
 	// 检查是否是hook相关的错误
 	if hook.IsParameterNodeError(err) {
 		// 参数节点错误通常是客户端问题（缺少必需参数）
@@ -106,6 +115,22 @@ func ClassifyError(err error, requestID, hookID string) *HTTPError {
 			WithRequestID(requestID).WithHookID(hookID)
 	}
 
+	var schemaErr *hook.SchemaError
+	if errors.As(err, &schemaErr) {
+		// 请求体不符合 hook 声明的 payload-schema
+		return NewHTTPError(ErrorTypeClient, http.StatusUnprocessableEntity,
+			"Request payload does not match the payload schema.", err).
+			WithRequestID(requestID).WithHookID(hookID)
+	}
+
+	var argErr *hook.ArgumentError
+	if errors.As(err, &argErr) {
+		// 缺少 required 参数是客户端问题，拒绝执行 hook
+		return NewHTTPError(ErrorTypeClient, http.StatusBadRequest,
+			fmt.Sprintf("Missing required argument %q.", argErr.Argument.Name), err).
+			WithRequestID(requestID).WithHookID(hookID)
+	}
+
 	if hook.IsSignatureError(err) {
 		// 签名错误通常是客户端问题（无效签名）
 		return NewHTTPError(ErrorTypeClient, http.StatusUnauthorized,
@@ -159,6 +184,10 @@ func HandleError(w http.ResponseWriter, r *http.Request, err error, requestID, h
 		RequestID: requestID,
 		HookID:    hookID,
 	}
+	var schemaErr *hook.SchemaError
+	if errors.As(httpErr.Err, &schemaErr) {
+		errorResp.Violations = schemaErr.Violations
+	}
 
 	// 序列化为JSON
 	if jsonErr := json.NewEncoder(w).Encode(errorResp); jsonErr != nil {
@@ -88,6 +88,9 @@ func TestClassifyError(t *testing.T) {
 		{"context canceled", context.Canceled, ErrorTypeTimeout, http.StatusRequestTimeout},
 		{"parameter node error", &hook.ParameterNodeError{Key: "test"}, ErrorTypeClient, http.StatusBadRequest},
 		{"signature error", &hook.SignatureError{Signature: "invalid"}, ErrorTypeClient, http.StatusUnauthorized},
+		{"schema error", &hook.SchemaError{Violations: []hook.SchemaViolation{{Path: "/ref", Message: "expected string, got number"}}}, ErrorTypeClient, http.StatusUnprocessableEntity},
+		{"argument error", &hook.ArgumentError{Argument: hook.Argument{Source: "payload", Name: "ref"}}, ErrorTypeClient, http.StatusBadRequest},
+		{"hook concurrency limit", ErrHookConcurrencyLimit, ErrorTypeClient, http.StatusTooManyRequests},
 		{"command validation error", security.NewCommandValidationError("path", "test", "/usr/bin/ls", nil), ErrorTypeServer, http.StatusInternalServerError},
 		{"permission denied", errors.New("permission denied"), ErrorTypeClient, http.StatusBadRequest},
 		{"not found", errors.New("not found"), ErrorTypeClient, http.StatusBadRequest},
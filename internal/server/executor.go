@@ -3,7 +3,10 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/soulteary/webhook/internal/hook"
@@ -18,12 +21,108 @@ const (
 	HookExecutionTimeout = 5 * time.Second
 )
 
+var (
+	// ErrHookConcurrencyLimit 表示 hook 已达到 max-concurrent 上限且策略为 reject
+	ErrHookConcurrencyLimit = errors.New("hook concurrency limit reached")
+	// ErrHookSuperseded 表示执行因 cancel-previous 策略被新的请求取消
+	ErrHookSuperseded = errors.New("hook execution superseded by a newer request")
+	// ErrExecutorShutdown 表示执行因服务关闭被取消
+	ErrExecutorShutdown = errors.New("hook executor is shutting down")
+)
+
+// terminationSlack 为终止命令时在宽限期之外额外等待的时间
+const terminationSlack = time.Second
+
+// ExecutionResult 描述一次 hook 执行的结果
+type ExecutionResult struct {
+	// Output 为合并后的 stdout 和 stderr（流式输出时为空）
+	Output string
+	Stdout string
+	Stderr string
+	// ExitCode 为命令的退出码，命令未能启动或被信号终止时为 -1
+	ExitCode int
+	Duration time.Duration
+	// Response 为命令写入响应文件的内容（command-response 为 file 时）
+	Response []byte
+	// UpstreamStatus 与 UpstreamHeader 为转发 hook 收到的上游响应（执行命令时为空）
+	UpstreamStatus int
+	UpstreamHeader http.Header
+	// ContentType 为 Output 的 Content-Type，为空时按纯文本处理
+	ContentType string
+	// Actions 为多动作 hook 中各个动作的执行结果
+	Actions []ActionResult
+	// Coalesced 表示本次请求被 debounce 合并，结果来自窗口内最后一个请求的执行
+	Coalesced bool
+}
+
 // HookExecutor 管理 hook 执行的并发控制和超时
 type HookExecutor struct {
 	sem            chan struct{}
 	maxConcurrent  int
 	defaultTimeout time.Duration
-	executorFunc   func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error)
+	resultFunc     func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (*ExecutionResult, error)
+	jobs           *jobRunner
+
+	limitersMu sync.Mutex
+	limiters   map[string]*hookLimiter
+
+	keyed     *keyedLocks
+	debounces *debouncer
+
+	// stopCtx 在 Terminate 时取消，用于终止所有正在执行的命令
+	stopCtx  context.Context
+	stop     context.CancelCauseFunc
+	maxGrace atomic.Int64
+}
+
+// hookLimiter 是单个 hook 的并发控制器
+type hookLimiter struct {
+	sem chan struct{}
+
+	mu      sync.Mutex
+	seq     uint64
+	running map[uint64]context.CancelCauseFunc
+}
+
+func newHookLimiter(maxConcurrent int) *hookLimiter {
+	return &hookLimiter{
+		sem:     make(chan struct{}, maxConcurrent),
+		running: make(map[uint64]context.CancelCauseFunc),
+	}
+}
+
+// track 记录一次正在运行的执行，返回的 id 用于 untrack
+func (l *hookLimiter) track(cancel context.CancelCauseFunc) uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seq++
+	l.running[l.seq] = cancel
+	return l.seq
+}
+
+func (l *hookLimiter) untrack(id uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.running, id)
+}
+
+// cancelOldest 取消最早开始的一次执行
+func (l *hookLimiter) cancelOldest() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var oldest uint64
+	for id := range l.running {
+		if oldest == 0 || id < oldest {
+			oldest = id
+		}
+	}
+	if oldest == 0 {
+		return false
+	}
+	l.running[oldest](ErrHookSuperseded)
+	delete(l.running, oldest)
+	return true
 }
 
 // NewHookExecutor 已废弃，请使用 NewHookExecutorWithFunc
@@ -34,38 +133,188 @@ func NewHookExecutor(maxConcurrent int, defaultTimeout time.Duration) *HookExecu
 
 // NewHookExecutorWithFunc 创建新的 HookExecutor 实例，允许自定义执行函数（主要用于测试）
 func NewHookExecutorWithFunc(maxConcurrent int, defaultTimeout time.Duration, executorFunc func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error)) *HookExecutor {
+	return NewHookExecutorWithResultFunc(maxConcurrent, defaultTimeout, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (*ExecutionResult, error) {
+		output, err := executorFunc(ctx, h, r, w)
+		result := &ExecutionResult{Output: output}
+		if err != nil {
+			result.ExitCode = -1
+		}
+		return result, err
+	})
+}
+
+// NewHookExecutorWithResultFunc 创建新的 HookExecutor 实例，执行函数返回结构化的执行结果
+func NewHookExecutorWithResultFunc(maxConcurrent int, defaultTimeout time.Duration, resultFunc func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (*ExecutionResult, error)) *HookExecutor {
 	if maxConcurrent <= 0 {
 		maxConcurrent = DefaultMaxConcurrentHooks
 	}
 	if defaultTimeout <= 0 {
 		defaultTimeout = DefaultHookTimeout
 	}
-	return &HookExecutor{
+	stopCtx, stop := context.WithCancelCause(context.Background())
+	he := &HookExecutor{
 		sem:            make(chan struct{}, maxConcurrent),
 		maxConcurrent:  maxConcurrent,
 		defaultTimeout: defaultTimeout,
-		executorFunc:   executorFunc,
+		resultFunc:     resultFunc,
+		limiters:       make(map[string]*hookLimiter),
+		keyed:          newKeyedLocks(),
+		debounces:      newDebouncer(),
+		stopCtx:        stopCtx,
+		stop:           stop,
 	}
+	he.jobs = newJobRunner(he)
+	return he
 }
 
 // Execute 执行 hook，带并发控制和超时
 func (he *HookExecutor) Execute(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter, executionTimeout time.Duration) (string, error) {
+	result, err := he.ExecuteWithResult(ctx, h, r, w, executionTimeout)
+	return result.Output, err
+}
+
+// ExecuteWithResult 与 Execute 相同，但返回结构化的执行结果（始终非 nil）
+// executionTimeout 为等待执行槽位的超时时间；命令的执行超时优先使用 hook 的 timeout 配置
+func (he *HookExecutor) ExecuteWithResult(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter, executionTimeout time.Duration) (*ExecutionResult, error) {
+	// 流式输出只能写入一个响应，因此不参与合并
+	if h != nil && h.Debounce > 0 && w == nil {
+		return he.debounces.join(ctx, serializationKey(h, r), h.Debounce.Duration(), r, func(ctx context.Context, r *hook.Request) (*ExecutionResult, error) {
+			return he.executeSerialized(ctx, h, r, nil, executionTimeout)
+		})
+	}
+	return he.executeSerialized(ctx, h, r, w, executionTimeout)
+}
+
+// executeSerialized 按 serialize-by 的 key 串行执行；等待前一次执行时不受 executionTimeout 限制
+func (he *HookExecutor) executeSerialized(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter, executionTimeout time.Duration) (*ExecutionResult, error) {
+	if h != nil && h.SerializeBy != nil {
+		unlock, err := he.keyed.lock(ctx, serializationKey(h, r))
+		if err != nil {
+			return &ExecutionResult{ExitCode: -1}, err
+		}
+		defer unlock()
+	}
+	return he.execute(ctx, h, r, w, executionTimeout)
+}
+
+// execute 获取执行槽位并运行 hook
+func (he *HookExecutor) execute(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter, executionTimeout time.Duration) (*ExecutionResult, error) {
+	runCtx, cancelRun := context.WithCancelCause(ctx)
+	defer cancelRun(nil)
+	stopRun := context.AfterFunc(he.stopCtx, func() { cancelRun(ErrExecutorShutdown) })
+	defer stopRun()
+
+	// 先获取 hook 自身的执行槽位，避免被拒绝的请求占用全局槽位
+	release, err := he.acquireHookSlot(runCtx, h, cancelRun, executionTimeout)
+	if err != nil {
+		return &ExecutionResult{ExitCode: -1}, err
+	}
+	defer release()
+
 	// 尝试获取 semaphore，带超时
 	select {
 	case he.sem <- struct{}{}:
 		defer func() { <-he.sem }()
 	case <-time.After(executionTimeout):
-		return "", errors.New("too many concurrent hooks, execution timeout")
-	case <-ctx.Done():
-		return "", ctx.Err()
+		return &ExecutionResult{ExitCode: -1}, errors.New("too many concurrent hooks, execution timeout")
+	case <-runCtx.Done():
+		return &ExecutionResult{ExitCode: -1}, executionCancelError(runCtx)
+	}
+
+	// 记录最长的终止宽限期，服务关闭时据此等待命令退出
+	if grace := int64(h.TerminationGracePeriod()); grace > he.maxGrace.Load() {
+		he.maxGrace.Store(grace)
 	}
 
 	// 创建带超时的 context
-	timeout := he.defaultTimeout
-	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	timeout := h.ExecutionTimeout(he.defaultTimeout)
+	execCtx, cancel := context.WithTimeout(runCtx, timeout)
 	defer cancel()
 
-	return he.executorFunc(execCtx, h, r, w)
+	startTime := time.Now()
+	result, err := he.resultFunc(execCtx, h, r, w)
+	if result == nil {
+		result = &ExecutionResult{ExitCode: -1}
+	}
+	result.Duration = time.Since(startTime)
+	if errors.Is(err, context.Canceled) && errors.Is(context.Cause(runCtx), ErrHookSuperseded) {
+		err = executionCancelError(runCtx)
+	}
+	return result, err
+}
+
+// acquireHookSlot 按 hook 的 max-concurrent 与 concurrency-policy 获取执行槽位；
+// 等待槽位时随 runCtx 一起在请求结束或执行器关闭时返回
+func (he *HookExecutor) acquireHookSlot(ctx context.Context, h *hook.Hook, cancel context.CancelCauseFunc, executionTimeout time.Duration) (func(), error) {
+	if h == nil || h.MaxConcurrent <= 0 {
+		return func() {}, nil
+	}
+
+	l := he.limiter(h.ID, h.MaxConcurrent)
+
+	select {
+	case l.sem <- struct{}{}:
+	default:
+		switch h.Policy() {
+		case hook.ConcurrencyPolicyReject:
+			return nil, ErrHookConcurrencyLimit
+		case hook.ConcurrencyPolicyCancelPrevious:
+			l.cancelOldest()
+		}
+
+		timer := time.NewTimer(executionTimeout)
+		defer timer.Stop()
+		select {
+		case l.sem <- struct{}{}:
+		case <-timer.C:
+			return nil, fmt.Errorf("too many concurrent executions of hook %s, execution timeout", h.ID)
+		case <-ctx.Done():
+			return nil, executionCancelError(ctx)
+		}
+	}
+
+	id := l.track(cancel)
+	return func() {
+		l.untrack(id)
+		<-l.sem
+	}, nil
+}
+
+// limiter 返回 hook 对应的并发控制器；max-concurrent 变化（如配置热更新）时重新创建
+func (he *HookExecutor) limiter(hookID string, maxConcurrent int) *hookLimiter {
+	he.limitersMu.Lock()
+	defer he.limitersMu.Unlock()
+
+	l, ok := he.limiters[hookID]
+	if !ok || cap(l.sem) != maxConcurrent {
+		l = newHookLimiter(maxConcurrent)
+		he.limiters[hookID] = l
+	}
+	return l
+}
+
+// executionCancelError 在执行被新的请求取代时保留 ErrHookSuperseded 原因
+func executionCancelError(ctx context.Context) error {
+	if cause := context.Cause(ctx); errors.Is(cause, ErrHookSuperseded) {
+		return fmt.Errorf("%w: %w", context.Canceled, ErrHookSuperseded)
+	}
+	return ctx.Err()
+}
+
+// Terminate 取消所有正在执行的命令（包括同步请求与异步任务），
+// 命令会按 hook 的 kill-signal 与 kill-grace-period 终止
+func (he *HookExecutor) Terminate() {
+	he.stop(ErrExecutorShutdown)
+	he.CancelJobs()
+}
+
+// TerminationTimeout 返回 Terminate 之后等待命令退出的最长时间；尚未执行过命令时为 0
+func (he *HookExecutor) TerminationTimeout() time.Duration {
+	grace := time.Duration(he.maxGrace.Load())
+	if grace == 0 {
+		return 0
+	}
+	return grace + terminationSlack
 }
 
 // GetMaxConcurrent 获取最大并发数（用于测试）
@@ -357,3 +357,148 @@ func TestNewHookExecutor_Panic(t *testing.T) {
 		NewHookExecutor(5, 10*time.Second)
 	}, "NewHookExecutor should panic")
 }
+
+func TestHookExecutor_Execute_HookTimeout(t *testing.T) {
+	mockExecutorFunc := func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+
+	// 全局超时较长，hook 自身的 timeout 应优先生效
+	executor := NewHookExecutorWithFunc(1, 10*time.Second, mockExecutorFunc)
+	h := &hook.Hook{ID: "test", Timeout: hook.Duration(50 * time.Millisecond)}
+
+	start := time.Now()
+	_, err := executor.Execute(context.Background(), h, &hook.Request{ID: "test-request"}, nil, time.Second)
+
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestHookExecutor_Execute_ConcurrencyPolicies(t *testing.T) {
+	started := make(chan string, 4)
+	release := make(chan struct{})
+	mockExecutorFunc := func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error) {
+		started <- r.ID
+		select {
+		case <-release:
+			return r.ID, nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		executor := NewHookExecutorWithFunc(5, 5*time.Second, mockExecutorFunc)
+		h := &hook.Hook{ID: "reject", MaxConcurrent: 1, ConcurrencyPolicy: hook.ConcurrencyPolicyReject}
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := executor.Execute(context.Background(), h, &hook.Request{ID: "first"}, nil, time.Second)
+			done <- err
+		}()
+		assert.Equal(t, "first", <-started)
+
+		_, err := executor.Execute(context.Background(), h, &hook.Request{ID: "second"}, nil, time.Second)
+		assert.ErrorIs(t, err, ErrHookConcurrencyLimit)
+
+		// 其他 hook 不受影响
+		other := &hook.Hook{ID: "other", MaxConcurrent: 1, ConcurrencyPolicy: hook.ConcurrencyPolicyReject}
+		otherDone := make(chan error, 1)
+		go func() {
+			_, err := executor.Execute(context.Background(), other, &hook.Request{ID: "other"}, nil, time.Second)
+			otherDone <- err
+		}()
+		assert.Equal(t, "other", <-started)
+
+		release <- struct{}{}
+		release <- struct{}{}
+		assert.NoError(t, <-done)
+		assert.NoError(t, <-otherDone)
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		executor := NewHookExecutorWithFunc(5, 5*time.Second, mockExecutorFunc)
+		h := &hook.Hook{ID: "queue", MaxConcurrent: 1}
+
+		done := make(chan error, 2)
+		go func() {
+			_, err := executor.Execute(context.Background(), h, &hook.Request{ID: "first"}, nil, time.Second)
+			done <- err
+		}()
+		assert.Equal(t, "first", <-started)
+
+		go func() {
+			_, err := executor.Execute(context.Background(), h, &hook.Request{ID: "second"}, nil, time.Second)
+			done <- err
+		}()
+
+		// 第二次执行需要等待第一次结束
+		select {
+		case id := <-started:
+			t.Fatalf("execution %s started while the hook was busy", id)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		release <- struct{}{}
+		assert.Equal(t, "second", <-started)
+		release <- struct{}{}
+		assert.NoError(t, <-done)
+		assert.NoError(t, <-done)
+	})
+
+	t.Run("cancel-previous", func(t *testing.T) {
+		executor := NewHookExecutorWithFunc(5, 5*time.Second, mockExecutorFunc)
+		h := &hook.Hook{ID: "cancel", MaxConcurrent: 1, ConcurrencyPolicy: hook.ConcurrencyPolicyCancelPrevious}
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := executor.Execute(context.Background(), h, &hook.Request{ID: "first"}, nil, time.Second)
+			done <- err
+		}()
+		assert.Equal(t, "first", <-started)
+
+		secondDone := make(chan error, 1)
+		go func() {
+			_, err := executor.Execute(context.Background(), h, &hook.Request{ID: "second"}, nil, time.Second)
+			secondDone <- err
+		}()
+
+		err := <-done
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorIs(t, err, ErrHookSuperseded)
+
+		assert.Equal(t, "second", <-started)
+		release <- struct{}{}
+		assert.NoError(t, <-secondDone)
+	})
+
+	t.Run("queue stops waiting on terminate", func(t *testing.T) {
+		executor := NewHookExecutorWithFunc(5, 5*time.Second, mockExecutorFunc)
+		h := &hook.Hook{ID: "queue-terminate", MaxConcurrent: 1}
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := executor.Execute(context.Background(), h, &hook.Request{ID: "first"}, nil, time.Second)
+			done <- err
+		}()
+		assert.Equal(t, "first", <-started)
+
+		waiting := make(chan error, 1)
+		go func() {
+			_, err := executor.Execute(context.Background(), h, &hook.Request{ID: "second"}, nil, 5*time.Second)
+			waiting <- err
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		// 等待槽位的执行随执行器关闭返回，而不是等到 executionTimeout
+		executor.Terminate()
+		select {
+		case err := <-waiting:
+			assert.ErrorIs(t, err, context.Canceled)
+		case <-time.After(time.Second):
+			t.Fatal("waiting execution did not return after Terminate")
+		}
+		assert.ErrorIs(t, <-done, context.Canceled)
+	})
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	loggerkit "github.com/soulteary/logger-kit"
+	"github.com/soulteary/webhook/internal/flags"
+	"github.com/soulteary/webhook/internal/hook"
+	"github.com/soulteary/webhook/internal/logger"
+	"github.com/soulteary/webhook/internal/metrics"
+	"github.com/soulteary/webhook/internal/rules"
+)
+
+// ExplainPath 是触发规则试运行（dry-run）接口的路径前缀
+const ExplainPath = "/explain"
+
+const (
+	// ExplainHeader 携带 explain-token 的请求头，触发规则不满足时返回评估轨迹
+	ExplainHeader = "X-Webhook-Explain"
+	// RuleTraceHeader 响应头，列出导致触发规则不满足的叶子规则
+	RuleTraceHeader = "X-Webhook-Rule-Trace"
+)
+
+// ruleTraceResponse 是评估轨迹的响应内容
+type ruleTraceResponse struct {
+	Hook          string          `json:"hook,omitempty"`
+	Matched       bool            `json:"matched"`
+	Message       string          `json:"message,omitempty"`
+	Reason        string          `json:"reason,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	MethodAllowed *bool           `json:"method-allowed,omitempty"`
+	FailedRules   []string        `json:"failed-rules,omitempty"`
+	Trace         *hook.RuleTrace `json:"trace,omitempty"`
+}
+
+// explainSample 是试运行接口接收的示例请求。body 为 JSON 字符串时作为原始请求体，
+// 为其它 JSON 值时作为 JSON 请求体。
+type explainSample struct {
+	Method     string            `json:"method,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Query      map[string]string `json:"query,omitempty"`
+	Body       json.RawMessage   `json:"body,omitempty"`
+	RemoteAddr string            `json:"remote-addr,omitempty"`
+}
+
+// explainAuthorized 判断请求是否携带了正确的 explain-token，未配置令牌时始终返回 false
+func explainAuthorized(r *http.Request, appFlags flags.AppFlags) bool {
+	if appFlags.ExplainToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get(ExplainHeader)), []byte(appFlags.ExplainToken)) == 1
+}
+
+// explainBearerAuthorized 判断试运行接口的 Authorization: Bearer 令牌是否正确
+func explainBearerAuthorized(r *http.Request, appFlags flags.AppFlags) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if appFlags.ExplainToken == "" || !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(appFlags.ExplainToken)) == 1
+}
+
+// writeRuleTrace 以 JSON 返回触发规则不满足的原因与评估轨迹，状态码需由调用方预先写入
+func writeRuleTrace(w http.ResponseWriter, message string, req *hook.Request) {
+	resp := ruleTraceResponse{
+		Message:     message,
+		Reason:      req.MismatchReason,
+		FailedRules: req.Trace.FailedRules(),
+		Trace:       req.Trace,
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Errorf("[%s] error encoding rule trace: %v", req.ID, err)
+	}
+}
+
+// request 根据示例构造用于评估触发规则的 HTTP 请求
+func (s explainSample) request(ctx context.Context, target, remoteAddr string) (*http.Request, error) {
+	method := strings.ToUpper(strings.TrimSpace(s.Method))
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	var body []byte
+	isJSON := false
+	if trimmed := bytes.TrimSpace(s.Body); len(trimmed) > 0 && !bytes.Equal(trimmed, []byte("null")) {
+		if trimmed[0] == '"' {
+			var raw string
+			if err := json.Unmarshal(trimmed, &raw); err != nil {
+				return nil, err
+			}
+			body = []byte(raw)
+		} else {
+			body, isJSON = trimmed, true
+		}
+	}
+
+	if len(s.Query) > 0 {
+		query := url.Values{}
+		for k, v := range s.Query {
+			query.Set(k, v)
+		}
+		target += "?" + query.Encode()
+	}
+
+	r, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range s.Headers {
+		r.Header.Set(k, v)
+	}
+	if isJSON && r.Header.Get("Content-Type") == "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	r.RemoteAddr = remoteAddr
+	if s.RemoteAddr != "" {
+		r.RemoteAddr = s.RemoteAddr
+	}
+	return r, nil
+}
+
+// createExplainHandler 创建触发规则试运行接口：根据示例请求评估 hook 的触发规则并返回评估轨迹，
+// 不会执行 hook，也不会记录 replay-guard 的 nonce
+func createExplainHandler(appFlags flags.AppFlags, basePath, hookBase string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		startTime := time.Now()
+		statusCode := http.StatusOK
+		wrappedWriter := &statusCodeResponseWriter{
+			ResponseWriter: w,
+			statusCode:     &statusCode,
+		}
+		defer func() {
+			metrics.RecordHTTPRequest(r.Method, fmt.Sprintf("%d", statusCode), basePath+"/{id}", time.Since(startTime))
+		}()
+
+		requestID := loggerkit.RequestIDFromRequest(r)
+
+		if r.Method != http.MethodPost {
+			wrappedWriter.Header().Set("Allow", "POST")
+			HandleError(wrappedWriter, r, NewHTTPError(ErrorTypeClient, http.StatusMethodNotAllowed,
+				fmt.Sprintf("HTTP %s method not allowed for explain", r.Method), nil), requestID, "")
+			return
+		}
+
+		if !explainBearerAuthorized(r, appFlags) {
+			wrappedWriter.Header().Set("WWW-Authenticate", "Bearer")
+			HandleError(wrappedWriter, r, NewHTTPError(ErrorTypeClient, http.StatusUnauthorized, "Unauthorized.", nil), requestID, "")
+			return
+		}
+
+		hookID := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, basePath+"/"))
+		matchedHook := rules.MatchLoadedHook(hookID)
+		if matchedHook == nil {
+			HandleError(wrappedWriter, r, NewHTTPError(ErrorTypeClient, http.StatusNotFound, "Hook not found.", nil), requestID, hookID)
+			return
+		}
+
+		maxBodySize := appFlags.MaxRequestBodySize
+		if maxBodySize <= 0 {
+			maxBodySize = flags.DEFAULT_MAX_REQUEST_BODY_SIZE
+		}
+		var sample explainSample
+		decoder := json.NewDecoder(http.MaxBytesReader(wrappedWriter, r.Body, maxBodySize))
+		if err := decoder.Decode(&sample); err != nil && !errors.Is(err, io.EOF) {
+			HandleError(wrappedWriter, r, NewHTTPError(ErrorTypeClient, http.StatusBadRequest, "Invalid sample request.", err), requestID, hookID)
+			return
+		}
+		sampleRequest, err := sample.request(r.Context(), hookBase+"/"+hookID, r.RemoteAddr)
+		if err != nil {
+			HandleError(wrappedWriter, r, NewHTTPError(ErrorTypeClient, http.StatusBadRequest, "Invalid sample request.", err), requestID, hookID)
+			return
+		}
+
+		req := &hook.Request{
+			ID:         requestID,
+			RawRequest: sampleRequest,
+			DryRun:     true,
+		}
+		if err := parseRequestBody(wrappedWriter, sampleRequest, req, matchedHook, appFlags, requestID, hookID); err != nil {
+			return
+		}
+
+		methodAllowed := isMethodAllowed(sampleRequest.Method, matchedHook, appFlags)
+		resp := ruleTraceResponse{
+			Hook:          matchedHook.ID,
+			Matched:       true,
+			MethodAllowed: &methodAllowed,
+		}
+		if matchedHook.TriggerRule != nil {
+			for _, err := range matchedHook.ParseJSONParameters(req) {
+				logger.Warnf("[%s] error parsing JSON parameters for hook %s: %v", requestID, hookID, err)
+			}
+			req.AllowSignatureErrors = matchedHook.TriggerSignatureSoftFailures
+			req.HookID = matchedHook.ID
+			req.EnableTrace()
+
+			ok, err := matchedHook.TriggerRule.Evaluate(req)
+			resp.Matched = ok
+			if err != nil {
+				resp.Error = err.Error()
+				if !hook.IsParameterNodeError(err) {
+					resp.Matched = false
+				}
+			}
+			if !resp.Matched {
+				resp.Message = mismatchMessage(req.MismatchReason)
+				resp.Reason = req.MismatchReason
+				resp.FailedRules = req.Trace.FailedRules()
+			}
+			resp.Trace = req.Trace
+		}
+
+		wrappedWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := json.NewEncoder(wrappedWriter).Encode(resp); err != nil {
+			logger.Errorf("[%s] error encoding rule trace for hook %s: %v", requestID, hookID, err)
+		}
+	}
+}
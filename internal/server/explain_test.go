@@ -0,0 +1,162 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/soulteary/webhook/internal/flags"
+	"github.com/soulteary/webhook/internal/hook"
+	"github.com/soulteary/webhook/internal/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadExplainHook 加载一个要求 push 事件且 ref 为 main 分支的 hook
+func loadExplainHook(t *testing.T) {
+	t.Helper()
+	testHook := hook.Hook{
+		ID:                                  "deploy",
+		ExecuteCommand:                      "true",
+		HTTPMethods:                         []string{"POST"},
+		TriggerRuleMismatchHttpResponseCode: 400,
+		TriggerRule: &hook.Rules{
+			And: &hook.AndRule{
+				{Match: &hook.MatchRule{Type: "value", Value: "push", Parameter: hook.Argument{Source: "header", Name: "X-Event"}}},
+				{Match: &hook.MatchRule{Type: "value", Value: "refs/heads/main", Parameter: hook.Argument{Source: "payload", Name: "ref"}}},
+				{ReplayGuard: &hook.ReplayGuardRule{Nonce: &hook.Argument{Source: "header", Name: "X-Delivery"}}},
+			},
+		},
+	}
+	rules.LoadedHooksFromFiles = map[string]hook.Hooks{
+		"test.json": {testHook},
+	}
+	rules.BuildIndex()
+}
+
+func TestCreateHookHandler_ExplainHeader(t *testing.T) {
+	loadExplainHook(t)
+	appFlags := flags.AppFlags{ExplainToken: "explain-secret"}
+	handler := createHookHandler(appFlags, nil)
+
+	newRequest := func(token string) *http.Request {
+		req := httptest.NewRequest("POST", "/hooks/deploy", bytes.NewBufferString(`{"ref": "refs/heads/dev"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Event", "push")
+		if token != "" {
+			req.Header.Set(ExplainHeader, token)
+		}
+		return req
+	}
+
+	t.Run("authorized", func(t *testing.T) {
+		resp, err := testHookApp(handler).Test(newRequest("explain-secret"), 5000)
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "and[1].match(value)", resp.Header.Get(RuleTraceHeader))
+
+		var body ruleTraceResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.False(t, body.Matched)
+		assert.Equal(t, "Hook rules were not satisfied.", body.Message)
+		assert.Equal(t, []string{"and[1].match(value)"}, body.FailedRules)
+		require.NotNil(t, body.Trace)
+		require.Len(t, body.Trace.Children, 2)
+		assert.Equal(t, "payload:ref", body.Trace.Children[1].Parameter)
+		assert.Equal(t, "refs/heads/dev", *body.Trace.Children[1].Value)
+	})
+
+	t.Run("wrong token", func(t *testing.T) {
+		resp, err := testHookApp(handler).Test(newRequest("guess"), 5000)
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		body, _ := io.ReadAll(resp.Body)
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get(RuleTraceHeader))
+		assert.Equal(t, "Hook rules were not satisfied.", string(body))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		resp, err := testHookApp(createHookHandler(flags.AppFlags{}, nil)).Test(newRequest("explain-secret"), 5000)
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+
+		assert.Empty(t, resp.Header.Get(RuleTraceHeader))
+	})
+}
+
+func TestCreateExplainHandler(t *testing.T) {
+	loadExplainHook(t)
+	appFlags := flags.AppFlags{ExplainToken: "explain-secret"}
+	handler := createExplainHandler(appFlags, ExplainPath, "/hooks")
+
+	explain := func(t *testing.T, path, token, sample string) (*httptest.ResponseRecorder, ruleTraceResponse) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(sample))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		var resp ruleTraceResponse
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		}
+		return rec, resp
+	}
+
+	sample := `{"headers": {"X-Event": "push", "X-Delivery": "delivery-1"}, "body": {"ref": "refs/heads/main"}}`
+
+	t.Run("matched", func(t *testing.T) {
+		// 试运行不会记录 nonce，重复请求同样匹配
+		for i := 0; i < 2; i++ {
+			rec, resp := explain(t, ExplainPath+"/deploy", "explain-secret", sample)
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "deploy", resp.Hook)
+			assert.True(t, resp.Matched)
+			assert.True(t, *resp.MethodAllowed)
+			assert.Empty(t, resp.FailedRules)
+			require.NotNil(t, resp.Trace)
+			assert.Len(t, resp.Trace.Children, 3)
+		}
+	})
+
+	t.Run("not matched", func(t *testing.T) {
+		rec, resp := explain(t, ExplainPath+"/deploy", "explain-secret",
+			`{"method": "get", "headers": {"X-Event": "push", "Content-Type": "application/json"}, "body": "{\"ref\": \"refs/heads/main\"}"}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.False(t, resp.Matched)
+		assert.False(t, *resp.MethodAllowed)
+		assert.Equal(t, []string{"and[2].replay-guard"}, resp.FailedRules)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		rec, _ := explain(t, ExplainPath+"/deploy", "guess", sample)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("hook not found", func(t *testing.T) {
+		rec, _ := explain(t, ExplainPath+"/missing", "explain-secret", sample)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("invalid sample", func(t *testing.T) {
+		rec, _ := explain(t, ExplainPath+"/deploy", "explain-secret", `{"headers": []}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, ExplainPath+"/deploy", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, "POST", rec.Header().Get("Allow"))
+	})
+}
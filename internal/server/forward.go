@@ -0,0 +1,237 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/soulteary/webhook/internal/hook"
+	"github.com/soulteary/webhook/internal/logger"
+	"github.com/soulteary/webhook/internal/metrics"
+)
+
+// MaxForwardResponseSize 上游响应体的最大读取字节数
+const MaxForwardResponseSize = 1 << 20
+
+// ForwardStatusError 表示上游返回了非 2xx 状态码
+type ForwardStatusError struct {
+	Status int
+}
+
+func (e *ForwardStatusError) Error() string {
+	return fmt.Sprintf("upstream responded with status %d", e.Status)
+}
+
+// forwardClientKey 是决定 HTTP 客户端配置的转发配置内容，
+// 内容相同的转发配置（包括重新加载后的配置）共享同一个客户端
+type forwardClientKey struct {
+	tls    bool
+	config hook.ForwardTLSConfig
+}
+
+// forwardClientEntry 是缓存的 HTTP 客户端，以及创建时 CA、客户端证书与私钥文件的修改时间
+type forwardClientEntry struct {
+	modTimes [3]int64
+	client   *http.Client
+}
+
+// forwardClients 按转发配置内容缓存 HTTP 客户端，以复用连接与 TLS 配置
+var forwardClients sync.Map
+
+// forwardClient 返回转发配置对应的 HTTP 客户端。
+// 证书文件被替换（例如证书轮换）后会重新加载并创建新的客户端
+func forwardClient(f *hook.ForwardConfig) (*http.Client, error) {
+	var key forwardClientKey
+	var modTimes [3]int64
+	if f.TLS != nil {
+		key = forwardClientKey{tls: true, config: *f.TLS}
+		modTimes = forwardTLSModTimes(f.TLS)
+	}
+	if e, ok := forwardClients.Load(key); ok && e.(*forwardClientEntry).modTimes == modTimes {
+		return e.(*forwardClientEntry).client, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if f.TLS != nil {
+		cfg, err := forwardTLSConfig(f.TLS)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = cfg
+	}
+
+	entry := &forwardClientEntry{modTimes: modTimes, client: &http.Client{Transport: transport}}
+	if old, loaded := forwardClients.Swap(key, entry); loaded {
+		old.(*forwardClientEntry).client.CloseIdleConnections()
+	}
+	return entry.client, nil
+}
+
+// forwardTLSModTimes 返回 CA、客户端证书与私钥文件的修改时间，未配置或无法读取的文件为 0
+func forwardTLSModTimes(c *hook.ForwardTLSConfig) [3]int64 {
+	var modTimes [3]int64
+	for i, path := range []string{c.CACert, c.ClientCert, c.ClientKey} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			modTimes[i] = info.ModTime().UnixNano()
+		}
+	}
+	return modTimes
+}
+
+// forwardTLSConfig 根据转发的 tls 配置加载 CA 与客户端证书
+func forwardTLSConfig(c *hook.ForwardTLSConfig) (*tls.Config, error) {
+	// #nosec G402 -- insecure-skip-verify 需要在 hook 配置中显式开启
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if c.CACert != "" {
+		pem, err := os.ReadFile(c.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("error reading forward ca-cert: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in forward ca-cert %s", c.CACert)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if c.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCert, c.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("error loading forward client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// runHookForward 将请求转发到上游并返回上游的状态码与响应体，返回值始终非 nil。
+// 网络错误、429 与 5xx 响应按 forward.retry 配置重试
+func runHookForward(ctx context.Context, h *hook.Hook, r *hook.Request) (*ExecutionResult, error) {
+	result := &ExecutionResult{ExitCode: -1}
+	f := h.Forward
+
+	fr, err := f.Render(r)
+	if err != nil {
+		logger.Errorf("[%s] error rendering forward request for hook %s: %v", r.ID, h.ID, err)
+		return result, err
+	}
+	client, err := forwardClient(f)
+	if err != nil {
+		logger.Errorf("[%s] error configuring forward client for hook %s: %v", r.ID, h.ID, err)
+		return result, err
+	}
+
+	logger.Infof("[%s] forwarding hook %s to %s %s", r.ID, h.ID, fr.Method, redactForwardURL(fr.URL))
+
+	attempts := f.Retry.Attempts()
+	for attempt := 1; ; attempt++ {
+		resp, body, err := sendForward(ctx, client, f.RequestTimeout(), fr)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			metrics.RecordHookForward(h.ID, "error")
+			return result, ctxErr
+		}
+
+		retryable := err != nil
+		if err != nil {
+			metrics.RecordHookForward(h.ID, "error")
+			logger.Warnf("[%s] forward request for hook %s failed (attempt %d/%d): %v", r.ID, h.ID, attempt, attempts, err)
+		} else {
+			metrics.RecordHookForward(h.ID, strconv.Itoa(resp.StatusCode))
+			retryable = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+			result.UpstreamStatus = resp.StatusCode
+			result.UpstreamHeader = resp.Header
+			result.Output = string(body)
+			result.Stdout = result.Output
+		}
+
+		if !retryable || attempt >= attempts {
+			if err != nil {
+				return result, err
+			}
+			break
+		}
+
+		delay := f.Retry.Delay(attempt, rand.Float64) // #nosec G404 -- jitter does not need a cryptographic source
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	logger.Infof("[%s] forward for hook %s finished with status %d", r.ID, h.ID, result.UpstreamStatus)
+	if result.UpstreamStatus < 200 || result.UpstreamStatus > 299 {
+		return result, &ForwardStatusError{Status: result.UpstreamStatus}
+	}
+	result.ExitCode = 0
+	return result, nil
+}
+
+// redactForwardURL 返回用于日志的上游 URL：名称敏感的查询参数（例如 token）
+// 替换为 hook.RedactedValue，并隐藏 URL 中的密码
+func redactForwardURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return hook.RedactedValue
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for name := range query {
+			if hook.IsSensitiveName(name) {
+				query[name] = []string{hook.RedactedValue}
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.Redacted()
+}
+
+// sendForward 发送一次上游请求并读取响应体
+func sendForward(ctx context.Context, client *http.Client, timeout time.Duration, fr *hook.ForwardRequest) (*http.Response, []byte, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, fr.Method, fr.URL, bytes.NewReader(fr.Body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header = fr.Header.Clone()
+
+	// #nosec G107 G704 -- URL 来自 hook 配置中的 forward.url 模板，且仅允许 http/https
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxForwardResponseSize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(body) > MaxForwardResponseSize {
+		return nil, nil, errors.New("upstream response exceeds size limit")
+	}
+	return resp, body, nil
+}
@@ -0,0 +1,434 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	loggerkit "github.com/soulteary/logger-kit"
+	"github.com/soulteary/webhook/internal/audit"
+	"github.com/soulteary/webhook/internal/hook"
+	"github.com/soulteary/webhook/internal/logger"
+	"github.com/soulteary/webhook/internal/metrics"
+	"github.com/soulteary/webhook/internal/queue"
+)
+
+// JobsPath 是异步任务状态接口的路径前缀
+const JobsPath = "/jobs"
+
+// jobRunner 负责异步 hook 任务的排队、执行与重试。
+// 每次尝试都在 asyncHookWaitGroup 中登记；等待中的重试定时器同样计入，
+// 以便优雅关闭时要么等待其完成，要么通过 stop 将其留在队列中供下次启动重放。
+type jobRunner struct {
+	executor *HookExecutor
+	queue    *queue.Queue
+	lookup   func(id string) *hook.Hook
+
+	// ctx 独立于请求的 context，请求返回后任务仍可继续执行
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	mu      sync.Mutex
+	timers  map[string]*time.Timer
+	stopped bool
+
+	rnd func() float64
+}
+
+// maxJobOutputBytes 任务中保存的 stdout/stderr 的最大长度，超出时保留末尾部分
+const maxJobOutputBytes = 64 * 1024
+
+// truncateOutput 截断过长的输出，保留最后 maxJobOutputBytes 字节
+func truncateOutput(s string) string {
+	if len(s) <= maxJobOutputBytes {
+		return s
+	}
+	return s[len(s)-maxJobOutputBytes:]
+}
+
+func newJobRunner(executor *HookExecutor) *jobRunner {
+	// 内存队列不会返回错误
+	q, _ := queue.Open("", queue.DefaultRetention)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	return &jobRunner{
+		executor: executor,
+		queue:    q,
+		ctx:      ctx,
+		cancel:   cancel,
+		timers:   make(map[string]*time.Timer),
+		rnd:      rand.Float64, // #nosec G404 -- jitter does not need a cryptographic source
+	}
+}
+
+// UseJobQueue 替换任务队列（例如持久化队列），并设置回放任务时查找 hook 的函数。
+// 需要在处理任何请求之前调用。
+func (he *HookExecutor) UseJobQueue(q *queue.Queue, lookup func(id string) *hook.Hook) {
+	he.jobs.mu.Lock()
+	defer he.jobs.mu.Unlock()
+	if q != nil {
+		he.jobs.queue = q
+	}
+	he.jobs.lookup = lookup
+}
+
+// JobQueue 返回当前使用的任务队列
+func (he *HookExecutor) JobQueue() *queue.Queue {
+	he.jobs.mu.Lock()
+	defer he.jobs.mu.Unlock()
+	return he.jobs.queue
+}
+
+// Enqueue 将一次异步执行写入队列并立即开始第一次尝试
+func (he *HookExecutor) Enqueue(h *hook.Hook, r *hook.Request, executionTimeout time.Duration) (*queue.Job, error) {
+	jr := he.jobs
+
+	job := &queue.Job{
+		ID:          queue.NewJobID(),
+		HookID:      h.ID,
+		RequestID:   r.ID,
+		State:       queue.StateQueued,
+		MaxAttempts: h.Retry.Attempts(),
+		CreatedAt:   time.Now(),
+		Request:     queue.SnapshotRequest(r),
+	}
+	if err := jr.queue.Put(job); err != nil {
+		return nil, err
+	}
+	queued := job.Clone()
+
+	asyncHookWaitGroup.Add(1)
+	go func() {
+		defer asyncHookWaitGroup.Done()
+		jr.attempt(job, h, r, executionTimeout)
+	}()
+
+	return queued, nil
+}
+
+// ResumeJobs 重放队列中尚未完成的任务，通常在启动时调用
+func (he *HookExecutor) ResumeJobs(executionTimeout time.Duration) int {
+	jr := he.jobs
+	jobs := jr.queue.Unfinished()
+
+	resumed := 0
+	for _, job := range jobs {
+		var h *hook.Hook
+		if jr.lookup != nil {
+			h = jr.lookup(job.HookID)
+		}
+		if h == nil {
+			logger.Warnf("[%s] dropping queued job %s: hook %s is no longer defined", job.RequestID, job.ID, job.HookID)
+			job.State = queue.StateFailed
+			job.LastError = "hook not found"
+			if err := jr.queue.Put(job); err != nil {
+				logger.Errorf("[%s] error updating job %s: %v", job.RequestID, job.ID, err)
+			}
+			var ip, userAgent string
+			if job.Request != nil {
+				ip, userAgent = job.Request.RemoteAddr, job.Request.UserAgent
+			}
+			audit.LogHookFailed(job.RequestID, job.HookID, ip, userAgent, "hook_not_found", 0)
+			continue
+		}
+
+		// 进程崩溃时正在执行的尝试会以 running 状态留在日志中：重新排队，并至少再给一次机会
+		job.State = queue.StateQueued
+		if job.MaxAttempts < job.Attempt+1 {
+			job.MaxAttempts = job.Attempt + 1
+		}
+
+		delay := time.Until(job.NextRunAt)
+		if delay < 0 {
+			delay = 0
+		}
+		logger.Infof("[%s] resuming job %s for hook %s (attempt %d/%d)", job.RequestID, job.ID, job.HookID, job.Attempt+1, job.MaxAttempts)
+		jr.schedule(job, h, job.Request.Request(), executionTimeout, delay)
+		resumed++
+	}
+
+	return resumed
+}
+
+// StopJobs 停止所有等待中的重试定时器，已停止的任务保留在队列中，下次启动时重放
+func (he *HookExecutor) StopJobs() {
+	jr := he.jobs
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+
+	jr.stopped = true
+	for id, t := range jr.timers {
+		if t.Stop() {
+			asyncHookWaitGroup.Done()
+		}
+		delete(jr.timers, id)
+	}
+}
+
+// CancelJobs 取消所有正在执行的尝试
+func (he *HookExecutor) CancelJobs() {
+	he.jobs.cancel(ErrExecutorShutdown)
+}
+
+// schedule 在 delay 之后执行任务的下一次尝试
+func (jr *jobRunner) schedule(job *queue.Job, h *hook.Hook, r *hook.Request, executionTimeout, delay time.Duration) {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+
+	if jr.stopped {
+		return
+	}
+
+	asyncHookWaitGroup.Add(1)
+	jr.timers[job.ID] = time.AfterFunc(delay, func() {
+		defer asyncHookWaitGroup.Done()
+
+		jr.mu.Lock()
+		delete(jr.timers, job.ID)
+		stopped := jr.stopped
+		jr.mu.Unlock()
+		if stopped {
+			return
+		}
+
+		jr.attempt(job, h, r, executionTimeout)
+	})
+}
+
+// lookupHook 按 ID 查找 hook，未设置查找函数时返回 nil
+func (jr *jobRunner) lookupHook(id string) *hook.Hook {
+	jr.mu.Lock()
+	lookup := jr.lookup
+	jr.mu.Unlock()
+	if lookup == nil {
+		return nil
+	}
+	return lookup(id)
+}
+
+// attempt 执行一次尝试，并根据结果完成任务或安排重试
+func (jr *jobRunner) attempt(job *queue.Job, h *hook.Hook, r *hook.Request, executionTimeout time.Duration) {
+	var ip, userAgent string
+	if job.Request != nil {
+		ip, userAgent = job.Request.RemoteAddr, job.Request.UserAgent
+	}
+
+	// 从日志回放的请求中敏感的请求头与查询参数已被替换为 ***，依赖这些值的 hook 不能执行
+	if v, ok := h.UsesRedactedValue(r, jr.lookupHook); ok {
+		job.State = queue.StateFailed
+		job.LastError = fmt.Sprintf("%s was not persisted in the job queue log and is required by hook %s", v, job.HookID)
+		logger.Errorf("[%s] async hook %s job %s failed: %s", job.RequestID, job.HookID, job.ID, job.LastError)
+		if err := jr.queue.Put(job); err != nil {
+			logger.Errorf("[%s] error updating job %s: %v", job.RequestID, job.ID, err)
+		}
+		metrics.RecordHookExecution(job.HookID, "error", 0)
+		audit.LogHookFailed(job.RequestID, job.HookID, ip, userAgent, "redacted_value", 0)
+		return
+	}
+
+	job.Attempt++
+	job.State = queue.StateRunning
+	job.NextRunAt = time.Time{}
+	if err := jr.queue.Put(job); err != nil {
+		logger.Errorf("[%s] error updating job %s: %v", job.RequestID, job.ID, err)
+	}
+
+	startTime := time.Now()
+	result, err := jr.executor.ExecuteWithResult(jr.ctx, h, r, nil, executionTimeout)
+	duration := time.Since(startTime)
+	durationMS := duration.Milliseconds()
+
+	exitCode := result.ExitCode
+	job.ExitCode = &exitCode
+	job.DurationMS = result.Duration.Milliseconds()
+	job.Stdout = truncateOutput(result.Stdout)
+	job.Stderr = truncateOutput(result.Stderr)
+
+	reason := ""
+	if err != nil {
+		reason = err.Error()
+	}
+	audit.LogHookAttempt(job.RequestID, job.HookID, job.ID, ip, userAgent, job.Attempt, job.MaxAttempts, reason, durationMS)
+
+	if err == nil {
+		job.State = queue.StateSucceeded
+		job.LastError = ""
+		if putErr := jr.queue.Put(job); putErr != nil {
+			logger.Errorf("[%s] error updating job %s: %v", job.RequestID, job.ID, putErr)
+		}
+		// 记录成功的 hook 执行
+		metrics.RecordHookExecution(job.HookID, "success", duration)
+		// 记录审计日志：执行成功
+		audit.LogHookExecuted(job.RequestID, job.HookID, ip, userAgent, durationMS)
+		jr.executor.triggerFollowUps(h, r, result, err, executionTimeout)
+		return
+	}
+
+	job.LastError = err.Error()
+
+	// 服务关闭时被取消的尝试不计入次数，留在队列中等待下次启动
+	if jr.ctx.Err() != nil {
+		logger.Warnf("[%s] async hook %s interrupted by shutdown, job %s stays queued (command: %s): %v", job.RequestID, job.HookID, job.ID, h.ExecuteCommand, err)
+		job.Attempt--
+		job.State = queue.StateQueued
+		if putErr := jr.queue.Put(job); putErr != nil {
+			logger.Errorf("[%s] error updating job %s: %v", job.RequestID, job.ID, putErr)
+		}
+		return
+	}
+
+	// concurrency-policy 为 reject 时任务被拒绝，与同步请求返回 429 一样不再重试
+	if errors.Is(err, ErrHookConcurrencyLimit) {
+		logger.Warnf("[%s] async hook %s rejected, job %s failed: %v", job.RequestID, job.HookID, job.ID, err)
+		job.State = queue.StateFailed
+		if putErr := jr.queue.Put(job); putErr != nil {
+			logger.Errorf("[%s] error updating job %s: %v", job.RequestID, job.ID, putErr)
+		}
+		metrics.RecordHookExecution(job.HookID, "rejected", duration)
+		audit.LogHookFailed(job.RequestID, job.HookID, ip, userAgent, err.Error(), durationMS)
+		return
+	}
+
+	if job.Attempt < job.MaxAttempts {
+		delay := h.Retry.Delay(job.Attempt, jr.rnd)
+		logger.Warnf("[%s] async hook %s attempt %d/%d failed, retrying in %v (command: %s): %v", job.RequestID, job.HookID, job.Attempt, job.MaxAttempts, delay, h.ExecuteCommand, err)
+		job.State = queue.StateQueued
+		job.NextRunAt = time.Now().Add(delay)
+		if putErr := jr.queue.Put(job); putErr != nil {
+			logger.Errorf("[%s] error updating job %s: %v", job.RequestID, job.ID, putErr)
+		}
+		metrics.RecordHookRetry(job.HookID)
+		jr.schedule(job, h, r, executionTimeout, delay)
+		return
+	}
+
+	job.State = queue.StateFailed
+	if errors.Is(err, context.DeadlineExceeded) {
+		job.State = queue.StateTimeout
+	}
+	if putErr := jr.queue.Put(job); putErr != nil {
+		logger.Errorf("[%s] error updating job %s: %v", job.RequestID, job.ID, putErr)
+	}
+
+	// 记录失败的 hook 执行
+	status := "error"
+	if errors.Is(err, context.DeadlineExceeded) {
+		status = "timeout"
+		logger.Errorf("[%s] async hook %s execution timeout (command: %s, timeout: %v): %v", job.RequestID, job.HookID, h.ExecuteCommand, executionTimeout, err)
+		// 记录审计日志：执行超时
+		audit.LogHookTimeout(job.RequestID, job.HookID, ip, userAgent, durationMS)
+	} else if errors.Is(err, context.Canceled) {
+		status = "cancelled"
+		logger.Warnf("[%s] async hook %s execution cancelled (command: %s): %v", job.RequestID, job.HookID, h.ExecuteCommand, err)
+		// 记录审计日志：执行取消
+		audit.LogHookCancelled(job.RequestID, job.HookID, ip, userAgent, durationMS)
+	} else {
+		logger.Errorf("[%s] error executing async hook %s (command: %s): %v", job.RequestID, job.HookID, h.ExecuteCommand, err)
+		// 记录审计日志：执行失败
+		audit.LogHookFailed(job.RequestID, job.HookID, ip, userAgent, err.Error(), durationMS)
+	}
+	metrics.RecordHookExecution(job.HookID, status, duration)
+
+	// 重试耗尽后才按最终结果触发后续 hook
+	jr.executor.triggerFollowUps(h, r, result, err, executionTimeout)
+}
+
+// jobStatus 是 /jobs/{id} 返回的任务状态
+type jobStatus struct {
+	ID          string     `json:"id"`
+	HookID      string     `json:"hook_id"`
+	RequestID   string     `json:"request_id,omitempty"`
+	State       string     `json:"state"`
+	Attempt     int        `json:"attempt"`
+	MaxAttempts int        `json:"max_attempts"`
+	ExitCode    *int       `json:"exit_code,omitempty"`
+	DurationMS  int64      `json:"duration_ms"`
+	Stdout      string     `json:"stdout"`
+	Stderr      string     `json:"stderr"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	NextRunAt   *time.Time `json:"next_run_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+func newJobStatus(job *queue.Job) jobStatus {
+	status := jobStatus{
+		ID:          job.ID,
+		HookID:      job.HookID,
+		RequestID:   job.RequestID,
+		State:       string(job.State),
+		Attempt:     job.Attempt,
+		MaxAttempts: job.MaxAttempts,
+		ExitCode:    job.ExitCode,
+		DurationMS:  job.DurationMS,
+		Stdout:      job.Stdout,
+		Stderr:      job.Stderr,
+		Error:       job.LastError,
+		CreatedAt:   job.CreatedAt,
+		UpdatedAt:   job.UpdatedAt,
+	}
+	if !job.NextRunAt.IsZero() {
+		nextRunAt := job.NextRunAt
+		status.NextRunAt = &nextRunAt
+	}
+	if !job.FinishedAt.IsZero() {
+		finishedAt := job.FinishedAt
+		status.FinishedAt = &finishedAt
+	}
+	return status
+}
+
+// createJobsHandler 返回异步任务状态接口：
+// GET {basePath}/{id} 返回任务状态，GET {basePath}/{id}/stdout 和 {basePath}/{id}/stderr 返回对应输出
+func createJobsHandler(executor *HookExecutor, basePath string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		startTime := time.Now()
+		statusCode := http.StatusOK
+		wrappedWriter := &statusCodeResponseWriter{
+			ResponseWriter: w,
+			statusCode:     &statusCode,
+		}
+		defer func() {
+			metrics.RecordHTTPRequest(r.Method, fmt.Sprintf("%d", statusCode), basePath+"/{id}", time.Since(startTime))
+		}()
+
+		requestID := loggerkit.RequestIDFromRequest(r)
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			wrappedWriter.Header().Set("Allow", "GET, HEAD")
+			HandleError(wrappedWriter, r, NewHTTPError(ErrorTypeClient, http.StatusMethodNotAllowed,
+				fmt.Sprintf("HTTP %s method not allowed for jobs", r.Method), nil), requestID, "")
+			return
+		}
+
+		rest := strings.TrimPrefix(r.URL.Path, basePath+"/")
+		jobID, stream, _ := strings.Cut(rest, "/")
+		job, ok := executor.JobQueue().Get(jobID)
+		if jobID == "" || !ok || (stream != "" && stream != "stdout" && stream != "stderr") {
+			HandleError(wrappedWriter, r, NewHTTPError(ErrorTypeClient, http.StatusNotFound, "Job not found.", nil), requestID, "")
+			return
+		}
+
+		switch stream {
+		case "stdout":
+			wrappedWriter.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			// #nosec G705 -- response is command stdout, returned as text/plain; not interpreted as HTML
+			_, _ = fmt.Fprint(wrappedWriter, job.Stdout)
+		case "stderr":
+			wrappedWriter.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			// #nosec G705 -- response is command stderr, returned as text/plain; not interpreted as HTML
+			_, _ = fmt.Fprint(wrappedWriter, job.Stderr)
+		default:
+			wrappedWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
+			if err := json.NewEncoder(wrappedWriter).Encode(newJobStatus(job)); err != nil {
+				logger.Errorf("error encoding job %s status: %v", job.ID, err)
+			}
+		}
+	}
+}
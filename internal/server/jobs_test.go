@@ -0,0 +1,428 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/soulteary/webhook/internal/flags"
+	"github.com/soulteary/webhook/internal/hook"
+	"github.com/soulteary/webhook/internal/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnqueue_SucceedsOnFirstAttempt(t *testing.T) {
+	var calls int32
+	executor := NewHookExecutorWithFunc(2, time.Second, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		return "ok", nil
+	})
+
+	h := &hook.Hook{ID: "job-success"}
+	job, err := executor.Enqueue(h, &hook.Request{ID: "req-1"}, time.Second)
+	require.NoError(t, err)
+	assert.NotEmpty(t, job.ID)
+	assert.Equal(t, queue.StateQueued, job.State)
+	assert.Equal(t, 1, job.MaxAttempts)
+
+	GetAsyncHookWaitGroup().Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	stored, ok := executor.JobQueue().Get(job.ID)
+	require.True(t, ok, "finished jobs should stay available during the retention window")
+	assert.Equal(t, queue.StateSucceeded, stored.State)
+	assert.Equal(t, 1, stored.Attempt)
+	require.NotNil(t, stored.ExitCode)
+	assert.Equal(t, 0, *stored.ExitCode)
+	assert.Empty(t, executor.JobQueue().Unfinished())
+}
+
+func TestEnqueue_RetriesUntilSuccess(t *testing.T) {
+	var calls int32
+	executor := NewHookExecutorWithFunc(2, time.Second, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return "", errors.New("exit status 1")
+		}
+		return "ok", nil
+	})
+
+	h := &hook.Hook{
+		ID: "job-retry",
+		Retry: &hook.RetryConfig{
+			MaxAttempts: 5,
+			Backoff:     hook.Duration(time.Millisecond),
+		},
+	}
+	job, err := executor.Enqueue(h, &hook.Request{ID: "req-2"}, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 5, job.MaxAttempts)
+
+	GetAsyncHookWaitGroup().Wait()
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	stored, ok := executor.JobQueue().Get(job.ID)
+	require.True(t, ok)
+	assert.Equal(t, queue.StateSucceeded, stored.State)
+	assert.Equal(t, 3, stored.Attempt)
+	assert.Empty(t, stored.LastError)
+}
+
+func TestEnqueue_GivesUpAfterMaxAttempts(t *testing.T) {
+	var calls int32
+	executor := NewHookExecutorWithFunc(2, time.Second, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		return "", errors.New("exit status 1")
+	})
+
+	h := &hook.Hook{
+		ID:    "job-fail",
+		Retry: &hook.RetryConfig{MaxAttempts: 3, Backoff: hook.Duration(time.Millisecond)},
+	}
+	job, err := executor.Enqueue(h, &hook.Request{ID: "req-3"}, time.Second)
+	require.NoError(t, err)
+
+	GetAsyncHookWaitGroup().Wait()
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	stored, ok := executor.JobQueue().Get(job.ID)
+	require.True(t, ok)
+	assert.Equal(t, queue.StateFailed, stored.State)
+	assert.Equal(t, "exit status 1", stored.LastError)
+}
+
+func TestEnqueue_RejectedIsNotRetried(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	executor := NewHookExecutorWithFunc(2, time.Second, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "ok", nil
+	})
+
+	h := &hook.Hook{
+		ID:                "job-reject",
+		MaxConcurrent:     1,
+		ConcurrencyPolicy: hook.ConcurrencyPolicyReject,
+		Retry:             &hook.RetryConfig{MaxAttempts: 3, Backoff: hook.Duration(time.Millisecond)},
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := executor.Execute(context.Background(), h, &hook.Request{ID: "running"}, nil, time.Second)
+		done <- err
+	}()
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 1 }, time.Second, time.Millisecond)
+
+	job, err := executor.Enqueue(h, &hook.Request{ID: "req-reject"}, time.Second)
+	require.NoError(t, err)
+	GetAsyncHookWaitGroup().Wait()
+
+	stored, ok := executor.JobQueue().Get(job.ID)
+	require.True(t, ok)
+	assert.Equal(t, queue.StateFailed, stored.State)
+	assert.Equal(t, 1, stored.Attempt)
+	assert.Equal(t, ErrHookConcurrencyLimit.Error(), stored.LastError)
+
+	close(release)
+	assert.NoError(t, <-done)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestEnqueue_TimeoutState(t *testing.T) {
+	executor := NewHookExecutorWithFunc(1, 20*time.Millisecond, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	job, err := executor.Enqueue(&hook.Hook{ID: "job-timeout"}, &hook.Request{ID: "req-timeout"}, time.Second)
+	require.NoError(t, err)
+
+	GetAsyncHookWaitGroup().Wait()
+
+	stored, ok := executor.JobQueue().Get(job.ID)
+	require.True(t, ok)
+	assert.Equal(t, queue.StateTimeout, stored.State)
+	require.NotNil(t, stored.ExitCode)
+	assert.Equal(t, -1, *stored.ExitCode)
+}
+
+func TestEnqueue_QueueClosed(t *testing.T) {
+	executor := NewHookExecutorWithFunc(1, time.Second, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error) {
+		return "", nil
+	})
+	require.NoError(t, executor.JobQueue().Close())
+
+	_, err := executor.Enqueue(&hook.Hook{ID: "closed"}, &hook.Request{ID: "req-4"}, time.Second)
+	assert.ErrorIs(t, err, queue.ErrClosed)
+}
+
+func TestStopJobs_KeepsPendingRetriesQueued(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.log")
+	q, err := queue.Open(path, 0)
+	require.NoError(t, err)
+
+	var calls int32
+	executor := NewHookExecutorWithFunc(1, time.Second, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		return "", errors.New("exit status 1")
+	})
+	executor.UseJobQueue(q, nil)
+
+	h := &hook.Hook{
+		ID:    "job-stop",
+		Retry: &hook.RetryConfig{MaxAttempts: 3, Backoff: hook.Duration(time.Hour)},
+	}
+	job, err := executor.Enqueue(h, &hook.Request{ID: "req-5"}, time.Second)
+	require.NoError(t, err)
+
+	// 等待第一次尝试失败并安排重试
+	require.Eventually(t, func() bool {
+		stored, ok := q.Get(job.ID)
+		return ok && stored.Attempt == 1 && stored.State == queue.StateQueued
+	}, time.Second, 5*time.Millisecond)
+
+	executor.StopJobs()
+	GetAsyncHookWaitGroup().Wait()
+	require.NoError(t, q.Close())
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	reopened, err := queue.Open(path, 0)
+	require.NoError(t, err)
+	defer func() { _ = reopened.Close() }()
+
+	stored, ok := reopened.Get(job.ID)
+	require.True(t, ok)
+	assert.Equal(t, 1, stored.Attempt)
+	assert.Equal(t, "exit status 1", stored.LastError)
+	assert.False(t, stored.NextRunAt.IsZero())
+}
+
+func TestResumeJobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.log")
+	q, err := queue.Open(path, 0)
+	require.NoError(t, err)
+
+	raw := httptest.NewRequest(http.MethodPost, "/hooks/deploy", nil)
+	raw.RemoteAddr = "10.0.0.2:4567"
+	snapshot := queue.SnapshotRequest(&hook.Request{ID: "req-6", Payload: map[string]interface{}{"ref": "main"}, RawRequest: raw})
+
+	require.NoError(t, q.Put(&queue.Job{ID: "interrupted", HookID: "deploy", RequestID: "req-6", State: queue.StateRunning, Attempt: 1, MaxAttempts: 1, Request: snapshot}))
+	require.NoError(t, q.Put(&queue.Job{ID: "orphan", HookID: "removed", RequestID: "req-7", State: queue.StateQueued, MaxAttempts: 1}))
+
+	var mu sync.Mutex
+	var seen []*hook.Request
+	executor := NewHookExecutorWithFunc(1, time.Second, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error) {
+		mu.Lock()
+		seen = append(seen, r)
+		mu.Unlock()
+		return "", nil
+	})
+	deploy := &hook.Hook{ID: "deploy"}
+	executor.UseJobQueue(q, func(id string) *hook.Hook {
+		if id == "deploy" {
+			return deploy
+		}
+		return nil
+	})
+
+	resumed := executor.ResumeJobs(time.Second)
+	assert.Equal(t, 1, resumed)
+
+	GetAsyncHookWaitGroup().Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, seen, 1)
+	assert.Equal(t, "req-6", seen[0].ID)
+	assert.Equal(t, "main", seen[0].Payload["ref"])
+	require.NotNil(t, seen[0].RawRequest)
+	assert.Equal(t, "10.0.0.2:4567", seen[0].RawRequest.RemoteAddr)
+	assert.Empty(t, q.Unfinished())
+}
+
+func TestResumeJobs_RedactedValueFailsJob(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.log")
+	q, err := queue.Open(path, 0)
+	require.NoError(t, err)
+
+	r := &hook.Request{ID: "req-8", Headers: map[string]interface{}{"Authorization": "Bearer s3cr3t", "X-Event": "push"}}
+	require.NoError(t, q.Put(&queue.Job{ID: "needs-auth", HookID: "deploy", RequestID: "req-8", State: queue.StateQueued, MaxAttempts: 1, Request: queue.SnapshotRequest(r)}))
+	require.NoError(t, q.Put(&queue.Job{ID: "event-only", HookID: "notify", RequestID: "req-8", State: queue.StateQueued, MaxAttempts: 1, Request: queue.SnapshotRequest(r)}))
+	require.NoError(t, q.Close())
+
+	// 重新打开后请求从日志回放，Authorization 已被替换
+	q, err = queue.Open(path, 0)
+	require.NoError(t, err)
+	defer func() { _ = q.Close() }()
+
+	var calls int32
+	executor := NewHookExecutorWithFunc(1, time.Second, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		return "", nil
+	})
+	hooks := map[string]*hook.Hook{
+		"deploy": {ID: "deploy", PassEnvironmentToCommand: []hook.Argument{{Source: hook.SourceHeader, Name: "authorization", EnvName: "TOKEN"}}},
+		"notify": {ID: "notify", PassArgumentsToCommand: []hook.Argument{{Source: hook.SourceHeader, Name: "X-Event"}}},
+	}
+	executor.UseJobQueue(q, func(id string) *hook.Hook { return hooks[id] })
+
+	assert.Equal(t, 2, executor.ResumeJobs(time.Second))
+	GetAsyncHookWaitGroup().Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	failed, ok := q.Get("needs-auth")
+	require.True(t, ok)
+	assert.Equal(t, queue.StateFailed, failed.State)
+	assert.Equal(t, 0, failed.Attempt)
+	assert.Equal(t, "header Authorization was not persisted in the job queue log and is required by hook deploy", failed.LastError)
+
+	succeeded, ok := q.Get("event-only")
+	require.True(t, ok)
+	assert.Equal(t, queue.StateSucceeded, succeeded.State)
+}
+
+func TestExecuteAsyncHook_QueueError(t *testing.T) {
+	executor := NewHookExecutorWithFunc(1, time.Second, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error) {
+		return "", nil
+	})
+	require.NoError(t, executor.JobQueue().Close())
+
+	h := &hook.Hook{ID: "queue-error", ResponseMessage: "queued"}
+	rec := httptest.NewRecorder()
+	executeAsyncHook(rec, context.Background(), h, &hook.Request{ID: "req-8"}, executor, time.Second, "req-8", h.ID, time.Now())
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "queued")
+}
+
+func TestExecuteAsyncHook_ReturnsJobLocation(t *testing.T) {
+	executor := NewHookExecutorWithFunc(1, time.Second, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error) {
+		return "", nil
+	})
+
+	h := &hook.Hook{ID: "job-location", ResponseMessage: "queued"}
+	rec := httptest.NewRecorder()
+	executeAsyncHook(rec, context.Background(), h, &hook.Request{ID: "req-9"}, executor, time.Second, "req-9", h.ID, time.Now())
+	GetAsyncHookWaitGroup().Wait()
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "queued", rec.Body.String())
+	jobID := rec.Header().Get("X-Job-Id")
+	require.NotEmpty(t, jobID)
+	assert.Equal(t, JobsPath+"/"+jobID, rec.Header().Get("Location"))
+}
+
+func TestExecuteAsyncHook_TemplateErrorDoesNotQueue(t *testing.T) {
+	executor := NewHookExecutorWithFunc(1, time.Second, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error) {
+		return "", nil
+	})
+
+	h := &hook.Hook{ID: "template-error", ResponseMessage: "{{ .Payload.missing }}"}
+	rec := httptest.NewRecorder()
+	executeAsyncHook(rec, context.Background(), h, &hook.Request{ID: "req-10"}, executor, time.Second, "req-10", h.ID, time.Now())
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Empty(t, rec.Header().Get("X-Job-Id"))
+	assert.Equal(t, 0, executor.JobQueue().Len())
+}
+
+func TestJobsHandler(t *testing.T) {
+	executor := NewHookExecutorWithResultFunc(1, time.Second, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (*ExecutionResult, error) {
+		return &ExecutionResult{Stdout: "deployed\n", Stderr: "warning\n", ExitCode: 3}, errors.New("exit status 3")
+	})
+
+	job, err := executor.Enqueue(&hook.Hook{ID: "job-status"}, &hook.Request{ID: "req-10"}, time.Second)
+	require.NoError(t, err)
+	GetAsyncHookWaitGroup().Wait()
+
+	handler := createJobsHandler(executor, JobsPath)
+
+	t.Run("status", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, JobsPath+"/"+job.ID, nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Header().Get("Content-Type"), "application/json")
+
+		var status map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+		assert.Equal(t, job.ID, status["id"])
+		assert.Equal(t, "job-status", status["hook_id"])
+		assert.Equal(t, "req-10", status["request_id"])
+		assert.Equal(t, string(queue.StateFailed), status["state"])
+		assert.Equal(t, float64(3), status["exit_code"])
+		assert.Equal(t, "deployed\n", status["stdout"])
+		assert.Equal(t, "warning\n", status["stderr"])
+		assert.Equal(t, "exit status 3", status["error"])
+		assert.NotEmpty(t, status["finished_at"])
+	})
+
+	t.Run("stdout", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, JobsPath+"/"+job.ID+"/stdout", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+		assert.Equal(t, "deployed\n", rec.Body.String())
+	})
+
+	t.Run("stderr", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, JobsPath+"/"+job.ID+"/stderr", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "warning\n", rec.Body.String())
+	})
+
+	t.Run("unknown job", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, JobsPath+"/missing", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("unknown stream", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, JobsPath+"/"+job.ID+"/env", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodDelete, JobsPath+"/"+job.ID, nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, "GET, HEAD", rec.Header().Get("Allow"))
+	})
+}
+
+func TestRunHookCommand_CapturesExitCodeAndStreams(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping on Windows")
+	}
+
+	scriptPath := filepath.Join(t.TempDir(), "test-script.sh")
+	scriptContent := "#!/bin/sh\necho 'to stdout'\necho 'to stderr' >&2\nexit 4\n"
+	require.NoError(t, os.WriteFile(scriptPath, []byte(scriptContent), 0755))
+
+	h := &hook.Hook{ID: "exit-code", ExecuteCommand: scriptPath, CaptureCommandOutput: true}
+	result, err := runHookCommand(context.Background(), h, &hook.Request{ID: "req-11"}, nil, flags.AppFlags{})
+
+	require.Error(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, 4, result.ExitCode)
+	assert.Equal(t, "to stdout\n", result.Stdout)
+	assert.Equal(t, "to stderr\n", result.Stderr)
+	assert.Contains(t, result.Output, "to stdout")
+	assert.Contains(t, result.Output, "to stderr")
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/soulteary/webhook/internal/hook"
+	"github.com/soulteary/webhook/internal/logger"
+)
+
+// executionOutcome 将执行结果映射为触发后续 hook 的结果类型；
+// 被拒绝或取消的执行不触发后续 hook
+func executionOutcome(err error) (string, bool) {
+	switch {
+	case err == nil:
+		return hook.OutcomeSuccess, true
+	case errors.Is(err, ErrHookConcurrencyLimit), errors.Is(err, context.Canceled):
+		return "", false
+	case errors.Is(err, context.DeadlineExceeded):
+		return hook.OutcomeTimeout, true
+	default:
+		return hook.OutcomeFailure, true
+	}
+}
+
+// triggerFollowUps 按执行结果将 on-success / on-failure / on-timeout 中的 hook 加入任务队列，
+// 后续 hook 可以通过 previous-result 参数来源读取本次执行的结果
+func (he *HookExecutor) triggerFollowUps(h *hook.Hook, r *hook.Request, result *ExecutionResult, err error, executionTimeout time.Duration) {
+	// 被合并的请求共享同一次执行，只由实际执行的请求触发后续 hook
+	if result.Coalesced {
+		return
+	}
+	outcome, ok := executionOutcome(err)
+	if !ok {
+		return
+	}
+	ids := h.FollowUps(outcome)
+	if len(ids) == 0 {
+		return
+	}
+
+	he.jobs.mu.Lock()
+	lookup := he.jobs.lookup
+	he.jobs.mu.Unlock()
+	if lookup == nil {
+		logger.Warnf("[%s] hook %s has follow-up hooks but no hook lookup is configured", r.ID, h.ID)
+		return
+	}
+
+	previous := &hook.PreviousResult{
+		HookID:   h.ID,
+		Status:   outcome,
+		ExitCode: result.ExitCode,
+		Output:   result.Output,
+		Stdout:   result.Stdout,
+		Stderr:   result.Stderr,
+	}
+	if err != nil {
+		previous.Error = err.Error()
+	}
+	chain := append(append([]string(nil), r.Chain...), h.ID)
+
+	for _, id := range ids {
+		if slices.Contains(chain, id) {
+			logger.Warnf("[%s] skipping follow-up hook %s of %s: reference cycle", r.ID, id, h.ID)
+			continue
+		}
+		next := lookup(id)
+		if next == nil {
+			logger.Warnf("[%s] follow-up hook %s of %s not found", r.ID, id, h.ID)
+			continue
+		}
+
+		followUp := *r
+		followUp.Previous = previous
+		followUp.Chain = chain
+		job, enqueueErr := he.Enqueue(next, &followUp, executionTimeout)
+		if enqueueErr != nil {
+			logger.Errorf("[%s] error queueing follow-up hook %s of %s: %v", r.ID, id, h.ID, enqueueErr)
+			continue
+		}
+		logger.Infof("[%s] hook %s finished with %s, queued follow-up hook %s as job %s", r.ID, h.ID, outcome, id, job.ID)
+	}
+}
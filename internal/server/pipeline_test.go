@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/soulteary/webhook/internal/hook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPipelineExecutor 创建一个按 hook ID 返回预设结果的执行器，并记录每次执行收到的请求
+func newPipelineExecutor(t *testing.T, hooks []*hook.Hook, results map[string]error) (*HookExecutor, func() map[string]*hook.Request) {
+	t.Helper()
+
+	var mu sync.Mutex
+	seen := make(map[string]*hook.Request)
+	executor := NewHookExecutorWithResultFunc(4, time.Second, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (*ExecutionResult, error) {
+		mu.Lock()
+		seen[h.ID] = r
+		mu.Unlock()
+		err := results[h.ID]
+		result := &ExecutionResult{Output: h.ID + " output", Stdout: h.ID + " output"}
+		if err != nil {
+			result.ExitCode = 1
+		}
+		return result, err
+	})
+
+	byID := make(map[string]*hook.Hook, len(hooks))
+	for _, h := range hooks {
+		byID[h.ID] = h
+	}
+	executor.UseJobQueue(nil, func(id string) *hook.Hook {
+		return byID[id]
+	})
+
+	return executor, func() map[string]*hook.Request {
+		mu.Lock()
+		defer mu.Unlock()
+		out := make(map[string]*hook.Request, len(seen))
+		for k, v := range seen {
+			out[k] = v
+		}
+		return out
+	}
+}
+
+func TestTriggerFollowUps_ByOutcome(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"success", nil, "notify-ok"},
+		{"failure", errors.New("exit status 1"), "notify-failed"},
+		{"timeout", context.DeadlineExceeded, "notify-timeout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			build := &hook.Hook{
+				ID:        "build",
+				OnSuccess: []string{"notify-ok"},
+				OnFailure: []string{"notify-failed"},
+				OnTimeout: []string{"notify-timeout"},
+			}
+			hooks := []*hook.Hook{build, {ID: "notify-ok"}, {ID: "notify-failed"}, {ID: "notify-timeout"}}
+			executor, seen := newPipelineExecutor(t, hooks, map[string]error{"build": tt.err})
+
+			_, err := executor.Enqueue(build, &hook.Request{ID: "req-" + tt.name}, time.Second)
+			require.NoError(t, err)
+			GetAsyncHookWaitGroup().Wait()
+
+			requests := seen()
+			require.Len(t, requests, 2)
+			next, ok := requests[tt.expected]
+			require.True(t, ok, "expected follow-up hook %s to run", tt.expected)
+			require.NotNil(t, next.Previous)
+			assert.Equal(t, "build", next.Previous.HookID)
+			assert.Equal(t, tt.name, next.Previous.Status)
+			assert.Equal(t, "build output", next.Previous.Stdout)
+			assert.Equal(t, []string{"build"}, next.Chain)
+			assert.Equal(t, "req-"+tt.name, next.ID)
+		})
+	}
+}
+
+func TestTriggerFollowUps_TimeoutFallsBackToOnFailure(t *testing.T) {
+	build := &hook.Hook{ID: "build", OnFailure: []string{"cleanup"}}
+	executor, seen := newPipelineExecutor(t, []*hook.Hook{build, {ID: "cleanup"}}, map[string]error{"build": context.DeadlineExceeded})
+
+	_, err := executor.Enqueue(build, &hook.Request{ID: "req-1"}, time.Second)
+	require.NoError(t, err)
+	GetAsyncHookWaitGroup().Wait()
+
+	next, ok := seen()["cleanup"]
+	require.True(t, ok)
+	assert.Equal(t, hook.OutcomeTimeout, next.Previous.Status)
+}
+
+func TestTriggerFollowUps_PreviousResultArgument(t *testing.T) {
+	build := &hook.Hook{ID: "build", OnSuccess: []string{"notify"}}
+	executor, seen := newPipelineExecutor(t, []*hook.Hook{build, {ID: "notify"}}, nil)
+
+	_, err := executor.Enqueue(build, &hook.Request{ID: "req-1"}, time.Second)
+	require.NoError(t, err)
+	GetAsyncHookWaitGroup().Wait()
+
+	next, ok := seen()["notify"]
+	require.True(t, ok)
+	arg := hook.Argument{Source: hook.SourcePreviousResult, Name: "output"}
+	value, err := arg.Get(next)
+	require.NoError(t, err)
+	assert.Equal(t, "build output", value)
+}
+
+func TestTriggerFollowUps_SkipsCycles(t *testing.T) {
+	a := &hook.Hook{ID: "a", OnSuccess: []string{"b"}}
+	b := &hook.Hook{ID: "b", OnSuccess: []string{"a", "missing"}}
+	executor, seen := newPipelineExecutor(t, []*hook.Hook{a, b}, nil)
+
+	_, err := executor.Enqueue(a, &hook.Request{ID: "req-1"}, time.Second)
+	require.NoError(t, err)
+	GetAsyncHookWaitGroup().Wait()
+
+	requests := seen()
+	assert.Len(t, requests, 2, "a must not run again from b's follow-ups")
+	assert.Equal(t, []string{"a"}, requests["b"].Chain)
+}
+
+func TestTriggerFollowUps_IgnoresRejectedExecutions(t *testing.T) {
+	build := &hook.Hook{ID: "build", OnFailure: []string{"cleanup"}}
+	executor, seen := newPipelineExecutor(t, []*hook.Hook{build, {ID: "cleanup"}}, nil)
+
+	executor.triggerFollowUps(build, &hook.Request{ID: "req-1"}, &ExecutionResult{}, ErrHookConcurrencyLimit, time.Second)
+	executor.triggerFollowUps(build, &hook.Request{ID: "req-2"}, &ExecutionResult{}, context.Canceled, time.Second)
+	GetAsyncHookWaitGroup().Wait()
+
+	assert.Empty(t, seen())
+}
+
+func TestTriggerFollowUps_IgnoresCoalescedRequests(t *testing.T) {
+	build := &hook.Hook{ID: "build", OnSuccess: []string{"notify"}}
+	executor, seen := newPipelineExecutor(t, []*hook.Hook{build, {ID: "notify"}}, nil)
+
+	executor.triggerFollowUps(build, &hook.Request{ID: "req-1"}, &ExecutionResult{Coalesced: true}, nil, time.Second)
+	GetAsyncHookWaitGroup().Wait()
+
+	assert.Empty(t, seen())
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/soulteary/webhook/internal/hook"
+	"github.com/soulteary/webhook/internal/logger"
+)
+
+// restrictedResponseHeaders 由 HTTP 服务器管理，命令不能通过结构化响应设置
+var restrictedResponseHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Length":    true,
+	"Keep-Alive":        true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// createCommandResponseFile 为 command-response: file 创建空的响应文件，返回文件路径
+func createCommandResponseFile(h *hook.Hook) (string, error) {
+	f, err := os.CreateTemp("", "webhook-response-*")
+	if err != nil {
+		return "", err
+	}
+	name := f.Name()
+	if err := f.Close(); err != nil {
+		_ = os.Remove(name)
+		return "", err
+	}
+
+	// 以其他用户运行的命令需要能写入响应文件
+	if h.Sandbox != nil && h.Sandbox.UID != nil {
+		if err := os.Chown(name, *h.Sandbox.UID, -1); err != nil {
+			_ = os.Remove(name)
+			return "", err
+		}
+	}
+	return name, nil
+}
+
+// readCommandResponseFile 读取命令写入的响应文件，超过 MaxCommandResponseSize 时返回错误
+func readCommandResponseFile(name string) ([]byte, error) {
+	// #nosec G304 -- name 由 createCommandResponseFile 创建
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(f, hook.MaxCommandResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > hook.MaxCommandResponseSize {
+		return nil, fmt.Errorf("command response exceeds %d bytes", hook.MaxCommandResponseSize)
+	}
+	return data, nil
+}
+
+// parseCommandResponse 按 hook 的 command-response 配置解析命令返回的结构化响应
+func parseCommandResponse(h *hook.Hook, result *ExecutionResult) (*hook.CommandResponse, error) {
+	if h.CommandResponse == hook.CommandResponseStdout {
+		return hook.ParseCommandResponse([]byte(result.Stdout))
+	}
+	return hook.ParseCommandResponse(result.Response)
+}
+
+// writeCommandResponse 按结构化响应写入状态码、响应头和响应体；未指定状态码时使用 status
+func writeCommandResponse(w http.ResponseWriter, requestID, hookID string, cr *hook.CommandResponse, status int) {
+	body, isJSON := cr.BodyBytes()
+	if isJSON {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+
+	for name, value := range cr.Headers {
+		if restrictedResponseHeaders[http.CanonicalHeaderKey(name)] {
+			logger.Warnf("[%s] ignoring header %s in command response of hook %s", requestID, name, hookID)
+			continue
+		}
+		w.Header().Set(name, value)
+	}
+
+	if cr.Status != 0 {
+		status = cr.Status
+	}
+	if status != 0 {
+		writeHttpResponseCode(w, requestID, hookID, status)
+	}
+	// #nosec G705 -- 响应体与 Content-Type 均由 hook 命令显式给出
+	_, _ = w.Write(body)
+}
+
+// writeResponseMessage 渲染 response-message 与 response-headers 模板并写入响应，
+// 捕获输出模式下模板还可以访问命令的退出码与输出
+func writeResponseMessage(w http.ResponseWriter, matchedHook *hook.Hook, data *hook.ResponseData, status int, requestID, hookID string) {
+	headers, err := matchedHook.RenderResponseHeaders(data)
+	var message string
+	if err == nil {
+		message, err = matchedHook.RenderResponseMessage(data)
+	}
+	if err != nil {
+		writeResponseTemplateError(w, err, requestID, hookID)
+		return
+	}
+
+	setResponseHeaders(w, headers)
+	writeRenderedMessage(w, message, status, requestID, hookID)
+}
+
+// writeRenderedMessage 写入状态码与已渲染的 response-message
+func writeRenderedMessage(w http.ResponseWriter, message string, status int, requestID, hookID string) {
+	if status != 0 {
+		writeHttpResponseCode(w, requestID, hookID, status)
+	}
+	// #nosec G705 -- response-message is configured by the hook author; the Content-Type is theirs to set
+	_, _ = fmt.Fprint(w, message)
+}
+
+// writeResponseTemplateError 在响应模板渲染失败时返回 500
+func writeResponseTemplateError(w http.ResponseWriter, err error, requestID, hookID string) {
+	logger.Errorf("[%s] hook %s: %v", requestID, hookID, err)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	_, _ = fmt.Fprint(w, "Error occurred while rendering the hook's response. Please check your logs for more details.")
+}
+
+// writeTemplatedCaptureResponse 在捕获输出模式下以渲染后的 response-message 代替命令输出
+func writeTemplatedCaptureResponse(w http.ResponseWriter, matchedHook *hook.Hook, req *hook.Request, result *ExecutionResult, status int, requestID, hookID string) {
+	data := hook.NewResponseData(matchedHook, req)
+	data.ExitCode = result.ExitCode
+	data.Output = result.Output
+
+	// 未通过 response-headers 指定 Content-Type 时按纯文本返回
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	writeResponseMessage(w, matchedHook, data, status, requestID, hookID)
+}
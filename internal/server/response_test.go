@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/soulteary/webhook/internal/flags"
+	"github.com/soulteary/webhook/internal/hook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCommandResponse(t *testing.T) {
+	t.Run("json body", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		cr := &hook.CommandResponse{Status: http.StatusCreated, Headers: map[string]string{"X-Build": "42", "Content-Length": "1"}, Body: []byte(`{"ok":true}`)}
+		writeCommandResponse(rec, "req-1", "deploy", cr, http.StatusOK)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "42", rec.Header().Get("X-Build"))
+		assert.Empty(t, rec.Header().Get("Content-Length"))
+		assert.Equal(t, `{"ok":true}`, rec.Body.String())
+	})
+
+	t.Run("default status and text body", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		cr := &hook.CommandResponse{Headers: map[string]string{"Content-Type": "text/html"}, Body: []byte(`"<p>done</p>"`)}
+		writeCommandResponse(rec, "req-2", "deploy", cr, http.StatusAccepted)
+
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, "text/html", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "<p>done</p>", rec.Body.String())
+	})
+}
+
+func TestExecuteCapturingHook_CommandResponse(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping on Windows")
+	}
+
+	dir := t.TempDir()
+	writeScript := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+content), 0755))
+		return path
+	}
+
+	executor := NewHookExecutorWithResultFunc(1, time.Second, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (*ExecutionResult, error) {
+		return runHookCommand(ctx, h, r, w, flags.AppFlags{})
+	})
+	run := func(h *hook.Hook) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		executeCapturingHook(rec, context.Background(), h, &hook.Request{ID: "req-" + h.ID}, executor, time.Second, "req-"+h.ID, h.ID, time.Now())
+		return rec
+	}
+
+	t.Run("stdout", func(t *testing.T) {
+		rec := run(&hook.Hook{
+			ID:              "stdout",
+			ExecuteCommand:  writeScript("stdout.sh", `echo '{"status": 201, "headers": {"X-Build": "42"}, "body": {"deployed": true}}'`+"\n"),
+			CommandResponse: hook.CommandResponseStdout,
+		})
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "42", rec.Header().Get("X-Build"))
+		assert.JSONEq(t, `{"deployed": true}`, rec.Body.String())
+	})
+
+	t.Run("file on failure", func(t *testing.T) {
+		rec := run(&hook.Hook{
+			ID:              "file",
+			ExecuteCommand:  writeScript("file.sh", "echo 'progress output'\necho '{\"body\": \"already running\"}' > \"$"+hook.CommandResponseFileEnv+"\"\nexit 75\n"),
+			CommandResponse: hook.CommandResponseFile,
+			ExitCodeMap:     map[int]int{75: http.StatusServiceUnavailable},
+		})
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "already running", rec.Body.String())
+	})
+
+	t.Run("exit code map without response", func(t *testing.T) {
+		rec := run(&hook.Hook{
+			ID:                          "exit-code",
+			ExecuteCommand:              writeScript("conflict.sh", "echo conflict\nexit 3\n"),
+			CaptureCommandOutput:        true,
+			CaptureCommandOutputOnError: true,
+			ExitCodeMap:                 map[int]int{3: http.StatusConflict},
+		})
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, "conflict\n", rec.Body.String())
+	})
+
+	t.Run("unmapped exit code", func(t *testing.T) {
+		rec := run(&hook.Hook{
+			ID:             "unmapped",
+			ExecuteCommand: writeScript("fail.sh", "exit 1\n"),
+			ExitCodeMap:    map[int]int{3: http.StatusConflict},
+		})
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+
+	t.Run("invalid response", func(t *testing.T) {
+		rec := run(&hook.Hook{
+			ID:              "invalid",
+			ExecuteCommand:  writeScript("invalid.sh", "echo 'not json'\n"),
+			CommandResponse: hook.CommandResponseStdout,
+		})
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "not json")
+	})
+}
+
+func TestExecuteCapturingHook_ResponseTemplate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping on Windows")
+	}
+
+	dir := t.TempDir()
+	writeScript := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+content), 0755))
+		return path
+	}
+
+	executor := NewHookExecutorWithResultFunc(1, time.Second, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (*ExecutionResult, error) {
+		return runHookCommand(ctx, h, r, w, flags.AppFlags{})
+	})
+	run := func(h *hook.Hook) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := &hook.Request{ID: "req-" + h.ID, Payload: map[string]interface{}{"ref": "main"}}
+		executeCapturingHook(rec, context.Background(), h, req, executor, time.Second, "req-"+h.ID, h.ID, time.Now())
+		return rec
+	}
+
+	t.Run("success", func(t *testing.T) {
+		rec := run(&hook.Hook{
+			ID:                   "success",
+			ExecuteCommand:       writeScript("success.sh", "echo deployed\n"),
+			CaptureCommandOutput: true,
+			ResponseMessage:      `{"text": {{json (printf "%s %s: %s" .HookID .Payload.ref .Output)}}}`,
+			ResponseHeaders:      hook.ResponseHeaders{{Name: "Content-Type", Value: "application/json"}},
+		})
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"text": "success main: deployed\n"}`, rec.Body.String())
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		rec := run(&hook.Hook{
+			ID:                          "failure",
+			ExecuteCommand:              writeScript("failure.sh", "echo conflict\nexit 3\n"),
+			CaptureCommandOutput:        true,
+			CaptureCommandOutputOnError: true,
+			ExitCodeMap:                 map[int]int{3: http.StatusConflict},
+			ResponseMessage:             "exit {{.ExitCode}}",
+		})
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "exit 3", rec.Body.String())
+	})
+
+	t.Run("render error", func(t *testing.T) {
+		rec := run(&hook.Hook{
+			ID:                   "render-error",
+			ExecuteCommand:       writeScript("render.sh", "echo done\n"),
+			CaptureCommandOutput: true,
+			ResponseMessage:      "{{.Payload.missing}}",
+		})
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "done")
+	})
+}
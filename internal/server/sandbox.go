@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/soulteary/webhook/internal/hook"
+)
+
+// ErrOutputLimitExceeded 表示命令输出超过了 sandbox.max-output-bytes 限制
+var ErrOutputLimitExceeded = errors.New("command output limit exceeded")
+
+// outputLimiter 统计 stdout 与 stderr 的总输出量，超过上限后终止命令并丢弃后续输出
+type outputLimiter struct {
+	mu       sync.Mutex
+	limit    int64
+	written  int64
+	exceeded bool
+	cancel   context.CancelCauseFunc
+}
+
+// newOutputLimiter 在 limit 为 0 时返回 nil，表示不限制输出
+func newOutputLimiter(limit int64, cancel context.CancelCauseFunc) *outputLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &outputLimiter{limit: limit, cancel: cancel}
+}
+
+// wrap 返回受总量限制的 writer
+func (l *outputLimiter) wrap(w io.Writer) io.Writer {
+	if l == nil {
+		return w
+	}
+	return &limitedWriter{l: l, w: w}
+}
+
+// Exceeded 返回输出是否被截断
+func (l *outputLimiter) Exceeded() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.exceeded
+}
+
+type limitedWriter struct {
+	l *outputLimiter
+	w io.Writer
+}
+
+// Write 始终报告写入成功，避免命令因管道错误提前退出而掩盖真实的终止原因
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	l := lw.l
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.exceeded {
+		return len(p), nil
+	}
+
+	chunk := p
+	if remaining := l.limit - l.written; int64(len(chunk)) > remaining {
+		chunk = chunk[:remaining]
+		l.exceeded = true
+	}
+	if len(chunk) > 0 {
+		n, err := lw.w.Write(chunk)
+		l.written += int64(n)
+		if err != nil {
+			return n, err
+		}
+	}
+	if l.exceeded {
+		l.cancel(ErrOutputLimitExceeded)
+	}
+	return len(p), nil
+}
+
+// runCommand 执行命令；需要 rlimit 或命名空间时经由 sandbox 初始化进程执行，
+// 使命令从第一条指令起就处于限制之下
+func runCommand(cmd *exec.Cmd, sb *hook.SandboxConfig) error {
+	if err := wrapSandboxInit(cmd, sb); err != nil {
+		return fmt.Errorf("failed to set up sandbox: %w", err)
+	}
+	return cmd.Run()
+}
+
+// sandboxInitArg 是 webhook 作为 sandbox 初始化进程重新执行自身时的第一个参数
+const sandboxInitArg = "__webhook-sandbox-init"
+
+// sandboxInitExitCode 是初始化进程无法应用 sandbox 时的退出码，与 shell 中命令无法执行时一致
+const sandboxInitExitCode = 126
+
+// sandboxSpec 描述初始化进程在 exec 目标命令之前应用的设置
+type sandboxSpec struct {
+	UID           *int   `json:"uid,omitempty"`
+	GID           *int   `json:"gid,omitempty"`
+	CPUSeconds    uint64 `json:"cpu_seconds,omitempty"`
+	AddressSpace  uint64 `json:"address_space,omitempty"`
+	OpenFiles     uint64 `json:"open_files,omitempty"`
+	PrivateMounts bool   `json:"private_mounts,omitempty"`
+	MountProc     bool   `json:"mount_proc,omitempty"`
+}
+
+// needsSandboxInit 返回命令是否需要经由初始化进程执行：rlimit 以及命名空间内的挂载
+// 都必须在 exec 之前于子进程中完成
+func needsSandboxInit(sb *hook.SandboxConfig) bool {
+	return sb.HasResourceLimits() || (sb != nil && len(sb.Namespaces) > 0)
+}
+
+// wrapSandboxInit 把命令改写为由当前可执行文件以初始化进程身份启动，
+// 初始化进程应用 sandbox 设置后再 exec 原命令
+func wrapSandboxInit(cmd *exec.Cmd, sb *hook.SandboxConfig) error {
+	if !needsSandboxInit(sb) {
+		return nil
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	spec := sandboxSpec{
+		UID:          sb.UID,
+		GID:          sb.GID,
+		CPUSeconds:   sb.CPUSeconds,
+		AddressSpace: sb.AddressSpace,
+		OpenFiles:    sb.OpenFiles,
+	}
+	for _, ns := range sb.Namespaces {
+		switch ns {
+		case hook.NamespaceMount:
+			spec.PrivateMounts = true
+		case hook.NamespacePID:
+			// 新 PID 命名空间需要在独立的挂载命名空间中挂载新的 /proc
+			spec.PrivateMounts = true
+			spec.MountProc = true
+		}
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+
+	cmd.Args = append([]string{self, sandboxInitArg, string(data), cmd.Path}, cmd.Args...)
+	cmd.Path = self
+	return nil
+}
+
+// RunSandboxInit 在进程作为 sandbox 初始化进程启动时应用 sandbox 设置并 exec 目标命令，
+// 不会返回；其他情况下直接返回。需要在 main 的开头调用
+func RunSandboxInit() {
+	if len(os.Args) < 5 || os.Args[1] != sandboxInitArg {
+		return
+	}
+
+	var spec sandboxSpec
+	err := json.Unmarshal([]byte(os.Args[2]), &spec)
+	if err == nil {
+		// execSandboxed 只在失败时返回
+		err = execSandboxed(&spec, os.Args[3], os.Args[4:])
+	}
+	fmt.Fprintf(os.Stderr, "webhook: sandbox setup failed: %v\n", err)
+	os.Exit(sandboxInitExitCode)
+}
@@ -0,0 +1,93 @@
+//go:build linux
+// +build linux
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/soulteary/webhook/internal/hook"
+	"golang.org/x/sys/unix"
+)
+
+// applySandbox 在命令启动前配置运行用户与命名空间；需要初始化进程时，
+// 运行用户由初始化进程在挂载与 rlimit 之后切换
+func applySandbox(cmd *exec.Cmd, sb *hook.SandboxConfig) error {
+	if sb == nil {
+		return nil
+	}
+	if !needsSandboxInit(sb) {
+		setCredential(cmd, sb)
+		return nil
+	}
+
+	for _, ns := range sb.Namespaces {
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		switch ns {
+		case hook.NamespaceMount:
+			cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNS
+		case hook.NamespacePID:
+			cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWPID | syscall.CLONE_NEWNS
+		}
+	}
+
+	return nil
+}
+
+// execSandboxed 在初始化进程中依次设置挂载、rlimit 与运行用户，然后 exec 目标命令，
+// 子进程会继承这些限制
+func execSandboxed(spec *sandboxSpec, path string, argv []string) error {
+	if spec.PrivateMounts {
+		// 挂载事件不再与宿主的挂载命名空间互相传播
+		if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+			return fmt.Errorf("failed to make mounts private: %w", err)
+		}
+	}
+	if spec.MountProc {
+		if err := unix.Mount("proc", "/proc", "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+			return fmt.Errorf("failed to mount /proc: %w", err)
+		}
+	}
+
+	limits := []struct {
+		resource int
+		value    uint64
+	}{
+		{unix.RLIMIT_CPU, spec.CPUSeconds},
+		{unix.RLIMIT_AS, spec.AddressSpace},
+		{unix.RLIMIT_NOFILE, spec.OpenFiles},
+	}
+	for _, l := range limits {
+		if l.value == 0 {
+			continue
+		}
+		if err := unix.Setrlimit(l.resource, &unix.Rlimit{Cur: l.value, Max: l.value}); err != nil {
+			return fmt.Errorf("failed to apply resource limits: %w", err)
+		}
+	}
+
+	if spec.UID != nil || spec.GID != nil {
+		// 丢弃 webhook 的附加用户组（例如以 root 运行时的 gid 0）
+		if err := syscall.Setgroups([]int{}); err != nil {
+			return fmt.Errorf("failed to drop supplementary groups: %w", err)
+		}
+		if spec.GID != nil {
+			if err := syscall.Setgid(*spec.GID); err != nil {
+				return fmt.Errorf("failed to set gid: %w", err)
+			}
+		}
+		if spec.UID != nil {
+			if err := syscall.Setuid(*spec.UID); err != nil {
+				return fmt.Errorf("failed to set uid: %w", err)
+			}
+		}
+	}
+
+	// #nosec G204 -- path 与 argv 由父进程经过命令白名单校验后传入
+	return syscall.Exec(path, argv, os.Environ())
+}
@@ -0,0 +1,31 @@
+//go:build !windows && !linux
+// +build !windows,!linux
+
+package server
+
+import (
+	"errors"
+	"os/exec"
+
+	"github.com/soulteary/webhook/internal/hook"
+)
+
+// applySandbox 非 Linux 的 Unix 系统仅支持切换运行用户
+func applySandbox(cmd *exec.Cmd, sb *hook.SandboxConfig) error {
+	if sb == nil {
+		return nil
+	}
+	if len(sb.Namespaces) > 0 {
+		return errors.New("sandbox namespaces are only supported on Linux")
+	}
+	if sb.HasResourceLimits() {
+		return errors.New("sandbox resource limits are only supported on Linux")
+	}
+
+	setCredential(cmd, sb)
+	return nil
+}
+
+func execSandboxed(spec *sandboxSpec, path string, argv []string) error {
+	return errors.New("sandbox resource limits and namespaces are only supported on Linux")
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/soulteary/webhook/internal/flags"
+	"github.com/soulteary/webhook/internal/hook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain 让测试二进制可以作为 sandbox 初始化进程运行
+func TestMain(m *testing.M) {
+	RunSandboxInit()
+	os.Exit(m.Run())
+}
+
+func TestOutputLimiter(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		var buf bytes.Buffer
+		limiter := newOutputLimiter(0, nil)
+		assert.Nil(t, limiter)
+		assert.Same(t, &buf, limiter.wrap(&buf))
+		assert.False(t, limiter.Exceeded())
+	})
+
+	t.Run("shared between writers", func(t *testing.T) {
+		var cause error
+		var stdout, stderr bytes.Buffer
+		limiter := newOutputLimiter(8, func(err error) { cause = err })
+		out := limiter.wrap(&stdout)
+		errOut := limiter.wrap(&stderr)
+
+		n, err := out.Write([]byte("hello"))
+		require.NoError(t, err)
+		assert.Equal(t, 5, n)
+		assert.False(t, limiter.Exceeded())
+
+		n, err = errOut.Write([]byte("world"))
+		require.NoError(t, err)
+		assert.Equal(t, 5, n, "writes past the limit still report success")
+		assert.True(t, limiter.Exceeded())
+		assert.ErrorIs(t, cause, ErrOutputLimitExceeded)
+
+		_, err = out.Write([]byte("discarded"))
+		require.NoError(t, err)
+		assert.Equal(t, "hello", stdout.String())
+		assert.Equal(t, "wor", stderr.String())
+	})
+}
+
+func TestRunHookCommand_Sandbox(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping on Windows")
+	}
+
+	dir := t.TempDir()
+	writeScript := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+content), 0755))
+		return path
+	}
+
+	t.Run("clean environment", func(t *testing.T) {
+		t.Setenv("WEBHOOK_SANDBOX_SECRET", "secret")
+		t.Setenv("WEBHOOK_SANDBOX_ALLOWED", "allowed")
+
+		h := &hook.Hook{
+			ID:                   "sandbox-env",
+			ExecuteCommand:       writeScript("env.sh", "env\n"),
+			CaptureCommandOutput: true,
+			Sandbox:              &hook.SandboxConfig{CleanEnv: true, EnvAllowlist: []string{"WEBHOOK_SANDBOX_ALLOWED"}},
+		}
+		result, err := runHookCommand(context.Background(), h, &hook.Request{ID: "req-sandbox-1"}, nil, flags.AppFlags{})
+
+		require.NoError(t, err)
+		assert.Contains(t, result.Stdout, "WEBHOOK_SANDBOX_ALLOWED=allowed")
+		assert.NotContains(t, result.Stdout, "WEBHOOK_SANDBOX_SECRET")
+	})
+
+	t.Run("output limit", func(t *testing.T) {
+		h := &hook.Hook{
+			ID:                   "sandbox-output",
+			ExecuteCommand:       writeScript("output.sh", "while true; do echo 0123456789; done\n"),
+			CaptureCommandOutput: true,
+			Sandbox:              &hook.SandboxConfig{MaxOutputBytes: 64},
+		}
+		result, err := runHookCommand(context.Background(), h, &hook.Request{ID: "req-sandbox-2"}, nil, flags.AppFlags{})
+
+		assert.ErrorIs(t, err, ErrOutputLimitExceeded)
+		assert.Len(t, result.Output, 64)
+		assert.True(t, strings.HasPrefix(result.Stdout, "0123456789\n"))
+	})
+
+	t.Run("resource limits", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("Resource limits are only supported on Linux")
+		}
+
+		h := &hook.Hook{
+			ID:                   "sandbox-rlimit",
+			ExecuteCommand:       writeScript("ulimit.sh", "ulimit -n\n"),
+			CaptureCommandOutput: true,
+			Sandbox:              &hook.SandboxConfig{OpenFiles: 32},
+		}
+		result, err := runHookCommand(context.Background(), h, &hook.Request{ID: "req-sandbox-3"}, nil, flags.AppFlags{})
+
+		require.NoError(t, err)
+		assert.Equal(t, "32\n", result.Stdout)
+	})
+}
+
+func TestWrapSandboxInit(t *testing.T) {
+	uid := 65534
+
+	cmd := exec.Command("/bin/true", "arg")
+	require.NoError(t, wrapSandboxInit(cmd, &hook.SandboxConfig{UID: &uid}))
+	assert.Equal(t, []string{"/bin/true", "arg"}, cmd.Args, "uid alone does not need the init process")
+
+	require.NoError(t, wrapSandboxInit(cmd, &hook.SandboxConfig{UID: &uid, OpenFiles: 32, Namespaces: []string{hook.NamespacePID}}))
+	self, err := os.Executable()
+	require.NoError(t, err)
+	assert.Equal(t, self, cmd.Path)
+	require.Len(t, cmd.Args, 6)
+	assert.Equal(t, sandboxInitArg, cmd.Args[1])
+	assert.JSONEq(t, `{"uid": 65534, "open_files": 32, "private_mounts": true, "mount_proc": true}`, cmd.Args[2])
+	assert.Equal(t, []string{"/bin/true", "/bin/true", "arg"}, cmd.Args[3:])
+}
+
+func TestRunHookCommand_SandboxIsolation(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Namespaces are only supported on Linux")
+	}
+	if os.Getuid() != 0 {
+		t.Skip("Namespaces and switching users require root")
+	}
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "isolation.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho $$\nid -G\n"), 0755))
+
+	uid := 65534
+	h := &hook.Hook{
+		ID:                   "sandbox-isolation",
+		ExecuteCommand:       script,
+		CaptureCommandOutput: true,
+		Sandbox:              &hook.SandboxConfig{UID: &uid, GID: &uid, Namespaces: []string{hook.NamespacePID}},
+	}
+	result, err := runHookCommand(context.Background(), h, &hook.Request{ID: "req-sandbox-4"}, nil, flags.AppFlags{})
+
+	require.NoError(t, err)
+	assert.Equal(t, "1\n65534\n", result.Stdout, "the command is PID 1 of its namespace and keeps no supplementary groups")
+}
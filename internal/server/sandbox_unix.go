@@ -0,0 +1,36 @@
+//go:build !windows
+// +build !windows
+
+package server
+
+import (
+	"os/exec"
+	"syscall"
+
+	"github.com/soulteary/webhook/internal/hook"
+)
+
+// setCredential 以 sandbox 中配置的 uid/gid 运行命令，未配置的一项沿用当前进程的值；
+// webhook 的附加用户组会被清空，避免命令保留 root 等用户组的权限
+func setCredential(cmd *exec.Cmd, sb *hook.SandboxConfig) {
+	if sb.UID == nil && sb.GID == nil {
+		return
+	}
+
+	cred := &syscall.Credential{
+		Uid:    uint32(syscall.Getuid()),
+		Gid:    uint32(syscall.Getgid()),
+		Groups: []uint32{},
+	}
+	if sb.UID != nil {
+		cred.Uid = uint32(*sb.UID) // #nosec G115 -- Validate 保证非负
+	}
+	if sb.GID != nil {
+		cred.Gid = uint32(*sb.GID) // #nosec G115 -- Validate 保证非负
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = cred
+}
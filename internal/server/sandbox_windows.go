@@ -0,0 +1,32 @@
+//go:build windows
+// +build windows
+
+package server
+
+import (
+	"errors"
+	"os/exec"
+
+	"github.com/soulteary/webhook/internal/hook"
+)
+
+// applySandbox Windows 下仅支持环境变量过滤与输出限制
+func applySandbox(cmd *exec.Cmd, sb *hook.SandboxConfig) error {
+	if sb == nil {
+		return nil
+	}
+	if sb.UID != nil || sb.GID != nil {
+		return errors.New("sandbox uid/gid are not supported on Windows")
+	}
+	if len(sb.Namespaces) > 0 {
+		return errors.New("sandbox namespaces are only supported on Linux")
+	}
+	if sb.HasResourceLimits() {
+		return errors.New("sandbox resource limits are only supported on Linux")
+	}
+	return nil
+}
+
+func execSandboxed(spec *sandboxSpec, path string, argv []string) error {
+	return errors.New("sandbox resource limits and namespaces are only supported on Linux")
+}
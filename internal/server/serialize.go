@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/soulteary/webhook/internal/hook"
+	"github.com/soulteary/webhook/internal/logger"
+)
+
+// keyedLocks 为相同 key 的执行提供互斥锁，用于 serialize-by
+type keyedLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+func newKeyedLocks() *keyedLocks {
+	return &keyedLocks{locks: make(map[string]*keyedLock)}
+}
+
+// lock 获取 key 对应的锁，返回释放函数；等待期间 ctx 结束时返回 ctx 的错误
+func (k *keyedLocks) lock(ctx context.Context, key string) (func(), error) {
+	k.mu.Lock()
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyedLock{ch: make(chan struct{}, 1)}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	select {
+	case l.ch <- struct{}{}:
+		return func() {
+			<-l.ch
+			k.release(key, l)
+		}, nil
+	case <-ctx.Done():
+		k.release(key, l)
+		return nil, ctx.Err()
+	}
+}
+
+func (k *keyedLocks) release(key string, l *keyedLock) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l.refs--
+	if l.refs == 0 && k.locks[key] == l {
+		delete(k.locks, key)
+	}
+}
+
+// debouncer 将窗口期内相同 key 的请求合并为一次执行，执行时使用最后一次请求的数据
+type debouncer struct {
+	mu      sync.Mutex
+	pending map[string]*pendingRun
+}
+
+type pendingRun struct {
+	ctx    context.Context
+	r      *hook.Request
+	timer  *time.Timer
+	joined int
+
+	done   chan struct{}
+	result *ExecutionResult
+	err    error
+}
+
+func newDebouncer() *debouncer {
+	return &debouncer{pending: make(map[string]*pendingRun)}
+}
+
+// join 加入 key 对应的等待中的执行（不存在时创建），每次加入都会重置窗口。
+// 所有加入同一次执行的调用方都会得到该次执行的结果。
+func (d *debouncer) join(ctx context.Context, key string, window time.Duration, r *hook.Request, run func(ctx context.Context, r *hook.Request) (*ExecutionResult, error)) (*ExecutionResult, error) {
+	d.mu.Lock()
+	p, ok := d.pending[key]
+	if ok {
+		p.ctx = ctx
+		p.r = r
+		p.joined++
+		p.timer.Reset(window)
+	} else {
+		p = &pendingRun{ctx: ctx, r: r, joined: 1, done: make(chan struct{})}
+		d.pending[key] = p
+		p.timer = time.AfterFunc(window, func() { d.fire(key, p, run) })
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-p.done:
+		result := *p.result
+		result.Coalesced = p.r != r
+		return &result, p.err
+	case <-ctx.Done():
+		return &ExecutionResult{ExitCode: -1}, ctx.Err()
+	}
+}
+
+func (d *debouncer) fire(key string, p *pendingRun, run func(ctx context.Context, r *hook.Request) (*ExecutionResult, error)) {
+	d.mu.Lock()
+	// 计时器在触发后又被重置时会再次回调，此时该次执行已经开始
+	if d.pending[key] != p {
+		d.mu.Unlock()
+		return
+	}
+	delete(d.pending, key)
+	ctx, r, joined := p.ctx, p.r, p.joined
+	d.mu.Unlock()
+
+	if joined > 1 {
+		logger.Debugf("[%s] coalesced %d requests into a single execution (key: %s)", r.ID, joined, key)
+	}
+
+	// 执行不随某个调用方的离开而取消，各调用方在 join 中各自等待
+	result, err := run(context.WithoutCancel(ctx), r)
+	if result == nil {
+		result = &ExecutionResult{ExitCode: -1}
+	}
+	p.result, p.err = result, err
+	close(p.done)
+}
+
+// serializationKey 返回 hook 执行的串行化 key：hook ID 加上 serialize-by 参数的值
+func serializationKey(h *hook.Hook, r *hook.Request) string {
+	if h.SerializeBy == nil {
+		return h.ID
+	}
+	value, err := h.SerializeBy.Get(r)
+	if err != nil {
+		logger.Debugf("[%s] serialize-by argument for hook %s is not available, using the hook wide key: %v", r.ID, h.ID, err)
+	}
+	return h.ID + "\x00" + value
+}
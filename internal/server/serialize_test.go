@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/soulteary/webhook/internal/hook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyedLocks(t *testing.T) {
+	k := newKeyedLocks()
+
+	unlock, err := k.lock(context.Background(), "a")
+	require.NoError(t, err)
+
+	// 不同的 key 互不影响
+	unlockB, err := k.lock(context.Background(), "b")
+	require.NoError(t, err)
+	unlockB()
+
+	// 相同的 key 需要等待
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = k.lock(ctx, "a")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	unlock()
+	unlock, err = k.lock(context.Background(), "a")
+	require.NoError(t, err)
+	unlock()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	assert.Empty(t, k.locks, "locks should be released once unused")
+}
+
+func TestSerializationKey(t *testing.T) {
+	h := &hook.Hook{ID: "deploy"}
+	r := &hook.Request{ID: "req", Payload: map[string]interface{}{
+		"repository": map[string]interface{}{"full_name": "acme/site"},
+	}}
+	assert.Equal(t, "deploy", serializationKey(h, r))
+
+	h.SerializeBy = &hook.Argument{Source: "payload", Name: "repository.full_name"}
+	assert.Equal(t, "deploy\x00acme/site", serializationKey(h, r))
+
+	// 缺少参数时退化为整个 hook 共用一个 key
+	assert.Equal(t, "deploy\x00", serializationKey(h, &hook.Request{ID: "req"}))
+}
+
+func TestHookExecutor_Execute_SerializeBy(t *testing.T) {
+	var mu sync.Mutex
+	running := make(map[string]int)
+	overlapped := make(map[string]bool)
+
+	executor := NewHookExecutorWithFunc(10, 5*time.Second, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error) {
+		repo := r.Payload["repo"].(string)
+		mu.Lock()
+		running[repo]++
+		if running[repo] > 1 {
+			overlapped[repo] = true
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		running[repo]--
+		mu.Unlock()
+		return "", nil
+	})
+
+	h := &hook.Hook{ID: "deploy", SerializeBy: &hook.Argument{Source: "payload", Name: "repo"}}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		for _, repo := range []string{"a", "b"} {
+			wg.Add(1)
+			go func(repo string) {
+				defer wg.Done()
+				r := &hook.Request{ID: repo, Payload: map[string]interface{}{"repo": repo}}
+				_, err := executor.Execute(context.Background(), h, r, nil, time.Second)
+				assert.NoError(t, err)
+			}(repo)
+		}
+	}
+	wg.Wait()
+
+	assert.Empty(t, overlapped, "executions with the same key must not overlap")
+	// 不同的 key 并行执行：总耗时约为单个 key 的三次执行
+	assert.Less(t, time.Since(start), 6*20*time.Millisecond)
+}
+
+func TestHookExecutor_Execute_Debounce(t *testing.T) {
+	var calls int32
+	var lastID atomic.Value
+	executor := NewHookExecutorWithFunc(10, 5*time.Second, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		lastID.Store(r.ID)
+		return "ran " + r.ID, nil
+	})
+
+	h := &hook.Hook{ID: "deploy", Debounce: hook.Duration(50 * time.Millisecond)}
+
+	outputs := make(chan string, 3)
+	for _, id := range []string{"first", "second", "third"} {
+		go func(id string) {
+			out, err := executor.Execute(context.Background(), h, &hook.Request{ID: id}, nil, time.Second)
+			assert.NoError(t, err)
+			outputs <- out
+		}(id)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "ran third", <-outputs)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, "third", lastID.Load())
+
+	// 窗口结束后的请求会触发新的执行
+	out, err := executor.Execute(context.Background(), h, &hook.Request{ID: "fourth"}, nil, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "ran fourth", out)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestHookExecutor_Execute_DebounceCallerCancelled(t *testing.T) {
+	done := make(chan struct{})
+	executor := NewHookExecutorWithFunc(10, 5*time.Second, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error) {
+		defer close(done)
+		return "", ctx.Err()
+	})
+
+	h := &hook.Hook{ID: "deploy", Debounce: hook.Duration(30 * time.Millisecond)}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := executor.Execute(ctx, h, &hook.Request{ID: "gone"}, nil, time.Second)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// 调用方离开后合并的执行仍然会运行，且不会被取消
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("debounced execution did not run")
+	}
+}
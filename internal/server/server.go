@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -22,6 +23,7 @@ import (
 	"github.com/soulteary/webhook/internal/link"
 	"github.com/soulteary/webhook/internal/logger"
 	"github.com/soulteary/webhook/internal/metrics"
+	"github.com/soulteary/webhook/internal/queue"
 	"github.com/soulteary/webhook/internal/rules"
 	"github.com/soulteary/webhook/internal/security"
 )
@@ -73,6 +75,24 @@ func (fw *flushWriter) Write(p []byte) (n int, err error) {
 	return
 }
 
+// lockedBuffer 是并发安全的 bytes.Buffer，用于同时收集 stdout 和 stderr
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (lb *lockedBuffer) Write(p []byte) (int, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.buf.Write(p)
+}
+
+func (lb *lockedBuffer) Bytes() []byte {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.buf.Bytes()
+}
+
 // statusCodeResponseWriter 用于捕获 HTTP 响应状态码
 type statusCodeResponseWriter struct {
 	http.ResponseWriter
@@ -188,12 +208,46 @@ func parseRequestBody(w http.ResponseWriter, r *http.Request, req *hook.Request,
 				"Error reading request body.", err), requestID, hookID)
 			return err
 		}
+
+		// 解码 Content-Encoding 压缩的请求体，解压后的大小同样受 maxBodySize 限制，防止压缩炸弹
+		if err := req.DecodeBody(r.Header.Get("Content-Encoding"), maxBodySize); err != nil {
+			var tooLargeErr *hook.BodyTooLargeError
+			var encodingErr *hook.ContentEncodingError
+			switch {
+			case errors.As(err, &tooLargeErr):
+				HandleErrorPlain(w, NewHTTPError(ErrorTypeClient, http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("Request body too large: maximum size is %d bytes", maxBodySize), err), requestID, hookID)
+			case errors.As(err, &encodingErr):
+				HandleErrorPlain(w, NewHTTPError(ErrorTypeClient, http.StatusUnsupportedMediaType,
+					fmt.Sprintf("Unsupported content encoding %q.", encodingErr.Encoding), err), requestID, hookID)
+			default:
+				HandleErrorPlain(w, NewHTTPError(ErrorTypeClient, http.StatusBadRequest,
+					"Error decoding request body.", err), requestID, hookID)
+			}
+			return err
+		}
+		req.VerifyCompressedBody = matchedHook.SignatureBody == hook.SignatureBodyCompressed
 	}
 
 	req.ParseHeaders(r.Header)
 	req.ParseQuery(r.URL.Query())
+	// binary 模式的 CloudEvents 通过 ce-* 请求头携带上下文属性
+	req.ParseCloudEventHeaders()
 
 	switch {
+	// 需要在 json 之前匹配，application/cloudevents+json 和 application/x-ndjson 同样包含 json
+	case strings.Contains(req.ContentType, "cloudevents"):
+		err := req.ParseCloudEventPayload()
+		if err != nil {
+			logger.Warnf("[%s] %s", requestID, err)
+		}
+
+	case strings.Contains(req.ContentType, "ndjson"):
+		err := req.ParseNDJSONPayload()
+		if err != nil {
+			logger.Warnf("[%s] %s", requestID, err)
+		}
+
 	case strings.Contains(req.ContentType, "json"):
 		err := req.ParseJSONPayload()
 		if err != nil {
@@ -212,6 +266,18 @@ func parseRequestBody(w http.ResponseWriter, r *http.Request, req *hook.Request,
 			logger.Warnf("[%s] %s", requestID, err)
 		}
 
+	case strings.Contains(req.ContentType, "yaml"):
+		err := req.ParseYAMLPayload()
+		if err != nil {
+			logger.Warnf("[%s] %s", requestID, err)
+		}
+
+	case strings.Contains(req.ContentType, "protobuf"):
+		err := req.ParseProtobufPayload(matchedHook.Protobuf)
+		if err != nil {
+			logger.Warnf("[%s] %s", requestID, err)
+		}
+
 	case isMultipart:
 		return handleMultipartForm(w, r, req, matchedHook, appFlags, requestID, hookID)
 
@@ -348,8 +414,21 @@ func evaluateTriggerRules(w http.ResponseWriter, matchedHook *hook.Hook, req *ho
 
 	// Save signature soft failures option in request for evaluators
 	req.AllowSignatureErrors = matchedHook.TriggerSignatureSoftFailures
+	req.HookID = matchedHook.ID
 
+	// 记录评估轨迹，用于指标统计以及 explain 请求头
+	req.EnableTrace()
 	ok, err := matchedHook.TriggerRule.Evaluate(req)
+	// 整个触发规则通过后才记录 nonce，避免签名校验失败等请求消耗真实投递的 nonce
+	if ok {
+		var commitErr error
+		if ok, commitErr = req.CommitNonces(); commitErr != nil {
+			err = commitErr
+		}
+	}
+	recordSignatureChecks(req, requestID, hookID)
+	recordReplayRejections(req, requestID, hookID)
+	recordTriggerRuleResult(req, matchedHook.ID, ok, err)
 	if err != nil {
 		// ParameterNodeError 是客户端错误，但通常不应该阻止请求继续
 		// 只有在非参数节点错误时才返回错误响应
@@ -370,6 +449,52 @@ func evaluateTriggerRules(w http.ResponseWriter, matchedHook *hook.Hook, req *ho
 	return ok, nil
 }
 
+// recordTriggerRuleResult 记录触发规则评估结果指标，不匹配时按评估轨迹记录导致不匹配的叶子规则
+func recordTriggerRuleResult(req *hook.Request, hookID string, ok bool, err error) {
+	switch {
+	case err != nil && !hook.IsParameterNodeError(err):
+		metrics.TriggerRule.RecordError(hookID)
+	case ok:
+		metrics.TriggerRule.RecordMatched(hookID)
+	default:
+		metrics.TriggerRule.RecordFailedRules(hookID, req.Trace.FailedRules())
+	}
+}
+
+// recordSignatureChecks 为触发规则中的平台签名校验记录审计日志与指标
+func recordSignatureChecks(req *hook.Request, requestID, hookID string) {
+	var ip string
+	if req.RawRequest != nil {
+		ip = req.RawRequest.RemoteAddr
+	}
+
+	for _, check := range req.SignatureChecks {
+		switch {
+		case check.Valid:
+			audit.LogSignatureValid(requestID, hookID, ip, check.Provider)
+			metrics.RecordSignatureVerify("success", check.Provider)
+		case check.Reason == hook.SignatureReasonError:
+			audit.LogSignatureInvalid(requestID, hookID, ip, check.Provider, check.Reason)
+			metrics.RecordSignatureVerify("error", check.Provider)
+		default:
+			audit.LogSignatureInvalid(requestID, hookID, ip, check.Provider, check.Reason)
+			metrics.RecordSignatureVerify("failure", check.Provider)
+		}
+	}
+}
+
+// recordReplayRejections 为被 replay-guard 规则拒绝的请求记录审计日志
+func recordReplayRejections(req *hook.Request, requestID, hookID string) {
+	for _, rejection := range req.ReplayRejections {
+		var ip string
+		if req.RawRequest != nil {
+			ip = req.RawRequest.RemoteAddr
+		}
+		logger.Warnf("[%s] request for hook %s rejected by replay guard: %s", requestID, hookID, rejection.Reason)
+		audit.LogReplayRejected(requestID, hookID, ip, rejection.Reason, rejection.Nonce)
+	}
+}
+
 // executeHookWithResponse 执行 hook 并根据配置处理响应（流式、捕获输出或异步）
 func executeHookWithResponse(w http.ResponseWriter, r *http.Request, matchedHook *hook.Hook, req *hook.Request, executor *HookExecutor, appFlags flags.AppFlags, requestID, hookID string) {
 	// 使用请求的 context，支持取消和超时
@@ -392,7 +517,7 @@ func executeHookWithResponse(w http.ResponseWriter, r *http.Request, matchedHook
 
 	if matchedHook.StreamCommandOutput {
 		executeStreamingHook(w, ctx, matchedHook, req, executor, executionTimeout, requestID, hookID, startTime)
-	} else if matchedHook.CaptureCommandOutput {
+	} else if matchedHook.CaptureCommandOutput || matchedHook.CommandResponse != "" {
 		executeCapturingHook(w, ctx, matchedHook, req, executor, executionTimeout, requestID, hookID, startTime)
 	} else {
 		executeAsyncHook(w, ctx, matchedHook, req, executor, executionTimeout, requestID, hookID, startTime)
@@ -403,7 +528,8 @@ func executeHookWithResponse(w http.ResponseWriter, r *http.Request, matchedHook
 func executeStreamingHook(w http.ResponseWriter, ctx context.Context, matchedHook *hook.Hook, req *hook.Request, executor *HookExecutor, executionTimeout time.Duration, requestID, hookID string, startTime time.Time) {
 	// 使用 trackingResponseWriter 来跟踪是否已经写入响应
 	trw := &trackingResponseWriter{ResponseWriter: w}
-	_, err := executor.Execute(ctx, matchedHook, req, trw, executionTimeout)
+	result, err := executor.ExecuteWithResult(ctx, matchedHook, req, trw, executionTimeout)
+	executor.triggerFollowUps(matchedHook, req, result, err, executionTimeout)
 	duration := time.Since(startTime)
 	durationMS := duration.Milliseconds()
 
@@ -415,6 +541,11 @@ func executeStreamingHook(w http.ResponseWriter, ctx context.Context, matchedHoo
 	}
 
 	if err != nil {
+		if errors.Is(err, ErrHookConcurrencyLimit) {
+			rejectConcurrentHook(w, err, requestID, hookID, ip, userAgent, duration)
+			return
+		}
+
 		// 记录失败的 hook 执行
 		status := "error"
 		if errors.Is(err, context.DeadlineExceeded) {
@@ -469,7 +600,9 @@ func executeStreamingHook(w http.ResponseWriter, ctx context.Context, matchedHoo
 
 // executeCapturingHook 执行捕获输出的 hook
 func executeCapturingHook(w http.ResponseWriter, ctx context.Context, matchedHook *hook.Hook, req *hook.Request, executor *HookExecutor, executionTimeout time.Duration, requestID, hookID string, startTime time.Time) {
-	response, err := executor.Execute(ctx, matchedHook, req, nil, executionTimeout)
+	result, err := executor.ExecuteWithResult(ctx, matchedHook, req, nil, executionTimeout)
+	executor.triggerFollowUps(matchedHook, req, result, err, executionTimeout)
+	response := result.Output
 	duration := time.Since(startTime)
 	durationMS := duration.Milliseconds()
 
@@ -481,6 +614,11 @@ func executeCapturingHook(w http.ResponseWriter, ctx context.Context, matchedHoo
 	}
 
 	if err != nil {
+		if errors.Is(err, ErrHookConcurrencyLimit) {
+			rejectConcurrentHook(w, err, requestID, hookID, ip, userAgent, duration)
+			return
+		}
+
 		// 记录失败的 hook 执行
 		status := "error"
 		if errors.Is(err, context.DeadlineExceeded) {
@@ -497,12 +635,43 @@ func executeCapturingHook(w http.ResponseWriter, ctx context.Context, matchedHoo
 		}
 		metrics.RecordHookExecution(hookID, status, duration)
 
-		// 如果配置了在错误时捕获输出，则返回输出内容
-		if matchedHook.CaptureCommandOutputOnError {
+		// 命令以非零退出码结束时按 exit-code-map 选择状态码，上游返回错误时使用 502，
+		// 两种情况下都允许给出结构化响应
+		httpStatus := http.StatusInternalServerError
+		completed := false
+		var exitErr *exec.ExitError
+		var upstreamErr *ForwardStatusError
+		if errors.As(err, &exitErr) && result.ExitCode >= 0 {
+			completed = true
+			if mapped, ok := matchedHook.StatusForExitCode(result.ExitCode); ok {
+				httpStatus = mapped
+			}
+		} else if errors.As(err, &upstreamErr) {
+			completed = true
+			httpStatus = http.StatusBadGateway
+		}
+		if completed {
+			if matchedHook.CommandResponse != "" {
+				if cr, parseErr := parseCommandResponse(matchedHook, result); parseErr == nil {
+					logger.Errorf("[%s] hook %s execution failed (command: %s): %v, using command response", requestID, hookID, matchedHook.ExecuteCommand, err)
+					writeCommandResponse(w, requestID, hookID, cr, httpStatus)
+					return
+				}
+			}
+		}
+
+		// 如果配置了在错误时捕获输出，则返回输出内容；response-message 为模板时改为返回渲染结果
+		if matchedHook.CaptureCommandOutputOnError && matchedHook.HasResponseMessageTemplate() {
+			logger.Errorf("[%s] hook %s execution failed (command: %s): %v, using response message", requestID, hookID, matchedHook.ExecuteCommand, err)
+			writeTemplatedCaptureResponse(w, matchedHook, req, result, httpStatus, requestID, hookID)
+		} else if matchedHook.CaptureCommandOutputOnError {
 			// 记录错误但不使用 ClassifyError，保持原有的日志格式
 			logger.Errorf("[%s] hook %s execution failed (command: %s): %v, output captured", requestID, hookID, matchedHook.ExecuteCommand, err)
 			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
+			if result.ContentType != "" {
+				w.Header().Set("Content-Type", result.ContentType)
+			}
+			w.WriteHeader(httpStatus)
 			// #nosec G705 -- response is command stdout/stderr, returned as text/plain; not interpreted as HTML
 			_, _ = fmt.Fprint(w, response)
 		} else {
@@ -515,7 +684,7 @@ func executeCapturingHook(w http.ResponseWriter, ctx context.Context, matchedHoo
 				logger.Errorf("[%s] error executing hook %s (command: %s): %v", requestID, hookID, matchedHook.ExecuteCommand, err)
 			}
 			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
+			w.WriteHeader(httpStatus)
 			_, _ = fmt.Fprint(w, "Error occurred while executing the hook's command. Please check your logs for more details.")
 		}
 	} else {
@@ -524,67 +693,82 @@ func executeCapturingHook(w http.ResponseWriter, ctx context.Context, matchedHoo
 		// 记录审计日志：执行成功
 		audit.LogHookExecuted(requestID, hookID, ip, userAgent, durationMS)
 
+		// 命令通过 stdout 或响应文件给出结构化响应
+		if matchedHook.CommandResponse != "" {
+			cr, parseErr := parseCommandResponse(matchedHook, result)
+			if parseErr != nil {
+				logger.Errorf("[%s] hook %s returned an invalid command response (source: %s): %v", requestID, hookID, matchedHook.CommandResponse, parseErr)
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = fmt.Fprint(w, "Error occurred while reading the hook's command response. Please check your logs for more details.")
+				return
+			}
+			writeCommandResponse(w, requestID, hookID, cr, matchedHook.SuccessHttpResponseCode)
+			return
+		}
+
+		// response-message 为模板时返回渲染结果，模板可以访问退出码与命令输出
+		if matchedHook.HasResponseMessageTemplate() {
+			successCode := matchedHook.SuccessHttpResponseCode
+			if successCode == 0 {
+				successCode = result.UpstreamStatus
+			}
+			writeTemplatedCaptureResponse(w, matchedHook, req, result, successCode, requestID, hookID)
+			return
+		}
+
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if result.ContentType != "" {
+			w.Header().Set("Content-Type", result.ContentType)
+		}
+		successCode := matchedHook.SuccessHttpResponseCode
+		// 转发 hook 默认返回上游的状态码与 Content-Type
+		if result.UpstreamStatus != 0 {
+			if ct := result.UpstreamHeader.Get("Content-Type"); ct != "" {
+				w.Header().Set("Content-Type", ct)
+			}
+			if successCode == 0 {
+				successCode = result.UpstreamStatus
+			}
+		}
 		// Check if a success return code is configured for the hook
-		if matchedHook.SuccessHttpResponseCode != 0 {
-			writeHttpResponseCode(w, requestID, matchedHook.ID, matchedHook.SuccessHttpResponseCode)
+		if successCode != 0 {
+			writeHttpResponseCode(w, requestID, matchedHook.ID, successCode)
 		}
-		// #nosec G705 -- response is command stdout/stderr, returned as text/plain; not interpreted as HTML
+		// #nosec G705 -- response is command stdout/stderr or the upstream body of a configured forward; not interpreted as HTML
 		_, _ = fmt.Fprint(w, response)
 	}
 }
 
-// executeAsyncHook 执行异步 hook
+// rejectConcurrentHook 在 hook 达到 max-concurrent 上限且策略为 reject 时返回 429
+func rejectConcurrentHook(w http.ResponseWriter, err error, requestID, hookID, ip, userAgent string, duration time.Duration) {
+	metrics.RecordHookExecution(hookID, "rejected", duration)
+	audit.LogHookFailed(requestID, hookID, ip, userAgent, err.Error(), duration.Milliseconds())
+	HandleErrorPlain(w, err, requestID, hookID)
+}
+
+// executeAsyncHook 执行异步 hook：写入任务队列后立即响应，由任务队列负责执行与重试
 func executeAsyncHook(w http.ResponseWriter, ctx context.Context, matchedHook *hook.Hook, req *hook.Request, executor *HookExecutor, executionTimeout time.Duration, requestID, hookID string, startTime time.Time) {
-	// 获取请求信息用于审计日志（在 goroutine 外获取，避免请求对象被回收）
-	var ip, userAgent string
-	if req.RawRequest != nil {
-		ip = req.RawRequest.RemoteAddr
-		userAgent = req.RawRequest.UserAgent()
+	// 响应头已在调用前渲染；入队前渲染 response-message，渲染失败时不创建任务
+	message, err := matchedHook.RenderResponseMessage(hook.NewResponseData(matchedHook, req))
+	if err != nil {
+		writeResponseTemplateError(w, err, requestID, hookID)
+		return
 	}
 
-	// 异步执行，但仍需要并发控制和超时
-	// 使用 WaitGroup 跟踪 goroutine，防止泄漏
-	asyncHookWaitGroup.Add(1)
-	go func() {
-		defer asyncHookWaitGroup.Done()
-		_, err := executor.Execute(ctx, matchedHook, req, nil, executionTimeout)
-		duration := time.Since(startTime)
-		durationMS := duration.Milliseconds()
-
-		if err != nil {
-			// 记录失败的 hook 执行
-			status := "error"
-			if errors.Is(err, context.DeadlineExceeded) {
-				status = "timeout"
-				logger.Errorf("[%s] async hook %s execution timeout (command: %s, timeout: %v): %v", requestID, hookID, matchedHook.ExecuteCommand, executionTimeout, err)
-				// 记录审计日志：执行超时
-				audit.LogHookTimeout(requestID, hookID, ip, userAgent, durationMS)
-			} else if errors.Is(err, context.Canceled) {
-				status = "cancelled"
-				logger.Warnf("[%s] async hook %s execution cancelled due to request context (command: %s): %v", requestID, hookID, matchedHook.ExecuteCommand, err)
-				// 记录审计日志：执行取消
-				audit.LogHookCancelled(requestID, hookID, ip, userAgent, durationMS)
-			} else {
-				logger.Errorf("[%s] error executing async hook %s (command: %s): %v", requestID, hookID, matchedHook.ExecuteCommand, err)
-				// 记录审计日志：执行失败
-				audit.LogHookFailed(requestID, hookID, ip, userAgent, err.Error(), durationMS)
-			}
-			metrics.RecordHookExecution(hookID, status, duration)
-		} else {
-			// 记录成功的 hook 执行
-			metrics.RecordHookExecution(hookID, "success", duration)
-			// 记录审计日志：执行成功
-			audit.LogHookExecuted(requestID, hookID, ip, userAgent, durationMS)
-		}
-	}()
-
-	// Check if a success return code is configured for the hook
-	if matchedHook.SuccessHttpResponseCode != 0 {
-		writeHttpResponseCode(w, requestID, matchedHook.ID, matchedHook.SuccessHttpResponseCode)
+	job, err := executor.Enqueue(matchedHook, req, executionTimeout)
+	if err != nil {
+		httpErr := NewHTTPError(ErrorTypeServer, http.StatusInternalServerError, "Error occurred while queueing the hook's command. Please check your logs for more details.", err)
+		HandleErrorPlain(w, httpErr, requestID, hookID)
+		return
 	}
+	logger.Debugf("[%s] hook %s queued as job %s", requestID, hookID, job.ID)
+
+	// 返回任务 ID，调用方可以通过 /jobs/{id} 查询执行结果
+	w.Header().Set("X-Job-Id", job.ID)
+	w.Header().Set("Location", JobsPath+"/"+job.ID)
 
-	_, _ = fmt.Fprint(w, matchedHook.ResponseMessage)
+	writeRenderedMessage(w, message, matchedHook.SuccessHttpResponseCode, requestID, hookID)
 }
 
 func createHookHandler(appFlags flags.AppFlags, srv *Server) func(w http.ResponseWriter, r *http.Request) {
@@ -600,11 +784,26 @@ func createHookHandler(appFlags flags.AppFlags, srv *Server) func(w http.Respons
 	}
 
 	// 创建 HookExecutor 实例，管理并发控制
-	// 创建一个包装函数，将 appFlags 传递给 handleHook
-	executorFunc := func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error) {
-		return handleHook(ctx, h, r, w, appFlags)
+	// 创建一个包装函数，将 appFlags 传递给 runHook
+	resultFunc := func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (*ExecutionResult, error) {
+		return runHook(ctx, h, r, w, appFlags)
+	}
+	executor := NewHookExecutorWithResultFunc(maxConcurrent, executionTimeout, resultFunc)
+
+	// 配置了任务队列文件时使用持久化队列，并重放上次未完成的异步任务
+	jobRetention := time.Duration(appFlags.JobRetentionSeconds) * time.Second
+	q, err := queue.Open(appFlags.JobQueuePath, jobRetention)
+	if err != nil {
+		logger.Errorf("error opening job queue %s, falling back to in-memory queue: %v", appFlags.JobQueuePath, err)
+		q, _ = queue.Open("", jobRetention)
+	}
+	executor.UseJobQueue(q, rules.MatchLoadedHook)
+	if resumed := executor.ResumeJobs(executionTimeout); resumed > 0 {
+		logger.Infof("resumed %d queued job(s) from %s", resumed, appFlags.JobQueuePath)
+	}
+	if srv != nil {
+		srv.executor = executor
 	}
-	executor := NewHookExecutorWithFunc(maxConcurrent, executionTimeout, executorFunc)
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		// 记录请求开始时间
@@ -695,6 +894,12 @@ func createHookHandler(appFlags flags.AppFlags, srv *Server) func(w http.Respons
 			return
 		}
 
+		// 校验请求体是否符合 payload-schema
+		if err := matchedHook.ValidatePayload(req); err != nil {
+			HandleError(wrappedWriter, r, err, requestID, hookID)
+			return
+		}
+
 		// 评估触发规则
 		ok, err := evaluateTriggerRules(wrappedWriter, matchedHook, req, requestID, hookID)
 		if err != nil {
@@ -703,18 +908,37 @@ func createHookHandler(appFlags flags.AppFlags, srv *Server) func(w http.Respons
 		}
 
 		if ok {
+			// 缺少 required 参数时拒绝执行，避免命令以空参数运行
+			if err := matchedHook.CheckRequiredArguments(req); err != nil {
+				HandleErrorPlain(wrappedWriter, err, requestID, hookID)
+				return
+			}
+
 			logger.Infof("[%s] %s hook triggered successfully", requestID, matchedHook.ID)
 
 			// 记录审计日志：hook 被触发
 			audit.LogHookTriggered(requestID, matchedHook.ID, r.RemoteAddr, r.UserAgent(), r.Method)
 
-			setResponseHeaders(wrappedWriter, matchedHook.ResponseHeaders)
+			// 响应头模板只能访问请求内容，捕获输出模式下会在命令结束后重新渲染
+			headers, err := matchedHook.RenderResponseHeaders(hook.NewResponseData(matchedHook, req))
+			if err != nil {
+				writeResponseTemplateError(wrappedWriter, err, requestID, hookID)
+				return
+			}
+			setResponseHeaders(wrappedWriter, headers)
 
 			// 执行 hook 并处理响应
 			executeHookWithResponse(wrappedWriter, r, matchedHook, req, executor, appFlags, requestID, hookID)
 			return
 		}
 
+		// 已授权的调用方可以通过请求头获取规则评估轨迹
+		explain := explainAuthorized(r, appFlags)
+		if explain {
+			wrappedWriter.Header().Set(RuleTraceHeader, strings.Join(req.Trace.FailedRules(), ", "))
+			wrappedWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
+		}
+
 		// Check if a return code is configured for the hook
 		if matchedHook.TriggerRuleMismatchHttpResponseCode != 0 {
 			statusCode = matchedHook.TriggerRuleMismatchHttpResponseCode
@@ -723,12 +947,30 @@ func createHookHandler(appFlags flags.AppFlags, srv *Server) func(w http.Respons
 
 		// if none of the hooks got triggered
 		logger.Debugf("[%s] %s got matched, but didn't get triggered because the trigger rules were not satisfied", requestID, matchedHook.ID)
+		if req.MismatchReason != "" {
+			logger.Infof("[%s] %s rejected: %s", requestID, matchedHook.ID, req.MismatchReason)
+		}
 
-		// 记录审计日志：触发规则不满足
-		audit.LogRulesNotSatisfied(requestID, matchedHook.ID, r.RemoteAddr, r.UserAgent())
+		// 记录审计日志：触发规则不满足（带上规则记录的具体原因）
+		audit.LogRulesNotSatisfied(requestID, matchedHook.ID, r.RemoteAddr, r.UserAgent(), req.MismatchReason)
 
-		_, _ = fmt.Fprint(wrappedWriter, "Hook rules were not satisfied.")
+		if explain {
+			writeRuleTrace(wrappedWriter, mismatchMessage(req.MismatchReason), req)
+			return
+		}
+		_, _ = fmt.Fprint(wrappedWriter, mismatchMessage(req.MismatchReason))
+	}
+}
+
+// mismatchMessage 返回触发规则不满足时的响应内容，time-window 规则有独立的提示
+func mismatchMessage(reason string) string {
+	switch reason {
+	case hook.TimeWindowReasonOutside:
+		return "Hook is not allowed to run at this time."
+	case hook.TimeWindowReasonBlackout:
+		return "Hook is not allowed to run during a blackout window."
 	}
+	return "Hook rules were not satisfied."
 }
 
 // createCommandValidator 根据配置创建命令验证器
@@ -870,10 +1112,18 @@ func makeSureCallable(ctx context.Context, h *hook.Hook, r *hook.Request, appFla
 }
 
 func handleHook(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter, appFlags flags.AppFlags) (string, error) {
+	result, err := runHook(ctx, h, r, w, appFlags)
+	return result.Output, err
+}
+
+// runHookCommand 执行 hook 命令并返回结构化的执行结果，返回值始终非 nil
+func runHookCommand(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter, appFlags flags.AppFlags) (*ExecutionResult, error) {
+	result := &ExecutionResult{ExitCode: -1}
+
 	// 检查 context 是否已取消
 	select {
 	case <-ctx.Done():
-		return "", ctx.Err()
+		return result, ctx.Err()
 	default:
 	}
 
@@ -884,13 +1134,26 @@ func handleHook(ctx context.Context, h *hook.Hook, r *hook.Request, w http.Respo
 
 	cmdPath, err := makeSureCallable(ctx, h, r, appFlags, validator)
 	if err != nil {
-		return "", err
+		return result, err
 	}
 
+	// 命令使用独立的可取消 context，输出超限时只终止命令本身
+	cmdCtx, cancelCmd := context.WithCancelCause(ctx)
+	defer cancelCmd(nil)
+
 	// 使用 exec.CommandContext 替代 exec.Command，支持超时和取消
 	// #nosec G204 G702 -- cmdPath 来自 makeSureCallable：经 exec.LookPath 解析，且已通过 validator.ValidateCommandPath 白名单校验
-	cmd := exec.CommandContext(ctx, cmdPath)
+	cmd := exec.CommandContext(cmdCtx, cmdPath)
 	cmd.Dir = h.CommandWorkingDirectory
+	// 超时或取消时向整个进程组发送 kill-signal，宽限期后再发送 SIGKILL
+	terminator := newCommandTerminator(cmd, h)
+
+	// 应用 sandbox 配置（运行用户、命名空间），无法满足时拒绝执行
+	if err := applySandbox(cmd, h.Sandbox); err != nil {
+		logger.Errorf("[%s] SECURITY ERROR: sandbox setup failed for hook %s (command: %s): %v", r.ID, h.ID, h.ExecuteCommand, err)
+		return result, fmt.Errorf("sandbox setup failed for hook %s: %w", h.ID, err)
+	}
+	limiter := newOutputLimiter(h.Sandbox.OutputLimit(), cancelCmd)
 
 	cmd.Args, errs = h.ExtractCommandArguments(r)
 	for _, err := range errs {
@@ -901,7 +1164,7 @@ func handleHook(ctx context.Context, h *hook.Hook, r *hook.Request, w http.Respo
 	if validator != nil {
 		if err := validator.ValidateArgs(cmd.Args); err != nil {
 			logger.Errorf("[%s] SECURITY ERROR: Command arguments validation failed for hook %s (command: %s, args count: %d): %v", r.ID, h.ID, h.ExecuteCommand, len(cmd.Args), err)
-			return "", fmt.Errorf("command arguments validation failed for hook %s: %w", h.ID, err)
+			return result, fmt.Errorf("command arguments validation failed for hook %s: %w", h.ID, err)
 		}
 	}
 
@@ -961,7 +1224,7 @@ func handleHook(ctx context.Context, h *hook.Hook, r *hook.Request, w http.Respo
 		// 检查 context 是否已取消
 		select {
 		case <-ctx.Done():
-			return "", ctx.Err()
+			return result, ctx.Err()
 		default:
 		}
 
@@ -1004,7 +1267,24 @@ func handleHook(ctx context.Context, h *hook.Hook, r *hook.Request, w http.Respo
 		envs = append(envs, fmt.Sprintf("%s=%s", files[i].EnvName, fileName))
 	}
 
-	cmd.Env = append(os.Environ(), envs...)
+	// command-response 为 file 时，通过环境变量告知命令响应文件的路径
+	var responseFile string
+	if h.CommandResponse == hook.CommandResponseFile {
+		responseFile, err = createCommandResponseFile(h)
+		if err != nil {
+			logger.Errorf("[%s] error creating response file for hook %s: %v", r.ID, h.ID, err)
+			return result, fmt.Errorf("error creating response file for hook %s: %w", h.ID, err)
+		}
+		defer func() {
+			if err := safeRemove(responseFile, os.TempDir()); err != nil && !os.IsNotExist(err) {
+				logger.Warnf("[%s] error removing response file for hook %s (file: %s): %v", r.ID, h.ID, responseFile, err)
+			}
+		}()
+		envs = append(envs, hook.CommandResponseFileEnv+"="+responseFile)
+	}
+
+	// 配置了 clean-env 或 env-allowlist 时只继承允许的环境变量
+	cmd.Env = append(h.Sandbox.Environ(os.Environ()), envs...)
 
 	// 使用安全验证器记录命令执行（脱敏处理）
 	if validator != nil {
@@ -1025,22 +1305,54 @@ func handleHook(ctx context.Context, h *hook.Hook, r *hook.Request, w http.Respo
 		if f, ok := w.(http.Flusher); ok {
 			fw.f = f
 		}
-		cmd.Stderr = &fw
-		cmd.Stdout = &fw
-
-		if err := cmd.Run(); err != nil {
+		cmd.Stderr = limiter.wrap(&fw)
+		cmd.Stdout = limiter.wrap(&fw)
+
+		runStart := time.Now()
+		runErr := runCommand(cmd, h.Sandbox)
+		recordCommandTermination(cmdCtx, h, r, terminator.finish(), time.Since(runStart))
+		if cmd.ProcessState != nil {
+			result.ExitCode = cmd.ProcessState.ExitCode()
+		}
+		if err := runErr; err != nil {
 			// 检查是否是超时错误
 			if ctx.Err() == context.DeadlineExceeded {
 				logger.Errorf("[%s] command execution timeout for hook %s (command: %s, path: %s, args: %v): %v", r.ID, h.ID, h.ExecuteCommand, cmdPath, cmd.Args, err)
-				return "", context.DeadlineExceeded
+				return result, context.DeadlineExceeded
 			} else if ctx.Err() == context.Canceled {
 				logger.Warnf("[%s] command execution canceled for hook %s (command: %s, path: %s, args: %v): %v", r.ID, h.ID, h.ExecuteCommand, cmdPath, cmd.Args, err)
-				return "", context.Canceled
+				return result, context.Canceled
 			}
 			logger.Errorf("[%s] error executing command for hook %s (command: %s, path: %s, args: %v, working_dir: %s): %v", r.ID, h.ID, h.ExecuteCommand, cmdPath, cmd.Args, cmd.Dir, err)
 		}
+		if limiter.Exceeded() {
+			logger.Warnf("[%s] output of hook %s truncated at %d bytes", r.ID, h.ID, h.Sandbox.OutputLimit())
+			return result, ErrOutputLimitExceeded
+		}
 	} else {
-		out, err = cmd.CombinedOutput()
+		// 分别收集 stdout 和 stderr，同时保留与 CombinedOutput 一致的合并输出
+		var combined lockedBuffer
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = limiter.wrap(io.MultiWriter(&stdout, &combined))
+		cmd.Stderr = limiter.wrap(io.MultiWriter(&stderr, &combined))
+
+		runStart := time.Now()
+		err = runCommand(cmd, h.Sandbox)
+		recordCommandTermination(cmdCtx, h, r, terminator.finish(), time.Since(runStart))
+		out = combined.Bytes()
+		result.Output = string(out)
+		result.Stdout = stdout.String()
+		result.Stderr = stderr.String()
+		if cmd.ProcessState != nil {
+			result.ExitCode = cmd.ProcessState.ExitCode()
+		}
+		if responseFile != "" {
+			if data, readErr := readCommandResponseFile(responseFile); readErr != nil {
+				logger.Warnf("[%s] error reading response file for hook %s: %v", r.ID, h.ID, readErr)
+			} else {
+				result.Response = data
+			}
+		}
 
 		logger.Debugf("[%s] command output: %s", r.ID, out)
 
@@ -1048,18 +1360,40 @@ func handleHook(ctx context.Context, h *hook.Hook, r *hook.Request, w http.Respo
 			// 检查是否是超时错误
 			if ctx.Err() == context.DeadlineExceeded {
 				logger.Errorf("[%s] command execution timeout for hook %s (command: %s, path: %s, args: %v): %v", r.ID, h.ID, h.ExecuteCommand, cmdPath, cmd.Args, err)
-				return string(out), context.DeadlineExceeded
+				return result, context.DeadlineExceeded
 			} else if ctx.Err() == context.Canceled {
 				logger.Warnf("[%s] command execution canceled for hook %s (command: %s, path: %s, args: %v): %v", r.ID, h.ID, h.ExecuteCommand, cmdPath, cmd.Args, err)
-				return string(out), context.Canceled
+				return result, context.Canceled
 			}
 			logger.Errorf("[%s] error executing command for hook %s (command: %s, path: %s, args: %v, working_dir: %s, exit_code: %v): %v", r.ID, h.ID, h.ExecuteCommand, cmdPath, cmd.Args, cmd.Dir, err, err)
 		}
+		if limiter.Exceeded() {
+			logger.Warnf("[%s] output of hook %s truncated at %d bytes", r.ID, h.ID, h.Sandbox.OutputLimit())
+			return result, ErrOutputLimitExceeded
+		}
 	}
 
 	logger.Infof("[%s] finished handling %s", r.ID, h.ID)
 
-	return string(out), err
+	return result, err
+}
+
+// recordCommandTermination 在命令被终止时记录终止原因与最后发送的信号
+func recordCommandTermination(ctx context.Context, h *hook.Hook, r *hook.Request, signal string, duration time.Duration) {
+	if signal == "" {
+		return
+	}
+
+	reason := killReason(ctx)
+	var ip, userAgent string
+	if r.RawRequest != nil {
+		ip = r.RawRequest.RemoteAddr
+		userAgent = r.RawRequest.UserAgent()
+	}
+
+	logger.Warnf("[%s] command for hook %s terminated with %s (reason: %s)", r.ID, h.ID, signal, reason)
+	metrics.RecordHookKill(h.ID, reason, signal)
+	audit.LogHookKilled(r.ID, h.ID, ip, userAgent, reason, signal, duration.Milliseconds())
 }
 
 func writeHttpResponseCode(w http.ResponseWriter, rid, hookId string, responseCode int) {
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/soulteary/webhook/internal/hook"
+)
+
+// commandTerminator 在 context 结束时按 hook 配置终止命令：
+// 先向命令所在的进程组发送 kill-signal，超过 kill-grace-period 仍未退出时发送 SIGKILL
+type commandTerminator struct {
+	cmd    *exec.Cmd
+	signal string
+	grace  time.Duration
+	exited chan struct{}
+
+	mu   sync.Mutex
+	sent string
+}
+
+// newCommandTerminator 配置 cmd 在独立的进程组中运行，并接管 context 结束时的终止逻辑
+func newCommandTerminator(cmd *exec.Cmd, h *hook.Hook) *commandTerminator {
+	t := &commandTerminator{
+		cmd:    cmd,
+		signal: h.TerminationSignal(),
+		grace:  h.TerminationGracePeriod(),
+		exited: make(chan struct{}),
+	}
+	setProcessGroup(cmd)
+	cmd.Cancel = t.terminate
+	return t
+}
+
+// terminate 由 exec 包在 context 结束时调用
+func (t *commandTerminator) terminate() error {
+	t.record(t.signal)
+	err := signalProcessGroup(t.cmd.Process, t.signal)
+	if t.signal == "SIGKILL" {
+		return err
+	}
+
+	go func() {
+		timer := time.NewTimer(t.grace)
+		defer timer.Stop()
+		select {
+		case <-t.exited:
+		case <-timer.C:
+			t.record("SIGKILL")
+			_ = signalProcessGroup(t.cmd.Process, "SIGKILL")
+		}
+	}()
+	return err
+}
+
+func (t *commandTerminator) record(signal string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sent = signal
+}
+
+// finish 在命令结束后调用，返回最后发送的信号（未终止时为空）
+func (t *commandTerminator) finish() string {
+	close(t.exited)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sent
+}
+
+// killReason 返回命令被终止的原因
+func killReason(ctx context.Context) string {
+	cause := context.Cause(ctx)
+	switch {
+	case errors.Is(cause, ErrHookSuperseded):
+		return "superseded"
+	case errors.Is(cause, ErrExecutorShutdown):
+		return "shutdown"
+	case errors.Is(cause, ErrOutputLimitExceeded):
+		return "output_limit"
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "cancelled"
+	}
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/soulteary/webhook/internal/hook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKillReason(t *testing.T) {
+	timedOut, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-timedOut.Done()
+
+	cancelled, cancel2 := context.WithCancel(context.Background())
+	cancel2()
+
+	superseded, cancel3 := context.WithCancelCause(context.Background())
+	cancel3(ErrHookSuperseded)
+
+	shutdown, cancel4 := context.WithCancelCause(context.Background())
+	cancel4(ErrExecutorShutdown)
+	// 子 context 继承父 context 的取消原因
+	child, cancel5 := context.WithTimeout(shutdown, time.Hour)
+	defer cancel5()
+
+	outputLimit, cancel6 := context.WithCancelCause(context.Background())
+	cancel6(ErrOutputLimitExceeded)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"timeout", timedOut, "timeout"},
+		{"cancelled", cancelled, "cancelled"},
+		{"superseded", superseded, "superseded"},
+		{"shutdown", shutdown, "shutdown"},
+		{"shutdown via parent", child, "shutdown"},
+		{"output limit", outputLimit, "output_limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, killReason(tt.ctx))
+		})
+	}
+}
+
+func TestCommandTerminator_SignalsProcessGroup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping on Windows")
+	}
+
+	dir := t.TempDir()
+	cleanup := filepath.Join(dir, "cleanup")
+	child := filepath.Join(dir, "child")
+	// 脚本在收到 SIGTERM 时清理；后台子进程在被终止前不会写入文件
+	script := "trap 'touch " + cleanup + "; exit 1' TERM\n" +
+		"(sleep 1; touch " + child + ") &\n" +
+		"while true; do sleep 0.05; done\n"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", script)
+	terminator := newCommandTerminator(cmd, &hook.Hook{ID: "graceful"})
+
+	err := cmd.Run()
+	signal := terminator.finish()
+
+	require.Error(t, err)
+	assert.Equal(t, "SIGTERM", signal)
+	assert.FileExists(t, cleanup, "the script should get a chance to clean up")
+
+	// 后台子进程与脚本在同一进程组中，也应被终止
+	time.Sleep(1500 * time.Millisecond)
+	_, statErr := os.Stat(child)
+	assert.True(t, errors.Is(statErr, os.ErrNotExist), "grandchild process should have been terminated")
+}
+
+func TestCommandTerminator_EscalatesToSIGKILL(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping on Windows")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// 忽略 SIGTERM 的命令在宽限期后被 SIGKILL 终止
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", "trap '' TERM; while true; do sleep 0.05; done")
+	terminator := newCommandTerminator(cmd, &hook.Hook{ID: "stubborn", KillGracePeriod: hook.Duration(200 * time.Millisecond)})
+
+	start := time.Now()
+	err := cmd.Run()
+	signal := terminator.finish()
+
+	require.Error(t, err)
+	assert.Equal(t, "SIGKILL", signal)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Equal(t, -1, cmd.ProcessState.ExitCode())
+}
+
+func TestCommandTerminator_NotTerminated(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping on Windows")
+	}
+
+	cmd := exec.CommandContext(context.Background(), "/bin/sh", "-c", "exit 0")
+	terminator := newCommandTerminator(cmd, &hook.Hook{ID: "quick"})
+
+	require.NoError(t, cmd.Run())
+	assert.Empty(t, terminator.finish())
+}
+
+func TestHookExecutor_Terminate(t *testing.T) {
+	executor := NewHookExecutorWithFunc(1, 5*time.Second, func(ctx context.Context, h *hook.Hook, r *hook.Request, w http.ResponseWriter) (string, error) {
+		<-ctx.Done()
+		assert.Equal(t, "shutdown", killReason(ctx))
+		return "", ctx.Err()
+	})
+	assert.Equal(t, time.Duration(0), executor.TerminationTimeout())
+
+	h := &hook.Hook{ID: "long", KillGracePeriod: hook.Duration(2 * time.Second)}
+	done := make(chan error, 1)
+	go func() {
+		_, err := executor.Execute(context.Background(), h, &hook.Request{ID: "req"}, nil, time.Second)
+		done <- err
+	}()
+
+	require.Eventually(t, func() bool { return executor.TerminationTimeout() > 0 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 2*time.Second+terminationSlack, executor.TerminationTimeout())
+
+	executor.Terminate()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
@@ -0,0 +1,42 @@
+//go:build !windows
+// +build !windows
+
+package server
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+var signalsByName = map[string]syscall.Signal{
+	"SIGTERM": syscall.SIGTERM,
+	"SIGINT":  syscall.SIGINT,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+	"SIGKILL": syscall.SIGKILL,
+}
+
+// setProcessGroup 让命令在独立的进程组中运行，以便连同其子进程一起终止
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalProcessGroup 向进程所在的整个进程组发送信号
+func signalProcessGroup(p *os.Process, name string) error {
+	sig, ok := signalsByName[name]
+	if !ok {
+		sig = syscall.SIGTERM
+	}
+	err := syscall.Kill(-p.Pid, sig)
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+	return err
+}
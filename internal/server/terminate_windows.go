@@ -0,0 +1,17 @@
+//go:build windows
+// +build windows
+
+package server
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup Windows 下没有进程组信号，保持默认行为
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalProcessGroup Windows 下无法发送 POSIX 信号，直接结束进程
+func signalProcessGroup(p *os.Process, name string) error {
+	return p.Kill()
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/soulteary/webhook/internal/flags"
+	"github.com/soulteary/webhook/internal/logger"
+)
+
+// tlsReloadInterval 限制检查证书文件变化的频率
+const tlsReloadInterval = time.Second
+
+// NewTLSListener 在明文监听器外包装 TLS；设置 client-ca 时校验客户端证书（mTLS）。
+// 证书、私钥与客户端 CA 文件变化后会在新连接握手时自动重新加载。
+func NewTLSListener(ln net.Listener, appFlags flags.AppFlags) (net.Listener, error) {
+	reloader, err := newCertReloader(appFlags.TLSCertFile, appFlags.TLSKeyFile, appFlags.TLSClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	return tls.NewListener(ln, reloader.tlsConfig(appFlags.TLSClientAuth)), nil
+}
+
+// Scheme 返回服务地址使用的协议
+func Scheme(appFlags flags.AppFlags) string {
+	if appFlags.TLSCertFile != "" {
+		return "https"
+	}
+	return "http"
+}
+
+// certReloader 缓存证书与客户端 CA，并在文件变化时重新加载
+type certReloader struct {
+	certFile, keyFile, caFile string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	versions  [3]fileVersion
+	checked   time.Time
+}
+
+// fileVersion 通过修改时间与大小判断文件是否变化
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+func newCertReloader(certFile, keyFile, caFile string) (*certReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("cert and key must be set together")
+	}
+	r := &certReloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	versions, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(versions); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// stat 读取证书、私钥与客户端 CA 文件的当前版本
+func (r *certReloader) stat() ([3]fileVersion, error) {
+	var versions [3]fileVersion
+	for i, path := range []string{r.certFile, r.keyFile, r.caFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return versions, err
+		}
+		versions[i] = fileVersion{modTime: info.ModTime(), size: info.Size()}
+	}
+	return versions, nil
+}
+
+func (r *certReloader) load(versions [3]fileVersion) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("error loading certificate %s: %w", r.certFile, err)
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("error reading client CA %s: %w", r.caFile, err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in client CA %s", r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.clientCAs, r.versions = &cert, pool, versions
+	r.mu.Unlock()
+	return nil
+}
+
+// current 返回当前证书与客户端 CA，必要时重新加载；
+// 重新加载失败时继续使用之前的证书，避免证书轮换过程中的中间状态导致服务中断
+func (r *certReloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	due := time.Since(r.checked) >= tlsReloadInterval
+	if due {
+		r.checked = time.Now()
+	}
+	r.mu.Unlock()
+
+	if due {
+		if versions, err := r.stat(); err != nil {
+			logger.Errorf("error checking TLS certificate files: %v", err)
+		} else if r.changed(versions) {
+			if err := r.load(versions); err != nil {
+				logger.Errorf("error reloading TLS certificate, keeping the previous one: %v", err)
+			} else {
+				logger.Infof("reloaded TLS certificate %s", r.certFile)
+			}
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, r.clientCAs
+}
+
+func (r *certReloader) changed(versions [3]fileVersion) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i := range versions {
+		if !versions[i].modTime.Equal(r.versions[i].modTime) || versions[i].size != r.versions[i].size {
+			return true
+		}
+	}
+	return false
+}
+
+// tlsConfig 构造监听器使用的 TLS 配置，每次握手时读取最新的证书与客户端 CA
+func (r *certReloader) tlsConfig(clientAuth string) *tls.Config {
+	base := &tls.Config{MinVersion: tls.VersionTLS12}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cert, clientCAs := r.current()
+		cfg := &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{*cert},
+		}
+		if clientCAs != nil {
+			cfg.ClientCAs = clientCAs
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			if clientAuth == flags.TLS_CLIENT_AUTH_VERIFY_IF_GIVEN {
+				cfg.ClientAuth = tls.VerifyClientCertIfGiven
+			}
+		}
+		return cfg, nil
+	}
+	return base
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/soulteary/webhook/internal/flags"
+	"github.com/soulteary/webhook/internal/hook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// issueTestCert 签发测试证书；parent 为 nil 时生成自签名 CA
+func issueTestCert(t *testing.T, cn string, parent *testCert, usage x509.ExtKeyUsage) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	signer := &testCert{cert: template, key: key}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		signer = parent
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer.cert, &key.PublicKey, signer.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCert{cert: cert, key: key}
+}
+
+func (c *testCert) writeFiles(t *testing.T, certPath, keyPath string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0o600))
+	if keyPath != "" {
+		der, err := x509.MarshalECPrivateKey(c.key)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600))
+	}
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+// serveTLS 使用 NewTLSListener 启动返回客户端证书 CN 的 HTTP 服务
+func serveTLS(t *testing.T, appFlags flags.AppFlags) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	tlsLn, err := NewTLSListener(ln, appFlags)
+	require.NoError(t, err)
+
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cn, _ := (&hook.Argument{Source: hook.SourceRequest, Name: "client-cert-cn"}).Get(&hook.Request{RawRequest: r})
+			_, _ = io.WriteString(w, cn)
+		}),
+		ReadHeaderTimeout: time.Second,
+	}
+	go func() { _ = srv.Serve(tlsLn) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return "https://" + ln.Addr().String()
+}
+
+func TestNewTLSListener_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := issueTestCert(t, "test-ca", nil, 0)
+	serverCert := issueTestCert(t, "webhook", ca, x509.ExtKeyUsageServerAuth)
+	clientCert := issueTestCert(t, "ci-runner", ca, x509.ExtKeyUsageClientAuth)
+	otherCA := issueTestCert(t, "other-ca", nil, 0)
+	untrustedClient := issueTestCert(t, "intruder", otherCA, x509.ExtKeyUsageClientAuth)
+
+	appFlags := flags.AppFlags{
+		TLSCertFile:     filepath.Join(dir, "server.pem"),
+		TLSKeyFile:      filepath.Join(dir, "server-key.pem"),
+		TLSClientCAFile: filepath.Join(dir, "ca.pem"),
+		TLSClientAuth:   flags.TLS_CLIENT_AUTH_REQUIRE,
+	}
+	serverCert.writeFiles(t, appFlags.TLSCertFile, appFlags.TLSKeyFile)
+	ca.writeFiles(t, appFlags.TLSClientCAFile, "")
+	url := serveTLS(t, appFlags)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+	}
+
+	resp, err := client(clientCert.tlsCertificate()).Get(url)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, "ci-runner", string(body))
+
+	_, err = client().Get(url)
+	assert.Error(t, err, "a client certificate is required")
+
+	_, err = client(untrustedClient.tlsCertificate()).Get(url)
+	assert.Error(t, err, "client certificates must be signed by the client CA")
+}
+
+func TestNewTLSListener_VerifyIfGiven(t *testing.T) {
+	dir := t.TempDir()
+	ca := issueTestCert(t, "test-ca", nil, 0)
+	serverCert := issueTestCert(t, "webhook", ca, x509.ExtKeyUsageServerAuth)
+
+	appFlags := flags.AppFlags{
+		TLSCertFile:     filepath.Join(dir, "server.pem"),
+		TLSKeyFile:      filepath.Join(dir, "server-key.pem"),
+		TLSClientCAFile: filepath.Join(dir, "ca.pem"),
+		TLSClientAuth:   flags.TLS_CLIENT_AUTH_VERIFY_IF_GIVEN,
+	}
+	serverCert.writeFiles(t, appFlags.TLSCertFile, appFlags.TLSKeyFile)
+	ca.writeFiles(t, appFlags.TLSClientCAFile, "")
+	url := serveTLS(t, appFlags)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Empty(t, string(body))
+}
+
+func TestNewTLSListener_ReloadsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := issueTestCert(t, "test-ca", nil, 0)
+	first := issueTestCert(t, "webhook-1", ca, x509.ExtKeyUsageServerAuth)
+	second := issueTestCert(t, "webhook-2", ca, x509.ExtKeyUsageServerAuth)
+
+	appFlags := flags.AppFlags{
+		TLSCertFile: filepath.Join(dir, "server.pem"),
+		TLSKeyFile:  filepath.Join(dir, "server-key.pem"),
+	}
+	first.writeFiles(t, appFlags.TLSCertFile, appFlags.TLSKeyFile)
+	url := serveTLS(t, appFlags)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	servedCN := func() string {
+		// 每次请求使用新的连接，以便观察握手时的证书
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}, DisableKeepAlives: true}}
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	assert.Equal(t, "webhook-1", servedCN())
+
+	// 写入无效证书时继续使用之前的证书
+	require.NoError(t, os.WriteFile(appFlags.TLSCertFile, []byte("not a certificate"), 0o600))
+	time.Sleep(tlsReloadInterval + 100*time.Millisecond)
+	assert.Equal(t, "webhook-1", servedCN())
+
+	second.writeFiles(t, appFlags.TLSCertFile, appFlags.TLSKeyFile)
+	time.Sleep(tlsReloadInterval + 100*time.Millisecond)
+	assert.Equal(t, "webhook-2", servedCN())
+}
+
+func TestNewTLSListener_InvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+
+	_, err = NewTLSListener(ln, flags.AppFlags{TLSCertFile: filepath.Join(dir, "missing.pem"), TLSKeyFile: filepath.Join(dir, "missing-key.pem")})
+	assert.Error(t, err)
+
+	ca := issueTestCert(t, "test-ca", nil, 0)
+	serverCert := issueTestCert(t, "webhook", ca, x509.ExtKeyUsageServerAuth)
+	appFlags := flags.AppFlags{
+		TLSCertFile:     filepath.Join(dir, "server.pem"),
+		TLSKeyFile:      filepath.Join(dir, "server-key.pem"),
+		TLSClientCAFile: filepath.Join(dir, "ca.pem"),
+	}
+	serverCert.writeFiles(t, appFlags.TLSCertFile, appFlags.TLSKeyFile)
+	require.NoError(t, os.WriteFile(appFlags.TLSClientCAFile, []byte("no certificates"), 0o600))
+	_, err = NewTLSListener(ln, appFlags)
+	assert.Error(t, err)
+}
+
+func TestLaunch_TLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := issueTestCert(t, "test-ca", nil, 0)
+	serverCert := issueTestCert(t, "webhook", ca, x509.ExtKeyUsageServerAuth)
+
+	appFlags := flags.AppFlags{
+		HooksURLPrefix:  "/hooks",
+		ResponseHeaders: hook.ResponseHeaders{},
+		TLSCertFile:     filepath.Join(dir, "server.pem"),
+		TLSKeyFile:      filepath.Join(dir, "server-key.pem"),
+	}
+	serverCert.writeFiles(t, appFlags.TLSCertFile, appFlags.TLSKeyFile)
+	assert.Equal(t, "https", Scheme(appFlags))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	tlsLn, err := NewTLSListener(ln, appFlags)
+	require.NoError(t, err)
+
+	server := Launch(appFlags, ln.Addr().String(), tlsLn)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	resp, err := client.Get("https://" + ln.Addr().String() + "/")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, "OK", string(body))
+}
@@ -19,6 +19,7 @@ import (
 	versionkit "github.com/soulteary/version-kit"
 	"github.com/soulteary/webhook/internal/configui"
 	"github.com/soulteary/webhook/internal/flags"
+	"github.com/soulteary/webhook/internal/hook"
 	"github.com/soulteary/webhook/internal/link"
 	"github.com/soulteary/webhook/internal/logger"
 	"github.com/soulteary/webhook/internal/metrics"
@@ -31,6 +32,7 @@ import (
 type Server struct {
 	app      *fiber.App
 	listener net.Listener
+	executor *HookExecutor
 	mu       sync.Mutex
 	shutdown bool
 }
@@ -96,6 +98,7 @@ func Launch(appFlags flags.AppFlags, addr string, ln net.Listener) *Server {
 	app.Use(recover.New())
 
 	// 限流中间件（适配 Std 中间件）
+	var rateLimiter *middleware.RateLimiter
 	if appFlags.RateLimitEnabled {
 		rateLimitConfig := middleware.RateLimitConfig{
 			Enabled:        appFlags.RateLimitEnabled,
@@ -108,7 +111,8 @@ func Launch(appFlags flags.AppFlags, addr string, ln net.Listener) *Server {
 			RedisKeyPrefix: appFlags.RedisKeyPrefix,
 			WindowSeconds:  appFlags.RateLimitWindowSec,
 		}
-		app.Use(adaptor.HTTPMiddleware(middleware.NewRateLimitMiddleware(rateLimitConfig)))
+		rateLimiter = middleware.NewRateLimiter(rateLimitConfig)
+		app.Use(adaptor.HTTPMiddleware(rateLimiter.Middleware))
 		if appFlags.RedisEnabled {
 			logger.Infof("rate limiting enabled with Redis: %d RPS, burst: %d, window: %ds, Redis: %s",
 				appFlags.RateLimitRPS, appFlags.RateLimitBurst, appFlags.RateLimitWindowSec, appFlags.RedisAddr)
@@ -117,6 +121,23 @@ func Launch(appFlags flags.AppFlags, addr string, ln net.Listener) *Server {
 		}
 	}
 
+	// 配置了 Redis 时 replay-guard 规则的 nonce 保存在 Redis 中，多实例之间共享；
+	// 限流使用 Redis 时复用其连接。否则保存在内存中，最多 -nonce-store-size 个
+	var nonceStore hook.NonceStore = hook.NewMemoryNonceStore(appFlags.NonceStoreSize)
+	if appFlags.RedisEnabled {
+		client := rateLimiter.RedisClient()
+		if client == nil {
+			var err error
+			if client, err = middleware.NewRedisClient(appFlags.RedisAddr, appFlags.RedisPassword, appFlags.RedisDB); err != nil {
+				logger.Warnf("failed to initialize Redis nonce store, falling back to in-memory: %v", err)
+			}
+		}
+		if client != nil {
+			nonceStore = middleware.NewRedisNonceStore(client, middleware.NonceKeyPrefix(appFlags.RedisKeyPrefix))
+		}
+	}
+	hook.SetNonceStore(nonceStore)
+
 	if appFlags.Debug {
 		dumperConfig := middleware.DumperConfig{
 			IncludeRequestBody: appFlags.LogRequestBody,
@@ -219,7 +240,7 @@ func Launch(appFlags flags.AppFlags, addr string, ln net.Listener) *Server {
 		if hookBaseForReserved == "" {
 			hookBaseForReserved = "/hooks"
 		}
-		reservedPaths := []string{"/", "/health", "/livez", "/readyz", "/version", "/metrics", hookBaseForReserved}
+		reservedPaths := []string{"/", "/health", "/livez", "/readyz", "/version", "/metrics", JobsPath, ExplainPath, hookBaseForReserved}
 		isReserved := false
 		for _, p := range reservedPaths {
 			if openapiPath == p || (p != "/" && strings.HasPrefix(openapiPath, p+"/")) {
@@ -230,12 +251,16 @@ func Launch(appFlags flags.AppFlags, addr string, ln net.Listener) *Server {
 		if isReserved {
 			logger.Warnf("openapi-path %q conflicts with reserved path; skipping OpenAPI route", openapiPath)
 		} else {
-			specJSON, err := openapi.Spec(appFlags, "http://"+addr)
-			if err != nil {
+			serverURL := Scheme(appFlags) + "://" + addr
+			if _, err := openapi.Spec(appFlags, serverURL); err != nil {
 				logger.Warnf("openapi spec generation failed: %v", err)
 			} else {
-				body := specJSON
 				app.Get(openapiPath, func(c *fiber.Ctx) error {
+					// 每次请求重新生成，热加载后 hook 的 payload-schema 同步更新
+					body, err := openapi.Spec(appFlags, serverURL)
+					if err != nil {
+						return err
+					}
 					c.Set("Content-Type", "application/json; charset=utf-8")
 					return c.Send(body)
 				})
@@ -258,7 +283,7 @@ func Launch(appFlags flags.AppFlags, addr string, ln net.Listener) *Server {
 		if hookBaseForReserved == "" {
 			hookBaseForReserved = "/hooks"
 		}
-		reservedPaths := []string{"/", "/health", "/livez", "/readyz", "/version", "/metrics", hookBaseForReserved}
+		reservedPaths := []string{"/", "/health", "/livez", "/readyz", "/version", "/metrics", JobsPath, ExplainPath, hookBaseForReserved}
 		if openapiPathLogged != "" {
 			reservedPaths = append(reservedPaths, openapiPathLogged)
 		}
@@ -272,7 +297,7 @@ func Launch(appFlags flags.AppFlags, addr string, ln net.Listener) *Server {
 		if isReserved {
 			logger.Warnf("config-ui-path %q conflicts with reserved path; skipping Config UI route", configUIPath)
 		} else {
-			configUIHandler, err := configui.Handler(configUIPath, "http://"+addr, appFlags.HooksDir, hookBaseForReserved)
+			configUIHandler, err := configui.Handler(configUIPath, Scheme(appFlags)+"://"+addr, appFlags.HooksDir, hookBaseForReserved)
 			if err != nil {
 				logger.Warnf("config-ui handler init failed: %v", err)
 			} else {
@@ -298,18 +323,45 @@ func Launch(appFlags flags.AppFlags, addr string, ln net.Listener) *Server {
 	app.All(hookBase+"/:id", adaptor.HTTPHandlerFunc(hookHandler))
 	app.All(hookBase+"/:id/*", adaptor.HTTPHandlerFunc(hookHandler))
 
+	// 异步任务状态接口
+	if hookBase == JobsPath {
+		logger.Warnf("hooks url prefix %q conflicts with the jobs endpoint; skipping jobs routes", hookBase)
+	} else if s.executor != nil {
+		jobsHandler := createJobsHandler(s.executor, JobsPath)
+		app.All(JobsPath+"/:id", adaptor.HTTPHandlerFunc(jobsHandler))
+		app.All(JobsPath+"/:id/:stream", adaptor.HTTPHandlerFunc(jobsHandler))
+	}
+
+	// 触发规则试运行接口，仅在配置了 explain-token 时启用
+	explainEnabled := false
+	if appFlags.ExplainToken != "" {
+		if hookBase == ExplainPath {
+			logger.Warnf("hooks url prefix %q conflicts with the explain endpoint; skipping explain routes", hookBase)
+		} else {
+			explainHandler := createExplainHandler(appFlags, ExplainPath, hookBase)
+			app.All(ExplainPath+"/:id", adaptor.HTTPHandlerFunc(explainHandler))
+			app.All(ExplainPath+"/:id/*", adaptor.HTTPHandlerFunc(explainHandler))
+			explainEnabled = true
+		}
+	}
+
 	metrics.StartSystemMetricsCollector(10 * time.Second)
 
 	go func() {
-		logger.Infof("serving hooks on http://%s%s", addr, link.MakeHumanPattern(&appFlags.HooksURLPrefix))
-		logger.Infof("health check endpoints: http://%s/health, http://%s/livez, http://%s/readyz", addr, addr, addr)
-		logger.Infof("version endpoint: http://%s/version", addr)
-		logger.Infof("metrics endpoint: http://%s/metrics", addr)
+		base := Scheme(appFlags) + "://" + addr
+		logger.Infof("serving hooks on %s%s", base, link.MakeHumanPattern(&appFlags.HooksURLPrefix))
+		logger.Infof("health check endpoints: %s/health, %s/livez, %s/readyz", base, base, base)
+		logger.Infof("version endpoint: %s/version", base)
+		logger.Infof("metrics endpoint: %s/metrics", base)
+		logger.Infof("job status endpoint: %s%s/{id}", base, JobsPath)
+		if explainEnabled {
+			logger.Infof("trigger rule explain endpoint: %s%s/{id}", base, ExplainPath)
+		}
 		if openapiPathLogged != "" {
-			logger.Infof("openapi spec: http://%s%s", addr, openapiPathLogged)
+			logger.Infof("openapi spec: %s%s", base, openapiPathLogged)
 		}
 		if configUIPathLogged != "" {
-			logger.Infof("config UI: http://%s%s", addr, configUIPathLogged)
+			logger.Infof("config UI: %s%s", base, configUIPathLogged)
 		}
 		if err := app.Listener(ln); err != nil {
 			logger.Error(fmt.Sprintf("server error: %v", err))
@@ -319,7 +371,7 @@ func Launch(appFlags flags.AppFlags, addr string, ln net.Listener) *Server {
 	return s
 }
 
-// Shutdown 优雅关闭服务器：先等异步 hook WaitGroup，再关闭 Fiber
+// Shutdown 优雅关闭服务器：先停止重试定时器并等异步 hook WaitGroup，再关闭 Fiber
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.mu.Lock()
 	if s.shutdown {
@@ -329,9 +381,19 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	s.shutdown = true
 	s.mu.Unlock()
 
+	// 停止等待中的重试，这些任务留在队列中，下次启动时重放
+	if s.executor != nil {
+		s.executor.StopJobs()
+	}
+
 	done := make(chan error, 1)
 	go func() {
 		GetAsyncHookWaitGroup().Wait()
+		if s.executor != nil {
+			if err := s.executor.JobQueue().Close(); err != nil {
+				logger.Errorf("error closing job queue: %v", err)
+			}
+		}
 		done <- s.app.Shutdown()
 	}()
 
@@ -345,6 +407,18 @@ func (s *Server) Shutdown(ctx context.Context) error {
 		return err
 	case <-ctx.Done():
 		logger.Warnf("server shutdown timeout: %v", ctx.Err())
+		// 终止仍在执行的命令（异步任务以 queued 状态留在队列中），
+		// 并等待命令在 kill-grace-period 内完成清理
+		if s.executor != nil {
+			s.executor.Terminate()
+			if wait := s.executor.TerminationTimeout(); wait > 0 {
+				select {
+				case <-done:
+				case <-time.After(wait):
+					logger.Warnf("hook commands did not exit within %v after termination", wait)
+				}
+			}
+		}
 		return ctx.Err()
 	}
 }
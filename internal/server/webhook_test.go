@@ -2,7 +2,13 @@ package server
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -12,6 +18,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/adaptor"
@@ -958,6 +965,238 @@ func TestCreateHookHandler_TriggerRuleMismatchHttpResponseCode(t *testing.T) {
 	assert.Contains(t, string(body), "Hook rules were not satisfied")
 }
 
+func TestCreateHookHandler_TimeWindowMismatch(t *testing.T) {
+	// Setup - the whole current year is a blackout window
+	year := time.Now().UTC().Year()
+	testHook := hook.Hook{
+		ID:                                  "test-hook",
+		HTTPMethods:                         []string{},
+		ResponseMessage:                     "success",
+		TriggerRuleMismatchHttpResponseCode: 423,
+		TriggerRule: &hook.Rules{
+			Match: &hook.MatchRule{
+				Type: hook.MatchTimeWindow,
+				TimeWindow: &hook.TimeWindowRule{
+					Blackouts: []hook.BlackoutWindow{{
+						From: fmt.Sprintf("%d-01-01", year),
+						To:   fmt.Sprintf("%d-12-31", year),
+					}},
+				},
+			},
+		},
+	}
+	rules.LoadedHooksFromFiles = map[string]hook.Hooks{
+		"test.json": {testHook},
+	}
+	rules.BuildIndex()
+	appFlags := flags.AppFlags{}
+
+	handler := createHookHandler(appFlags, nil)
+
+	req := httptest.NewRequest("POST", "/hooks/test-hook", bytes.NewBufferString(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	app := testHookApp(handler)
+	resp, err := app.Test(req, 5000)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+
+	assert.Equal(t, 423, resp.StatusCode)
+	assert.Contains(t, string(body), "blackout window")
+}
+
+func TestCreateHookHandler_RequiredArgumentMissing(t *testing.T) {
+	// Setup
+	testHook := hook.Hook{
+		ID:              "test-hook",
+		ExecuteCommand:  "echo",
+		HTTPMethods:     []string{},
+		ResponseMessage: "success",
+		PassArgumentsToCommand: []hook.Argument{
+			{Source: "payload", Name: "ref", Required: true, Transform: []hook.ArgumentTransform{{Type: hook.TransformTrim}}},
+		},
+	}
+	rules.LoadedHooksFromFiles = map[string]hook.Hooks{
+		"test.json": {testHook},
+	}
+	rules.BuildIndex()
+
+	handler := createHookHandler(flags.AppFlags{}, nil)
+
+	req := httptest.NewRequest("POST", "/hooks/test-hook", bytes.NewBufferString(`{"branch": "main"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := testHookApp(handler).Test(req, 5000)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, `Missing required argument "ref".`, string(body))
+}
+
+func TestCreateHookHandler_PayloadSchemaViolation(t *testing.T) {
+	// Setup
+	var schema hook.PayloadSchema
+	require.NoError(t, json.Unmarshal([]byte(`{"type": "object", "required": ["ref"], "properties": {"ref": {"type": "string"}}}`), &schema))
+	testHook := hook.Hook{
+		ID:              "test-hook",
+		ExecuteCommand:  "echo",
+		HTTPMethods:     []string{},
+		ResponseMessage: "success",
+		PayloadSchema:   &schema,
+	}
+	rules.LoadedHooksFromFiles = map[string]hook.Hooks{
+		"test.json": {testHook},
+	}
+	rules.BuildIndex()
+
+	handler := createHookHandler(flags.AppFlags{}, nil)
+
+	req := httptest.NewRequest("POST", "/hooks/test-hook", bytes.NewBufferString(`{"ref": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := testHookApp(handler).Test(req, 5000)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	var body ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "test-hook", body.HookID)
+	assert.Equal(t, []hook.SchemaViolation{{Path: "/ref", Message: "expected string, got number"}}, body.Violations)
+}
+
+func TestCreateHookHandler_CompressedBody(t *testing.T) {
+	gzipBody := func(body []byte) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write(body)
+		_ = zw.Close()
+		return buf.Bytes()
+	}
+	payload := []byte(`{"ref": "main"}`)
+	compressed := gzipBody(payload)
+	sign := func(body []byte) string {
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(body)
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name          string
+		encoding      string
+		body          []byte
+		signature     string
+		signatureBody string
+		maxBodySize   int64
+		wantStatus    int
+	}{
+		{"gzip", "gzip", compressed, sign(payload), "", 0, http.StatusOK},
+		{"signature over compressed body", "gzip", compressed, sign(compressed), hook.SignatureBodyCompressed, 0, http.StatusOK},
+		{"signature over decompressed body", "gzip", compressed, sign(compressed), "", 0, http.StatusInternalServerError},
+		{"decompressed body too large", "gzip", gzipBody(bytes.Repeat([]byte(" "), 4096)), sign(payload), "", 1024, http.StatusRequestEntityTooLarge},
+		{"unsupported encoding", "compress", payload, sign(payload), "", 0, http.StatusUnsupportedMediaType},
+		{"corrupt body", "gzip", payload, sign(payload), "", 0, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testHook := hook.Hook{
+				ID:              "test-hook",
+				HTTPMethods:     []string{},
+				ResponseMessage: "success",
+				SignatureBody:   tt.signatureBody,
+				TriggerRule: &hook.Rules{
+					And: &hook.AndRule{
+						{Match: &hook.MatchRule{Type: hook.MatchValue, Value: "main", Parameter: hook.Argument{Source: hook.SourcePayload, Name: "ref"}}},
+						{Match: &hook.MatchRule{Type: hook.MatchHMACSHA256, Secret: "secret", Parameter: hook.Argument{Source: hook.SourceHeader, Name: "X-Signature"}}},
+					},
+				},
+			}
+			rules.LoadedHooksFromFiles = map[string]hook.Hooks{
+				"test.json": {testHook},
+			}
+			rules.BuildIndex()
+
+			handler := createHookHandler(flags.AppFlags{MaxRequestBodySize: tt.maxBodySize}, nil)
+
+			req := httptest.NewRequest("POST", "/hooks/test-hook", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Content-Encoding", tt.encoding)
+			req.Header.Set("X-Signature", tt.signature)
+
+			resp, err := testHookApp(handler).Test(req, 5000)
+			require.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestCreateHookHandler_PayloadFormats(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		headers     map[string]string
+		body        string
+		parameter   hook.Argument
+	}{
+		{"yaml", "application/yaml", nil, "ref: main\n", hook.Argument{Source: hook.SourcePayload, Name: "ref"}},
+		{"ndjson", "application/x-ndjson", nil, "{\"ref\": \"dev\"}\n{\"ref\": \"main\"}\n", hook.Argument{Source: hook.SourcePayload, Name: "root.1.ref"}},
+		{
+			"cloudevents structured",
+			"application/cloudevents+json",
+			nil,
+			`{"specversion": "1.0", "type": "com.example.push", "source": "/repos/hello", "id": "1", "data": {"ref": "main"}}`,
+			hook.Argument{Source: hook.SourcePayload, Name: "ref"},
+		},
+		{
+			"cloudevents binary",
+			"application/json",
+			map[string]string{"Ce-Specversion": "1.0", "Ce-Type": "com.example.push", "Ce-Subject": "main"},
+			`{}`,
+			hook.Argument{Source: hook.SourceCloudEvent, Name: "subject"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testHook := hook.Hook{
+				ID:                                  "test-hook",
+				HTTPMethods:                         []string{},
+				ResponseMessage:                     "success",
+				TriggerRuleMismatchHttpResponseCode: http.StatusPreconditionFailed,
+				TriggerRule: &hook.Rules{
+					Match: &hook.MatchRule{Type: hook.MatchValue, Value: "main", Parameter: tt.parameter},
+				},
+			}
+			rules.LoadedHooksFromFiles = map[string]hook.Hooks{
+				"test.json": {testHook},
+			}
+			rules.BuildIndex()
+
+			handler := createHookHandler(flags.AppFlags{}, nil)
+
+			req := httptest.NewRequest("POST", "/hooks/test-hook", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			resp, err := testHookApp(handler).Test(req, 5000)
+			require.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+			body, _ := io.ReadAll(resp.Body)
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Contains(t, string(body), "success")
+		})
+	}
+}
+
 func TestCreateHookHandler_SuccessHttpResponseCode(t *testing.T) {
 	// Setup
 	testHook := hook.Hook{
@@ -984,6 +1223,49 @@ func TestCreateHookHandler_SuccessHttpResponseCode(t *testing.T) {
 	assert.Equal(t, 201, resp.StatusCode)
 }
 
+func TestCreateHookHandler_ResponseTemplate(t *testing.T) {
+	testHook := hook.Hook{
+		ID:              "test-hook",
+		HTTPMethods:     []string{},
+		ResponseMessage: `{"text": {{json (printf "%s received %s" .HookID .Payload.ref)}}}`,
+		ResponseHeaders: hook.ResponseHeaders{
+			{Name: "Content-Type", Value: "application/json"},
+			{Name: "X-Event", Value: `{{index .Headers "X-Github-Event"}}`},
+		},
+	}
+	rules.LoadedHooksFromFiles = map[string]hook.Hooks{
+		"test.json": {testHook},
+	}
+	rules.BuildIndex()
+
+	handler := createHookHandler(flags.AppFlags{}, nil)
+	app := testHookApp(handler)
+
+	req := httptest.NewRequest("POST", "/hooks/test-hook", bytes.NewBufferString(`{"ref": "main"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Github-Event", "push")
+
+	resp, err := app.Test(req, 5000)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "push", resp.Header.Get("X-Event"))
+	assert.JSONEq(t, `{"text": "test-hook received main"}`, string(body))
+
+	// 模板引用的字段缺失时返回 500
+	req = httptest.NewRequest("POST", "/hooks/test-hook", bytes.NewBufferString(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err = app.Test(req, 5000)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
+
 func TestHandleHook_FileOperations(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("Skipping on Windows")
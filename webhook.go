@@ -95,6 +95,9 @@ func SetupLogger(appFlags flags.AppFlags, logQueue *[]string) error {
 }
 
 func main() {
+	// webhook re-executes itself to set up sandboxed commands
+	server.RunSandboxInit()
+
 	appFlags := flags.Parse()
 
 	if err := i18n.InitLocaleByFiles(appFlags.I18nDir, WebhookLocales); err != nil {
@@ -206,7 +209,7 @@ func main() {
 	}
 
 	if appFlags.OpenAPIEnabled && appFlags.OpenAPIPrint {
-		spec, err := openapi.Spec(appFlags, "http://"+addr)
+		spec, err := openapi.Spec(appFlags, server.Scheme(appFlags)+"://"+addr)
 		if err != nil {
 			logger.Warnf("openapi spec generation failed: %v", err)
 		} else {
@@ -214,6 +217,15 @@ func main() {
 		}
 	}
 
+	// 设置 -cert 时以 HTTPS 提供服务，设置 -client-ca 时校验客户端证书
+	if appFlags.TLSCertFile != "" {
+		tlsListener, err := server.NewTLSListener(*ln, appFlags)
+		if err != nil {
+			logger.Fatalln(i18n.Sprintf(i18n.ERR_SERVER_TLS_CONFIG, err))
+		}
+		*ln = tlsListener
+	}
+
 	// 启动服务器
 	httpServer = server.Launch(appFlags, addr, *ln)
 